@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	kvclient "github.com/strider2038/key-value-database/pkg/client"
+)
+
+// Коды завершения клиента без интерактивного режима. Коды exitBadRequest
+// и exitServerError возвращаются только с флагом --fail-on-error.
+const (
+	exitOK          = 0
+	exitClientError = 1
+	exitBadRequest  = 2
+	exitServerError = 3
+	exitTimeout     = 4
+)
+
+var errMetaCommand = errors.New("meta commands are supported only in interactive mode")
+
+// batch выполняет команды без интерактивного режима: из аргументов
+// командной строки, файла или перенаправленного ввода. Результаты выводятся
+// без приглашений в формате output.
+type batch struct {
+	client      Client
+	output      resultWriter
+	failOnError bool
+}
+
+// runCommands выполняет команды из аргументов и возвращает код завершения.
+func (b *batch) runCommands(ctx context.Context, commands []string) int {
+	for _, command := range commands {
+		if code, stop := b.execute(ctx, command); stop {
+			return code
+		}
+	}
+
+	return exitOK
+}
+
+// runScript выполняет команды из input по одной на строке. Пустые строки
+// и строки, начинающиеся с #, пропускаются. Значение в кавычках может
+// занимать несколько строк.
+func (b *batch) runScript(ctx context.Context, input io.Reader) int {
+	reader := bufio.NewReader(input)
+	command := ""
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			_ = b.output.Write(command, "", fmt.Errorf("read commands: %w", err))
+
+			return exitClientError
+		}
+		if command == "" && strings.HasPrefix(strings.TrimSpace(line), "#") {
+			line = ""
+		}
+		command += line
+
+		if _, _, splitErr := splitInput(command); !errors.Is(splitErr, errUnterminatedQuote) || errors.Is(err, io.EOF) {
+			if code, stop := b.execute(ctx, strings.TrimSpace(command)); stop {
+				return code
+			}
+			command = ""
+		}
+		if errors.Is(err, io.EOF) {
+			return exitOK
+		}
+	}
+}
+
+// execute выполняет команду. Второе значение - true, если выполнение
+// нужно прекратить с кодом завершения из первого значения.
+func (b *batch) execute(ctx context.Context, input string) (int, bool) {
+	tokens, quoted, err := splitInput(input)
+	if err == nil && len(tokens) == 0 {
+		return exitOK, false
+	}
+	if err == nil && (tokens[0] == "exit" || tokens[0] == `\q`) {
+		return exitOK, true
+	}
+	if err == nil && strings.HasPrefix(tokens[0], `\`) {
+		err = errMetaCommand
+	}
+	var request string
+	var value []byte
+	if err == nil {
+		request, value, err = newRequest(input, tokens, quoted)
+	}
+	if err != nil {
+		return b.fail(input, &kvclient.BadRequestError{Message: err.Error()})
+	}
+	if err := ctx.Err(); err != nil {
+		return b.stop(input, exitTimeout, err)
+	}
+
+	if stream, ok := b.client.(StreamClient); ok && strings.HasPrefix(request, "SUBSCRIBE-CHANGES") {
+		err := stream.Stream([]byte(request), func(message []byte) {
+			_ = b.output.Write(input, string(message), nil)
+		})
+		if err != nil {
+			return b.stop(input, exitClientError, err)
+		}
+
+		return exitOK, true
+	}
+
+	response, err := send(ctx, b.client, request, value)
+	if err != nil {
+		if ctx.Err() != nil || isTimeout(err) {
+			return b.stop(input, exitTimeout, err)
+		}
+
+		return b.stop(input, exitClientError, err)
+	}
+	result, err := kvclient.ParseResponse(string(response))
+	if err != nil {
+		return b.fail(input, err)
+	}
+	if err := b.output.Write(input, result, nil); err != nil {
+		return exitClientError, true
+	}
+
+	return exitOK, false
+}
+
+// fail выводит ошибку выполнения команды. С флагом --fail-on-error
+// выполнение прекращается с кодом, зависящим от типа ошибки.
+func (b *batch) fail(input string, err error) (int, bool) {
+	if err := b.output.Write(input, "", err); err != nil {
+		return exitClientError, true
+	}
+	if !b.failOnError {
+		return exitOK, false
+	}
+
+	var badRequest *kvclient.BadRequestError
+	if errors.As(err, &badRequest) {
+		return exitBadRequest, true
+	}
+
+	return exitServerError, true
+}
+
+// stop выводит ошибку, после которой выполнение команд невозможно.
+func (b *batch) stop(input string, code int, err error) (int, bool) {
+	_ = b.output.Write(input, "", err)
+
+	return code, true
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+
+	return errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+func TestBatch_RunScript(t *testing.T) {
+	client := &testClient{responses: map[string]string{"GET key": "value"}}
+	output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+	b := &batch{client: client, output: newResultWriter("raw", output, errorOutput)}
+	script := strings.Join([]string{
+		"# comment",
+		"",
+		"GET key",
+		`SET key "first line`,
+		`second line"`,
+		"  DEL key  ",
+		"exit",
+		"GET key",
+	}, "\n")
+
+	code := b.runScript(context.Background(), strings.NewReader(script))
+
+	assert.Equal(t, exitOK, code)
+	assert.Equal(t, []string{"GET key", "SETCHUNKED key", "DEL key"}, client.requests)
+	assert.Equal(t, [][]byte{[]byte("first line\nsecond line")}, client.values)
+	assert.Equal(t, "value\nOK\nOK\n", output.String())
+	assert.Empty(t, errorOutput.String())
+}
+
+func TestBatch_RunScript_WhenQuoteNotClosed_ExpectBadRequest(t *testing.T) {
+	client := &testClient{}
+	output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+	b := &batch{client: client, output: newResultWriter("raw", output, errorOutput), failOnError: true}
+
+	code := b.runScript(context.Background(), strings.NewReader("SET key \"value\nGET key\n"))
+
+	assert.Equal(t, exitBadRequest, code)
+	assert.Empty(t, client.requests)
+	assert.Empty(t, output.String())
+	assert.Equal(t, "ERROR: bad request: unterminated quote\n", errorOutput.String())
+}
+
+func TestBatch_RunCommands_ExitCodes(t *testing.T) {
+	tests := []struct {
+		name            string
+		client          *testClient
+		failOnError     bool
+		commands        []string
+		wantCode        int
+		wantOutput      string
+		wantErrorOutput string
+	}{
+		{
+			name:       "success",
+			client:     &testClient{responses: map[string]string{"GET key": "value"}},
+			commands:   []string{"SET key value", "GET key"},
+			wantCode:   exitOK,
+			wantOutput: "OK\nvalue\n",
+		},
+		{
+			name:       "exit",
+			client:     &testClient{},
+			commands:   []string{"SET key value", "exit", "DEL key"},
+			wantCode:   exitOK,
+			wantOutput: "OK\n",
+		},
+		{
+			name:            "bad request without fail on error",
+			client:          &testClient{responses: map[string]string{"UNKNOWN": "Bad request: unknown command"}},
+			commands:        []string{"UNKNOWN", "DEL key"},
+			wantCode:        exitOK,
+			wantOutput:      "OK\n",
+			wantErrorOutput: "ERROR: bad request: unknown command\n",
+		},
+		{
+			name:            "bad request",
+			client:          &testClient{responses: map[string]string{"UNKNOWN": "Bad request: unknown command"}},
+			failOnError:     true,
+			commands:        []string{"UNKNOWN", "DEL key"},
+			wantCode:        exitBadRequest,
+			wantErrorOutput: "ERROR: bad request: unknown command\n",
+		},
+		{
+			name:            "meta command",
+			client:          &testClient{},
+			failOnError:     true,
+			commands:        []string{`\timing`},
+			wantCode:        exitBadRequest,
+			wantErrorOutput: "ERROR: bad request: meta commands are supported only in interactive mode\n",
+		},
+		{
+			name:            "server error",
+			client:          &testClient{responses: map[string]string{"GET key": "Internal server error"}},
+			failOnError:     true,
+			commands:        []string{"GET key", "DEL key"},
+			wantCode:        exitServerError,
+			wantErrorOutput: "ERROR: server error: Internal server error\n",
+		},
+		{
+			name:            "server error code",
+			client:          &testClient{responses: map[string]string{"GET key": "NOPERM no access"}},
+			failOnError:     true,
+			commands:        []string{"GET key"},
+			wantCode:        exitServerError,
+			wantErrorOutput: "ERROR: server error: NOPERM no access\n",
+		},
+		{
+			name:            "client error",
+			client:          &testClient{errors: map[string]error{"GET key": errors.New("connection reset")}},
+			commands:        []string{"GET key", "DEL key"},
+			wantCode:        exitClientError,
+			wantErrorOutput: "ERROR: connection reset\n",
+		},
+		{
+			name:            "timeout",
+			client:          &testClient{errors: map[string]error{"GET key": context.DeadlineExceeded}},
+			commands:        []string{"SET key value", "GET key", "DEL key"},
+			wantCode:        exitTimeout,
+			wantOutput:      "OK\n",
+			wantErrorOutput: "ERROR: context deadline exceeded\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+			b := &batch{
+				client:      test.client,
+				output:      newResultWriter("raw", output, errorOutput),
+				failOnError: test.failOnError,
+			}
+
+			code := b.runCommands(context.Background(), test.commands)
+
+			assert.Equal(t, test.wantCode, code)
+			assert.Equal(t, test.wantOutput, output.String())
+			assert.Equal(t, test.wantErrorOutput, errorOutput.String())
+		})
+	}
+}
+
+func TestBatch_RunCommands_WhenDeadlineExceeded_ExpectTimeoutCode(t *testing.T) {
+	client := &testClient{}
+	output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+	b := &batch{client: client, output: newResultWriter("raw", output, errorOutput)}
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	code := b.runCommands(ctx, []string{"GET key"})
+
+	assert.Equal(t, exitTimeout, code)
+	assert.Empty(t, client.requests)
+	assert.Empty(t, output.String())
+	assert.Equal(t, "ERROR: context deadline exceeded\n", errorOutput.String())
+}
+
+func TestBatch_RunCommands_WhenStreamMessagesEndWithNewLine_ExpectNoEmptyLines(t *testing.T) {
+	client := &testClient{stream: []string{"SET key value\n", "DEL key\n"}}
+	output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+	b := &batch{client: client, output: newResultWriter("raw", output, errorOutput)}
+
+	code := b.runCommands(context.Background(), []string{"SUBSCRIBE-CHANGES", "GET key"})
+
+	assert.Equal(t, exitOK, code)
+	assert.Empty(t, client.requests, "connection in streaming mode does not accept commands")
+	assert.Equal(t, "SET key value\nDEL key\n", output.String())
+	assert.Empty(t, errorOutput.String())
+}
+
+func TestBatch_RunCommands_OutputFormats(t *testing.T) {
+	tests := []struct {
+		format     string
+		wantOutput string
+	}{
+		{
+			format: "json",
+			wantOutput: `{"command":"GET key","result":"value"}` + "\n" +
+				`{"command":"GET multiline","result":"a,b\nc"}` + "\n" +
+				`{"command":"GET missing","result":null}` + "\n" +
+				`{"command":"UNKNOWN","error":"bad request: unknown command"}` + "\n",
+		},
+		{
+			format: "csv",
+			wantOutput: "command,result,error\n" +
+				"GET key,value,\n" +
+				"GET multiline,\"a,b\nc\",\n" +
+				"GET missing,,\n" +
+				"UNKNOWN,,bad request: unknown command\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.format, func(t *testing.T) {
+			client := &testClient{responses: map[string]string{
+				"GET key":       "value",
+				"GET multiline": "a,b\nc",
+				"GET missing":   querylang.Nil,
+				"UNKNOWN":       "Bad request: unknown command",
+			}}
+			output, errorOutput := &bytes.Buffer{}, &bytes.Buffer{}
+			b := &batch{client: client, output: newResultWriter(test.format, output, errorOutput)}
+
+			code := b.runCommands(context.Background(), []string{"GET key", "GET multiline", "GET missing", "UNKNOWN"})
+			err := b.output.Flush()
+
+			assert.NoError(t, err)
+			assert.Equal(t, exitOK, code)
+			assert.Equal(t, test.wantOutput, output.String())
+			assert.Empty(t, errorOutput.String())
+		})
+	}
+}
+
+// testClient отвечает на запросы по таблице responses или ошибкой из errors,
+// на остальные запросы - "OK". Потоковый запрос получает сообщения stream.
+type testClient struct {
+	responses map[string]string
+	errors    map[string]error
+	stream    []string
+	requests  []string
+	values    [][]byte
+}
+
+func (c *testClient) Send(request []byte) ([]byte, error) {
+	return c.SendContext(context.Background(), request)
+}
+
+func (c *testClient) SendContext(_ context.Context, request []byte) ([]byte, error) {
+	c.requests = append(c.requests, string(request))
+	if err, ok := c.errors[string(request)]; ok {
+		return nil, err
+	}
+	if response, ok := c.responses[string(request)]; ok {
+		return []byte(response), nil
+	}
+
+	return []byte("OK"), nil
+}
+
+func (c *testClient) SendChunked(ctx context.Context, request, value []byte) ([]byte, error) {
+	c.values = append(c.values, value)
+
+	return c.SendContext(ctx, request)
+}
+
+func (c *testClient) Stream(_ []byte, receive func(message []byte)) error {
+	for _, message := range c.stream {
+		receive([]byte(message))
+	}
+
+	return nil
+}
+
+func (c *testClient) Close() error {
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/analyzing"
+)
+
+// metaCommands - команды клиента, которые не отправляются серверу.
+var metaCommands = []string{`\help`, `\timing`, `\q`}
+
+// keywordPattern выделяет ключевые слова из описания аргументов команды,
+// например FROM и PREFIX из "[FROM <lsn>] [PREFIX <prefix>]".
+var keywordPattern = regexp.MustCompile(`\b[A-Z][A-Z-]+\b`)
+
+// completer дополняет имена команд, подкоманд и ключевые слова аргументов
+// по таблице команд анализатора.
+type completer struct {
+	commands []analyzing.CommandSyntax
+	// names - первые слова команд, groups - подкоманды групп (CLUSTER, ACL, CLIENT).
+	names  []string
+	groups map[string][]string
+}
+
+func newCompleter(commands []analyzing.CommandSyntax) *completer {
+	c := &completer{commands: commands, groups: make(map[string][]string)}
+	for _, command := range commands {
+		name, subcommand, isSubcommand := strings.Cut(command.Name, " ")
+		if !slices.Contains(c.names, name) {
+			c.names = append(c.names, name)
+		}
+		if isSubcommand {
+			c.groups[name] = append(c.groups[name], subcommand)
+		}
+	}
+
+	return c
+}
+
+// Complete возвращает варианты дополнения слова, на котором находится курсор:
+// head - ввод до слова, tail - ввод после курсора.
+func (c *completer) Complete(line string, pos int) (string, []string, string) {
+	start := strings.LastIndexFunc(line[:pos], unicode.IsSpace) + 1
+	head, word, tail := line[:start], line[start:pos], line[pos:]
+
+	var candidates []string
+	previous := strings.Fields(head)
+	switch {
+	case len(previous) == 0:
+		candidates = append(append(slices.Clone(c.names), "TIMEOUT"), metaCommands...)
+	case previous[0] == `\help`:
+		candidates = c.names
+	case previous[0] == "TIMEOUT" && len(previous) == 2:
+		candidates = c.names
+	default:
+		candidates = c.arguments(previous)
+	}
+
+	completions := make([]string, 0, len(candidates))
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, strings.ToUpper(word)) || strings.HasPrefix(candidate, word) {
+			completions = append(completions, candidate+" ")
+		}
+	}
+
+	return head, completions, tail
+}
+
+// arguments возвращает подкоманды группы или еще не указанные ключевые слова
+// аргументов команды.
+func (c *completer) arguments(previous []string) []string {
+	if previous[0] == "TIMEOUT" {
+		previous = previous[min(2, len(previous)):]
+	}
+	if len(previous) == 0 {
+		return nil
+	}
+	if subcommands, isGroup := c.groups[previous[0]]; isGroup && len(previous) == 1 {
+		return subcommands
+	}
+
+	command, ok := c.find(previous)
+	if !ok {
+		return nil
+	}
+	var keywords []string
+	for _, keyword := range keywordPattern.FindAllString(command.Arguments, -1) {
+		if !slices.Contains(previous, keyword) {
+			keywords = append(keywords, keyword)
+		}
+	}
+
+	return keywords
+}
+
+// find находит команду по первым словам ввода.
+func (c *completer) find(tokens []string) (analyzing.CommandSyntax, bool) {
+	name := tokens[0]
+	if _, isGroup := c.groups[name]; isGroup && len(tokens) > 1 {
+		name += " " + tokens[1]
+	}
+	for _, command := range c.commands {
+		if command.Name == name {
+			return command, true
+		}
+	}
+
+	return analyzing.CommandSyntax{}, false
+}
+
+// usage возвращает подсказку по аргументам команды, например "GET <key>".
+func (c *completer) usage(tokens []string) (string, bool) {
+	if len(tokens) > 2 && tokens[0] == "TIMEOUT" {
+		tokens = tokens[2:]
+	}
+	if len(tokens) == 0 {
+		return "", false
+	}
+	command, ok := c.find(tokens)
+	if !ok {
+		return "", false
+	}
+
+	return strings.TrimSpace(command.Name + " " + command.Arguments), true
+}
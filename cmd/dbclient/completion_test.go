@@ -0,0 +1,153 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/analyzing"
+)
+
+var testCommands = []analyzing.CommandSyntax{
+	{Name: "GET", Arguments: "<key>"},
+	{Name: "GETRANGE", Arguments: "<key> <offset> <length>"},
+	{Name: "SET", Arguments: "<key> <value>"},
+	{Name: "CLUSTER JOIN", Arguments: "<node_id> <address>"},
+	{Name: "CLUSTER NODES"},
+	{Name: "SUBSCRIBE-CHANGES", Arguments: "[FROM <lsn>] [PREFIX <prefix>]"},
+}
+
+func TestCompleter_Complete(t *testing.T) {
+	tests := []struct {
+		name            string
+		line            string
+		pos             int
+		wantHead        string
+		wantCompletions []string
+		wantTail        string
+	}{
+		{
+			name:            "command name",
+			line:            "GE",
+			pos:             2,
+			wantCompletions: []string{"GET ", "GETRANGE "},
+		},
+		{
+			name:            "lower case command name",
+			line:            "se",
+			pos:             2,
+			wantCompletions: []string{"SET "},
+		},
+		{
+			name:            "empty line",
+			line:            "",
+			pos:             0,
+			wantCompletions: []string{"GET ", "GETRANGE ", "SET ", "CLUSTER ", "SUBSCRIBE-CHANGES ", "TIMEOUT ", `\help `, `\timing `, `\q `},
+		},
+		{
+			name:            "meta command",
+			line:            `\t`,
+			pos:             2,
+			wantCompletions: []string{`\timing `},
+		},
+		{
+			name:            "subcommand",
+			line:            "CLUSTER ",
+			pos:             8,
+			wantHead:        "CLUSTER ",
+			wantCompletions: []string{"JOIN ", "NODES "},
+		},
+		{
+			name:            "command after timeout",
+			line:            "TIMEOUT 1s CL",
+			pos:             13,
+			wantHead:        "TIMEOUT 1s ",
+			wantCompletions: []string{"CLUSTER "},
+		},
+		{
+			name:            "subcommand after timeout",
+			line:            "TIMEOUT 1s CLUSTER N",
+			pos:             20,
+			wantHead:        "TIMEOUT 1s CLUSTER ",
+			wantCompletions: []string{"NODES "},
+		},
+		{
+			name:            "command for help",
+			line:            `\help CL`,
+			pos:             8,
+			wantHead:        `\help `,
+			wantCompletions: []string{"CLUSTER "},
+		},
+		{
+			name:            "argument keywords",
+			line:            "SUBSCRIBE-CHANGES ",
+			pos:             18,
+			wantHead:        "SUBSCRIBE-CHANGES ",
+			wantCompletions: []string{"FROM ", "PREFIX "},
+		},
+		{
+			name:            "keyword already entered",
+			line:            "SUBSCRIBE-CHANGES FROM 10 ",
+			pos:             26,
+			wantHead:        "SUBSCRIBE-CHANGES FROM 10 ",
+			wantCompletions: []string{"PREFIX "},
+		},
+		{
+			name:            "cursor in the middle",
+			line:            "GE key",
+			pos:             2,
+			wantCompletions: []string{"GET ", "GETRANGE "},
+			wantTail:        " key",
+		},
+		{
+			name:            "no keywords",
+			line:            "GET ",
+			pos:             4,
+			wantHead:        "GET ",
+			wantCompletions: []string{},
+		},
+		{
+			name:            "unknown command",
+			line:            "UNKNOWN ",
+			pos:             8,
+			wantHead:        "UNKNOWN ",
+			wantCompletions: []string{},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			completer := newCompleter(testCommands)
+
+			head, completions, tail := completer.Complete(test.line, test.pos)
+
+			assert.Equal(t, test.wantHead, head)
+			assert.Equal(t, test.wantCompletions, completions)
+			assert.Equal(t, test.wantTail, tail)
+		})
+	}
+}
+
+func TestCompleter_Usage(t *testing.T) {
+	tests := []struct {
+		tokens    []string
+		wantUsage string
+		wantFound bool
+	}{
+		{tokens: []string{"GET"}, wantUsage: "GET <key>", wantFound: true},
+		{tokens: []string{"TIMEOUT", "1s", "SET", "key"}, wantUsage: "SET <key> <value>", wantFound: true},
+		{tokens: []string{"CLUSTER", "NODES"}, wantUsage: "CLUSTER NODES", wantFound: true},
+		{tokens: []string{"CLUSTER"}, wantFound: false},
+		{tokens: []string{"UNKNOWN"}, wantFound: false},
+		{tokens: []string{"TIMEOUT", "1s"}, wantFound: false},
+	}
+	for _, test := range tests {
+		t.Run(strings.Join(test.tokens, " "), func(t *testing.T) {
+			completer := newCompleter(testCommands)
+
+			usage, found := completer.usage(test.tokens)
+
+			assert.Equal(t, test.wantFound, found)
+			assert.Equal(t, test.wantUsage, usage)
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
+)
+
+var errUnterminatedQuote = errors.New("unterminated quote")
+
+// splitInput разбивает ввод на аргументы. Аргумент в двойных кавычках может
+// содержать пробелы, переводы строк и экранированные символы (\n, \t, \",
+// \\ и т.д.), в одинарных кавычках - любые символы, кроме одинарной кавычки.
+// Второе значение - true, если ввод содержит аргументы в кавычках.
+// Незакрытая кавычка означает, что ввод продолжается на следующей строке
+// (ошибка errUnterminatedQuote).
+func splitInput(input string) ([]string, bool, error) {
+	var tokens []string
+	quoted := false
+	token := strings.Builder{}
+	inToken := false
+
+	for i := 0; i < len(input); i++ {
+		c := input[i]
+		switch {
+		case c == '"':
+			end := closingQuote(input, i+1)
+			if end < 0 {
+				return nil, quoted, errUnterminatedQuote
+			}
+			// строковый литерал Go не допускает перевода строки
+			value, err := strconv.Unquote(strings.ReplaceAll(input[i:end+1], "\n", `\n`))
+			if err != nil {
+				return nil, quoted, err
+			}
+			token.WriteString(value)
+			inToken, quoted = true, true
+			i = end
+		case c == '\'':
+			end := strings.IndexByte(input[i+1:], '\'')
+			if end < 0 {
+				return nil, quoted, errUnterminatedQuote
+			}
+			token.WriteString(input[i+1 : i+1+end])
+			inToken, quoted = true, true
+			i += end + 1
+		case unicode.IsSpace(rune(c)):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteByte(c)
+			inToken = true
+		}
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+
+	return tokens, quoted, nil
+}
+
+// closingQuote возвращает позицию двойной кавычки, закрывающей строку,
+// которая начинается с позиции start, или -1.
+func closingQuote(input string, start int) int {
+	for i := start; i < len(input); i++ {
+		switch input[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+
+	return -1
+}
+
+// formatInput собирает аргументы в одну строку для истории команд.
+// Аргументы, которые нельзя передать без кавычек, заключаются в двойные
+// кавычки с экранированием, поэтому многострочный ввод занимает одну строку.
+func formatInput(tokens []string) string {
+	formatted := make([]string, len(tokens))
+	for i, token := range tokens {
+		if parsing.IsPlainToken(token) {
+			formatted[i] = token
+		} else {
+			formatted[i] = strconv.Quote(token)
+		}
+	}
+
+	return strings.Join(formatted, " ")
+}
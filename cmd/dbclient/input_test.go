@@ -0,0 +1,90 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitInput(t *testing.T) {
+	tests := []struct {
+		input      string
+		wantTokens []string
+		wantQuoted bool
+		wantError  error
+	}{
+		{
+			input: "",
+		},
+		{
+			input:      " GET\tkey ",
+			wantTokens: []string{"GET", "key"},
+		},
+		{
+			input:      `SET key "two words"`,
+			wantTokens: []string{"SET", "key", "two words"},
+			wantQuoted: true,
+		},
+		{
+			input:      `SET key "tab\there \"quoted\" \\"`,
+			wantTokens: []string{"SET", "key", "tab\there \"quoted\" \\"},
+			wantQuoted: true,
+		},
+		{
+			input:      "SET key \"first line\nsecond line\"",
+			wantTokens: []string{"SET", "key", "first line\nsecond line"},
+			wantQuoted: true,
+		},
+		{
+			input:      `SET key 'single "quoted" \n'`,
+			wantTokens: []string{"SET", "key", `single "quoted" \n`},
+			wantQuoted: true,
+		},
+		{
+			input:      `SET key prefix" "suffix`,
+			wantTokens: []string{"SET", "key", "prefix suffix"},
+			wantQuoted: true,
+		},
+		{
+			input:      `SET key ""`,
+			wantTokens: []string{"SET", "key", ""},
+			wantQuoted: true,
+		},
+		{
+			input:     `SET key "unterminated`,
+			wantError: errUnterminatedQuote,
+		},
+		{
+			input:     `SET key 'unterminated`,
+			wantError: errUnterminatedQuote,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			gotTokens, gotQuoted, err := splitInput(test.input)
+
+			if test.wantError != nil {
+				assert.ErrorIs(t, err, test.wantError)
+				assert.Nil(t, gotTokens)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantTokens, gotTokens)
+			assert.Equal(t, test.wantQuoted, gotQuoted)
+		})
+	}
+}
+
+func TestSplitInput_WhenEscapeSequenceInvalid_ExpectError(t *testing.T) {
+	_, _, err := splitInput(`SET key "\q"`)
+
+	assert.Error(t, err)
+}
+
+func TestFormatInput(t *testing.T) {
+	formatted := formatInput([]string{"SET", "key", "first line\nsecond \"line\""})
+
+	assert.Equal(t, `SET key "first line\nsecond \"line\""`, formatted)
+}
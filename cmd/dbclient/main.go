@@ -1,49 +1,137 @@
 package main
 
 import (
-	"bufio"
-	"errors"
+	"context"
 	"fmt"
-	"io"
 	"log"
 	"os"
-	"strings"
 
 	"github.com/strider2038/key-value-database/internal/config"
 	"github.com/strider2038/key-value-database/internal/di"
 )
 
+type Client interface {
+	Send(request []byte) ([]byte, error)
+	Close() error
+}
+
+type StreamClient interface {
+	Stream(request []byte, receive func(message []byte)) error
+}
+
+// ContextClient ограничивает выполнение запроса сроком контекста.
+type ContextClient interface {
+	SendContext(ctx context.Context, request []byte) ([]byte, error)
+}
+
+// ChunkedClient передает значение команды отдельно от запроса.
+type ChunkedClient interface {
+	SendChunked(ctx context.Context, request, value []byte) ([]byte, error)
+}
+
 func main() {
 	options, err := config.LoadClientOptions()
 	if err != nil {
 		log.Fatalln("parse command line arguments: ", err)
 	}
+	if options.Timeout > 0 {
+		options.IdleTimeout = min(options.IdleTimeout, options.Timeout)
+	}
 
-	client, err := di.NewClient(options)
+	client, err := newClient(options)
 	if err != nil {
+		if options.Timeout > 0 && isTimeout(err) {
+			log.Println("create client: ", err)
+			os.Exit(exitTimeout)
+		}
 		log.Fatalln("create client: ", err)
 	}
-	defer client.Close()
 
-	input := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("command: ")
-		command, _ := input.ReadString('\n')
-		command = strings.TrimSpace(command)
-		if command == "exit" || command == "\\q" {
-			break
-		}
-		result, err := client.Send([]byte(command))
+	if !isBatchMode(options) {
+		newREPL(client).run()
+		_ = client.Close()
+
+		return
+	}
+
+	code := runBatch(options, client)
+	_ = client.Close()
+	os.Exit(code)
+}
+
+// isBatchMode проверяет, что команды переданы аргументами, файлом или
+// перенаправленным вводом и интерактивный режим не нужен.
+func isBatchMode(options config.ClientOptions) bool {
+	if len(options.Commands) > 0 || options.ScriptFile != "" {
+		return true
+	}
+	stat, err := os.Stdin.Stat()
+
+	return err == nil && stat.Mode()&os.ModeCharDevice == 0
+}
+
+func runBatch(options config.ClientOptions, client Client) int {
+	ctx := context.Background()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	b := &batch{
+		client:      client,
+		output:      newResultWriter(options.Format, os.Stdout, os.Stderr),
+		failOnError: options.FailOnError,
+	}
+	var code int
+	switch {
+	case len(options.Commands) > 0:
+		code = b.runCommands(ctx, options.Commands)
+	case options.ScriptFile != "":
+		file, err := os.Open(options.ScriptFile)
 		if err != nil {
-			if errors.Is(err, io.EOF) {
-				fmt.Println("server closed a connection")
-			} else {
-				fmt.Println("ERROR: ", err.Error())
-			}
+			log.Println("open script: ", err)
+
+			return exitClientError
+		}
+		defer file.Close()
+		code = b.runScript(ctx, file)
+	default:
+		code = b.runScript(ctx, os.Stdin)
+	}
+
+	if err := b.output.Flush(); err != nil {
+		log.Println("write output: ", err)
+		if code == exitOK {
+			code = exitClientError
+		}
+	}
+
+	return code
+}
 
-			break
+// send отправляет запрос. Значение, если оно есть, передается отдельно
+// от запроса по частям.
+func send(ctx context.Context, client Client, request string, value []byte) ([]byte, error) {
+	if value != nil {
+		chunked, ok := client.(ChunkedClient)
+		if !ok {
+			return nil, fmt.Errorf("values in quotes are not supported by the client: %w", errQuotedArgument)
 		}
 
-		fmt.Println("result: ", string(result))
+		return chunked.SendChunked(ctx, []byte(request), value)
 	}
+	if contextClient, ok := client.(ContextClient); ok {
+		return contextClient.SendContext(ctx, []byte(request))
+	}
+
+	return client.Send([]byte(request))
+}
+
+func newClient(options config.ClientOptions) (Client, error) {
+	if options.Cluster {
+		return di.NewClusterClient(options)
+	}
+
+	return di.NewClient(options)
 }
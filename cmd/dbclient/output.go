@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+// resultWriter выводит результаты команд без интерактивного режима.
+type resultWriter interface {
+	Write(command, result string, err error) error
+	Flush() error
+}
+
+func newResultWriter(format string, output, errorOutput io.Writer) resultWriter {
+	switch format {
+	case "json":
+		return &jsonWriter{encoder: json.NewEncoder(output)}
+	case "csv":
+		return &csvWriter{writer: csv.NewWriter(output)}
+	default:
+		return &rawWriter{output: output, errorOutput: errorOutput}
+	}
+}
+
+// rawWriter выводит результат команды как есть, а ошибку - в errorOutput.
+// Перевод строки добавляется, только если результат им не заканчивается,
+// как сообщения потока изменений.
+type rawWriter struct {
+	output      io.Writer
+	errorOutput io.Writer
+}
+
+func (w *rawWriter) Write(command, result string, err error) error {
+	if err != nil {
+		_, err = fmt.Fprintln(w.errorOutput, "ERROR:", err.Error())
+
+		return err
+	}
+	if strings.HasSuffix(result, "\n") {
+		_, err = fmt.Fprint(w.output, result)
+	} else {
+		_, err = fmt.Fprintln(w.output, result)
+	}
+
+	return err
+}
+
+func (w *rawWriter) Flush() error {
+	return nil
+}
+
+// jsonWriter выводит каждую команду отдельным JSON объектом в строке:
+// {"command": "...", "result": "..."} или {"command": "...", "error": "..."}.
+// Отсутствующее значение (querylang.Nil) выводится как null.
+type jsonWriter struct {
+	encoder *json.Encoder
+}
+
+type jsonResult struct {
+	Command string  `json:"command"`
+	Result  *string `json:"result"`
+}
+
+type jsonError struct {
+	Command string `json:"command"`
+	Error   string `json:"error"`
+}
+
+func (w *jsonWriter) Write(command, result string, err error) error {
+	if err != nil {
+		return w.encoder.Encode(jsonError{Command: command, Error: err.Error()})
+	}
+	line := jsonResult{Command: command}
+	if result != querylang.Nil {
+		line.Result = &result
+	}
+
+	return w.encoder.Encode(line)
+}
+
+func (w *jsonWriter) Flush() error {
+	return nil
+}
+
+// csvWriter выводит таблицу с колонками command, result и error.
+// Отсутствующее значение (querylang.Nil) выводится пустой строкой.
+type csvWriter struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func (w *csvWriter) Write(command, result string, err error) error {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if err := w.writer.Write([]string{"command", "result", "error"}); err != nil {
+			return err
+		}
+	}
+
+	record := []string{command, "", ""}
+	switch {
+	case err != nil:
+		record[2] = err.Error()
+	case result != querylang.Nil:
+		record[1] = result
+	}
+
+	return w.writer.Write(record)
+}
+
+func (w *csvWriter) Flush() error {
+	w.writer.Flush()
+
+	return w.writer.Error()
+}
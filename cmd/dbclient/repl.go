@@ -0,0 +1,280 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/peterh/liner"
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/analyzing"
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
+)
+
+const (
+	prompt             = "command: "
+	continuationPrompt = "     ... "
+	historyFile        = ".kvdb_history"
+)
+
+var errQuotedArgument = errors.New("quoted arguments with spaces or special symbols are supported only for SET value")
+
+// repl - интерактивный режим клиента: редактирование строки, история команд
+// в домашнем каталоге пользователя, дополнение команд по Tab, ввод значений
+// в кавычках на нескольких строках и мета-команды \help и \timing.
+type repl struct {
+	client    Client
+	completer *completer
+	line      *liner.State
+	history   string
+	timing    bool
+}
+
+func newREPL(client Client) *repl {
+	r := &repl{
+		client:    client,
+		completer: newCompleter(analyzing.Commands()),
+		line:      liner.NewLiner(),
+	}
+	r.line.SetCtrlCAborts(true)
+	r.line.SetMultiLineMode(true)
+	r.line.SetTabCompletionStyle(liner.TabPrints)
+	r.line.SetWordCompleter(r.completer.Complete)
+	if home, err := os.UserHomeDir(); err == nil {
+		r.history = filepath.Join(home, historyFile)
+	}
+
+	return r
+}
+
+// run выполняет команды до выхода пользователя или разрыва соединения.
+func (r *repl) run() {
+	r.readHistory()
+	defer r.close()
+
+	for {
+		input, tokens, quoted, err := r.readInput()
+		if errors.Is(err, liner.ErrPromptAborted) {
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			fmt.Println("ERROR: ", err.Error())
+
+			if errors.Is(err, errUnterminatedQuote) {
+				continue
+			}
+
+			return
+		}
+		if !r.execute(input, tokens, quoted) {
+			return
+		}
+	}
+}
+
+// readInput читает команду. Пока в ней есть незакрытая кавычка, ввод
+// продолжается на следующей строке.
+func (r *repl) readInput() (string, []string, bool, error) {
+	input, err := r.line.Prompt(prompt)
+	if err != nil {
+		return "", nil, false, err
+	}
+	lines := 1
+	for {
+		tokens, quoted, err := splitInput(input)
+		if errors.Is(err, errUnterminatedQuote) {
+			next, err := r.line.Prompt(continuationPrompt)
+			if err != nil {
+				return "", nil, false, err
+			}
+			input += "\n" + next
+			lines++
+
+			continue
+		}
+		if err != nil {
+			return "", nil, false, err
+		}
+
+		input = strings.TrimSpace(input)
+		if lines > 1 {
+			// в истории многострочная команда хранится одной строкой
+			r.line.AppendHistory(formatInput(tokens))
+		} else if input != "" {
+			r.line.AppendHistory(input)
+		}
+
+		return input, tokens, quoted, nil
+	}
+}
+
+// execute выполняет команду. Возвращает false, если работу нужно завершить.
+func (r *repl) execute(input string, tokens []string, quoted bool) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+
+	switch tokens[0] {
+	case "exit", `\q`:
+		return false
+	case `\help`:
+		r.printHelp(tokens[1:])
+
+		return true
+	case `\timing`:
+		r.timing = !r.timing
+		if r.timing {
+			fmt.Println("Timing is on.")
+		} else {
+			fmt.Println("Timing is off.")
+		}
+
+		return true
+	}
+
+	request, value, err := newRequest(input, tokens, quoted)
+	if err != nil {
+		fmt.Println("ERROR: ", err.Error())
+
+		return true
+	}
+	if stream, ok := r.client.(StreamClient); ok && strings.HasPrefix(request, "SUBSCRIBE-CHANGES") {
+		// в потоковом режиме соединение не принимает новых команд
+		err := stream.Stream([]byte(request), func(message []byte) {
+			fmt.Print(string(message))
+		})
+		if err != nil {
+			fmt.Println("ERROR: ", err.Error())
+		}
+
+		return false
+	}
+
+	startedAt := time.Now()
+	result, err := send(context.Background(), r.client, request, value)
+	elapsed := time.Since(startedAt)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			fmt.Println("server closed a connection")
+		} else {
+			fmt.Println("ERROR: ", err.Error())
+		}
+
+		// запрос с неподдерживаемым клиентом значением не отправлен
+		return errors.Is(err, errQuotedArgument)
+	}
+
+	fmt.Println("result: ", string(result))
+	if isArgumentsError(string(result)) {
+		if usage, ok := r.completer.usage(tokens); ok {
+			fmt.Println("usage: ", usage)
+		}
+	}
+	if r.timing {
+		fmt.Printf("Time: %.3f ms\n", float64(elapsed.Microseconds())/1000)
+	}
+
+	return true
+}
+
+func (r *repl) printHelp(arguments []string) {
+	prefix := ""
+	if len(arguments) > 0 {
+		prefix = strings.ToUpper(strings.Join(arguments, " "))
+	} else {
+		fmt.Println(`Meta commands:
+  \help [command]  list commands or show command syntax
+  \timing          toggle printing of command execution time
+  \q, exit         quit
+
+Values with spaces or line breaks are entered in quotes: SET key "first line
+second line". Any command may be prefixed with TIMEOUT <duration>, e.g. 500ms.
+
+Commands:`)
+	}
+
+	found := false
+	for _, command := range analyzing.Commands() {
+		if strings.HasPrefix(command.Name, prefix) {
+			fmt.Println(" ", strings.TrimSpace(command.Name+" "+command.Arguments))
+			found = true
+		}
+	}
+	if !found {
+		fmt.Println("ERROR: ", analyzing.ErrUnknownCommand.Error())
+	}
+}
+
+func (r *repl) readHistory() {
+	if r.history == "" {
+		return
+	}
+	file, err := os.Open(r.history)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	_, _ = r.line.ReadHistory(file)
+}
+
+// close сохраняет историю команд и восстанавливает режим терминала.
+func (r *repl) close() {
+	defer r.line.Close()
+	if r.history == "" {
+		return
+	}
+
+	file, err := os.OpenFile(r.history, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		fmt.Println("ERROR: save history: ", err.Error())
+
+		return
+	}
+	defer file.Close()
+
+	if _, err := r.line.WriteHistory(file); err != nil {
+		fmt.Println("ERROR: save history: ", err.Error())
+	}
+}
+
+// newRequest формирует запрос из ввода. Ввод без кавычек передается как есть.
+// Значение команды SET в кавычках передается по частям командой SETCHUNKED,
+// т.к. запрос не может содержать пробелы и специальные символы.
+func newRequest(input string, tokens []string, quoted bool) (string, []byte, error) {
+	if !quoted {
+		return input, nil, nil
+	}
+
+	command := tokens
+	prefix := ""
+	if len(command) > 2 && command[0] == "TIMEOUT" {
+		prefix = strings.Join(command[:2], " ") + " "
+		command = command[2:]
+	}
+	if len(command) == 3 && command[0] == "SET" && parsing.IsPlainToken(command[1]) {
+		value := command[2]
+
+		return prefix + "SETCHUNKED " + command[1], []byte(value), nil
+	}
+	if formatted := formatInput(tokens); !strings.Contains(formatted, `"`) {
+		return formatted, nil, nil
+	}
+
+	return "", nil, errQuotedArgument
+}
+
+// isArgumentsError проверяет, что сервер отклонил команду из-за количества
+// аргументов.
+func isArgumentsError(result string) bool {
+	return strings.HasPrefix(result, "Bad request") &&
+		(strings.Contains(result, analyzing.ErrNotEnoughArguments.Error()) ||
+			strings.Contains(result, analyzing.ErrTooMuchArguments.Error()))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequest(t *testing.T) {
+	tests := []struct {
+		input       string
+		wantRequest string
+		wantValue   []byte
+		wantError   error
+	}{
+		{
+			input:       "GET key",
+			wantRequest: "GET key",
+		},
+		{
+			input:       `SET key "two words"`,
+			wantRequest: "SETCHUNKED key",
+			wantValue:   []byte("two words"),
+		},
+		{
+			input:       "SET key 'first line\nsecond line'",
+			wantRequest: "SETCHUNKED key",
+			wantValue:   []byte("first line\nsecond line"),
+		},
+		{
+			input:       `TIMEOUT 500ms SET key "two words"`,
+			wantRequest: "TIMEOUT 500ms SETCHUNKED key",
+			wantValue:   []byte("two words"),
+		},
+		{
+			input:       `SET key ""`,
+			wantRequest: "SETCHUNKED key",
+			wantValue:   []byte{},
+		},
+		{
+			input:       `GET "key"`,
+			wantRequest: "GET key",
+		},
+		{
+			input:     `GET "two words"`,
+			wantError: errQuotedArgument,
+		},
+		{
+			input:     `SET "two words" value`,
+			wantError: errQuotedArgument,
+		},
+		{
+			input:     `SET key "two words" extra`,
+			wantError: errQuotedArgument,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			tokens, quoted, err := splitInput(test.input)
+			require.NoError(t, err)
+
+			gotRequest, gotValue, err := newRequest(test.input, tokens, quoted)
+
+			if test.wantError != nil {
+				assert.ErrorIs(t, err, test.wantError)
+
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.wantRequest, gotRequest)
+			assert.Equal(t, test.wantValue, gotValue)
+		})
+	}
+}
+
+func TestIsArgumentsError(t *testing.T) {
+	assert.True(t, isArgumentsError("Bad request: not enough arguments"))
+	assert.True(t, isArgumentsError("Bad request: too much arguments"))
+	assert.False(t, isArgumentsError("Bad request: unknown command"))
+	assert.False(t, isArgumentsError("not enough arguments"))
+}
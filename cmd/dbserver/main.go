@@ -1,17 +1,31 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 
 	"github.com/strider2038/key-value-database/internal/config"
+	"github.com/strider2038/key-value-database/internal/database/acl"
 	"github.com/strider2038/key-value-database/internal/di"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := hashPassword(); err != nil {
+			log.Fatalln(err)
+		}
+
+		return
+	}
+
 	options, err := config.LoadServerOptions()
 	if err != nil {
 		log.Fatalln("load config:", err)
@@ -37,3 +51,24 @@ func runServer(options *config.ServerOptions) error {
 
 	return nil
 }
+
+// hashPassword читает пароль из стандартного ввода и выводит его хеш
+// для указания в настройках пользователя (acl.users[].password).
+func hashPassword() error {
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("read password: %w", err)
+	}
+	password = strings.TrimRight(password, "\r\n")
+	if password == "" {
+		return errors.New("password is empty")
+	}
+
+	hash, err := acl.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+
+	return nil
+}
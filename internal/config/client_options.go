@@ -6,4 +6,35 @@ type ClientOptions struct {
 	Address        string
 	MaxMessageSize int
 	IdleTimeout    time.Duration
+	// Cluster - режим шардированного кластера: Address используется для
+	// загрузки карты слотов, команды отправляются узлам-владельцам ключей.
+	Cluster bool
+	// TLS - подключение по TLS. Сертификат клиента необязателен, без CAFile
+	// сертификат сервера проверяется по системному набору корневых сертификатов.
+	TLS ClientTLS
+	// User и Password - учетные данные для аутентификации командой AUTH
+	// после подключения. Если User пуст, аутентификация не выполняется.
+	User     string
+	Password string
+	// Commands и ScriptFile - команды для выполнения без интерактивного
+	// режима: из аргументов командной строки или из файла.
+	Commands   []string
+	ScriptFile string
+	// Format - формат вывода результатов без интерактивного режима:
+	// "raw", "json" или "csv".
+	Format string
+	// FailOnError - завершить работу при первой ошибке выполнения команды
+	// с кодом, зависящим от типа ошибки.
+	FailOnError bool
+	// Timeout - ограничение времени работы клиента, включая подключение,
+	// 0 - без ограничения.
+	Timeout time.Duration
+}
+
+type ClientTLS struct {
+	Enabled    bool
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	MinVersion string
 }
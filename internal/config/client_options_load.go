@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/dustin/go-humanize"
 	"github.com/spf13/pflag"
@@ -9,9 +10,22 @@ import (
 )
 
 func LoadClientOptions() (ClientOptions, error) {
-	pflag.StringP("address", "a", DefaultAddress, "Database network address.")
+	pflag.StringP("address", "a", DefaultAddress, "Database network address: host:port or unix:///path/to/socket.sock.")
 	pflag.String("max-message-size", humanize.Bytes(DefaultMaxMessageSize), "Max message size, example: 100 Kb.")
 	pflag.Duration("idle-timeout", DefaultIdleTimeout, "Network idle timeout, example: 10 s.")
+	pflag.Bool("cluster", false, "Sharded cluster mode: route commands to the nodes owning the keys.")
+	pflag.Bool("tls", false, "Connect using TLS.")
+	pflag.String("tls-cert", "", "Client certificate file for mutual TLS.")
+	pflag.String("tls-key", "", "Client private key file for mutual TLS.")
+	pflag.String("tls-ca", "", "CA bundle file to verify the server certificate.")
+	pflag.String("tls-min-version", DefaultTLSMinVersion, "Minimum TLS version: 1.2 or 1.3.")
+	pflag.StringP("user", "u", "", "User name to authenticate with AUTH command.")
+	pflag.String("password", "", "User password, defaults to KVDB_PASSWORD environment variable.")
+	pflag.StringArrayP("command", "c", nil, "Command to execute without interactive mode, can be repeated.")
+	pflag.StringP("file", "f", "", "Script file with commands to execute without interactive mode.")
+	pflag.String("format", "raw", "Output format of non-interactive mode: raw, json or csv.")
+	pflag.Bool("fail-on-error", false, "Stop at the first failed command with exit code 2 (bad request) or 3 (server error).")
+	pflag.Duration("timeout", 0, "Time limit for connecting and executing commands, example: 5s.")
 	pflag.Parse()
 	if err := viper.BindPFlags(pflag.CommandLine); err != nil {
 		return ClientOptions{}, err
@@ -22,9 +36,43 @@ func LoadClientOptions() (ClientOptions, error) {
 		return ClientOptions{}, fmt.Errorf(`parse "max-message-size": %w`, err)
 	}
 
+	format := viper.GetString("format")
+	if format != "raw" && format != "json" && format != "csv" {
+		return ClientOptions{}, fmt.Errorf(`invalid "format": must be one of: raw, json, csv`)
+	}
+	if viper.GetDuration("timeout") < 0 {
+		return ClientOptions{}, fmt.Errorf(`invalid "timeout": must be >= 0`)
+	}
+
+	// viper разбирает значения списков как CSV, что искажает команды с кавычками
+	commands, err := pflag.CommandLine.GetStringArray("command")
+	if err != nil {
+		return ClientOptions{}, fmt.Errorf(`parse "command": %w`, err)
+	}
+
+	password := viper.GetString("password")
+	if password == "" {
+		password = os.Getenv("KVDB_PASSWORD")
+	}
+
 	return ClientOptions{
 		Address:        viper.GetString("address"),
 		MaxMessageSize: int(maxMessageSize),
 		IdleTimeout:    viper.GetDuration("idle-timeout"),
+		Cluster:        viper.GetBool("cluster"),
+		TLS: ClientTLS{
+			Enabled:    viper.GetBool("tls"),
+			CertFile:   viper.GetString("tls-cert"),
+			KeyFile:    viper.GetString("tls-key"),
+			CAFile:     viper.GetString("tls-ca"),
+			MinVersion: viper.GetString("tls-min-version"),
+		},
+		User:        viper.GetString("user"),
+		Password:    password,
+		Commands:    commands,
+		ScriptFile:  viper.GetString("file"),
+		Format:      format,
+		FailOnError: viper.GetBool("fail-on-error"),
+		Timeout:     viper.GetDuration("timeout"),
 	}, nil
 }
@@ -2,24 +2,55 @@ package config
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/muonsoft/validation"
 	"github.com/muonsoft/validation/it"
 	"github.com/spf13/afero"
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
 )
 
 const (
 	DefaultAddress        = "localhost:3434"
 	DefaultMaxMessageSize = 10_000
+	DefaultMaxValueSize   = 16 * 1024 * 1024
 	DefaultMaxConnections = 100
 	DefaultIdleTimeout    = time.Minute
+	DefaultOverload       = "reject"
+	DefaultQueueSize      = 100
+	DefaultQueueTimeout   = 5 * time.Second
+	DefaultDrainTimeout   = 5 * time.Second
+	DefaultIOModel        = "goroutines"
+
+	DefaultNormalOutputHardLimit   = 16 * 1024 * 1024
+	DefaultNormalOutputSoftLimit   = 4 * 1024 * 1024
+	DefaultNormalOutputSoftTimeout = 10 * time.Second
+	DefaultStreamOutputHardLimit   = 256 * 1024 * 1024
+	DefaultStreamOutputSoftLimit   = 64 * 1024 * 1024
+	DefaultStreamOutputSoftTimeout = time.Minute
+	DefaultTLSMinVersion           = "1.2"
 
 	DefaultWALFlushingBatchSize    = 100
 	DefaultWALFlushingBatchTimeout = 20 * time.Millisecond
 	DefaultWALMaxSegmentSize       = 4 * 1024 * 1024
+
+	DefaultRaftAddress           = "localhost:4434"
+	DefaultRaftElectionTimeout   = 300 * time.Millisecond
+	DefaultRaftHeartbeatInterval = 50 * time.Millisecond
+	DefaultRaftCommitTimeout     = 5 * time.Second
+	DefaultRaftSnapshotThreshold = 10_000
+
+	DefaultMultiLeaderRetryInterval = time.Second
+
+	DefaultRESPAddress = "localhost:6379"
+	DefaultHTTPAddress = "localhost:8080"
+
+	DefaultMemcachedAddress = "localhost:11211"
 )
 
+var socketPermissionsPattern = regexp.MustCompile(`^0?[0-7]{3}$`)
+
 func DefaultServerOptions() *ServerOptions {
 	return &ServerOptions{
 		Engine: Engine{
@@ -32,11 +63,63 @@ func DefaultServerOptions() *ServerOptions {
 			MaxSegmentSize:       DefaultWALMaxSegmentSize,
 			DataDirectory:        "/wal",
 		},
+		Raft: Raft{
+			Enabled:           false,
+			Address:           DefaultRaftAddress,
+			ElectionTimeout:   DefaultRaftElectionTimeout,
+			HeartbeatInterval: DefaultRaftHeartbeatInterval,
+			CommitTimeout:     DefaultRaftCommitTimeout,
+			SnapshotThreshold: DefaultRaftSnapshotThreshold,
+			DataDirectory:     "/raft",
+		},
+		MultiLeader: MultiLeader{
+			Enabled:       false,
+			RetryInterval: DefaultMultiLeaderRetryInterval,
+		},
+		Sharding: Sharding{
+			Enabled:       false,
+			Address:       DefaultAddress,
+			DataDirectory: "/sharding",
+		},
 		Network: Network{
 			Address:        DefaultAddress,
 			MaxConnections: DefaultMaxConnections,
 			MaxMessageSize: DefaultMaxMessageSize,
+			MaxValueSize:   DefaultMaxValueSize,
 			IdleTimeout:    DefaultIdleTimeout,
+			Overload:       DefaultOverload,
+			QueueSize:      DefaultQueueSize,
+			QueueTimeout:   DefaultQueueTimeout,
+			DrainTimeout:   DefaultDrainTimeout,
+			IOModel:        DefaultIOModel,
+			OutputLimits: OutputLimits{
+				Normal: OutputLimit{
+					HardLimit:   DefaultNormalOutputHardLimit,
+					SoftLimit:   DefaultNormalOutputSoftLimit,
+					SoftTimeout: DefaultNormalOutputSoftTimeout,
+				},
+				Stream: OutputLimit{
+					HardLimit:   DefaultStreamOutputHardLimit,
+					SoftLimit:   DefaultStreamOutputSoftLimit,
+					SoftTimeout: DefaultStreamOutputSoftTimeout,
+				},
+			},
+			TLS: TLS{
+				Enabled:    false,
+				MinVersion: DefaultTLSMinVersion,
+			},
+		},
+		RESP: RESP{
+			Enabled: false,
+			Address: DefaultRESPAddress,
+		},
+		HTTP: HTTP{
+			Enabled: false,
+			Address: DefaultHTTPAddress,
+		},
+		Memcached: Memcached{
+			Enabled: false,
+			Address: DefaultMemcachedAddress,
 		},
 		Logging: Logging{
 			Level:  "info",
@@ -46,19 +129,37 @@ func DefaultServerOptions() *ServerOptions {
 }
 
 type ServerOptions struct {
-	FS      afero.Fs
-	Engine  Engine
-	WAL     WAL
-	Network Network
-	Logging Logging
+	FS          afero.Fs
+	Engine      Engine
+	WAL         WAL
+	Raft        Raft
+	MultiLeader MultiLeader
+	Sharding    Sharding
+	Network     Network
+	RESP        RESP
+	HTTP        HTTP
+	Memcached   Memcached
+	ACL         ACL
+	Logging     Logging
 }
 
 func (p *ServerOptions) Validate(ctx context.Context, validator *validation.Validator) error {
 	return validator.Validate(ctx,
 		validation.ValidProperty("engine", p.Engine),
 		validation.ValidProperty("wal", p.WAL),
+		validation.ValidProperty("raft", p.Raft),
+		validation.ValidProperty("multiLeader", p.MultiLeader),
+		validation.ValidProperty("sharding", p.Sharding),
 		validation.ValidProperty("network", p.Network),
+		validation.ValidProperty("resp", p.RESP),
+		validation.ValidProperty("http", p.HTTP),
+		validation.ValidProperty("memcached", p.Memcached),
+		validation.ValidProperty("acl", p.ACL),
 		validation.ValidProperty("logging", p.Logging),
+		validation.When(p.Network.ReservedConnections > 0 && !p.ACL.Enabled).
+			At(validation.PropertyName("network"), validation.PropertyName("reserved_connections")).
+			Then(validation.Number(p.Network.ReservedConnections, it.IsEqualTo(0).
+				WithMessage("Reserved connections require ACL to be enabled."))),
 	)
 }
 
@@ -97,27 +198,383 @@ func (w WAL) Validate(ctx context.Context, validator *validation.Validator) erro
 	)
 }
 
+// Raft - настройки режима кластера с консенсусом Raft. В этом режиме журнал Raft
+// заменяет WAL журнал, настройки WAL не используются. SnapshotOnShutdown - создать
+// снимок состояния при остановке узла, чтобы сократить журнал для следующего запуска.
+type Raft struct {
+	Enabled            bool
+	NodeID             string
+	Address            string
+	Peers              []RaftPeer
+	ElectionTimeout    time.Duration
+	HeartbeatInterval  time.Duration
+	CommitTimeout      time.Duration
+	SnapshotThreshold  int
+	SnapshotOnShutdown bool
+	DataDirectory      string
+}
+
+func (r Raft) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !r.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("nodeID", r.NodeID, it.IsNotBlank()),
+		validation.StringProperty("address", r.Address, it.IsNotBlank()),
+		validation.NumberProperty(
+			"electionTimeout", r.ElectionTimeout,
+			it.IsBetween(10*time.Millisecond, time.Minute),
+		),
+		validation.NumberProperty(
+			"heartbeatInterval", r.HeartbeatInterval,
+			it.IsBetween(time.Millisecond, r.ElectionTimeout-1),
+		),
+		validation.NumberProperty("commitTimeout", r.CommitTimeout, it.IsBetween(time.Millisecond, time.Hour)),
+		validation.NumberProperty("snapshotThreshold", r.SnapshotThreshold, it.IsGreaterThanOrEqual(0)),
+		validation.StringProperty("dataDirectory", r.DataDirectory, it.IsNotBlank()),
+		validation.CountableProperty("peers", len(r.Peers), it.HasMaxCount(5)),
+	)
+}
+
+// RaftPeer - участник кластера, заданный при первоначальной настройке.
+type RaftPeer struct {
+	ID            string `mapstructure:"id"`
+	Address       string `mapstructure:"address"`
+	ClientAddress string `mapstructure:"client_address"`
+}
+
+// MultiLeader - настройки режима нескольких лидеров: каждый узел принимает запись
+// и обменивается записями WAL журнала с остальными узлами. Требует включенного WAL.
+type MultiLeader struct {
+	Enabled       bool
+	NodeID        string
+	Peers         []MultiLeaderPeer
+	RetryInterval time.Duration
+}
+
+func (m MultiLeader) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !m.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("nodeID", m.NodeID, it.IsNotBlank()),
+		validation.CountableProperty("peers", len(m.Peers), it.HasMinCount(1)),
+		validation.NumberProperty("retryInterval", m.RetryInterval, it.IsBetween(time.Millisecond, time.Hour)),
+	)
+}
+
+// MultiLeaderPeer - другой узел в режиме нескольких лидеров.
+type MultiLeaderPeer struct {
+	ID      string `mapstructure:"id"`
+	Address string `mapstructure:"address"`
+}
+
+// Sharding - настройки режима шардированного кластера. Пространство ключей делится
+// на хеш-слоты, которые распределяются между узлами кластера.
+type Sharding struct {
+	Enabled bool
+	// Address - адрес этого узла для клиентов, должен совпадать с адресом в списке узлов.
+	Address       string
+	Nodes         []ShardingNode
+	DataDirectory string
+}
+
+func (s Sharding) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !s.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("address", s.Address, it.IsNotBlank()),
+		validation.CountableProperty("nodes", len(s.Nodes), it.HasMinCount(1)),
+		validation.StringProperty("dataDirectory", s.DataDirectory, it.IsNotBlank()),
+	)
+}
+
+// ShardingNode - узел кластера и его слоты при первоначальной настройке,
+// например: "0-5460,5462".
+type ShardingNode struct {
+	Address string `mapstructure:"address"`
+	Slots   string `mapstructure:"slots"`
+}
+
+// Network - настройки основного протокола. В файле настроек network.address
+// может быть строкой (Address) или списком адресов (Listeners), элементы которого -
+// строки или объекты с полями address, max_connections и socket_permissions.
+// Адреса задаются как "host:port" или "unix:///path.sock".
+//
+// Overload - поведение при достижении max_connections: "reject" - сразу
+// ответить ошибкой и закрыть соединение, "queue" - ожидать освобождения места
+// в очереди размером QueueSize не дольше QueueTimeout. ReservedConnections -
+// дополнительные соединения для пользователей с правами admin (требует ACL).
+// Эти настройки применяются ко всем адресам, а также к серверам RESP и memcached.
+//
+// DrainTimeout - время, которое при остановке сервера дается начатым запросам
+// на завершение до закрытия соединений. При нулевом значении начатые запросы
+// прерываются сразу.
+//
+// MaxValueSize - максимальный размер значения, передаваемого по частям
+// (SETCHUNKED) основным протоколом. Позволяет хранить значения больше
+// MaxMessageSize без увеличения размера сообщения для всех запросов.
+//
+// IOModel - реализация сервера основного протокола: "goroutines" - горутина
+// и буфер сообщения на каждое соединение, "epoll" (только Linux) - пул из
+// Workers горутин (по умолчанию по числу процессоров) и общий пул буферов,
+// что позволяет держать много простаивающих соединений. Сервер epoll
+// не поддерживает TLS, очередь и резерв соединений, потоковые команды
+// и передачу значений по частям.
+//
+// OutputLimits - ограничения буфера неотправленных ответов соединений
+// всех протоколов для обычных клиентов и клиентов, получающих поток сообщений.
 type Network struct {
-	Address        string
-	MaxConnections int
-	MaxMessageSize int
-	IdleTimeout    time.Duration
-	OnServerStart  func()
+	Address             string
+	Listeners           []Listener
+	MaxConnections      int
+	MaxMessageSize      int
+	MaxValueSize        int
+	IdleTimeout         time.Duration
+	Overload            string
+	QueueSize           int
+	QueueTimeout        time.Duration
+	ReservedConnections int
+	DrainTimeout        time.Duration
+	IOModel             string
+	Workers             int
+	OutputLimits        OutputLimits
+	TLS                 TLS
+	OnServerStart       func()
 }
 
 func (n Network) Validate(ctx context.Context, validator *validation.Validator) error {
 	return validator.Validate(ctx,
+		validation.When(len(n.Listeners) == 0).
+			At(validation.PropertyName("address")).
+			Then(validation.String(n.Address, it.IsNotBlank())),
+		validation.ValidSliceProperty("listeners", n.Listeners),
+		validation.When(n.IOModel != "epoll").
+			Then(validation.NumberProperty("max_connections", n.MaxConnections, it.IsBetween(1, 10_000))),
+		validation.When(n.IOModel == "epoll").
+			Then(
+				validation.NumberProperty("max_connections", n.MaxConnections, it.IsBetween(1, 1_000_000)),
+				validation.StringProperty(
+					"overload", n.Overload,
+					it.IsEqualTo("reject").WithMessage("Only reject policy is supported by epoll IO model."),
+				),
+				validation.NumberProperty(
+					"reserved_connections", n.ReservedConnections,
+					it.IsEqualTo(0).WithMessage("Reserved connections are not supported by epoll IO model."),
+				),
+				validation.BoolProperty(
+					"tls.enabled", n.TLS.Enabled,
+					it.IsFalse().WithMessage("TLS is not supported by epoll IO model."),
+				),
+			),
 		validation.StringProperty(
-			"address", n.Address,
-			it.IsNotBlank(),
+			"io_model", n.IOModel,
+			it.IsOneOf("goroutines", "epoll").WithMessage("Must be one of: {{ choices }}."),
 		),
-		validation.NumberProperty(
-			"max_connections", n.MaxConnections,
-			it.IsBetween(1, 10_000),
+		validation.NumberProperty("workers", n.Workers, it.IsBetween(0, 1_000)),
+		validation.StringProperty(
+			"overload", n.Overload,
+			it.IsOneOf("reject", "queue").WithMessage("Must be one of: {{ choices }}."),
 		),
+		validation.When(n.Overload == "queue").
+			Then(
+				validation.NumberProperty("queue_size", n.QueueSize, it.IsBetween(1, 10_000)),
+				validation.NumberProperty("queue_timeout", n.QueueTimeout, it.IsBetween(time.Millisecond, time.Hour)),
+			),
+		validation.NumberProperty("reserved_connections", n.ReservedConnections, it.IsBetween(0, 1_000)),
+		validation.NumberProperty("drain_timeout", n.DrainTimeout, it.IsBetween(0, time.Hour)),
+		validation.NumberProperty("max_value_size", n.MaxValueSize, it.IsBetween(0, 1024*1024*1024)),
+		validation.ValidProperty("output_limits", n.OutputLimits),
+		validation.ValidProperty("tls", n.TLS),
+	)
+}
+
+type OutputLimits struct {
+	Normal OutputLimit
+	Stream OutputLimit
+}
+
+func (l OutputLimits) Validate(ctx context.Context, validator *validation.Validator) error {
+	return validator.Validate(ctx,
+		validation.ValidProperty("normal", l.Normal),
+		validation.ValidProperty("stream", l.Stream),
 	)
 }
 
+// OutputLimit - ограничения буфера ответов клиента: соединение закрывается,
+// если буфер больше HardLimit или остается больше SoftLimit дольше
+// SoftTimeout. Значение 0 отключает ограничение.
+type OutputLimit struct {
+	HardLimit   int
+	SoftLimit   int
+	SoftTimeout time.Duration
+}
+
+func (l OutputLimit) Validate(ctx context.Context, validator *validation.Validator) error {
+	return validator.Validate(ctx,
+		validation.NumberProperty("hard_limit", l.HardLimit, it.IsBetween(0, 4*1024*1024*1024)),
+		validation.NumberProperty("soft_limit", l.SoftLimit, it.IsBetween(0, 4*1024*1024*1024)),
+		validation.When(l.HardLimit > 0).
+			Then(validation.NumberProperty("soft_limit", l.SoftLimit, it.IsLessThanOrEqual(l.HardLimit))),
+		validation.NumberProperty("soft_timeout", l.SoftTimeout, it.IsBetween(0, time.Hour)),
+	)
+}
+
+// Listener - адрес основного протокола с собственным ограничением количества
+// соединений (если не задано, используется network.max_connections).
+// SocketPermissions - права доступа к файлу unix сокета в восьмеричной записи, например "0660".
+type Listener struct {
+	Address           string `mapstructure:"address"`
+	MaxConnections    int    `mapstructure:"max_connections"`
+	SocketPermissions string `mapstructure:"socket_permissions"`
+}
+
+func (l Listener) Validate(ctx context.Context, validator *validation.Validator) error {
+	return validator.Validate(ctx,
+		validation.StringProperty("address", l.Address, it.IsNotBlank()),
+		validation.NumberProperty("max_connections", l.MaxConnections, it.IsBetween(0, 10_000)),
+		validation.StringProperty("socket_permissions", l.SocketPermissions, it.Matches(socketPermissionsPattern)),
+	)
+}
+
+// TLS - настройки TLS соединений основного протокола. На сервере сертификат
+// и ключ перечитываются при изменении файлов. Если задан CAFile, то им проверяются
+// сертификаты клиентов, а VerifyClient делает сертификат клиента обязательным.
+// Те же сертификаты используются при подключении узла к другим узлам кластера.
+type TLS struct {
+	Enabled      bool
+	CertFile     string
+	KeyFile      string
+	CAFile       string
+	MinVersion   string
+	VerifyClient bool
+}
+
+func (t TLS) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !t.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("certFile", t.CertFile, it.IsNotBlank()),
+		validation.StringProperty("keyFile", t.KeyFile, it.IsNotBlank()),
+		validation.StringProperty(
+			"minVersion", t.MinVersion,
+			it.IsOneOf("1.2", "1.3").WithMessage("Must be one of: {{ choices }}."),
+		),
+		validation.When(t.VerifyClient).
+			At(validation.PropertyName("caFile")).
+			Then(validation.String(t.CAFile, it.IsNotBlank())),
+	)
+}
+
+// RESP - настройки дополнительного сервера, совместимого с протоколом Redis
+// (RESP2/RESP3). Ограничения соединений и сообщений берутся из настроек Network.
+type RESP struct {
+	Enabled       bool
+	Address       string
+	OnServerStart func()
+}
+
+func (r RESP) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !r.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("address", r.Address, it.IsNotBlank()),
+	)
+}
+
+// HTTP - настройки HTTP/JSON шлюза. Ограничения соединений, размер тела запроса
+// и время ожидания берутся из настроек Network.
+type HTTP struct {
+	Enabled       bool
+	Address       string
+	OnServerStart func()
+}
+
+func (h HTTP) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !h.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("address", h.Address, it.IsNotBlank()),
+	)
+}
+
+// Memcached - настройки сервера, совместимого с текстовым протоколом memcached.
+// Ограничения соединений и сообщений берутся из настроек Network.
+type Memcached struct {
+	Enabled       bool
+	Address       string
+	OnServerStart func()
+}
+
+func (m Memcached) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !m.Enabled {
+		return nil
+	}
+
+	return validator.Validate(ctx,
+		validation.StringProperty("address", m.Address, it.IsNotBlank()),
+	)
+}
+
+// ACL - пользователи и их права доступа. Если контроль доступа включен, клиент
+// должен пройти аутентификацию командой AUTH до выполнения других команд.
+// NodeUser и NodePassword - учетные данные, с которыми узел подключается
+// к другим узлам кластера; этому пользователю нужны права read, write и admin.
+type ACL struct {
+	Enabled      bool
+	Users        []ACLUser
+	NodeUser     string
+	NodePassword string
+}
+
+func (a ACL) Validate(ctx context.Context, validator *validation.Validator) error {
+	if !a.Enabled {
+		return nil
+	}
+
+	// учетные данные передаются командой AUTH, которая не поддерживает экранирование
+	return validator.Validate(ctx,
+		validation.CountableProperty("users", len(a.Users), it.HasMinCount(1)),
+		validation.When(a.NodeUser != "").
+			At(validation.PropertyName("nodeUser")).
+			Then(validation.Bool(parsing.IsPlainToken(a.NodeUser), isPlainToken())),
+		validation.When(a.NodeUser != "").
+			At(validation.PropertyName("nodePassword")).
+			Then(
+				validation.String(a.NodePassword, it.IsNotBlank()),
+				validation.Bool(a.NodePassword == "" || parsing.IsPlainToken(a.NodePassword), isPlainToken()),
+			),
+	)
+}
+
+func isPlainToken() validation.BoolConstraint {
+	return it.IsTrue().WithMessage(`Must contain only latin letters, digits and symbols "*_/.:-".`)
+}
+
+// ACLUser - пользователь с хешем пароля, полученным командой "dbserver hash-password".
+type ACLUser struct {
+	Name     string    `mapstructure:"name"`
+	Password string    `mapstructure:"password"`
+	Rules    []ACLRule `mapstructure:"rules"`
+}
+
+// ACLRule разрешает команды категорий read, write, admin над ключами,
+// соответствующими шаблону, например: "user:*".
+type ACLRule struct {
+	Categories []string `mapstructure:"categories"`
+	Keys       string   `mapstructure:"keys"`
+}
+
 type Logging struct {
 	Level  string
 	Output string
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/dustin/go-humanize"
 	"github.com/muonsoft/validation/validator"
@@ -44,10 +45,54 @@ func initServerOptions(loader *viper.Viper) (*ServerOptions, error) {
 	loader.Set("wal.flushing_batch_timeout", options.WAL.FlushingBatchTimeout)
 	loader.Set("wal.max_segment_size", humanize.Bytes(uint64(options.WAL.MaxSegmentSize)))
 	loader.Set("wal.data_directory", options.WAL.DataDirectory)
+	loader.Set("raft.enabled", options.Raft.Enabled)
+	loader.Set("raft.node_id", options.Raft.NodeID)
+	loader.Set("raft.address", options.Raft.Address)
+	loader.Set("raft.peers", options.Raft.Peers)
+	loader.Set("raft.election_timeout", options.Raft.ElectionTimeout)
+	loader.Set("raft.heartbeat_interval", options.Raft.HeartbeatInterval)
+	loader.Set("raft.commit_timeout", options.Raft.CommitTimeout)
+	loader.Set("raft.snapshot_threshold", options.Raft.SnapshotThreshold)
+	loader.Set("raft.snapshot_on_shutdown", options.Raft.SnapshotOnShutdown)
+	loader.Set("raft.data_directory", options.Raft.DataDirectory)
+	loader.Set("multi_leader.enabled", options.MultiLeader.Enabled)
+	loader.Set("multi_leader.node_id", options.MultiLeader.NodeID)
+	loader.Set("multi_leader.peers", options.MultiLeader.Peers)
+	loader.Set("multi_leader.retry_interval", options.MultiLeader.RetryInterval)
+	loader.Set("sharding.enabled", options.Sharding.Enabled)
+	loader.Set("sharding.address", options.Sharding.Address)
+	loader.Set("sharding.nodes", options.Sharding.Nodes)
+	loader.Set("sharding.data_directory", options.Sharding.DataDirectory)
 	loader.Set("network.address", options.Network.Address)
 	loader.Set("network.max_connections", options.Network.MaxConnections)
 	loader.Set("network.max_message_size", humanize.Bytes(uint64(options.Network.MaxMessageSize)))
+	loader.Set("network.max_value_size", humanize.Bytes(uint64(options.Network.MaxValueSize)))
 	loader.Set("network.idle_timeout", options.Network.IdleTimeout)
+	loader.Set("network.overload", options.Network.Overload)
+	loader.Set("network.queue_size", options.Network.QueueSize)
+	loader.Set("network.queue_timeout", options.Network.QueueTimeout)
+	loader.Set("network.reserved_connections", options.Network.ReservedConnections)
+	loader.Set("network.drain_timeout", options.Network.DrainTimeout)
+	loader.Set("network.io_model", options.Network.IOModel)
+	loader.Set("network.workers", options.Network.Workers)
+	setOutputLimit(loader, "network.output_limits.normal", options.Network.OutputLimits.Normal)
+	setOutputLimit(loader, "network.output_limits.stream", options.Network.OutputLimits.Stream)
+	loader.Set("network.tls.enabled", options.Network.TLS.Enabled)
+	loader.Set("network.tls.cert_file", options.Network.TLS.CertFile)
+	loader.Set("network.tls.key_file", options.Network.TLS.KeyFile)
+	loader.Set("network.tls.ca_file", options.Network.TLS.CAFile)
+	loader.Set("network.tls.min_version", options.Network.TLS.MinVersion)
+	loader.Set("network.tls.verify_client", options.Network.TLS.VerifyClient)
+	loader.Set("resp.enabled", options.RESP.Enabled)
+	loader.Set("resp.address", options.RESP.Address)
+	loader.Set("http.enabled", options.HTTP.Enabled)
+	loader.Set("http.address", options.HTTP.Address)
+	loader.Set("memcached.enabled", options.Memcached.Enabled)
+	loader.Set("memcached.address", options.Memcached.Address)
+	loader.Set("acl.enabled", options.ACL.Enabled)
+	loader.Set("acl.users", options.ACL.Users)
+	loader.Set("acl.node_user", options.ACL.NodeUser)
+	loader.Set("acl.node_password", options.ACL.NodePassword)
 	loader.Set("logging.level", options.Logging.Level)
 	loader.Set("logging.output", options.Logging.Output)
 	if err := loader.SafeWriteConfig(); err != nil {
@@ -58,16 +103,59 @@ func initServerOptions(loader *viper.Viper) (*ServerOptions, error) {
 }
 
 func loadServerOptions(loader *viper.Viper) (*ServerOptions, error) {
+	// значения по умолчанию для ключей, отсутствующих в файлах настроек,
+	// созданных предыдущими версиями
+	loader.SetDefault("network.overload", DefaultOverload)
+	loader.SetDefault("network.queue_size", DefaultQueueSize)
+	loader.SetDefault("network.queue_timeout", DefaultQueueTimeout)
+	loader.SetDefault("network.io_model", DefaultIOModel)
+	loader.SetDefault("network.drain_timeout", DefaultDrainTimeout)
+
 	errs := make([]error, 0)
 
 	maxMessageSize, err := humanize.ParseBytes(loader.GetString("network.max_message_size"))
 	if err != nil {
 		errs = append(errs, fmt.Errorf(`parse "network.max_message_size": %w`, err))
 	}
+	// ключ отсутствует в файлах настроек, созданных предыдущими версиями
+	loader.SetDefault("network.max_value_size", humanize.IBytes(DefaultMaxValueSize))
+	maxValueSize, err := humanize.ParseBytes(loader.GetString("network.max_value_size"))
+	if err != nil {
+		errs = append(errs, fmt.Errorf(`parse "network.max_value_size": %w`, err))
+	}
+	defaults := DefaultServerOptions().Network.OutputLimits
+	normalOutputLimit, err := parseOutputLimit(loader, "network.output_limits.normal", defaults.Normal)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	streamOutputLimit, err := parseOutputLimit(loader, "network.output_limits.stream", defaults.Stream)
+	if err != nil {
+		errs = append(errs, err)
+	}
 	walMaxSegmentSize, err := humanize.ParseBytes(loader.GetString("wal.max_segment_size"))
 	if err != nil {
 		errs = append(errs, fmt.Errorf(`parse "wal.max_segment_size": %w`, err))
 	}
+	var raftPeers []RaftPeer
+	if err := loader.UnmarshalKey("raft.peers", &raftPeers); err != nil {
+		errs = append(errs, fmt.Errorf(`parse "raft.peers": %w`, err))
+	}
+	var multiLeaderPeers []MultiLeaderPeer
+	if err := loader.UnmarshalKey("multi_leader.peers", &multiLeaderPeers); err != nil {
+		errs = append(errs, fmt.Errorf(`parse "multi_leader.peers": %w`, err))
+	}
+	var shardingNodes []ShardingNode
+	if err := loader.UnmarshalKey("sharding.nodes", &shardingNodes); err != nil {
+		errs = append(errs, fmt.Errorf(`parse "sharding.nodes": %w`, err))
+	}
+	address, listeners, err := parseNetworkAddress(loader)
+	if err != nil {
+		errs = append(errs, fmt.Errorf(`parse "network.address": %w`, err))
+	}
+	var aclUsers []ACLUser
+	if err := loader.UnmarshalKey("acl.users", &aclUsers); err != nil {
+		errs = append(errs, fmt.Errorf(`parse "acl.users": %w`, err))
+	}
 	if len(errs) > 0 {
 		return nil, errors.Join(errs...)
 	}
@@ -83,11 +171,74 @@ func loadServerOptions(loader *viper.Viper) (*ServerOptions, error) {
 			MaxSegmentSize:       int(walMaxSegmentSize),
 			DataDirectory:        loader.GetString("wal.data_directory"),
 		},
+		Raft: Raft{
+			Enabled:            loader.GetBool("raft.enabled"),
+			NodeID:             loader.GetString("raft.node_id"),
+			Address:            loader.GetString("raft.address"),
+			Peers:              raftPeers,
+			ElectionTimeout:    loader.GetDuration("raft.election_timeout"),
+			HeartbeatInterval:  loader.GetDuration("raft.heartbeat_interval"),
+			CommitTimeout:      loader.GetDuration("raft.commit_timeout"),
+			SnapshotThreshold:  loader.GetInt("raft.snapshot_threshold"),
+			SnapshotOnShutdown: loader.GetBool("raft.snapshot_on_shutdown"),
+			DataDirectory:      loader.GetString("raft.data_directory"),
+		},
+		MultiLeader: MultiLeader{
+			Enabled:       loader.GetBool("multi_leader.enabled"),
+			NodeID:        loader.GetString("multi_leader.node_id"),
+			Peers:         multiLeaderPeers,
+			RetryInterval: loader.GetDuration("multi_leader.retry_interval"),
+		},
+		Sharding: Sharding{
+			Enabled:       loader.GetBool("sharding.enabled"),
+			Address:       loader.GetString("sharding.address"),
+			Nodes:         shardingNodes,
+			DataDirectory: loader.GetString("sharding.data_directory"),
+		},
 		Network: Network{
-			Address:        loader.GetString("network.address"),
-			MaxConnections: loader.GetInt("network.max_connections"),
-			MaxMessageSize: int(maxMessageSize),
-			IdleTimeout:    loader.GetDuration("network.idle_timeout"),
+			Address:             address,
+			Listeners:           listeners,
+			MaxConnections:      loader.GetInt("network.max_connections"),
+			MaxMessageSize:      int(maxMessageSize),
+			MaxValueSize:        int(maxValueSize),
+			IdleTimeout:         loader.GetDuration("network.idle_timeout"),
+			Overload:            loader.GetString("network.overload"),
+			QueueSize:           loader.GetInt("network.queue_size"),
+			QueueTimeout:        loader.GetDuration("network.queue_timeout"),
+			ReservedConnections: loader.GetInt("network.reserved_connections"),
+			DrainTimeout:        loader.GetDuration("network.drain_timeout"),
+			IOModel:             loader.GetString("network.io_model"),
+			Workers:             loader.GetInt("network.workers"),
+			OutputLimits: OutputLimits{
+				Normal: normalOutputLimit,
+				Stream: streamOutputLimit,
+			},
+			TLS: TLS{
+				Enabled:      loader.GetBool("network.tls.enabled"),
+				CertFile:     loader.GetString("network.tls.cert_file"),
+				KeyFile:      loader.GetString("network.tls.key_file"),
+				CAFile:       loader.GetString("network.tls.ca_file"),
+				MinVersion:   loader.GetString("network.tls.min_version"),
+				VerifyClient: loader.GetBool("network.tls.verify_client"),
+			},
+		},
+		RESP: RESP{
+			Enabled: loader.GetBool("resp.enabled"),
+			Address: loader.GetString("resp.address"),
+		},
+		HTTP: HTTP{
+			Enabled: loader.GetBool("http.enabled"),
+			Address: loader.GetString("http.address"),
+		},
+		Memcached: Memcached{
+			Enabled: loader.GetBool("memcached.enabled"),
+			Address: loader.GetString("memcached.address"),
+		},
+		ACL: ACL{
+			Enabled:      loader.GetBool("acl.enabled"),
+			Users:        aclUsers,
+			NodeUser:     loader.GetString("acl.node_user"),
+			NodePassword: loader.GetString("acl.node_password"),
 		},
 		Logging: Logging{
 			Level:  loader.GetString("logging.level"),
@@ -95,3 +246,57 @@ func loadServerOptions(loader *viper.Viper) (*ServerOptions, error) {
 		},
 	}, nil
 }
+
+func setOutputLimit(loader *viper.Viper, key string, limit OutputLimit) {
+	loader.Set(key+".hard_limit", humanize.Bytes(uint64(limit.HardLimit)))
+	loader.Set(key+".soft_limit", humanize.Bytes(uint64(limit.SoftLimit)))
+	loader.Set(key+".soft_timeout", limit.SoftTimeout)
+}
+
+// parseOutputLimit разбирает ограничения буфера ответов. Для ключей,
+// отсутствующих в файле настроек, созданном предыдущими версиями,
+// используются значения по умолчанию.
+func parseOutputLimit(loader *viper.Viper, key string, defaults OutputLimit) (OutputLimit, error) {
+	loader.SetDefault(key+".hard_limit", humanize.Bytes(uint64(defaults.HardLimit)))
+	loader.SetDefault(key+".soft_limit", humanize.Bytes(uint64(defaults.SoftLimit)))
+	loader.SetDefault(key+".soft_timeout", defaults.SoftTimeout)
+
+	hardLimit, err := humanize.ParseBytes(loader.GetString(key + ".hard_limit"))
+	if err != nil {
+		return OutputLimit{}, fmt.Errorf(`parse "%s.hard_limit": %w`, key, err)
+	}
+	softLimit, err := humanize.ParseBytes(loader.GetString(key + ".soft_limit"))
+	if err != nil {
+		return OutputLimit{}, fmt.Errorf(`parse "%s.soft_limit": %w`, key, err)
+	}
+
+	return OutputLimit{
+		HardLimit:   int(hardLimit),
+		SoftLimit:   int(softLimit),
+		SoftTimeout: loader.GetDuration(key + ".soft_timeout"),
+	}, nil
+}
+
+// parseNetworkAddress разбирает network.address: строку с одним адресом
+// или список, элементы которого - строки или объекты Listener.
+func parseNetworkAddress(loader *viper.Viper) (string, []Listener, error) {
+	if _, isList := loader.Get("network.address").([]any); !isList {
+		return loader.GetString("network.address"), nil, nil
+	}
+
+	var listeners []Listener
+	err := loader.UnmarshalKey("network.address", &listeners, viper.DecodeHook(
+		func(from reflect.Type, to reflect.Type, data any) (any, error) {
+			if from.Kind() == reflect.String && to == reflect.TypeOf(Listener{}) {
+				return Listener{Address: data.(string)}, nil
+			}
+
+			return data, nil
+		},
+	))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return "", listeners, nil
+}
@@ -0,0 +1,152 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/config"
+)
+
+func TestLoadServerOptions_WhenMaxValueSizeMissing_ExpectDefault(t *testing.T) {
+	options, err := loadServerOptions(t, `
+engine:
+  type: in_memory
+wal:
+  enabled: true
+  flushing_batch_size: 100
+  flushing_batch_timeout: 20ms
+  max_segment_size: 4.2 MB
+  data_directory: /wal
+network:
+  address: localhost:3434
+  max_connections: 100
+  max_message_size: 10 kB
+  idle_timeout: 1m0s
+  drain_timeout: 5s
+  overload: reject
+  io_model: goroutines
+logging:
+  level: info
+  output: stdout
+`)
+
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultMaxValueSize, options.Network.MaxValueSize)
+}
+
+func TestLoadServerOptions_WhenConfigOfPreviousVersion_ExpectDefaults(t *testing.T) {
+	// файл настроек, созданный первой версией сервера
+	options, err := loadServerOptions(t, `
+engine:
+  type: in_memory
+logging:
+  level: info
+  output: stdout
+network:
+  address: localhost:3434
+  idle_timeout: 1m0s
+  max_connections: 100
+  max_message_size: 10 kB
+wal:
+  data_directory: /wal
+  enabled: true
+  flushing_batch_size: 100
+  flushing_batch_timeout: 20ms
+  max_segment_size: 4.2 MB
+`)
+
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultMaxValueSize, options.Network.MaxValueSize)
+	assert.Equal(t, config.DefaultOverload, options.Network.Overload)
+	assert.Equal(t, config.DefaultQueueSize, options.Network.QueueSize)
+	assert.Equal(t, config.DefaultQueueTimeout, options.Network.QueueTimeout)
+	assert.Equal(t, config.DefaultDrainTimeout, options.Network.DrainTimeout)
+	assert.Equal(t, config.DefaultIOModel, options.Network.IOModel)
+}
+
+func TestLoadServerOptions_WhenReservedConnectionsWithoutACL_ExpectError(t *testing.T) {
+	_, err := loadServerOptions(t, `
+engine:
+  type: in_memory
+wal:
+  enabled: false
+  max_segment_size: 4.2 MB
+network:
+  address: localhost:3434
+  max_connections: 100
+  reserved_connections: 2
+  max_message_size: 10 kB
+  idle_timeout: 1m0s
+`)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Reserved connections require ACL to be enabled.")
+}
+
+func TestLoadServerOptions_WhenNodeCredentialsAreNotPlainTokens_ExpectError(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials string
+		property    string
+	}{
+		{
+			name:        "user with space",
+			credentials: "node_user: node admin\n  node_password: secret",
+			property:    "acl.nodeUser",
+		},
+		{
+			name:        "password with space",
+			credentials: "node_user: node\n  node_password: two words",
+			property:    "acl.nodePassword",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := loadServerOptions(t, `
+engine:
+  type: in_memory
+wal:
+  enabled: false
+  max_segment_size: 4.2 MB
+network:
+  address: localhost:3434
+  max_connections: 100
+  max_message_size: 10 kB
+  idle_timeout: 1m0s
+acl:
+  enabled: true
+  users:
+    - name: node
+      password: hash
+      rules:
+        - categories: [read, write, admin]
+          keys: "*"
+  `+test.credentials+`
+`)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.property)
+			assert.Contains(t, err.Error(), `Must contain only latin letters, digits and symbols "*_/.:-".`)
+		})
+	}
+}
+
+// loadServerOptions загружает настройки из файла kvdb.yaml с содержимым
+// content во временной рабочей директории.
+func loadServerOptions(t *testing.T, content string) (*config.ServerOptions, error) {
+	t.Helper()
+
+	directory := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(directory, "kvdb.yaml"), []byte(content), 0o600))
+	workingDirectory, err := os.Getwd()
+	require.NoError(t, err)
+	require.NoError(t, os.Chdir(directory))
+	defer func() {
+		require.NoError(t, os.Chdir(workingDirectory))
+	}()
+
+	return config.LoadServerOptions()
+}
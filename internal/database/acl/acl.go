@@ -0,0 +1,221 @@
+package acl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+// Category - категория команд, на выполнение которых выдается разрешение.
+type Category string
+
+const (
+	// CategoryRead - чтение значений (GET).
+	CategoryRead Category = "read"
+	// CategoryWrite - изменение значений (SET, DEL).
+	CategoryWrite Category = "write"
+	// CategoryAdmin - команды управления кластером, синхронизации узлов,
+	// подписки на изменения, просмотра списка пользователей и управления
+	// клиентскими соединениями (CLIENT LIST, CLIENT KILL).
+	CategoryAdmin Category = "admin"
+)
+
+func ParseCategory(category string) (Category, error) {
+	switch Category(category) {
+	case CategoryRead, CategoryWrite, CategoryAdmin:
+		return Category(category), nil
+	}
+
+	return "", fmt.Errorf("%w: unknown category %q", ErrInvalidRule, category)
+}
+
+// Rule разрешает команды указанных категорий над ключами, соответствующими
+// шаблону Keys. В шаблоне "*" обозначает любую последовательность символов,
+// "?" - любой один символ. Команды категории admin не привязаны к ключам,
+// поэтому для них шаблон не проверяется.
+type Rule struct {
+	Categories []Category
+	Keys       string
+}
+
+func (r Rule) allows(category Category, key string) bool {
+	if !slices.Contains(r.Categories, category) {
+		return false
+	}
+
+	return category == CategoryAdmin || matchPattern(r.Keys, key)
+}
+
+func (r Rule) String() string {
+	categories := make([]string, len(r.Categories))
+	for i, category := range r.Categories {
+		categories[i] = string(category)
+	}
+
+	return strings.Join(categories, ",") + "~" + r.Keys
+}
+
+type User struct {
+	name     string
+	password *passwordHash
+	rules    []Rule
+}
+
+// NewUser создает пользователя. Пароль задается хешем, полученным через HashPassword.
+func NewUser(name, passwordHash string, rules ...Rule) (*User, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: empty user name", ErrInvalidRule)
+	}
+	password, err := parsePasswordHash(passwordHash)
+	if err != nil {
+		return nil, fmt.Errorf("user %q: %w", name, err)
+	}
+	for _, rule := range rules {
+		if len(rule.Categories) == 0 {
+			return nil, fmt.Errorf("user %q: %w: no categories for keys %q", name, ErrInvalidRule, rule.Keys)
+		}
+		for _, category := range rule.Categories {
+			if _, err := ParseCategory(string(category)); err != nil {
+				return nil, fmt.Errorf("user %q: %w", name, err)
+			}
+		}
+		if rule.Keys == "" {
+			return nil, fmt.Errorf("user %q: %w: empty keys pattern", name, ErrInvalidRule)
+		}
+	}
+
+	return &User{name: name, password: password, rules: rules}, nil
+}
+
+func (u *User) Name() string { return u.name }
+
+func (u *User) String() string {
+	var builder strings.Builder
+	builder.WriteString("user ")
+	builder.WriteString(u.name)
+	for _, rule := range u.rules {
+		builder.WriteString(" ")
+		builder.WriteString(rule.String())
+	}
+
+	return builder.String()
+}
+
+func (u *User) allows(category Category, key string) bool {
+	for _, rule := range u.rules {
+		if rule.allows(category, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ACL - список пользователей и их прав доступа.
+type ACL struct {
+	users []*User
+}
+
+func NewACL(users ...*User) (*ACL, error) {
+	names := make(map[string]struct{}, len(users))
+	for _, user := range users {
+		if _, exists := names[user.name]; exists {
+			return nil, fmt.Errorf("%w: duplicate user %q", ErrInvalidRule, user.name)
+		}
+		names[user.name] = struct{}{}
+	}
+
+	return &ACL{users: users}, nil
+}
+
+// Authenticate проверяет имя и пароль пользователя. Для неизвестного пользователя
+// пароль также проверяется, чтобы время ответа не выдавало существующие имена.
+func (a *ACL) Authenticate(name, password string) (*User, error) {
+	index := slices.IndexFunc(a.users, func(user *User) bool { return user.name == name })
+	if index < 0 {
+		unknownUserPassword.verify(password)
+
+		return nil, ErrInvalidCredentials
+	}
+	user := a.users[index]
+	if !user.password.verify(password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// Authorize проверяет, разрешено ли пользователю выполнение команды.
+// Если пользователь не аутентифицирован (nil), возвращает ErrNotAuthenticated.
+func (a *ACL) Authorize(user *User, command *querylang.Command) error {
+	if user == nil {
+		return ErrNotAuthenticated
+	}
+
+	var category Category
+	key := ""
+	switch command.ID() {
+	case querylang.CommandACLWhoAmI,
+		querylang.CommandClientSetName,
+		querylang.CommandClientGetName,
+		querylang.CommandClientID:
+		// команды относятся только к соединению самого пользователя
+		return nil
+	case querylang.CommandGet, querylang.CommandGetRange:
+		category, key = CategoryRead, command.Arguments()[0]
+	case querylang.CommandSet, querylang.CommandDel:
+		category, key = CategoryWrite, command.Arguments()[0]
+	default:
+		category = CategoryAdmin
+	}
+
+	if !user.allows(category, key) {
+		return fmt.Errorf("%w: user %q has no %s access for %s command", ErrForbidden, user.name, category, command.ID())
+	}
+
+	return nil
+}
+
+// IsAdmin сообщает, есть ли у пользователя права на команды категории admin.
+func (a *ACL) IsAdmin(user *User) bool {
+	return user != nil && user.allows(CategoryAdmin, "")
+}
+
+// List возвращает описания пользователей в порядке их объявления.
+func (a *ACL) List() []string {
+	list := make([]string, len(a.users))
+	for i, user := range a.users {
+		list[i] = user.String()
+	}
+
+	return list
+}
+
+// matchPattern проверяет соответствие ключа шаблону с символами "*" и "?".
+func matchPattern(pattern, key string) bool {
+	// Позиции для возврата после неудачного сопоставления с последней звездочкой
+	starPattern, starKey := -1, 0
+	p, k := 0, 0
+	for k < len(key) {
+		switch {
+		case p < len(pattern) && pattern[p] == '*':
+			starPattern, starKey = p, k
+			p++
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == key[k]):
+			p++
+			k++
+		case starPattern >= 0:
+			starKey++
+			p, k = starPattern+1, starKey
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+
+	return p == len(pattern)
+}
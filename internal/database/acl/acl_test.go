@@ -0,0 +1,182 @@
+package acl_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+func TestACL_Authenticate(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	list := newACL(t, newUser(t, "alice", hash))
+
+	user, err := list.Authenticate("alice", "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user.Name())
+
+	_, err = list.Authenticate("alice", "wrong")
+	assert.ErrorIs(t, err, acl.ErrInvalidCredentials)
+
+	_, err = list.Authenticate("bob", "secret")
+	assert.ErrorIs(t, err, acl.ErrInvalidCredentials)
+}
+
+func TestACL_Authorize(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	reader := newUser(t, "reader", hash, acl.Rule{Categories: []acl.Category{acl.CategoryRead}, Keys: "*"})
+	writer := newUser(t, "writer", hash,
+		acl.Rule{Categories: []acl.Category{acl.CategoryRead, acl.CategoryWrite}, Keys: "user:*"},
+		acl.Rule{Categories: []acl.Category{acl.CategoryRead}, Keys: "config:?"},
+	)
+	admin := newUser(t, "admin", hash, acl.Rule{Categories: []acl.Category{acl.CategoryAdmin}, Keys: "*"})
+	list := newACL(t, reader, writer, admin)
+
+	tests := []struct {
+		name      string
+		user      *acl.User
+		command   *querylang.Command
+		wantError error
+	}{
+		{
+			name:      "not authenticated",
+			command:   querylang.NewCommand(1, querylang.CommandGet, "key"),
+			wantError: acl.ErrNotAuthenticated,
+		},
+		{
+			name:    "read any key",
+			user:    reader,
+			command: querylang.NewCommand(1, querylang.CommandGet, "key"),
+		},
+		{
+			name:      "write without permission",
+			user:      reader,
+			command:   querylang.NewCommand(1, querylang.CommandSet, "key", "value"),
+			wantError: acl.ErrForbidden,
+		},
+		{
+			name:    "write matching key",
+			user:    writer,
+			command: querylang.NewCommand(1, querylang.CommandDel, "user:1"),
+		},
+		{
+			name:      "write not matching key",
+			user:      writer,
+			command:   querylang.NewCommand(1, querylang.CommandSet, "order:1", "value"),
+			wantError: acl.ErrForbidden,
+		},
+		{
+			name:    "read by single character pattern",
+			user:    writer,
+			command: querylang.NewCommand(1, querylang.CommandGet, "config:a"),
+		},
+		{
+			name:      "read not matching single character pattern",
+			user:      writer,
+			command:   querylang.NewCommand(1, querylang.CommandGet, "config:ab"),
+			wantError: acl.ErrForbidden,
+		},
+		{
+			name:      "admin command without permission",
+			user:      writer,
+			command:   querylang.NewCommand(1, querylang.CommandACLList),
+			wantError: acl.ErrForbidden,
+		},
+		{
+			name:    "admin command",
+			user:    admin,
+			command: querylang.NewCommand(1, querylang.CommandClusterNodes),
+		},
+		{
+			name:      "admin has no data access",
+			user:      admin,
+			command:   querylang.NewCommand(1, querylang.CommandGet, "key"),
+			wantError: acl.ErrForbidden,
+		},
+		{
+			name:    "whoami for any user",
+			user:    newUser(t, "guest", hash),
+			command: querylang.NewCommand(1, querylang.CommandACLWhoAmI),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := list.Authorize(test.user, test.command)
+
+			if test.wantError == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, test.wantError)
+			}
+		})
+	}
+}
+
+func TestACL_List(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	list := newACL(t,
+		newUser(t, "alice", hash,
+			acl.Rule{Categories: []acl.Category{acl.CategoryRead, acl.CategoryWrite}, Keys: "user:*"},
+			acl.Rule{Categories: []acl.Category{acl.CategoryAdmin}, Keys: "*"},
+		),
+		newUser(t, "bob", hash),
+	)
+
+	assert.Equal(t, []string{"user alice read,write~user:* admin~*", "user bob"}, list.List())
+}
+
+func TestACL_IsAdmin(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	admin := newUser(t, "admin", hash, acl.Rule{Categories: []acl.Category{acl.CategoryAdmin}, Keys: "*"})
+	writer := newUser(t, "writer", hash, acl.Rule{Categories: []acl.Category{acl.CategoryWrite}, Keys: "*"})
+	list := newACL(t, admin, writer)
+
+	assert.True(t, list.IsAdmin(admin))
+	assert.False(t, list.IsAdmin(writer))
+	assert.False(t, list.IsAdmin(nil))
+}
+
+func TestNewUser_InvalidRule(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+
+	_, err = acl.NewUser("alice", hash, acl.Rule{Categories: []acl.Category{"delete"}, Keys: "*"})
+	assert.ErrorIs(t, err, acl.ErrInvalidRule)
+
+	_, err = acl.NewUser("alice", hash, acl.Rule{Categories: []acl.Category{acl.CategoryRead}})
+	assert.ErrorIs(t, err, acl.ErrInvalidRule)
+
+	_, err = acl.NewUser("alice", "plain-password")
+	assert.ErrorIs(t, err, acl.ErrInvalidPassword)
+}
+
+func TestNewACL_DuplicateUser(t *testing.T) {
+	hash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+
+	_, err = acl.NewACL(newUser(t, "alice", hash), newUser(t, "alice", hash))
+
+	assert.ErrorIs(t, err, acl.ErrInvalidRule)
+}
+
+func newUser(tb testing.TB, name, hash string, rules ...acl.Rule) *acl.User {
+	tb.Helper()
+	user, err := acl.NewUser(name, hash, rules...)
+	require.NoError(tb, err)
+
+	return user
+}
+
+func newACL(tb testing.TB, users ...*acl.User) *acl.ACL {
+	tb.Helper()
+	list, err := acl.NewACL(users...)
+	require.NoError(tb, err)
+
+	return list
+}
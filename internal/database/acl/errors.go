@@ -0,0 +1,11 @@
+package acl
+
+import "errors"
+
+var (
+	ErrNotAuthenticated   = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid username-password pair")
+	ErrForbidden          = errors.New("no permissions to run the command")
+	ErrInvalidRule        = errors.New("invalid ACL rule")
+	ErrInvalidPassword    = errors.New("invalid password hash")
+)
@@ -0,0 +1,106 @@
+package acl
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	passwordScheme     = "pbkdf2-sha256"
+	passwordIterations = 100_000
+	passwordSaltSize   = 16
+)
+
+// HashPassword возвращает хеш пароля в формате
+// "pbkdf2-sha256$<iterations>$<salt>$<hash>" (соль и хеш в base64),
+// который указывается в настройках пользователя.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, passwordSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	hash := pbkdf2([]byte(password), salt, passwordIterations, sha256.Size)
+
+	return fmt.Sprintf(
+		"%s$%d$%s$%s",
+		passwordScheme,
+		passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(hash),
+	), nil
+}
+
+// unknownUserPassword используется для проверки пароля неизвестного пользователя.
+var unknownUserPassword = &passwordHash{
+	iterations: passwordIterations,
+	salt:       make([]byte, passwordSaltSize),
+	hash:       make([]byte, sha256.Size),
+}
+
+type passwordHash struct {
+	iterations int
+	salt       []byte
+	hash       []byte
+}
+
+func parsePasswordHash(encoded string) (*passwordHash, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != passwordScheme {
+		return nil, fmt.Errorf("%w: expected format %s$<iterations>$<salt>$<hash>", ErrInvalidPassword, passwordScheme)
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations <= 0 {
+		return nil, fmt.Errorf("%w: iterations %q", ErrInvalidPassword, parts[1])
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: salt: %w", ErrInvalidPassword, err)
+	}
+	hash, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil || len(hash) == 0 {
+		return nil, fmt.Errorf("%w: hash", ErrInvalidPassword)
+	}
+
+	return &passwordHash{iterations: iterations, salt: salt, hash: hash}, nil
+}
+
+func (h *passwordHash) verify(password string) bool {
+	hash := pbkdf2([]byte(password), h.salt, h.iterations, len(h.hash))
+
+	return subtle.ConstantTimeCompare(hash, h.hash) == 1
+}
+
+// pbkdf2 - PBKDF2 с HMAC-SHA256 (RFC 8018).
+func pbkdf2(password, salt []byte, iterations, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	blocks := (keyLength + prf.Size() - 1) / prf.Size()
+	key := make([]byte, 0, blocks*prf.Size())
+	counter := make([]byte, 4)
+	for block := 1; block <= blocks; block++ {
+		binary.BigEndian.PutUint32(counter, uint32(block))
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(counter)
+		u := prf.Sum(nil)
+		t := make([]byte, len(u))
+		copy(t, u)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+
+	return key[:keyLength]
+}
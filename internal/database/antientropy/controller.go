@@ -0,0 +1,308 @@
+package antientropy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+type StorageController interface {
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
+}
+
+type IDGenerator interface {
+	NextSeqID() uint64
+}
+
+// NodeClient - соединение с узлом, с которым сравнивается дерево хешей.
+type NodeClient interface {
+	Send(request []byte) ([]byte, error)
+	Close() error
+}
+
+type Dialer func(address string) (NodeClient, error)
+
+// Controller - адаптер контроллера базы данных для проверки согласованности данных
+// между узлами. Команды DIGEST и MERKLE читают дерево хешей локального хранилища,
+// в том числе на репликах. Команда REPAIR сравнивает дерево с деревом другого узла
+// и копирует с него только расходящиеся ключи.
+type Controller struct {
+	storageController StorageController
+	storage           *Storage
+	idGenerator       IDGenerator
+	dial              Dialer
+	logger            *slog.Logger
+}
+
+func NewController(
+	storageController StorageController,
+	storage *Storage,
+	idGenerator IDGenerator,
+	dial Dialer,
+	logger *slog.Logger,
+) *Controller {
+	return &Controller{
+		storageController: storageController,
+		storage:           storage,
+		idGenerator:       idGenerator,
+		dial:              dial,
+		logger:            logger,
+	}
+}
+
+func (c *Controller) Execute(ctx context.Context, command *querylang.Command) (string, error) {
+	switch command.ID() {
+	case querylang.CommandDigest:
+		return formatHash(c.storage.Digest(command.Arguments()[0])), nil
+	case querylang.CommandMerkle:
+		return c.handleMerkle(command.Arguments())
+	case querylang.CommandRepair:
+		return c.handleRepair(ctx, command.Arguments())
+	default:
+		return c.storageController.Execute(ctx, command)
+	}
+}
+
+// handleMerkle возвращает хеши дочерних узлов через пробел, а для листа -
+// пары "<ключ>=<хеш>" через пробел (querylang.Nil для пустого листа).
+func (c *Controller) handleMerkle(arguments []string) (string, error) {
+	level, index, err := parseNode(arguments[0], arguments[1])
+	if err != nil {
+		return "", err
+	}
+
+	if level == LeafLevel {
+		hashes, err := c.storage.Bucket(index)
+		if err != nil {
+			return "", err
+		}
+
+		return formatBucket(hashes), nil
+	}
+
+	hashes, err := c.storage.Children(level, index)
+	if err != nil {
+		return "", err
+	}
+	formatted := make([]string, 0, len(hashes))
+	for _, hash := range hashes {
+		formatted = append(formatted, formatHash(hash))
+	}
+
+	return strings.Join(formatted, " "), nil
+}
+
+// handleRepair спускается по дереву хешей только в расходящиеся поддеревья и
+// копирует значения расходящихся ключей с узла address. Изменения применяются
+// через обычный путь записи (WAL, Raft), т.е. сохраняются и реплицируются.
+// Ключи, изменяемые во время сравнения, могут быть исправлены при следующем вызове.
+func (c *Controller) handleRepair(ctx context.Context, arguments []string) (string, error) {
+	address := arguments[0]
+	start := time.Now()
+
+	client, err := c.dial(address)
+	if err != nil {
+		return "", fmt.Errorf("connect to %s: %w", address, err)
+	}
+	defer client.Close()
+
+	repair := &repairSession{ctx: ctx, controller: c, client: client, address: address}
+	if err := repair.compare(0, 0); err != nil {
+		return "", err
+	}
+
+	c.logger.Info(
+		"repair completed",
+		slog.String("address", address),
+		slog.Int("comparedBuckets", repair.comparedBuckets),
+		slog.Int("repairedKeys", repair.repairedKeys),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return fmt.Sprintf("REPAIRED %d", repair.repairedKeys), nil
+}
+
+type repairSession struct {
+	ctx        context.Context
+	controller *Controller
+	client     NodeClient
+	address    string
+
+	comparedBuckets int
+	repairedKeys    int
+}
+
+func (r *repairSession) compare(level, index int) error {
+	local, err := r.controller.storage.Children(level, index)
+	if err != nil {
+		return err
+	}
+	request := fmt.Sprintf("MERKLE %d %d", level, index)
+	response, err := r.send(request)
+	if err != nil {
+		return err
+	}
+	remote, err := parseHashes(response)
+	if err != nil || len(remote) != Fanout {
+		return &RemoteError{Address: r.address, Request: request, Response: response}
+	}
+
+	for child := 0; child < Fanout; child++ {
+		if local[child] == remote[child] {
+			continue
+		}
+		childIndex := index*Fanout + child
+		if level+1 == LeafLevel {
+			err = r.repairBucket(childIndex)
+		} else {
+			err = r.compare(level+1, childIndex)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repairSession) repairBucket(index int) error {
+	r.comparedBuckets++
+
+	local, err := r.controller.storage.Bucket(index)
+	if err != nil {
+		return err
+	}
+	request := fmt.Sprintf("MERKLE %d %d", LeafLevel, index)
+	response, err := r.send(request)
+	if err != nil {
+		return err
+	}
+	remote, err := parseBucket(response)
+	if err != nil {
+		return &RemoteError{Address: r.address, Request: request, Response: response}
+	}
+
+	keys := make([]string, 0)
+	for key, hash := range remote {
+		if local[key] != hash {
+			keys = append(keys, key)
+		}
+	}
+	for key := range local {
+		if _, exists := remote[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if err := r.repairKey(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *repairSession) repairKey(key string) error {
+	value, err := r.send("GET " + key)
+	if err != nil {
+		return err
+	}
+
+	command := querylang.NewCommand(r.controller.idGenerator.NextSeqID(), querylang.CommandSet, key, value)
+	if value == querylang.Nil {
+		command = querylang.NewCommand(r.controller.idGenerator.NextSeqID(), querylang.CommandDel, key)
+	}
+	if _, err := r.controller.storageController.Execute(r.ctx, command); err != nil {
+		return fmt.Errorf("repair key %q: %w", key, err)
+	}
+	r.repairedKeys++
+
+	return nil
+}
+
+func (r *repairSession) send(request string) (string, error) {
+	response, err := r.client.Send([]byte(request))
+	if err != nil {
+		return "", fmt.Errorf("send to %s: %w", r.address, err)
+	}
+
+	return string(response), nil
+}
+
+func parseNode(level, index string) (int, int, error) {
+	levelNumber, err := strconv.Atoi(level)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: level %q", ErrInvalidNode, level)
+	}
+	indexNumber, err := strconv.Atoi(index)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: index %q", ErrInvalidNode, index)
+	}
+
+	return levelNumber, indexNumber, nil
+}
+
+func formatHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+func parseHash(hash string) (uint64, error) {
+	return strconv.ParseUint(hash, 16, 64)
+}
+
+func parseHashes(response string) ([]uint64, error) {
+	fields := strings.Fields(response)
+	hashes := make([]uint64, 0, len(fields))
+	for _, field := range fields {
+		hash, err := parseHash(field)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, nil
+}
+
+func formatBucket(hashes map[string]uint64) string {
+	if len(hashes) == 0 {
+		return querylang.Nil
+	}
+
+	pairs := make([]string, 0, len(hashes))
+	for key, hash := range hashes {
+		pairs = append(pairs, key+"="+formatHash(hash))
+	}
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, " ")
+}
+
+func parseBucket(response string) (map[string]uint64, error) {
+	hashes := make(map[string]uint64)
+	if response == querylang.Nil {
+		return hashes, nil
+	}
+
+	for _, pair := range strings.Fields(response) {
+		key, hash, found := strings.Cut(pair, "=")
+		if !found {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidNode, pair)
+		}
+		value, err := parseHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		hashes[key] = value
+	}
+
+	return hashes, nil
+}
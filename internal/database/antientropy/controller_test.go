@@ -0,0 +1,119 @@
+package antientropy_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/antientropy"
+	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/storage"
+	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
+)
+
+type TestNode struct {
+	Controller *antientropy.Controller
+	Storage    *antientropy.Storage
+	Requests   []string
+}
+
+func NewTestNode(remote *TestNode) *TestNode {
+	treeStorage := antientropy.NewStorage(inmemory.NewMapStorage())
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	dial := func(address string) (antientropy.NodeClient, error) {
+		if remote == nil {
+			return nil, errors.New("connection refused")
+		}
+
+		return remote, nil
+	}
+
+	return &TestNode{
+		Controller: antientropy.NewController(
+			storage.NewController(treeStorage),
+			treeStorage,
+			&engine.IDGenerator{},
+			dial,
+			logger,
+		),
+		Storage: treeStorage,
+	}
+}
+
+// Send выполняет команды MERKLE и GET, отправленные узлу по сети.
+func (n *TestNode) Send(request []byte) ([]byte, error) {
+	n.Requests = append(n.Requests, string(request))
+	fields := strings.Fields(string(request))
+	commandIDs := map[string]querylang.CommandID{
+		"MERKLE": querylang.CommandMerkle,
+		"GET":    querylang.CommandGet,
+	}
+	response, err := n.Controller.Execute(context.Background(), querylang.NewCommand(1, commandIDs[fields[0]], fields[1:]...))
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(response), nil
+}
+
+func (n *TestNode) Close() error {
+	return nil
+}
+
+func (n *TestNode) Execute(tb testing.TB, command querylang.CommandID, arguments ...string) string {
+	tb.Helper()
+
+	result, err := n.Controller.Execute(context.Background(), querylang.NewCommand(1, command, arguments...))
+	require.NoError(tb, err)
+
+	return result
+}
+
+func TestController_Execute_Repair(t *testing.T) {
+	master := NewTestNode(nil)
+	replica := NewTestNode(master)
+	for i := 0; i < 1000; i++ {
+		master.Execute(t, querylang.CommandSet, fmt.Sprintf("key%d", i), "value")
+		replica.Execute(t, querylang.CommandSet, fmt.Sprintf("key%d", i), "value")
+	}
+	master.Execute(t, querylang.CommandSet, "key1", "changed")
+	master.Execute(t, querylang.CommandSet, "missing", "value")
+	replica.Execute(t, querylang.CommandSet, "extra", "value")
+	require.NotEqual(t, master.Execute(t, querylang.CommandDigest, ""), replica.Execute(t, querylang.CommandDigest, ""))
+
+	result := replica.Execute(t, querylang.CommandRepair, "master")
+
+	assert.Equal(t, "REPAIRED 3", result)
+	assert.Equal(t, master.Execute(t, querylang.CommandDigest, ""), replica.Execute(t, querylang.CommandDigest, ""))
+	assert.Equal(t, "changed", replica.Execute(t, querylang.CommandGet, "key1"))
+	assert.Equal(t, "value", replica.Execute(t, querylang.CommandGet, "missing"))
+	assert.Equal(t, querylang.Nil, replica.Execute(t, querylang.CommandGet, "extra"))
+	gets := 0
+	for _, request := range master.Requests {
+		if strings.HasPrefix(request, "GET") {
+			gets++
+		}
+	}
+	assert.Equal(t, 3, gets, "only diverged keys must be read")
+	assert.Less(t, len(master.Requests), 3+1+3*antientropy.Fanout, "only diverged subtrees must be compared")
+
+	assert.Equal(t, "REPAIRED 0", replica.Execute(t, querylang.CommandRepair, "master"))
+}
+
+func TestController_Execute_Merkle(t *testing.T) {
+	node := NewTestNode(nil)
+	node.Execute(t, querylang.CommandSet, "foo", "1")
+
+	root := node.Execute(t, querylang.CommandMerkle, "0", "0")
+	assert.Len(t, strings.Fields(root), antientropy.Fanout)
+	assert.Equal(t, querylang.Nil, node.Execute(t, querylang.CommandMerkle, "3", "0"))
+	_, err := node.Controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandMerkle, "4", "0"))
+	assert.ErrorIs(t, err, antientropy.ErrInvalidNode)
+}
@@ -0,0 +1,19 @@
+package antientropy
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrInvalidNode = errors.New("invalid tree node")
+
+// RemoteError - некорректный ответ узла, с которым сравнивается дерево хешей.
+type RemoteError struct {
+	Address  string
+	Request  string
+	Response string
+}
+
+func (e *RemoteError) Error() string {
+	return fmt.Sprintf("unexpected response from %s to %q: %q", e.Address, e.Request, e.Response)
+}
@@ -0,0 +1,207 @@
+package antientropy
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/strider2038/key-value-database/internal/database/storage"
+)
+
+const (
+	// Fanout - количество дочерних узлов у каждого внутреннего узла дерева.
+	Fanout = 16
+	// LeafLevel - уровень листьев дерева (корень - уровень 0).
+	LeafLevel = 3
+	// BucketCount - количество листьев (хеш-корзин) дерева.
+	BucketCount = Fanout * Fanout * Fanout
+)
+
+type bucket struct {
+	mu     sync.Mutex
+	hash   uint64
+	hashes map[string]uint64
+}
+
+// Storage - декоратор хранилища, поддерживающий дерево хешей (дерево Меркла)
+// над всеми ключами. Ключи распределяются по BucketCount корзинам, хеш корзины -
+// сумма хешей пар ключ-значение, хеш внутреннего узла - сумма хешей дочерних узлов.
+// Благодаря сложению хеши не зависят от порядка записи и обновляются за O(1)
+// при каждом изменении ключа.
+type Storage struct {
+	storage storage.Storage
+	buckets [BucketCount]bucket
+}
+
+func NewStorage(storage storage.Storage) *Storage {
+	s := &Storage{storage: storage}
+	s.rebuild(storage.Snapshot())
+
+	return s
+}
+
+func (s *Storage) Get(ctx context.Context, key string) (string, error) {
+	return s.storage.Get(ctx, key)
+}
+
+func (s *Storage) Set(ctx context.Context, key, value string) error {
+	b := &s.buckets[bucketIndex(key)]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := s.storage.Set(ctx, key, value); err != nil {
+		return err
+	}
+	hash := hashKeyValue(key, value)
+	b.hash += hash - b.hashes[key]
+	b.hashes[key] = hash
+
+	return nil
+}
+
+func (s *Storage) Del(ctx context.Context, key string) error {
+	b := &s.buckets[bucketIndex(key)]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := s.storage.Del(ctx, key); err != nil {
+		return err
+	}
+	b.hash -= b.hashes[key]
+	delete(b.hashes, key)
+
+	return nil
+}
+
+func (s *Storage) Snapshot() map[string]string {
+	return s.storage.Snapshot()
+}
+
+func (s *Storage) Restore(values map[string]string) {
+	for i := range s.buckets {
+		s.buckets[i].mu.Lock()
+	}
+	defer func() {
+		for i := range s.buckets {
+			s.buckets[i].mu.Unlock()
+		}
+	}()
+
+	s.storage.Restore(values)
+	s.rebuild(values)
+}
+
+// Digest возвращает хеш всех ключей с префиксом prefix, не зависящий от
+// порядка их записи.
+func (s *Storage) Digest(prefix string) uint64 {
+	if prefix == "" {
+		hashes, _ := s.Children(0, 0)
+
+		return sum(hashes)
+	}
+
+	var digest uint64
+	for i := range s.buckets {
+		b := &s.buckets[i]
+		b.mu.Lock()
+		for key, hash := range b.hashes {
+			if strings.HasPrefix(key, prefix) {
+				digest += hash
+			}
+		}
+		b.mu.Unlock()
+	}
+
+	return digest
+}
+
+// Children возвращает хеши дочерних узлов узла index на уровне level.
+func (s *Storage) Children(level, index int) ([]uint64, error) {
+	if level < 0 || level >= LeafLevel || index < 0 || index >= nodeCount(level) {
+		return nil, fmt.Errorf("%w: level %d, index %d", ErrInvalidNode, level, index)
+	}
+
+	// количество листьев под каждым дочерним узлом
+	leaves := BucketCount / nodeCount(level+1)
+	hashes := make([]uint64, Fanout)
+	for child := 0; child < Fanout; child++ {
+		first := (index*Fanout + child) * leaves
+		for i := first; i < first+leaves; i++ {
+			b := &s.buckets[i]
+			b.mu.Lock()
+			hashes[child] += b.hash
+			b.mu.Unlock()
+		}
+	}
+
+	return hashes, nil
+}
+
+// Bucket возвращает хеши пар ключ-значение в корзине index.
+func (s *Storage) Bucket(index int) (map[string]uint64, error) {
+	if index < 0 || index >= BucketCount {
+		return nil, fmt.Errorf("%w: level %d, index %d", ErrInvalidNode, LeafLevel, index)
+	}
+
+	b := &s.buckets[index]
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	hashes := make(map[string]uint64, len(b.hashes))
+	for key, hash := range b.hashes {
+		hashes[key] = hash
+	}
+
+	return hashes, nil
+}
+
+// rebuild пересчитывает дерево. Вызывается под блокировкой всех корзин
+// или до начала работы с хранилищем.
+func (s *Storage) rebuild(values map[string]string) {
+	for i := range s.buckets {
+		s.buckets[i].hash = 0
+		s.buckets[i].hashes = make(map[string]uint64)
+	}
+	for key, value := range values {
+		b := &s.buckets[bucketIndex(key)]
+		hash := hashKeyValue(key, value)
+		b.hash += hash
+		b.hashes[key] = hash
+	}
+}
+
+func nodeCount(level int) int {
+	count := 1
+	for i := 0; i < level; i++ {
+		count *= Fanout
+	}
+
+	return count
+}
+
+func bucketIndex(key string) int {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+
+	return int(hash.Sum64() % BucketCount)
+}
+
+func hashKeyValue(key, value string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	hash.Write([]byte{0})
+	hash.Write([]byte(value))
+
+	return hash.Sum64()
+}
+
+func sum(hashes []uint64) uint64 {
+	var total uint64
+	for _, hash := range hashes {
+		total += hash
+	}
+
+	return total
+}
@@ -0,0 +1,75 @@
+package antientropy_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/antientropy"
+	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
+)
+
+func TestStorage_Digest_DoesNotDependOnWriteOrder(t *testing.T) {
+	first := antientropy.NewStorage(inmemory.NewMapStorage())
+	second := antientropy.NewStorage(inmemory.NewMapStorage())
+	for i := 0; i < 100; i++ {
+		require.NoError(t, first.Set(context.Background(), fmt.Sprintf("key%d", i), "value"))
+		require.NoError(t, second.Set(context.Background(), fmt.Sprintf("key%d", 99-i), "old"))
+	}
+	for i := 0; i < 100; i++ {
+		require.NoError(t, second.Set(context.Background(), fmt.Sprintf("key%d", i), "value"))
+	}
+	require.NoError(t, first.Set(context.Background(), "deleted", "value"))
+	require.NoError(t, first.Del(context.Background(), "deleted"))
+
+	assert.Equal(t, first.Digest(""), second.Digest(""))
+	assert.Equal(t, first.Digest("key1"), second.Digest("key1"))
+
+	require.NoError(t, second.Set(context.Background(), "key1", "changed"))
+	assert.NotEqual(t, first.Digest(""), second.Digest(""))
+	assert.NotEqual(t, first.Digest("key1"), second.Digest("key1"))
+	assert.Equal(t, first.Digest("key2"), second.Digest("key2"))
+}
+
+func TestStorage_Children_SumsUpToDigest(t *testing.T) {
+	storage := antientropy.NewStorage(inmemory.NewMapStorage())
+	for i := 0; i < 1000; i++ {
+		require.NoError(t, storage.Set(context.Background(), fmt.Sprintf("key%d", i), "value"))
+	}
+
+	var digest uint64
+	for index := 0; index < antientropy.Fanout; index++ {
+		children, err := storage.Children(1, index)
+		require.NoError(t, err)
+		for _, hash := range children {
+			digest += hash
+		}
+	}
+
+	assert.Equal(t, storage.Digest(""), digest)
+}
+
+func TestStorage_Restore_RebuildsTree(t *testing.T) {
+	storage := antientropy.NewStorage(inmemory.NewMapStorage())
+	require.NoError(t, storage.Set(context.Background(), "old", "value"))
+	expected := antientropy.NewStorage(inmemory.NewMapStorage())
+	require.NoError(t, expected.Set(context.Background(), "foo", "1"))
+	require.NoError(t, expected.Set(context.Background(), "bar", "2"))
+
+	storage.Restore(map[string]string{"foo": "1", "bar": "2"})
+
+	assert.Equal(t, expected.Digest(""), storage.Digest(""))
+}
+
+func TestStorage_Children_WhenInvalidNode_ExpectError(t *testing.T) {
+	storage := antientropy.NewStorage(inmemory.NewMapStorage())
+
+	_, err := storage.Children(antientropy.LeafLevel, 0)
+	assert.ErrorIs(t, err, antientropy.ErrInvalidNode)
+	_, err = storage.Children(1, antientropy.Fanout)
+	assert.ErrorIs(t, err, antientropy.ErrInvalidNode)
+	_, err = storage.Bucket(antientropy.BucketCount)
+	assert.ErrorIs(t, err, antientropy.ErrInvalidNode)
+}
@@ -2,35 +2,266 @@ package analyzing
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
+	"time"
 
 	"github.com/strider2038/key-value-database/internal/database/computation"
 	"github.com/strider2038/key-value-database/internal/database/querylang"
 )
 
+var (
+	lsnPattern    = regexp.MustCompile(`^\d{1,20}\.\d{1,20}$`)
+	numberPattern = regexp.MustCompile(`^\d{1,5}$`)
+	offsetPattern = regexp.MustCompile(`^\d{1,10}$`)
+)
+
 type Analyzer struct{}
 
 func NewAnalyzer() *Analyzer {
 	return &Analyzer{}
 }
 
+// AnalyzeCommand разбирает команду. Команда может начинаться с префикса
+// TIMEOUT <duration>, ограничивающего время ее выполнения, например,
+// "TIMEOUT 500ms SET key value".
 func (a *Analyzer) AnalyzeCommand(tokens []string) (*computation.Command, error) {
+	if len(tokens) > 0 && tokens[0] == "TIMEOUT" {
+		return analyzeTimeoutPrefix(tokens[1:])
+	}
+
+	return analyzeCommand(tokens)
+}
+
+func analyzeTimeoutPrefix(tokens []string) (*computation.Command, error) {
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("invalid \"TIMEOUT\" prefix: %w", ErrNotEnoughArguments)
+	}
+	timeout, err := time.ParseDuration(tokens[0])
+	if err != nil || timeout <= 0 {
+		return nil, fmt.Errorf("invalid \"TIMEOUT\" prefix: %w: duration must be positive, for example 500ms", ErrInvalidArgument)
+	}
+	if tokens[1] == "TIMEOUT" {
+		return nil, fmt.Errorf("invalid \"TIMEOUT\" prefix: %w: nested prefix", ErrInvalidArgument)
+	}
+
+	command, err := analyzeCommand(tokens[1:])
+	if err != nil {
+		return nil, err
+	}
+	command.Timeout = timeout
+
+	return command, nil
+}
+
+// CommandSyntax - описание команды для справки и подсказок клиента:
+// имя команды (с подкомандой, например "CLUSTER JOIN") и ее аргументы.
+type CommandSyntax struct {
+	Name      string
+	Arguments string
+}
+
+type commandDefinition struct {
+	CommandSyntax
+	analyze func(arguments []string) (*computation.Command, error)
+}
+
+// commandTable - команды, поддерживаемые анализатором. Команды из нескольких
+// слов образуют группы (CLUSTER, ACL, CLIENT), первое слово которых
+// не является самостоятельной командой.
+var commandTable = []commandDefinition{
+	{CommandSyntax{"GET", "<key>"}, fixedArguments(querylang.CommandGet, 1)},
+	{CommandSyntax{"SET", "<key> <value>"}, fixedArguments(querylang.CommandSet, 2)},
+	{CommandSyntax{"DEL", "<key>"}, fixedArguments(querylang.CommandDel, 1)},
+	{CommandSyntax{"GETRANGE", "<key> <offset> <length>"}, analyzeGetRangeCommand},
+	{CommandSyntax{"SETCHUNKED", "<key>"}, analyzeSetChunkedCommand},
+	{CommandSyntax{"CLUSTER JOIN", "<node_id> <address> <client_address>"}, fixedArguments(querylang.CommandClusterJoin, 3)},
+	{CommandSyntax{"CLUSTER LEAVE", "<node_id>"}, fixedArguments(querylang.CommandClusterLeave, 1)},
+	{CommandSyntax{"CLUSTER NODES", ""}, fixedArguments(querylang.CommandClusterNodes, 0)},
+	{CommandSyntax{"CLUSTER SLOTS", ""}, fixedArguments(querylang.CommandClusterSlots, 0)},
+	{CommandSyntax{"CLUSTER SETSLOT", "<slot> <address>"}, slotArguments(querylang.CommandClusterSetSlot, 2)},
+	{CommandSyntax{"CLUSTER IMPORT", "<slot> <key> <value>"}, slotArguments(querylang.CommandClusterImport, 3)},
+	{CommandSyntax{"CLUSTER IMPORTCHUNKED", "<slot> <key>"}, analyzeClusterImportChunkedCommand},
+	{CommandSyntax{"CLUSTER MIGRATE", "<slot> <address>"}, slotArguments(querylang.CommandClusterMigrate, 2)},
+	{CommandSyntax{"SUBSCRIBE-CHANGES", "[FROM <lsn>] [PREFIX <prefix>] [ORIGIN <node_id>]"}, analyzeSubscribeChangesCommand},
+	{CommandSyntax{"DIGEST", "[prefix]"}, analyzeDigestCommand},
+	{CommandSyntax{"MERKLE", "<level> <index>"}, analyzeMerkleCommand},
+	{CommandSyntax{"REPAIR", "<address>"}, fixedArguments(querylang.CommandRepair, 1)},
+	{CommandSyntax{"AUTH", "<user> <password>"}, fixedArguments(querylang.CommandAuth, 2)},
+	{CommandSyntax{"ACL WHOAMI", ""}, fixedArguments(querylang.CommandACLWhoAmI, 0)},
+	{CommandSyntax{"ACL LIST", ""}, fixedArguments(querylang.CommandACLList, 0)},
+	{CommandSyntax{"CLIENT LIST", ""}, fixedArguments(querylang.CommandClientList, 0)},
+	{CommandSyntax{"CLIENT SETNAME", "<name>"}, fixedArguments(querylang.CommandClientSetName, 1)},
+	{CommandSyntax{"CLIENT GETNAME", ""}, fixedArguments(querylang.CommandClientGetName, 0)},
+	{CommandSyntax{"CLIENT ID", ""}, fixedArguments(querylang.CommandClientID, 0)},
+	{CommandSyntax{"CLIENT KILL", "<id|address>"}, fixedArguments(querylang.CommandClientKill, 1)},
+}
+
+var (
+	commandsByName = make(map[string]commandDefinition, len(commandTable))
+	commandGroups  = make(map[string]bool)
+)
+
+func init() {
+	for _, definition := range commandTable {
+		commandsByName[definition.Name] = definition
+		if group, _, isSubcommand := strings.Cut(definition.Name, " "); isSubcommand {
+			commandGroups[group] = true
+		}
+	}
+}
+
+// Commands возвращает описания поддерживаемых команд. Префикс TIMEOUT
+// в список не входит.
+func Commands() []CommandSyntax {
+	commands := make([]CommandSyntax, len(commandTable))
+	for i, definition := range commandTable {
+		commands[i] = definition.CommandSyntax
+	}
+
+	return commands
+}
+
+func analyzeCommand(tokens []string) (*computation.Command, error) {
 	if len(tokens) == 0 {
 		return nil, ErrEmptyTokens
 	}
 
-	commandID := tokens[0]
+	name := tokens[0]
 	arguments := tokens[1:]
+	if commandGroups[name] {
+		if len(arguments) == 0 {
+			return nil, fmt.Errorf("invalid %q command: %w", name, ErrNotEnoughArguments)
+		}
+		name += " " + arguments[0]
+		arguments = arguments[1:]
+	}
+
+	definition, exists := commandsByName[name]
+	if !exists {
+		return nil, ErrUnknownCommand
+	}
+
+	return definition.analyze(arguments)
+}
+
+// analyzeSubscribeChangesCommand разбирает команду
+// SUBSCRIBE-CHANGES [FROM lsn] [PREFIX p] [ORIGIN node].
+// Аргументы команды приводятся к виду [lsn, prefix, node], отсутствующие опции - пустые строки.
+func analyzeSubscribeChangesCommand(arguments []string) (*computation.Command, error) {
+	if len(arguments)%2 != 0 {
+		return nil, fmt.Errorf("invalid %q command: %w", querylang.CommandSubscribeChanges, ErrNotEnoughArguments)
+	}
 
-	switch commandID {
-	case "GET":
-		return newCommand(querylang.CommandGet, 1, arguments)
-	case "SET":
-		return newCommand(querylang.CommandSet, 2, arguments)
-	case "DEL":
-		return newCommand(querylang.CommandDel, 1, arguments)
+	options := map[string]string{}
+	for i := 0; i < len(arguments); i += 2 {
+		option, value := arguments[i], arguments[i+1]
+		if option != "FROM" && option != "PREFIX" && option != "ORIGIN" {
+			return nil, fmt.Errorf("invalid %q command: %w: unknown option %q", querylang.CommandSubscribeChanges, ErrInvalidArgument, option)
+		}
+		if _, exists := options[option]; exists {
+			return nil, fmt.Errorf("invalid %q command: %w: duplicate option %q", querylang.CommandSubscribeChanges, ErrInvalidArgument, option)
+		}
+		if option == "FROM" && !lsnPattern.MatchString(value) {
+			return nil, fmt.Errorf("invalid %q command: %w: LSN must be in format <session_id>.<seq_id>", querylang.CommandSubscribeChanges, ErrInvalidArgument)
+		}
+		options[option] = value
+	}
+
+	return &computation.Command{
+		ID:        querylang.CommandSubscribeChanges,
+		Arguments: []string{options["FROM"], options["PREFIX"], options["ORIGIN"]},
+	}, nil
+}
+
+// analyzeDigestCommand разбирает команду DIGEST [prefix]. Аргументы команды
+// приводятся к виду [prefix], отсутствующий префикс - пустая строка.
+func analyzeDigestCommand(arguments []string) (*computation.Command, error) {
+	if len(arguments) == 0 {
+		arguments = []string{""}
+	}
+
+	return newCommand(querylang.CommandDigest, 1, arguments)
+}
+
+// analyzeMerkleCommand разбирает команду MERKLE <level> <index>.
+func analyzeMerkleCommand(arguments []string) (*computation.Command, error) {
+	command, err := newCommand(querylang.CommandMerkle, 2, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if !numberPattern.MatchString(arguments[0]) || !numberPattern.MatchString(arguments[1]) {
+		return nil, fmt.Errorf("invalid %q command: %w: level and index must be numbers", querylang.CommandMerkle, ErrInvalidArgument)
+	}
+
+	return command, nil
+}
+
+// analyzeGetRangeCommand разбирает команду GETRANGE <key> <offset> <length>,
+// которая возвращает часть значения длиной не более length байт.
+func analyzeGetRangeCommand(arguments []string) (*computation.Command, error) {
+	command, err := newCommand(querylang.CommandGetRange, 3, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if !offsetPattern.MatchString(arguments[1]) || !offsetPattern.MatchString(arguments[2]) {
+		return nil, fmt.Errorf("invalid %q command: %w: offset and length must be numbers", querylang.CommandGetRange, ErrInvalidArgument)
+	}
+
+	return command, nil
+}
+
+// analyzeSetChunkedCommand разбирает команду SETCHUNKED <key>. Значение
+// передается отдельно от строки запроса и добавляется к аргументам позже,
+// после чего команда выполняется как SET.
+func analyzeSetChunkedCommand(arguments []string) (*computation.Command, error) {
+	command, err := newCommand(querylang.CommandSet, 1, arguments)
+	if err != nil {
+		return nil, err
+	}
+	command.ValueExpected = true
+
+	return command, nil
+}
+
+// analyzeClusterImportChunkedCommand разбирает команду CLUSTER IMPORTCHUNKED
+// <slot> <key>. Как и для SETCHUNKED, значение передается отдельно от строки
+// запроса, после чего команда выполняется как CLUSTER IMPORT.
+func analyzeClusterImportChunkedCommand(arguments []string) (*computation.Command, error) {
+	command, err := newSlotCommand(querylang.CommandClusterImport, 2, arguments)
+	if err != nil {
+		return nil, err
+	}
+	command.ValueExpected = true
+
+	return command, nil
+}
+
+// fixedArguments разбирает команду с фиксированным количеством аргументов.
+func fixedArguments(id querylang.CommandID, argumentsCount int) func(arguments []string) (*computation.Command, error) {
+	return func(arguments []string) (*computation.Command, error) {
+		return newCommand(id, argumentsCount, arguments)
+	}
+}
+
+// slotArguments разбирает команду, первый аргумент которой - номер хеш-слота.
+func slotArguments(id querylang.CommandID, argumentsCount int) func(arguments []string) (*computation.Command, error) {
+	return func(arguments []string) (*computation.Command, error) {
+		return newSlotCommand(id, argumentsCount, arguments)
+	}
+}
+
+// newSlotCommand создает команду, первый аргумент которой - номер хеш-слота.
+func newSlotCommand(id querylang.CommandID, argumentsCount int, arguments []string) (*computation.Command, error) {
+	command, err := newCommand(id, argumentsCount, arguments)
+	if err != nil {
+		return nil, err
+	}
+	if !numberPattern.MatchString(arguments[0]) {
+		return nil, fmt.Errorf("invalid %q command: %w: slot must be a number", id, ErrInvalidArgument)
 	}
 
-	return nil, ErrUnknownCommand
+	return command, nil
 }
 
 func newCommand(id querylang.CommandID, argumentsCount int, arguments []string) (*computation.Command, error) {
@@ -3,6 +3,7 @@ package analyzing_test
 import (
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -16,6 +17,8 @@ func TestAnalyzer_AnalyzeCommand(t *testing.T) {
 		tokens        []string
 		wantCommand   querylang.CommandID
 		wantArguments []string
+		wantTimeout   time.Duration
+		wantValue     bool
 		wantError     error
 	}{
 		{
@@ -60,6 +63,46 @@ func TestAnalyzer_AnalyzeCommand(t *testing.T) {
 			tokens:    strings.Fields("SET key1 key2 key3"),
 			wantError: analyzing.ErrTooMuchArguments,
 		},
+		{
+			name:          "getrange command: valid",
+			tokens:        strings.Fields("GETRANGE key1 1024 512"),
+			wantCommand:   querylang.CommandGetRange,
+			wantArguments: []string{"key1", "1024", "512"},
+		},
+		{
+			name:      "getrange command: invalid offset",
+			tokens:    strings.Fields("GETRANGE key1 -1 512"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "getrange command: not enough arguments",
+			tokens:    strings.Fields("GETRANGE key1 0"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:          "setchunked command: valid",
+			tokens:        strings.Fields("SETCHUNKED key1"),
+			wantCommand:   querylang.CommandSet,
+			wantArguments: []string{"key1"},
+			wantValue:     true,
+		},
+		{
+			name:      "setchunked command: too much arguments",
+			tokens:    strings.Fields("SETCHUNKED key1 value1"),
+			wantError: analyzing.ErrTooMuchArguments,
+		},
+		{
+			name:          "cluster importchunked command: valid",
+			tokens:        strings.Fields("CLUSTER IMPORTCHUNKED 12182 foo"),
+			wantCommand:   querylang.CommandClusterImport,
+			wantArguments: []string{"12182", "foo"},
+			wantValue:     true,
+		},
+		{
+			name:      "cluster importchunked command: invalid slot",
+			tokens:    strings.Fields("CLUSTER IMPORTCHUNKED foo bar"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
 		{
 			name:          "del command: valid",
 			tokens:        strings.Fields("DEL key1"),
@@ -76,6 +119,211 @@ func TestAnalyzer_AnalyzeCommand(t *testing.T) {
 			tokens:    strings.Fields("DEL key1 key2"),
 			wantError: analyzing.ErrTooMuchArguments,
 		},
+		{
+			name:          "cluster join command: valid",
+			tokens:        strings.Fields("CLUSTER JOIN node2 127.0.0.1:4002 127.0.0.1:3002"),
+			wantCommand:   querylang.CommandClusterJoin,
+			wantArguments: []string{"node2", "127.0.0.1:4002", "127.0.0.1:3002"},
+		},
+		{
+			name:          "cluster leave command: valid",
+			tokens:        strings.Fields("CLUSTER LEAVE node2"),
+			wantCommand:   querylang.CommandClusterLeave,
+			wantArguments: []string{"node2"},
+		},
+		{
+			name:          "cluster nodes command: valid",
+			tokens:        strings.Fields("CLUSTER NODES"),
+			wantCommand:   querylang.CommandClusterNodes,
+			wantArguments: []string{},
+		},
+		{
+			name:          "cluster slots command: valid",
+			tokens:        strings.Fields("CLUSTER SLOTS"),
+			wantCommand:   querylang.CommandClusterSlots,
+			wantArguments: []string{},
+		},
+		{
+			name:          "cluster migrate command: valid",
+			tokens:        strings.Fields("CLUSTER MIGRATE 1234 127.0.0.1:3002"),
+			wantCommand:   querylang.CommandClusterMigrate,
+			wantArguments: []string{"1234", "127.0.0.1:3002"},
+		},
+		{
+			name:          "cluster import command: valid",
+			tokens:        strings.Fields("CLUSTER IMPORT 12182 foo value"),
+			wantCommand:   querylang.CommandClusterImport,
+			wantArguments: []string{"12182", "foo", "value"},
+		},
+		{
+			name:          "cluster setslot command: valid",
+			tokens:        strings.Fields("CLUSTER SETSLOT 0 127.0.0.1:3002"),
+			wantCommand:   querylang.CommandClusterSetSlot,
+			wantArguments: []string{"0", "127.0.0.1:3002"},
+		},
+		{
+			name:      "cluster migrate command: invalid slot",
+			tokens:    strings.Fields("CLUSTER MIGRATE foo 127.0.0.1:3002"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:          "digest command: no prefix",
+			tokens:        strings.Fields("DIGEST"),
+			wantCommand:   querylang.CommandDigest,
+			wantArguments: []string{""},
+		},
+		{
+			name:          "digest command: prefix",
+			tokens:        strings.Fields("DIGEST user:"),
+			wantCommand:   querylang.CommandDigest,
+			wantArguments: []string{"user:"},
+		},
+		{
+			name:      "digest command: too much arguments",
+			tokens:    strings.Fields("DIGEST a b"),
+			wantError: analyzing.ErrTooMuchArguments,
+		},
+		{
+			name:          "merkle command: valid",
+			tokens:        strings.Fields("MERKLE 2 255"),
+			wantCommand:   querylang.CommandMerkle,
+			wantArguments: []string{"2", "255"},
+		},
+		{
+			name:      "merkle command: invalid index",
+			tokens:    strings.Fields("MERKLE 2 root"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:          "repair command: valid",
+			tokens:        strings.Fields("REPAIR 127.0.0.1:3434"),
+			wantCommand:   querylang.CommandRepair,
+			wantArguments: []string{"127.0.0.1:3434"},
+		},
+		{
+			name:      "cluster command: no subcommand",
+			tokens:    strings.Fields("CLUSTER"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:          "subscribe changes command: no options",
+			tokens:        strings.Fields("SUBSCRIBE-CHANGES"),
+			wantCommand:   querylang.CommandSubscribeChanges,
+			wantArguments: []string{"", "", ""},
+		},
+		{
+			name:          "subscribe changes command: all options",
+			tokens:        strings.Fields("SUBSCRIBE-CHANGES PREFIX user: FROM 1700000000000.15 ORIGIN dc-1"),
+			wantCommand:   querylang.CommandSubscribeChanges,
+			wantArguments: []string{"1700000000000.15", "user:", "dc-1"},
+		},
+		{
+			name:      "subscribe changes command: option without value",
+			tokens:    strings.Fields("SUBSCRIBE-CHANGES FROM"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:      "subscribe changes command: invalid LSN",
+			tokens:    strings.Fields("SUBSCRIBE-CHANGES FROM 15"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "subscribe changes command: unknown option",
+			tokens:    strings.Fields("SUBSCRIBE-CHANGES TO 1.1"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "subscribe changes command: duplicate option",
+			tokens:    strings.Fields("SUBSCRIBE-CHANGES PREFIX a PREFIX b"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:          "auth command: valid",
+			tokens:        strings.Fields("AUTH alice secret"),
+			wantCommand:   querylang.CommandAuth,
+			wantArguments: []string{"alice", "secret"},
+		},
+		{
+			name:      "auth command: not enough arguments",
+			tokens:    strings.Fields("AUTH alice"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:          "acl whoami command: valid",
+			tokens:        strings.Fields("ACL WHOAMI"),
+			wantCommand:   querylang.CommandACLWhoAmI,
+			wantArguments: []string{},
+		},
+		{
+			name:          "acl list command: valid",
+			tokens:        strings.Fields("ACL LIST"),
+			wantCommand:   querylang.CommandACLList,
+			wantArguments: []string{},
+		},
+		{
+			name:      "acl command: unknown subcommand",
+			tokens:    strings.Fields("ACL SETUSER alice"),
+			wantError: analyzing.ErrUnknownCommand,
+		},
+		{
+			name:          "timeout prefix: valid",
+			tokens:        strings.Fields("TIMEOUT 500ms SET key1 value1"),
+			wantCommand:   querylang.CommandSet,
+			wantArguments: []string{"key1", "value1"},
+			wantTimeout:   500 * time.Millisecond,
+		},
+		{
+			name:      "timeout prefix: no command",
+			tokens:    strings.Fields("TIMEOUT 1s"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:      "timeout prefix: invalid duration",
+			tokens:    strings.Fields("TIMEOUT 10 GET key1"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "timeout prefix: negative duration",
+			tokens:    strings.Fields("TIMEOUT -1s GET key1"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "timeout prefix: nested prefix",
+			tokens:    strings.Fields("TIMEOUT 1s TIMEOUT 2s GET key1"),
+			wantError: analyzing.ErrInvalidArgument,
+		},
+		{
+			name:      "timeout prefix: invalid command",
+			tokens:    strings.Fields("TIMEOUT 1s GET"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:          "client setname command: valid",
+			tokens:        strings.Fields("CLIENT SETNAME worker"),
+			wantCommand:   querylang.CommandClientSetName,
+			wantArguments: []string{"worker"},
+		},
+		{
+			name:          "client kill command: valid",
+			tokens:        strings.Fields("CLIENT KILL 127.0.0.1:50000"),
+			wantCommand:   querylang.CommandClientKill,
+			wantArguments: []string{"127.0.0.1:50000"},
+		},
+		{
+			name:      "client kill command: not enough arguments",
+			tokens:    strings.Fields("CLIENT KILL"),
+			wantError: analyzing.ErrNotEnoughArguments,
+		},
+		{
+			name:      "client command: unknown subcommand",
+			tokens:    strings.Fields("CLIENT FOO"),
+			wantError: analyzing.ErrUnknownCommand,
+		},
+		{
+			name:      "cluster command: unknown subcommand",
+			tokens:    strings.Fields("CLUSTER FOO"),
+			wantError: analyzing.ErrUnknownCommand,
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -87,6 +335,8 @@ func TestAnalyzer_AnalyzeCommand(t *testing.T) {
 				require.NoError(t, err)
 				assert.Equal(t, test.wantCommand.String(), command.ID.String())
 				assert.Equal(t, test.wantArguments, command.Arguments)
+				assert.Equal(t, test.wantTimeout, command.Timeout)
+				assert.Equal(t, test.wantValue, command.ValueExpected)
 			} else {
 				assert.Nil(t, command)
 				assert.ErrorIs(t, err, test.wantError)
@@ -94,3 +344,13 @@ func TestAnalyzer_AnalyzeCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestCommands_ExpectEveryCommandRecognizedByAnalyzer(t *testing.T) {
+	analyzer := analyzing.NewAnalyzer()
+
+	for _, command := range analyzing.Commands() {
+		_, err := analyzer.AnalyzeCommand(strings.Fields(command.Name))
+
+		assert.NotErrorIs(t, err, analyzing.ErrUnknownCommand, command.Name)
+	}
+}
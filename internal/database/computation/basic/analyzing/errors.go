@@ -7,4 +7,5 @@ var (
 	ErrUnknownCommand     = errors.New("unknown command")
 	ErrNotEnoughArguments = errors.New("not enough arguments")
 	ErrTooMuchArguments   = errors.New("too much arguments")
+	ErrInvalidArgument    = errors.New("invalid argument")
 )
@@ -40,3 +40,14 @@ func (c *Computer) ParseRequest(request string) (*computation.Command, error) {
 
 	return command, nil
 }
+
+// ParseArguments создает команду из уже разделенных аргументов, например,
+// полученных по протоколу RESP. Аргументы могут содержать пробелы.
+func (c *Computer) ParseArguments(arguments []string) (*computation.Command, error) {
+	command, err := c.analyzer.AnalyzeCommand(arguments)
+	if err != nil {
+		return nil, fmt.Errorf("analyze command: %w", err)
+	}
+
+	return command, nil
+}
@@ -34,6 +34,22 @@ func TestParser_ParseCommand(t *testing.T) {
 			input:      "digits123 letters punctuation*/_",
 			wantTokens: []string{"digits123", "letters", "punctuation*/_"},
 		},
+		{
+			input:      "CLUSTER JOIN node-2 127.0.0.1:4001",
+			wantTokens: []string{"CLUSTER", "JOIN", "node-2", "127.0.0.1:4001"},
+		},
+		{
+			input:      "GET user:42.profile-v2",
+			wantTokens: []string{"GET", "user:42.profile-v2"},
+		},
+		{
+			input:      "- . :",
+			wantTokens: []string{"-", ".", ":"},
+		},
+		{
+			input:     "GET key,other",
+			wantError: parsing.ErrUnexpectedSymbol,
+		},
 		{
 			input:     "unexpected =",
 			wantError: parsing.ErrUnexpectedSymbol,
@@ -55,3 +71,10 @@ func TestParser_ParseCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestIsPlainToken(t *testing.T) {
+	assert.True(t, parsing.IsPlainToken("key_1/path:2.3-*"))
+	assert.False(t, parsing.IsPlainToken(""))
+	assert.False(t, parsing.IsPlainToken("two words"))
+	assert.False(t, parsing.IsPlainToken("line\nbreak"))
+}
@@ -103,5 +103,21 @@ func isSymbol(c rune) bool {
 	return c >= '0' && c <= '9' ||
 		c >= 'a' && c <= 'z' ||
 		c >= 'A' && c <= 'Z' ||
-		c == '*' || c == '_' || c == '/'
+		c == '*' || c == '_' || c == '/' ||
+		c == '.' || c == ':' || c == '-'
+}
+
+// IsPlainToken проверяет, что строку можно передать в запросе одним
+// аргументом: она не пуста и состоит только из допустимых символов.
+func IsPlainToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, c := range token {
+		if !isSymbol(c) {
+			return false
+		}
+	}
+
+	return true
 }
@@ -1,8 +1,18 @@
 package computation
 
-import "github.com/strider2038/key-value-database/internal/database/querylang"
+import (
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
 
 type Command struct {
 	ID        querylang.CommandID
 	Arguments []string
+	// Timeout - ограничение времени выполнения команды, заданное клиентом
+	// префиксом TIMEOUT. Нулевое значение - без ограничения.
+	Timeout time.Duration
+	// ValueExpected - последний аргумент команды (значение) передается отдельно
+	// от строки запроса, например, по частям для команды SETCHUNKED.
+	ValueExpected bool
 }
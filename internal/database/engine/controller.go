@@ -2,37 +2,84 @@ package engine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/strider2038/key-value-database/internal/database/acl"
 	"github.com/strider2038/key-value-database/internal/database/computation"
+	"github.com/strider2038/key-value-database/internal/database/network"
 	"github.com/strider2038/key-value-database/internal/database/querylang"
 )
 
+// loggedValueSize - максимальный размер значения, записываемого в журнал целиком.
+const loggedValueSize = 256
+
 type RequestParser interface {
 	ParseRequest(request string) (*computation.Command, error)
+	ParseArguments(arguments []string) (*computation.Command, error)
 }
 
+// StorageController выполняет команды над данными. Отмена контекста или
+// истечение его срока прерывает ожидание выполнения команды.
 type StorageController interface {
-	Execute(command *querylang.Command) (string, error)
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
+}
+
+// ChangeFeed - источник потока изменений для команды SUBSCRIBE-CHANGES.
+type ChangeFeed interface {
+	StreamChanges(ctx context.Context, from, prefix, origin string, send func(change string) error) error
+}
+
+// AccessControl проверяет учетные данные пользователей и их права на выполнение команд.
+type AccessControl interface {
+	Authenticate(name, password string) (*acl.User, error)
+	Authorize(user *acl.User, command *querylang.Command) error
+	IsAdmin(user *acl.User) bool
+	List() []string
+}
+
+// ClientRegistry - реестр клиентских соединений для команд CLIENT LIST и CLIENT KILL.
+type ClientRegistry interface {
+	List() []network.ClientInfo
+	Kill(id uint64) bool
+	KillByAddress(address string) int
 }
 
 type Controller struct {
 	requestParser     RequestParser
 	storageController StorageController
-	idGenerator       IDGenerator
+	changeFeed        ChangeFeed
+	accessControl     AccessControl
+	clients           ClientRegistry
+	idGenerator       *IDGenerator
 	logger            *slog.Logger
 }
 
+// NewController создает контроллер. Параметр changeFeed может быть nil,
+// тогда команда SUBSCRIBE-CHANGES недоступна. Если accessControl равен nil,
+// аутентификация не требуется и команды AUTH и ACL недоступны. Если clients
+// равен nil, недоступны команды CLIENT LIST и CLIENT KILL. Генератор
+// idGenerator может использоваться и другими компонентами, создающими команды.
 func NewController(
 	requestParser RequestParser,
 	storageController StorageController,
+	changeFeed ChangeFeed,
+	accessControl AccessControl,
+	clients ClientRegistry,
+	idGenerator *IDGenerator,
 	logger *slog.Logger,
 ) *Controller {
 	return &Controller{
 		requestParser:     requestParser,
 		storageController: storageController,
+		changeFeed:        changeFeed,
+		accessControl:     accessControl,
+		clients:           clients,
+		idGenerator:       idGenerator,
 		logger:            logger,
 	}
 }
@@ -40,12 +87,165 @@ func NewController(
 func (c *Controller) Execute(ctx context.Context, rawCommand string) (string, error) {
 	start := time.Now()
 
-	command, err := c.parseCommand(rawCommand)
+	parsedCommand, err := c.requestParser.ParseRequest(rawCommand)
+	if err != nil {
+		return "", &BadRequestError{err: fmt.Errorf("parse command: %w", err)}
+	}
+	if parsedCommand.ValueExpected {
+		return "", &BadRequestError{err: ErrValueExpected}
+	}
+	ctx, cancel := withTimeout(ctx, parsedCommand.Timeout)
+	defer cancel()
+
+	return c.execute(ctx, c.newCommand(parsedCommand, start, slog.String("rawCommand", rawCommand)), start)
+}
+
+// ExecuteWithValue выполняет команду, значение которой передано отдельно
+// от строки запроса (SETCHUNKED <key>, CLUSTER IMPORTCHUNKED <slot> <key>).
+// Значение не разбирается парсером, поэтому может содержать любые байты
+// и превышать размер сообщения.
+func (c *Controller) ExecuteWithValue(ctx context.Context, rawCommand string, value string) (string, error) {
+	start := time.Now()
+
+	parsedCommand, err := c.requestParser.ParseRequest(rawCommand)
+	if err != nil {
+		return "", &BadRequestError{err: fmt.Errorf("parse command: %w", err)}
+	}
+	if !parsedCommand.ValueExpected {
+		return "", &BadRequestError{err: ErrUnexpectedValue}
+	}
+	parsedCommand.Arguments = append(parsedCommand.Arguments, value)
+	ctx, cancel := withTimeout(ctx, parsedCommand.Timeout)
+	defer cancel()
+
+	return c.execute(ctx, c.newCommand(parsedCommand, start, slog.String("rawCommand", rawCommand)), start)
+}
+
+// ExecuteArguments выполняет команду, заданную списком аргументов, без разбора
+// строки запроса. Первый аргумент - название команды.
+func (c *Controller) ExecuteArguments(ctx context.Context, arguments []string) (string, error) {
+	start := time.Now()
+
+	parsedCommand, err := c.requestParser.ParseArguments(arguments)
 	if err != nil {
-		return "", &BadRequestError{err: err}
+		return "", &BadRequestError{err: fmt.Errorf("parse command: %w", err)}
+	}
+	if parsedCommand.ValueExpected {
+		return "", &BadRequestError{err: ErrValueExpected}
+	}
+	ctx, cancel := withTimeout(ctx, parsedCommand.Timeout)
+	defer cancel()
+
+	return c.execute(ctx, c.newCommand(parsedCommand, start, slog.Any("rawArguments", arguments)), start)
+}
+
+// withTimeout ограничивает время выполнения команды, если клиент задал
+// префикс TIMEOUT.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// AccessControlEnabled сообщает, требуется ли аутентификация для выполнения команд.
+func (c *Controller) AccessControlEnabled() bool {
+	return c.accessControl != nil
+}
+
+// Authenticate проверяет учетные данные и привязывает пользователя к сессии
+// из контекста. Используется сервисами, передающими учетные данные вне
+// команды AUTH (например, HTTP Basic).
+func (c *Controller) Authenticate(ctx context.Context, name, password string) error {
+	if c.accessControl == nil {
+		return &BadRequestError{err: ErrAccessControlDisabled}
+	}
+	session, ok := sessionFromContext(ctx)
+	if !ok {
+		return &BadRequestError{err: ErrSessionNotSupported}
 	}
 
-	result, err := c.storageController.Execute(command)
+	user, err := c.accessControl.Authenticate(name, password)
+	if err != nil {
+		c.logger.Warn("authentication failed", slog.String("user", name))
+
+		return err
+	}
+	session.setUser(user)
+
+	return nil
+}
+
+// AuthorizeAdmin проверяет, что пользователь сессии из контекста имеет права
+// на команды категории admin. Используется для служебных ресурсов, доступ
+// к которым не связан с командами (например, метрик HTTP шлюза). Если контроль
+// доступа выключен, доступ разрешен.
+func (c *Controller) AuthorizeAdmin(ctx context.Context) error {
+	if c.accessControl == nil {
+		return nil
+	}
+
+	user := c.sessionUser(ctx)
+	if user == nil {
+		return acl.ErrNotAuthenticated
+	}
+	if !c.accessControl.IsAdmin(user) {
+		c.logger.Warn("admin access rejected", slog.String("user", user.Name()))
+
+		return fmt.Errorf("%w: user %q has no admin access", acl.ErrForbidden, user.Name())
+	}
+
+	return nil
+}
+
+func (c *Controller) execute(ctx context.Context, command *querylang.Command, start time.Time) (string, error) {
+	if client, ok := network.ClientFromContext(ctx); ok {
+		client.SetLastCommand(command.ID().String())
+	}
+
+	switch command.ID() {
+	case querylang.CommandAuth:
+		arguments := command.Arguments()
+		err := c.Authenticate(ctx, arguments[0], arguments[1])
+		if c.reservedSession(ctx) && (err != nil || !c.accessControl.IsAdmin(c.sessionUser(ctx))) {
+			// резервное место не остается за пользователем без прав admin
+			c.closeReservedConnection(ctx)
+			if err == nil {
+				err = ErrReservedConnection
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+
+		return "OK", nil
+	case querylang.CommandACLWhoAmI, querylang.CommandACLList:
+		if c.accessControl == nil {
+			return "", &BadRequestError{err: ErrAccessControlDisabled}
+		}
+	}
+
+	if err := c.authorize(ctx, command); err != nil {
+		return "", err
+	}
+
+	switch command.ID() {
+	case querylang.CommandACLWhoAmI:
+		return c.sessionUser(ctx).Name(), nil
+	case querylang.CommandACLList:
+		return strings.Join(c.accessControl.List(), "\n"), nil
+	case querylang.CommandSubscribeChanges:
+		return "", c.streamChanges(ctx, command)
+	case querylang.CommandClientList,
+		querylang.CommandClientSetName,
+		querylang.CommandClientGetName,
+		querylang.CommandClientID,
+		querylang.CommandClientKill:
+		return c.executeClientCommand(ctx, command)
+	}
+
+	result, err := c.storageController.Execute(ctx, command)
 	if err != nil {
 		c.logger.Error("command execution failed", "seqID", command.SeqID(), "error", err)
 
@@ -57,32 +257,187 @@ func (c *Controller) Execute(ctx context.Context, rawCommand string) (string, er
 		slog.Uint64("seqID", command.SeqID()),
 		slog.Duration("duration", time.Since(start)),
 		slog.String("commandID", command.ID().String()),
-		slog.Any("commandArgs", command.Arguments()),
+		slog.Any("commandArgs", loggedArguments(command)),
 	)
 
 	return result, nil
 }
 
-func (c *Controller) parseCommand(rawCommand string) (*querylang.Command, error) {
-	start := time.Now()
+// authorize отклоняет команду, если пользователь сессии не аутентифицирован
+// или не имеет прав на ее выполнение. В резервных соединениях команды
+// доступны только администраторам, соединение другого клиента закрывается
+// после первой команды.
+func (c *Controller) authorize(ctx context.Context, command *querylang.Command) error {
+	if c.accessControl == nil {
+		if c.reservedSession(ctx) {
+			c.closeReservedConnection(ctx)
 
-	parsedCommand, err := c.requestParser.ParseRequest(rawCommand)
+			return ErrReservedConnection
+		}
+
+		return nil
+	}
+
+	user := c.sessionUser(ctx)
+	err := c.accessControl.Authorize(user, command)
+	if c.reservedSession(ctx) && !c.accessControl.IsAdmin(user) {
+		c.closeReservedConnection(ctx)
+		err = ErrReservedConnection
+	}
 	if err != nil {
-		return nil, fmt.Errorf("parse command: %w", err)
+		userName := ""
+		if user != nil {
+			userName = user.Name()
+		}
+		c.logger.Warn(
+			"command rejected",
+			slog.Uint64("seqID", command.SeqID()),
+			slog.String("commandID", command.ID().String()),
+			slog.String("user", userName),
+		)
+
+		return err
 	}
 
+	return nil
+}
+
+// closeReservedConnection закрывает резервное соединение после ответа
+// на текущий запрос, освобождая место для администраторов.
+func (c *Controller) closeReservedConnection(ctx context.Context) {
+	if client, ok := network.ClientFromContext(ctx); ok {
+		c.logger.Warn("reserved connection closed", slog.Uint64("client", client.ID()))
+		client.Kill()
+	}
+}
+
+func (c *Controller) reservedSession(ctx context.Context) bool {
+	session, ok := sessionFromContext(ctx)
+
+	return ok && session.Reserved()
+}
+
+func (c *Controller) sessionUser(ctx context.Context) *acl.User {
+	session, ok := sessionFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return session.User()
+}
+
+// executeClientCommand выполняет команды CLIENT. Команды SETNAME, GETNAME
+// и ID относятся к соединению, в котором выполняется запрос.
+func (c *Controller) executeClientCommand(ctx context.Context, command *querylang.Command) (string, error) {
+	switch command.ID() {
+	case querylang.CommandClientList:
+		if c.clients == nil {
+			return "", &BadRequestError{err: ErrClientNotSupported}
+		}
+		clients := c.clients.List()
+		lines := make([]string, len(clients))
+		for i, client := range clients {
+			lines[i] = client.String()
+		}
+
+		return strings.Join(lines, "\n"), nil
+	case querylang.CommandClientKill:
+		if c.clients == nil {
+			return "", &BadRequestError{err: ErrClientNotSupported}
+		}
+		target := command.Arguments()[0]
+		killed := false
+		if id, err := strconv.ParseUint(target, 10, 64); err == nil {
+			killed = c.clients.Kill(id)
+		} else {
+			killed = c.clients.KillByAddress(target) > 0
+		}
+		if !killed {
+			return "", &BadRequestError{err: ErrClientNotFound}
+		}
+		c.logger.Info("client connection killed", slog.Uint64("seqID", command.SeqID()), slog.String("client", target))
+
+		return "OK", nil
+	}
+
+	client, ok := network.ClientFromContext(ctx)
+	if !ok {
+		return "", &BadRequestError{err: ErrClientNotSupported}
+	}
+	switch command.ID() {
+	case querylang.CommandClientSetName:
+		client.SetName(command.Arguments()[0])
+
+		return "OK", nil
+	case querylang.CommandClientGetName:
+		if client.Name() == "" {
+			return querylang.Nil, nil
+		}
+
+		return client.Name(), nil
+	default:
+		return strconv.FormatUint(client.ID(), 10), nil
+	}
+}
+
+// streamChanges отправляет клиенту поток изменений до отключения клиента
+// или остановки сервера.
+func (c *Controller) streamChanges(ctx context.Context, command *querylang.Command) error {
+	if c.changeFeed == nil {
+		return &BadRequestError{err: ErrChangeFeedDisabled}
+	}
+	send, ok := senderFromContext(ctx)
+	if !ok {
+		return &BadRequestError{err: ErrStreamingNotSupported}
+	}
+
+	c.logger.Info(
+		"changes subscription started",
+		slog.Uint64("seqID", command.SeqID()),
+		slog.Any("commandArgs", command.Arguments()),
+	)
+
+	arguments := command.Arguments()
+	err := c.changeFeed.StreamChanges(ctx, arguments[0], arguments[1], arguments[2], send)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		return fmt.Errorf("stream changes: %w", err)
+	}
+
+	c.logger.Info("changes subscription finished", slog.Uint64("seqID", command.SeqID()))
+
+	return nil
+}
+
+func (c *Controller) newCommand(parsedCommand *computation.Command, start time.Time, request slog.Attr) *querylang.Command {
 	seqID := c.idGenerator.NextSeqID()
 	command := querylang.NewCommand(seqID, parsedCommand.ID, parsedCommand.Arguments...)
+	if command.ID() == querylang.CommandAuth {
+		// пароль не должен попадать в журнал
+		request = slog.String(request.Key, "AUTH")
+	}
 
 	c.logger.
 		With(
 			slog.Uint64("seqID", command.SeqID()),
-			slog.String("rawCommand", rawCommand),
+			request,
 			slog.Duration("duration", time.Since(start)),
 			slog.String("commandID", command.ID().String()),
-			slog.Any("commandArgs", command.Arguments()),
+			slog.Any("commandArgs", loggedArguments(command)),
 		).
 		Debug("command parsing completed")
 
-	return command, nil
+	return command
+}
+
+// loggedArguments возвращает аргументы команды для записи в журнал без паролей.
+func loggedArguments(command *querylang.Command) []string {
+	if command.ID() == querylang.CommandAuth {
+		return command.Arguments()[:1]
+	}
+	// значение, переданное по частям, может быть большим и двоичным
+	if command.ID() == querylang.CommandSet && len(command.Arguments()[1]) > loggedValueSize {
+		return []string{command.Arguments()[0], fmt.Sprintf("<%d bytes>", len(command.Arguments()[1]))}
+	}
+
+	return command.Arguments()
 }
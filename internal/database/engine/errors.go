@@ -1,6 +1,21 @@
 package engine
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrChangeFeedDisabled    = errors.New("changes subscription requires WAL to be enabled")
+	ErrStreamingNotSupported = errors.New("streaming is not supported by connection")
+	ErrAccessControlDisabled = errors.New("access control is disabled")
+	ErrSessionNotSupported   = errors.New("sessions are not supported by connection")
+	ErrReservedConnection    = errors.New("too many connections: reserved connections are available only to admin users")
+	ErrValueExpected         = errors.New("command expects a value transferred in chunks")
+	ErrUnexpectedValue       = errors.New("value transferred in chunks is supported only by SETCHUNKED and CLUSTER IMPORTCHUNKED commands")
+	ErrClientNotSupported    = errors.New("client commands are not supported by connection")
+	ErrClientNotFound        = errors.New("no such client")
+)
 
 type BadRequestError struct {
 	err error
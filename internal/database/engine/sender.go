@@ -0,0 +1,21 @@
+package engine
+
+import "context"
+
+// Sender отправляет клиенту сообщение в потоковом режиме, не дожидаясь
+// завершения обработки запроса.
+type Sender func(message string) error
+
+type senderKey struct{}
+
+// WithSender возвращает контекст запроса, позволяющий отправлять клиенту
+// несколько сообщений в ответ на один запрос.
+func WithSender(ctx context.Context, sender Sender) context.Context {
+	return context.WithValue(ctx, senderKey{}, sender)
+}
+
+func senderFromContext(ctx context.Context) (Sender, bool) {
+	sender, ok := ctx.Value(senderKey{}).(Sender)
+
+	return sender, ok
+}
@@ -0,0 +1,61 @@
+package engine
+
+import (
+	"context"
+	"sync"
+
+	"github.com/strider2038/key-value-database/internal/database/acl"
+)
+
+// Session - состояние клиентского соединения: пользователь, прошедший
+// аутентификацию командой AUTH. Сессия создается сетевым сервисом на время
+// соединения (или одного HTTP запроса).
+type Session struct {
+	mu       sync.Mutex
+	user     *acl.User
+	reserved bool
+}
+
+func NewSession() *Session {
+	return &Session{}
+}
+
+// NewReservedSession создает сессию соединения из резерва для администраторов:
+// после аутентификации команды выполняются, только если пользователь имеет
+// права admin.
+func NewReservedSession() *Session {
+	return &Session{reserved: true}
+}
+
+// Reserved сообщает, что сессия создана для резервного соединения.
+func (s *Session) Reserved() bool {
+	return s.reserved
+}
+
+// User возвращает аутентифицированного пользователя или nil.
+func (s *Session) User() *acl.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.user
+}
+
+func (s *Session) setUser(user *acl.User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.user = user
+}
+
+type sessionKey struct{}
+
+// WithSession возвращает контекст запроса, выполняемого в рамках сессии.
+func WithSession(ctx context.Context, session *Session) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+func sessionFromContext(ctx context.Context) (*Session, bool) {
+	session, ok := ctx.Value(sessionKey{}).(*Session)
+
+	return session, ok
+}
@@ -0,0 +1,123 @@
+package hlc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidTimestamp = errors.New("invalid timestamp")
+
+// Timestamp - метка гибридных логических часов (Hybrid Logical Clock).
+// WallTime - физическое время в наносекундах, Logical - счетчик событий,
+// произошедших в пределах одного значения WallTime.
+type Timestamp struct {
+	WallTime int64
+	Logical  uint32
+}
+
+func (t Timestamp) Compare(compared Timestamp) int {
+	if t.WallTime != compared.WallTime {
+		if t.WallTime < compared.WallTime {
+			return -1
+		}
+
+		return 1
+	}
+	if t.Logical != compared.Logical {
+		if t.Logical < compared.Logical {
+			return -1
+		}
+
+		return 1
+	}
+
+	return 0
+}
+
+func (t Timestamp) IsZero() bool {
+	return t == Timestamp{}
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%d.%d", t.WallTime, t.Logical)
+}
+
+// ParseTimestamp разбирает метку в формате <wall_time>.<logical>.
+func ParseTimestamp(s string) (Timestamp, error) {
+	wallTime, logical, found := strings.Cut(s, ".")
+	if !found {
+		return Timestamp{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, s)
+	}
+
+	var timestamp Timestamp
+	var err error
+	if timestamp.WallTime, err = strconv.ParseInt(wallTime, 10, 64); err != nil {
+		return Timestamp{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, s)
+	}
+	logicalNumber, err := strconv.ParseUint(logical, 10, 32)
+	if err != nil {
+		return Timestamp{}, fmt.Errorf("%w: %q", ErrInvalidTimestamp, s)
+	}
+	timestamp.Logical = uint32(logicalNumber)
+
+	return timestamp, nil
+}
+
+// Clock - гибридные логические часы. Метки часов монотонно возрастают, даже если
+// физическое время отстает или идет назад, и всегда больше меток полученных
+// от других узлов событий, переданных в Update. При этом метки остаются близки
+// к физическому времени.
+type Clock struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	last Timestamp
+}
+
+// NewClock создает часы. Если now не задана, используется time.Now.
+func NewClock(now func() time.Time) *Clock {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &Clock{now: now}
+}
+
+// Now возвращает метку для локального события.
+func (c *Clock) Now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wallTime := c.now().UnixNano()
+	if wallTime > c.last.WallTime {
+		c.last = Timestamp{WallTime: wallTime}
+	} else {
+		c.last.Logical++
+	}
+
+	return c.last
+}
+
+// Update учитывает метку события, полученного от другого узла.
+func (c *Clock) Update(remote Timestamp) Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wallTime := c.now().UnixNano()
+	switch {
+	case wallTime > c.last.WallTime && wallTime > remote.WallTime:
+		c.last = Timestamp{WallTime: wallTime}
+	case remote.WallTime > c.last.WallTime:
+		c.last = Timestamp{WallTime: remote.WallTime, Logical: remote.Logical + 1}
+	case c.last.WallTime > remote.WallTime:
+		c.last.Logical++
+	default:
+		c.last.Logical = max(c.last.Logical, remote.Logical) + 1
+	}
+
+	return c.last
+}
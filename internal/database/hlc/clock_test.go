@@ -0,0 +1,58 @@
+package hlc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+)
+
+type ManualTime struct {
+	now time.Time
+}
+
+func (t *ManualTime) Now() time.Time {
+	return t.now
+}
+
+func TestClock_Now_WhenWallTimeGoesBack_ExpectMonotonicTimestamps(t *testing.T) {
+	wallTime := &ManualTime{now: time.Unix(100, 0)}
+	clock := hlc.NewClock(wallTime.Now)
+
+	first := clock.Now()
+	wallTime.now = time.Unix(99, 0)
+	second := clock.Now()
+	third := clock.Now()
+	wallTime.now = time.Unix(101, 0)
+	fourth := clock.Now()
+
+	assert.Equal(t, hlc.Timestamp{WallTime: time.Unix(100, 0).UnixNano()}, first)
+	assert.Equal(t, hlc.Timestamp{WallTime: first.WallTime, Logical: 1}, second)
+	assert.Equal(t, hlc.Timestamp{WallTime: first.WallTime, Logical: 2}, third)
+	assert.Equal(t, hlc.Timestamp{WallTime: time.Unix(101, 0).UnixNano()}, fourth)
+}
+
+func TestClock_Update_ExpectTimestampAfterRemote(t *testing.T) {
+	wallTime := &ManualTime{now: time.Unix(100, 0)}
+	clock := hlc.NewClock(wallTime.Now)
+	remote := hlc.Timestamp{WallTime: time.Unix(105, 0).UnixNano(), Logical: 3}
+
+	updated := clock.Update(remote)
+	next := clock.Now()
+
+	assert.Equal(t, hlc.Timestamp{WallTime: remote.WallTime, Logical: 4}, updated)
+	assert.Equal(t, 1, next.Compare(updated))
+	assert.Equal(t, 1, clock.Update(hlc.Timestamp{WallTime: 1}).Compare(next))
+}
+
+func TestParseTimestamp(t *testing.T) {
+	timestamp, err := hlc.ParseTimestamp("1700000000000000000.12")
+	require.NoError(t, err)
+	assert.Equal(t, hlc.Timestamp{WallTime: 1700000000000000000, Logical: 12}, timestamp)
+	assert.Equal(t, "1700000000000000000.12", timestamp.String())
+
+	_, err = hlc.ParseTimestamp("1700000000000000000")
+	assert.ErrorIs(t, err, hlc.ErrInvalidTimestamp)
+}
@@ -0,0 +1,273 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/raft"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
+)
+
+const keysPath = "/keys/"
+
+type HTTPNetwork interface {
+	Serve(ctx context.Context, handler http.Handler) error
+}
+
+// HTTPService - шлюз HTTP/JSON к базе данных для клиентов, которые не могут
+// использовать TCP протокол:
+//   - GET /keys/{key} - значение ключа в виде {"key": "...", "value": "..."}, 404 если ключ не найден;
+//   - PUT /keys/{key} - запись значения из тела {"value": "..."};
+//   - DELETE /keys/{key} - удаление ключа;
+//   - POST /command - выполнение команды из тела {"command": "GET key"}, ответ {"result": "..."};
+//   - GET /debug/vars - метрики сервера в формате expvar (например,
+//     network_output_limit_disconnects - соединения, закрытые из-за
+//     переполнения буфера ответов); при включенном контроле доступа
+//     доступны только администраторам.
+//
+// Ошибки возвращаются в виде {"error": "..."}: некорректный запрос - 400, запрос
+// к другому узлу кластера - 421, недоступный хеш-слот - 503, истек срок
+// выполнения - 504, внутренняя ошибка - 500. Параметр запроса timeout
+// (например, ?timeout=500ms) ограничивает время выполнения команды.
+// Если включен контроль доступа, учетные данные передаются через HTTP Basic
+// в каждом запросе: ошибка аутентификации - 401, недостаточно прав - 403.
+type HTTPService struct {
+	controller *engine.Controller
+	network    HTTPNetwork
+	logger     *slog.Logger
+}
+
+func NewHTTPService(
+	controller *engine.Controller,
+	network HTTPNetwork,
+	logger *slog.Logger,
+) *HTTPService {
+	return &HTTPService{
+		controller: controller,
+		network:    network,
+		logger:     logger,
+	}
+}
+
+func (s *HTTPService) Serve(ctx context.Context) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(keysPath, s.handleKey)
+	mux.HandleFunc("/command", s.handleCommand)
+	mux.HandleFunc("/debug/vars", s.handleVars)
+
+	if err := s.network.Serve(ctx, s.authenticate(mux)); err != nil {
+		return fmt.Errorf("serve HTTP: %w", err)
+	}
+
+	return nil
+}
+
+type keyResponse struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type setKeyRequest struct {
+	Value *string `json:"value"`
+}
+
+type commandRequest struct {
+	Command string `json:"command"`
+}
+
+type resultResponse struct {
+	Result string `json:"result"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// authenticate создает сессию на время запроса и аутентифицирует пользователя
+// по учетным данным HTTP Basic, если они переданы. Здесь же применяется
+// ограничение времени выполнения из параметра timeout.
+func (s *HTTPService) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		ctx := engine.WithSession(request.Context(), engine.NewSession())
+		if value := request.URL.Query().Get("timeout"); value != "" {
+			timeout, err := time.ParseDuration(value)
+			if err != nil || timeout <= 0 {
+				s.writeJSON(writer, http.StatusBadRequest, errorResponse{Error: "invalid timeout: duration must be positive, for example 500ms"})
+
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		if name, password, ok := request.BasicAuth(); ok {
+			if err := s.controller.Authenticate(ctx, name, password); err != nil {
+				s.writeError(writer, err)
+
+				return
+			}
+		}
+
+		next.ServeHTTP(writer, request.WithContext(ctx))
+	})
+}
+
+func (s *HTTPService) handleVars(writer http.ResponseWriter, request *http.Request) {
+	if err := s.controller.AuthorizeAdmin(request.Context()); err != nil {
+		s.writeError(writer, err)
+
+		return
+	}
+
+	expvar.Handler().ServeHTTP(writer, request)
+}
+
+func (s *HTTPService) handleKey(writer http.ResponseWriter, request *http.Request) {
+	key := strings.TrimPrefix(request.URL.Path, keysPath)
+	if key == "" {
+		s.writeJSON(writer, http.StatusBadRequest, errorResponse{Error: "key is required"})
+
+		return
+	}
+
+	switch request.Method {
+	case http.MethodGet:
+		value, err := s.controller.ExecuteArguments(request.Context(), []string{"GET", key})
+		if err != nil {
+			s.writeError(writer, err)
+
+			return
+		}
+		if value == querylang.Nil {
+			s.writeJSON(writer, http.StatusNotFound, errorResponse{Error: "key not found"})
+
+			return
+		}
+		s.writeJSON(writer, http.StatusOK, keyResponse{Key: key, Value: value})
+	case http.MethodPut:
+		var body setKeyRequest
+		if !s.decodeBody(writer, request, &body) {
+			return
+		}
+		if body.Value == nil {
+			s.writeJSON(writer, http.StatusBadRequest, errorResponse{Error: `field "value" is required`})
+
+			return
+		}
+		s.execute(writer, request, []string{"SET", key, *body.Value})
+	case http.MethodDelete:
+		s.execute(writer, request, []string{"DEL", key})
+	default:
+		writer.Header().Set("Allow", "GET, PUT, DELETE")
+		s.writeJSON(writer, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+	}
+}
+
+func (s *HTTPService) handleCommand(writer http.ResponseWriter, request *http.Request) {
+	if request.Method != http.MethodPost {
+		writer.Header().Set("Allow", "POST")
+		s.writeJSON(writer, http.StatusMethodNotAllowed, errorResponse{Error: "method not allowed"})
+
+		return
+	}
+
+	var body commandRequest
+	if !s.decodeBody(writer, request, &body) {
+		return
+	}
+
+	result, err := s.controller.Execute(request.Context(), body.Command)
+	if err != nil {
+		s.writeError(writer, err)
+
+		return
+	}
+	s.writeJSON(writer, http.StatusOK, resultResponse{Result: result})
+}
+
+func (s *HTTPService) execute(writer http.ResponseWriter, request *http.Request, arguments []string) {
+	result, err := s.controller.ExecuteArguments(request.Context(), arguments)
+	if err != nil {
+		s.writeError(writer, err)
+
+		return
+	}
+	s.writeJSON(writer, http.StatusOK, resultResponse{Result: result})
+}
+
+// decodeBody разбирает JSON тело запроса. При ошибке записывает ответ и возвращает false.
+func (s *HTTPService) decodeBody(writer http.ResponseWriter, request *http.Request, body any) bool {
+	err := json.NewDecoder(request.Body).Decode(body)
+	if err == nil {
+		return true
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		s.writeJSON(writer, http.StatusRequestEntityTooLarge, errorResponse{
+			Error: fmt.Sprintf("request body exceeds limit of %d bytes", tooLarge.Limit),
+		})
+
+		return false
+	}
+	s.writeJSON(writer, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid JSON body: %s", err)})
+
+	return false
+}
+
+func (s *HTTPService) writeError(writer http.ResponseWriter, err error) {
+	status := errorStatus(err)
+	if status == http.StatusUnauthorized {
+		writer.Header().Set("WWW-Authenticate", `Basic realm="`+serverName+`"`)
+	}
+	s.writeJSON(writer, status, errorResponse{Error: formatError(err, s.logger)})
+}
+
+func (s *HTTPService) writeJSON(writer http.ResponseWriter, status int, body any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+	if err := json.NewEncoder(writer).Encode(body); err != nil {
+		s.logger.Debug("write HTTP response", "error", err)
+	}
+}
+
+func errorStatus(err error) int {
+	if errors.Is(err, acl.ErrNotAuthenticated) || errors.Is(err, acl.ErrInvalidCredentials) {
+		return http.StatusUnauthorized
+	}
+	if errors.Is(err, acl.ErrForbidden) {
+		return http.StatusForbidden
+	}
+	var badRequest *engine.BadRequestError
+	if errors.As(err, &badRequest) {
+		return http.StatusBadRequest
+	}
+	var notLeader *raft.NotLeaderError
+	var moved *sharding.MovedError
+	if errors.As(err, &notLeader) || errors.As(err, &moved) {
+		return http.StatusMisdirectedRequest
+	}
+	if errors.Is(err, sharding.ErrSlotNotServed) {
+		return http.StatusServiceUnavailable
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	for _, clusterErr := range clusterRequestErrors {
+		if errors.Is(err, clusterErr) {
+			return http.StatusBadRequest
+		}
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,8 @@
+package memcached
+
+import "errors"
+
+var (
+	ErrInvalidItem = errors.New("invalid memcached item")
+	ErrNotNumber   = errors.New("cannot increment or decrement non-numeric value")
+)
@@ -0,0 +1,65 @@
+package memcached
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// itemHeader - признак значения, записанного через протокол memcached. Значения,
+// записанные другими протоколами, читаются как элементы без флагов и срока жизни.
+const itemHeader = "\x00MC "
+
+// Item - элемент memcached. Метаданные хранятся в значении ключа базы данных
+// в виде заголовка "\x00MC <flags> <expiresAt> <cas> <storedAt>\n" перед данными.
+type Item struct {
+	Key   string
+	Value string
+	Flags uint32
+	// ExpiresAt - время истечения срока жизни (unix, секунды), 0 - без ограничения.
+	ExpiresAt int64
+	CAS       uint64
+	// StoredAt - время записи (unix, наносекунды), используется командой flush_all.
+	StoredAt int64
+}
+
+func encodeItem(item *Item) string {
+	return fmt.Sprintf("%s%d %d %d %d\n%s", itemHeader, item.Flags, item.ExpiresAt, item.CAS, item.StoredAt, item.Value)
+}
+
+func decodeItem(key, raw string) (*Item, error) {
+	if !strings.HasPrefix(raw, itemHeader) {
+		return &Item{Key: key, Value: raw}, nil
+	}
+
+	header, value, found := strings.Cut(raw[len(itemHeader):], "\n")
+	fields := strings.Fields(header)
+	if !found || len(fields) != 4 {
+		return nil, fmt.Errorf("%w: key %q", ErrInvalidItem, key)
+	}
+	flags, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: flags: %w", ErrInvalidItem, key, err)
+	}
+	expiresAt, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: expiration time: %w", ErrInvalidItem, key, err)
+	}
+	cas, err := strconv.ParseUint(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: cas: %w", ErrInvalidItem, key, err)
+	}
+	storedAt, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: key %q: store time: %w", ErrInvalidItem, key, err)
+	}
+
+	return &Item{
+		Key:       key,
+		Value:     value,
+		Flags:     uint32(flags),
+		ExpiresAt: expiresAt,
+		CAS:       cas,
+		StoredAt:  storedAt,
+	}, nil
+}
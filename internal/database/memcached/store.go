@@ -0,0 +1,323 @@
+package memcached
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+const (
+	// maxRelativeExpiration - значения срока жизни больше 30 дней считаются
+	// абсолютным временем (unix), как в memcached.
+	maxRelativeExpiration = 30 * 24 * 60 * 60
+
+	// flushKey - служебный ключ, хранящий время последнего вызова flush_all.
+	flushKey = "\x00memcached:flush_all"
+
+	lockStripes = 256
+)
+
+type Executor interface {
+	ExecuteArguments(ctx context.Context, arguments []string) (string, error)
+}
+
+type StoreResult int
+
+const (
+	Stored StoreResult = iota
+	NotStored
+	Exists
+	NotFound
+)
+
+func (r StoreResult) String() string {
+	switch r {
+	case Stored:
+		return "STORED"
+	case NotStored:
+		return "NOT_STORED"
+	case Exists:
+		return "EXISTS"
+	case NotFound:
+		return "NOT_FOUND"
+	default:
+		return ""
+	}
+}
+
+// Store реализует семантику команд memcached поверх команд GET, SET и DEL базы данных.
+// Составные операции (add, replace, cas, incr, decr, touch) атомарны относительно
+// других операций Store, но не относительно записи тех же ключей другими протоколами.
+// Истекшие и сброшенные командой flush_all элементы удаляются при чтении.
+type Store struct {
+	executor Executor
+	now      func() time.Time
+	locks    [lockStripes]sync.Mutex
+	lastCAS  atomic.Uint64
+
+	flushMu     sync.Mutex
+	flushLoaded bool
+	flushAt     int64
+}
+
+func NewStore(executor Executor, now func() time.Time) *Store {
+	if now == nil {
+		now = time.Now
+	}
+	s := &Store{executor: executor, now: now}
+	// значения CAS должны оставаться уникальными после перезапуска сервера
+	s.lastCAS.Store(uint64(now().UnixNano()))
+
+	return s
+}
+
+// Get возвращает элемент или nil, если элемент не найден или истек его срок жизни.
+func (s *Store) Get(ctx context.Context, key string) (*Item, error) {
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.get(ctx, key)
+}
+
+func (s *Store) Set(ctx context.Context, item *Item, exptime int64) error {
+	lock := s.lock(item.Key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return s.set(ctx, item, exptime)
+}
+
+func (s *Store) Add(ctx context.Context, item *Item, exptime int64) (StoreResult, error) {
+	return s.update(ctx, item.Key, func(current *Item) (StoreResult, error) {
+		if current != nil {
+			return NotStored, nil
+		}
+
+		return Stored, s.set(ctx, item, exptime)
+	})
+}
+
+func (s *Store) Replace(ctx context.Context, item *Item, exptime int64) (StoreResult, error) {
+	return s.update(ctx, item.Key, func(current *Item) (StoreResult, error) {
+		if current == nil {
+			return NotStored, nil
+		}
+
+		return Stored, s.set(ctx, item, exptime)
+	})
+}
+
+// CompareAndSwap записывает элемент, только если его CAS не изменился с момента чтения.
+func (s *Store) CompareAndSwap(ctx context.Context, item *Item, exptime int64, cas uint64) (StoreResult, error) {
+	return s.update(ctx, item.Key, func(current *Item) (StoreResult, error) {
+		if current == nil {
+			return NotFound, nil
+		}
+		if current.CAS != cas {
+			return Exists, nil
+		}
+
+		return Stored, s.set(ctx, item, exptime)
+	})
+}
+
+func (s *Store) Delete(ctx context.Context, key string) (bool, error) {
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.get(ctx, key)
+	if err != nil || current == nil {
+		return false, err
+	}
+
+	return true, s.delete(ctx, key)
+}
+
+// Increment изменяет числовое значение на delta. При увеличении значение
+// переполняется через 2^64, при уменьшении не становится меньше нуля.
+// Возвращает false, если элемент не найден.
+func (s *Store) Increment(ctx context.Context, key string, delta uint64, decrement bool) (uint64, bool, error) {
+	var value uint64
+	result, err := s.update(ctx, key, func(current *Item) (StoreResult, error) {
+		if current == nil {
+			return NotFound, nil
+		}
+		number, err := strconv.ParseUint(current.Value, 10, 64)
+		if err != nil {
+			return NotStored, ErrNotNumber
+		}
+		switch {
+		case !decrement:
+			value = number + delta
+		case delta > number:
+			value = 0
+		default:
+			value = number - delta
+		}
+
+		item := *current
+		item.Value = strconv.FormatUint(value, 10)
+
+		return Stored, s.write(ctx, &item)
+	})
+
+	return value, result == Stored, err
+}
+
+// Touch обновляет срок жизни элемента. Возвращает false, если элемент не найден.
+func (s *Store) Touch(ctx context.Context, key string, exptime int64) (bool, error) {
+	result, err := s.update(ctx, key, func(current *Item) (StoreResult, error) {
+		if current == nil {
+			return NotFound, nil
+		}
+		item := *current
+		item.ExpiresAt = s.expiresAt(exptime)
+
+		return Stored, s.write(ctx, &item)
+	})
+
+	return result == Stored, err
+}
+
+// FlushAll делает недействительными все элементы, записанные до момента
+// now+delay, начиная с этого момента.
+func (s *Store) FlushAll(ctx context.Context, delay time.Duration) error {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	flushAt := s.now().Add(delay).UnixNano()
+	if _, err := s.executor.ExecuteArguments(ctx, []string{"SET", flushKey, strconv.FormatInt(flushAt, 10)}); err != nil {
+		return fmt.Errorf("save flush time: %w", err)
+	}
+	s.flushAt = flushAt
+	s.flushLoaded = true
+
+	return nil
+}
+
+func (s *Store) update(ctx context.Context, key string, update func(current *Item) (StoreResult, error)) (StoreResult, error) {
+	lock := s.lock(key)
+	lock.Lock()
+	defer lock.Unlock()
+
+	current, err := s.get(ctx, key)
+	if err != nil {
+		return NotStored, err
+	}
+
+	return update(current)
+}
+
+func (s *Store) get(ctx context.Context, key string) (*Item, error) {
+	raw, err := s.executor.ExecuteArguments(ctx, []string{"GET", key})
+	if err != nil {
+		return nil, err
+	}
+	if raw == querylang.Nil {
+		return nil, nil
+	}
+	item, err := decodeItem(key, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	isExpired, err := s.isExpired(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+	if isExpired {
+		return nil, s.delete(ctx, key)
+	}
+
+	return item, nil
+}
+
+func (s *Store) set(ctx context.Context, item *Item, exptime int64) error {
+	stored := *item
+	stored.ExpiresAt = s.expiresAt(exptime)
+
+	return s.write(ctx, &stored)
+}
+
+// write записывает элемент с новыми значениями CAS и времени записи.
+func (s *Store) write(ctx context.Context, item *Item) error {
+	item.CAS = s.lastCAS.Add(1)
+	item.StoredAt = s.now().UnixNano()
+	_, err := s.executor.ExecuteArguments(ctx, []string{"SET", item.Key, encodeItem(item)})
+
+	return err
+}
+
+func (s *Store) delete(ctx context.Context, key string) error {
+	_, err := s.executor.ExecuteArguments(ctx, []string{"DEL", key})
+
+	return err
+}
+
+func (s *Store) isExpired(ctx context.Context, item *Item) (bool, error) {
+	now := s.now()
+	if item.ExpiresAt != 0 && item.ExpiresAt <= now.Unix() {
+		return true, nil
+	}
+
+	flushAt, err := s.flushTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return flushAt != 0 && now.UnixNano() >= flushAt && item.StoredAt <= flushAt, nil
+}
+
+func (s *Store) flushTime(ctx context.Context) (int64, error) {
+	s.flushMu.Lock()
+	defer s.flushMu.Unlock()
+
+	if s.flushLoaded {
+		return s.flushAt, nil
+	}
+
+	raw, err := s.executor.ExecuteArguments(ctx, []string{"GET", flushKey})
+	if err != nil {
+		return 0, fmt.Errorf("load flush time: %w", err)
+	}
+	if raw != querylang.Nil {
+		s.flushAt, err = strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: flush time %q", ErrInvalidItem, raw)
+		}
+	}
+	s.flushLoaded = true
+
+	return s.flushAt, nil
+}
+
+// expiresAt переводит срок жизни из формата memcached в абсолютное время: 0 - без
+// ограничения, до 30 дней - секунды от текущего момента, больше - время unix,
+// отрицательное значение - элемент сразу считается истекшим.
+func (s *Store) expiresAt(exptime int64) int64 {
+	switch {
+	case exptime == 0:
+		return 0
+	case exptime < 0:
+		return 1
+	case exptime <= maxRelativeExpiration:
+		return s.now().Unix() + exptime
+	default:
+		return exptime
+	}
+}
+
+func (s *Store) lock(key string) *sync.Mutex {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	return &s.locks[hash.Sum32()%lockStripes]
+}
@@ -0,0 +1,214 @@
+package memcached_test
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/memcached"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+type MapExecutor map[string]string
+
+func (e MapExecutor) ExecuteArguments(_ context.Context, arguments []string) (string, error) {
+	switch arguments[0] {
+	case "GET":
+		if value, exists := e[arguments[1]]; exists {
+			return value, nil
+		}
+
+		return querylang.Nil, nil
+	case "SET":
+		e[arguments[1]] = arguments[2]
+	case "DEL":
+		delete(e, arguments[1])
+	}
+
+	return "OK", nil
+}
+
+type Clock struct {
+	Time time.Time
+}
+
+func (c *Clock) Now() time.Time {
+	return c.Time
+}
+
+func (c *Clock) Advance(duration time.Duration) {
+	c.Time = c.Time.Add(duration)
+}
+
+func newStore() (*memcached.Store, MapExecutor, *Clock) {
+	executor := MapExecutor{}
+	clock := &Clock{Time: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	return memcached.NewStore(executor, clock.Now), executor, clock
+}
+
+func TestStore_SetGet(t *testing.T) {
+	ctx := context.Background()
+	store, executor, _ := newStore()
+	executor["plain"] = "written by other protocol"
+
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "key", Value: "multi\r\nline value", Flags: 42}, 0))
+
+	item, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "multi\r\nline value", item.Value)
+	assert.Equal(t, uint32(42), item.Flags)
+	assert.NotZero(t, item.CAS)
+	item, err = store.Get(ctx, "plain")
+	require.NoError(t, err)
+	require.NotNil(t, item)
+	assert.Equal(t, "written by other protocol", item.Value)
+	item, err = store.Get(ctx, "missing")
+	require.NoError(t, err)
+	assert.Nil(t, item)
+}
+
+func TestStore_Get_WhenExpired_ExpectItemDeleted(t *testing.T) {
+	ctx := context.Background()
+	store, executor, clock := newStore()
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "relative", Value: "1"}, 10))
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "absolute", Value: "2"}, clock.Time.Unix()+20))
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "negative", Value: "3"}, -1))
+
+	clock.Advance(10 * time.Second)
+
+	assertMissing(t, store, "relative")
+	assertMissing(t, store, "negative")
+	assertValue(t, store, "absolute", "2")
+	assert.NotContains(t, executor, "relative")
+}
+
+func TestStore_AddReplace(t *testing.T) {
+	ctx := context.Background()
+	store, _, _ := newStore()
+
+	result, err := store.Replace(ctx, &memcached.Item{Key: "key", Value: "1"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.NotStored, result)
+	result, err = store.Add(ctx, &memcached.Item{Key: "key", Value: "2"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.Stored, result)
+	result, err = store.Add(ctx, &memcached.Item{Key: "key", Value: "3"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.NotStored, result)
+	result, err = store.Replace(ctx, &memcached.Item{Key: "key", Value: "4"}, 0)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.Stored, result)
+	assertValue(t, store, "key", "4")
+}
+
+func TestStore_CompareAndSwap(t *testing.T) {
+	ctx := context.Background()
+	store, _, _ := newStore()
+	result, err := store.CompareAndSwap(ctx, &memcached.Item{Key: "key", Value: "1"}, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.NotFound, result)
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "key", Value: "1"}, 0))
+	item, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+
+	result, err = store.CompareAndSwap(ctx, &memcached.Item{Key: "key", Value: "2"}, 0, item.CAS)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.Stored, result)
+	result, err = store.CompareAndSwap(ctx, &memcached.Item{Key: "key", Value: "3"}, 0, item.CAS)
+	require.NoError(t, err)
+	assert.Equal(t, memcached.Exists, result)
+	assertValue(t, store, "key", "2")
+}
+
+func TestStore_Increment(t *testing.T) {
+	ctx := context.Background()
+	store, _, _ := newStore()
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "counter", Value: "10", Flags: 7}, 0))
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "max", Value: strconv.FormatUint(math.MaxUint64, 10)}, 0))
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "text", Value: "abc"}, 0))
+
+	tests := []struct {
+		key       string
+		delta     uint64
+		decrement bool
+		want      uint64
+	}{
+		{key: "counter", delta: 5, want: 15},
+		{key: "counter", delta: 3, decrement: true, want: 12},
+		{key: "counter", delta: 100, decrement: true, want: 0},
+		{key: "max", delta: 2, want: 1},
+	}
+	for _, test := range tests {
+		value, found, err := store.Increment(ctx, test.key, test.delta, test.decrement)
+		require.NoError(t, err)
+		assert.True(t, found)
+		assert.Equal(t, test.want, value)
+	}
+
+	item, err := store.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), item.Flags, "flags must be kept")
+	_, found, err := store.Increment(ctx, "missing", 1, false)
+	require.NoError(t, err)
+	assert.False(t, found)
+	_, _, err = store.Increment(ctx, "text", 1, false)
+	assert.ErrorIs(t, err, memcached.ErrNotNumber)
+}
+
+func TestStore_Touch(t *testing.T) {
+	ctx := context.Background()
+	store, _, clock := newStore()
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "key", Value: "1"}, 10))
+
+	touched, err := store.Touch(ctx, "key", 100)
+	require.NoError(t, err)
+	assert.True(t, touched)
+	clock.Advance(50 * time.Second)
+	assertValue(t, store, "key", "1")
+	touched, err = store.Touch(ctx, "missing", 100)
+	require.NoError(t, err)
+	assert.False(t, touched)
+}
+
+func TestStore_FlushAll(t *testing.T) {
+	ctx := context.Background()
+	store, executor, clock := newStore()
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "key", Value: "1"}, 0))
+	storedBeforeFlush := executor["key"]
+
+	require.NoError(t, store.FlushAll(ctx, 10*time.Second))
+	assertValue(t, store, "key", "1")
+	clock.Advance(10 * time.Second)
+	assertMissing(t, store, "key")
+	clock.Advance(time.Second)
+	require.NoError(t, store.Set(ctx, &memcached.Item{Key: "key", Value: "2"}, 0))
+	assertValue(t, store, "key", "2")
+
+	restarted := memcached.NewStore(executor, clock.Now)
+	executor["copy"] = storedBeforeFlush
+	assertMissing(t, restarted, "copy")
+	assertValue(t, restarted, "key", "2")
+}
+
+func assertValue(tb testing.TB, store *memcached.Store, key, want string) {
+	tb.Helper()
+
+	item, err := store.Get(context.Background(), key)
+	require.NoError(tb, err)
+	require.NotNil(tb, item, "item %q not found", key)
+	assert.Equal(tb, want, item.Value)
+}
+
+func assertMissing(tb testing.TB, store *memcached.Store, key string) {
+	tb.Helper()
+
+	item, err := store.Get(context.Background(), key)
+	require.NoError(tb, err)
+	assert.Nil(tb, item, "item %q must be missing", key)
+}
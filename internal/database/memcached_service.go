@@ -0,0 +1,469 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/memcached"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+const (
+	memcachedMaxKeyLength = 250
+	// memcachedMaxLineLength - ограничение длины командной строки (без блока данных).
+	memcachedMaxLineLength = 2048
+)
+
+var (
+	errMemcachedBadFormat    = errors.New("bad command line format")
+	errMemcachedLineTooLong  = errors.New("line is too long")
+	errMemcachedBadDataChunk = errors.New("bad data chunk")
+)
+
+// MemcachedService принимает команды текстового протокола memcached (get, gets, set,
+// add, replace, cas, delete, incr, decr, touch, flush_all, version, quit)
+// и выполняет их через memcached.Store поверх контроллера базы данных.
+// Если включен контроль доступа, клиент аутентифицируется как в memcached:
+// блок данных первой команды set содержит "<username> <password>".
+type MemcachedService struct {
+	controller     *engine.Controller
+	store          *memcached.Store
+	network        ConnectionNetwork
+	maxMessageSize int
+	idleTimeout    time.Duration
+	logger         *slog.Logger
+}
+
+func NewMemcachedService(
+	controller *engine.Controller,
+	network ConnectionNetwork,
+	maxMessageSize int,
+	idleTimeout time.Duration,
+	logger *slog.Logger,
+) *MemcachedService {
+	return &MemcachedService{
+		controller:     controller,
+		store:          memcached.NewStore(controller, nil),
+		network:        network,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+		logger:         logger,
+	}
+}
+
+func (s *MemcachedService) Serve(ctx context.Context) error {
+	if err := s.network.ServeConnections(ctx, s); err != nil {
+		return fmt.Errorf("serve memcached: %w", err)
+	}
+
+	return nil
+}
+
+// memcachedReadError - ошибка чтения блока данных, после которой соединение закрывается.
+type memcachedReadError struct {
+	err error
+}
+
+func (e *memcachedReadError) Error() string {
+	return fmt.Sprintf("read data block: %s", e.err)
+}
+
+type memcachedSession struct {
+	session *engine.Session
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+}
+
+// RejectConnection отвечает ошибкой сервера, когда достигнуто ограничение соединений.
+func (s *MemcachedService) RejectConnection(connection net.Conn, err error) {
+	if _, err := connection.Write([]byte("SERVER_ERROR " + err.Error() + "\r\n")); err != nil {
+		s.logger.Debug("write to connection", "error", err)
+	}
+}
+
+func (s *MemcachedService) HandleConnection(ctx context.Context, connection net.Conn) {
+	// При graceful shutdown ожидание запроса прерывается установкой истекшего
+	// дедлайна чтения. Начатая команда выполняется с контекстом запроса
+	// и успевает отправить ответ.
+	stop := context.AfterFunc(ctx, func() {
+		_ = connection.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	session := &memcachedSession{
+		session: newConnectionSession(ctx),
+		reader:  bufio.NewReaderSize(connection, memcachedMaxLineLength),
+		writer:  bufio.NewWriter(connection),
+	}
+	ctx = engine.WithSession(ctx, session.session)
+
+	for {
+		if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			s.logger.Warn("set connection deadline", "error", err)
+
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		line, err := s.readLine(session)
+		if errors.Is(err, errMemcachedLineTooLong) {
+			// положение в потоке данных не определено, поэтому соединение закрывается
+			s.writeLine(session, "CLIENT_ERROR "+err.Error())
+			_ = session.writer.Flush()
+
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logger.Warn("read from connection", "error", err)
+			}
+
+			return
+		}
+
+		requestContext, cancel := network.RequestContext(ctx)
+		quit, err := s.handleCommand(requestContext, session, strings.Fields(line))
+		cancel()
+		if err != nil {
+			// ошибка чтения блока данных
+			s.logger.Warn("read from connection", "error", err)
+
+			return
+		}
+		if err := session.writer.Flush(); err != nil {
+			s.logger.Warn("write to connection", "error", err)
+
+			return
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+// handleCommand выполняет команду и записывает ответ. Возвращает true,
+// если соединение нужно закрыть, и ошибку, если не удалось прочитать блок данных.
+func (s *MemcachedService) handleCommand(ctx context.Context, session *memcachedSession, fields []string) (bool, error) {
+	if len(fields) == 0 {
+		s.writeLine(session, "ERROR")
+
+		return false, nil
+	}
+
+	command, arguments := fields[0], fields[1:]
+	noreply := len(arguments) > 0 && arguments[len(arguments)-1] == "noreply"
+	if noreply && command != "get" && command != "gets" {
+		arguments = arguments[:len(arguments)-1]
+	}
+
+	var response string
+	var err error
+	switch command {
+	case "get", "gets":
+		s.handleGet(ctx, session, arguments, command == "gets")
+
+		return false, nil
+	case "set", "add", "replace", "cas":
+		response, err = s.handleStorage(ctx, session, command, arguments)
+		var readErr *memcachedReadError
+		if errors.As(err, &readErr) {
+			return false, readErr.err
+		}
+	case "delete":
+		response, err = s.handleDelete(ctx, arguments)
+	case "incr", "decr":
+		response, err = s.handleIncrement(ctx, arguments, command == "decr")
+	case "touch":
+		response, err = s.handleTouch(ctx, arguments)
+	case "flush_all":
+		response, err = s.handleFlushAll(ctx, arguments)
+	case "version":
+		response = "VERSION 1.0.0"
+	case "quit":
+		return true, nil
+	default:
+		s.writeLine(session, "ERROR")
+
+		return false, nil
+	}
+
+	if err != nil {
+		response = s.formatError(err)
+	}
+	if !noreply {
+		s.writeLine(session, response)
+	}
+
+	return false, nil
+}
+
+// handleGet отправляет найденные элементы в формате "VALUE <key> <flags> <bytes> [<cas>]".
+// При ошибке ответ прерывается сообщением об ошибке без завершающего END.
+func (s *MemcachedService) handleGet(ctx context.Context, session *memcachedSession, keys []string, withCAS bool) {
+	if len(keys) == 0 {
+		s.writeLine(session, "ERROR")
+
+		return
+	}
+	for _, key := range keys {
+		if err := validateMemcachedKey(key); err != nil {
+			s.writeLine(session, s.formatError(err))
+
+			return
+		}
+	}
+
+	for _, key := range keys {
+		item, err := s.store.Get(ctx, key)
+		if err != nil {
+			s.writeLine(session, s.formatError(err))
+
+			return
+		}
+		if item == nil {
+			continue
+		}
+		if withCAS {
+			s.writeLine(session, fmt.Sprintf("VALUE %s %d %d %d", key, item.Flags, len(item.Value), item.CAS))
+		} else {
+			s.writeLine(session, fmt.Sprintf("VALUE %s %d %d", key, item.Flags, len(item.Value)))
+		}
+		s.writeLine(session, item.Value)
+	}
+	s.writeLine(session, "END")
+}
+
+// handleStorage разбирает команды "<command> <key> <flags> <exptime> <bytes> [<cas>]"
+// и читает следующий за ними блок данных.
+func (s *MemcachedService) handleStorage(
+	ctx context.Context,
+	session *memcachedSession,
+	command string,
+	arguments []string,
+) (string, error) {
+	argumentsCount := 4
+	if command == "cas" {
+		argumentsCount = 5
+	}
+	if len(arguments) != argumentsCount {
+		return "ERROR", nil
+	}
+	size, err := strconv.Atoi(arguments[3])
+	if err != nil || size < 0 {
+		return "", errMemcachedBadFormat
+	}
+	if size > s.maxMessageSize {
+		// блок данных пропускается, чтобы продолжить чтение команд
+		if _, err := io.CopyN(io.Discard, session.reader, int64(size)+2); err != nil {
+			return "", &memcachedReadError{err: err}
+		}
+
+		return "SERVER_ERROR object too large for cache", nil
+	}
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(session.reader, data); err != nil {
+		return "", &memcachedReadError{err: err}
+	}
+	if string(data[size:]) != "\r\n" {
+		return "", errMemcachedBadDataChunk
+	}
+	if command == "set" && s.controller.AccessControlEnabled() && session.session.User() == nil {
+		return s.authenticate(ctx, string(data[:size]))
+	}
+
+	key := arguments[0]
+	if err := validateMemcachedKey(key); err != nil {
+		return "", err
+	}
+	flags, err := strconv.ParseUint(arguments[1], 10, 32)
+	if err != nil {
+		return "", errMemcachedBadFormat
+	}
+	exptime, err := strconv.ParseInt(arguments[2], 10, 64)
+	if err != nil {
+		return "", errMemcachedBadFormat
+	}
+	item := &memcached.Item{Key: key, Value: string(data[:size]), Flags: uint32(flags)}
+
+	var result memcached.StoreResult
+	switch command {
+	case "set":
+		result, err = memcached.Stored, s.store.Set(ctx, item, exptime)
+	case "add":
+		result, err = s.store.Add(ctx, item, exptime)
+	case "replace":
+		result, err = s.store.Replace(ctx, item, exptime)
+	case "cas":
+		cas, parseErr := strconv.ParseUint(arguments[4], 10, 64)
+		if parseErr != nil {
+			return "", errMemcachedBadFormat
+		}
+		result, err = s.store.CompareAndSwap(ctx, item, exptime, cas)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return result.String(), nil
+}
+
+// authenticate проверяет учетные данные "<username> <password>" из блока данных команды set.
+func (s *MemcachedService) authenticate(ctx context.Context, credentials string) (string, error) {
+	name, password, found := strings.Cut(credentials, " ")
+	if !found {
+		return "CLIENT_ERROR authentication failure", nil
+	}
+	if err := s.controller.Authenticate(ctx, name, password); err != nil {
+		if errors.Is(err, acl.ErrInvalidCredentials) {
+			return "CLIENT_ERROR authentication failure", nil
+		}
+
+		return "", err
+	}
+
+	return memcached.Stored.String(), nil
+}
+
+func (s *MemcachedService) handleDelete(ctx context.Context, arguments []string) (string, error) {
+	if len(arguments) != 1 {
+		return "ERROR", nil
+	}
+	if err := validateMemcachedKey(arguments[0]); err != nil {
+		return "", err
+	}
+
+	deleted, err := s.store.Delete(ctx, arguments[0])
+	if err != nil {
+		return "", err
+	}
+	if !deleted {
+		return "NOT_FOUND", nil
+	}
+
+	return "DELETED", nil
+}
+
+func (s *MemcachedService) handleIncrement(ctx context.Context, arguments []string, decrement bool) (string, error) {
+	if len(arguments) != 2 {
+		return "ERROR", nil
+	}
+	if err := validateMemcachedKey(arguments[0]); err != nil {
+		return "", err
+	}
+	delta, err := strconv.ParseUint(arguments[1], 10, 64)
+	if err != nil {
+		return "CLIENT_ERROR invalid numeric delta argument", nil
+	}
+
+	value, found, err := s.store.Increment(ctx, arguments[0], delta, decrement)
+	if err != nil {
+		return "", err
+	}
+	if !found {
+		return "NOT_FOUND", nil
+	}
+
+	return strconv.FormatUint(value, 10), nil
+}
+
+func (s *MemcachedService) handleTouch(ctx context.Context, arguments []string) (string, error) {
+	if len(arguments) != 2 {
+		return "ERROR", nil
+	}
+	if err := validateMemcachedKey(arguments[0]); err != nil {
+		return "", err
+	}
+	exptime, err := strconv.ParseInt(arguments[1], 10, 64)
+	if err != nil {
+		return "", errMemcachedBadFormat
+	}
+
+	touched, err := s.store.Touch(ctx, arguments[0], exptime)
+	if err != nil {
+		return "", err
+	}
+	if !touched {
+		return "NOT_FOUND", nil
+	}
+
+	return "TOUCHED", nil
+}
+
+func (s *MemcachedService) handleFlushAll(ctx context.Context, arguments []string) (string, error) {
+	if len(arguments) > 1 {
+		return "ERROR", nil
+	}
+	delay := 0
+	if len(arguments) == 1 {
+		var err error
+		delay, err = strconv.Atoi(arguments[0])
+		if err != nil || delay < 0 {
+			return "", errMemcachedBadFormat
+		}
+	}
+
+	if err := s.store.FlushAll(ctx, time.Duration(delay)*time.Second); err != nil {
+		return "", err
+	}
+
+	return "OK", nil
+}
+
+// readLine читает командную строку без завершающего "\r\n".
+func (s *MemcachedService) readLine(session *memcachedSession) (string, error) {
+	line, err := session.reader.ReadSlice('\n')
+	if errors.Is(err, bufio.ErrBufferFull) {
+		return "", errMemcachedLineTooLong
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(line), "\r\n"), nil
+}
+
+func (s *MemcachedService) writeLine(session *memcachedSession, line string) {
+	session.writer.WriteString(line)
+	session.writer.WriteString("\r\n")
+}
+
+// formatError преобразует ошибку в ответ: ошибки запроса - CLIENT_ERROR,
+// остальные - SERVER_ERROR с тем же текстом, что и в основном протоколе.
+func (s *MemcachedService) formatError(err error) string {
+	for _, clientErr := range []error{errMemcachedBadFormat, errMemcachedBadDataChunk, memcached.ErrNotNumber} {
+		if errors.Is(err, clientErr) {
+			return "CLIENT_ERROR " + clientErr.Error()
+		}
+	}
+	var badRequest *engine.BadRequestError
+	if errors.As(err, &badRequest) || errors.Is(err, acl.ErrNotAuthenticated) || errors.Is(err, acl.ErrForbidden) {
+		return "CLIENT_ERROR " + formatError(err, s.logger)
+	}
+
+	return "SERVER_ERROR " + formatError(err, s.logger)
+}
+
+func validateMemcachedKey(key string) error {
+	if len(key) > memcachedMaxKeyLength {
+		return errMemcachedBadFormat
+	}
+	for _, symbol := range []byte(key) {
+		if symbol <= ' ' || symbol == 0x7f {
+			return errMemcachedBadFormat
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,68 @@
+package multileader
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+// Controller - адаптер контроллера базы данных для режима нескольких лидеров.
+// Каждая локальная команда записи получает версию из метки гибридных логических
+// часов и идентификатора узла, после чего записывается в WAL журнал и применяется
+// через Register. Команды других узлов применяются через ApplyRemote с их
+// исходной версией.
+type Controller struct {
+	storageController StorageController
+	register          *Register
+	clock             *hlc.Clock
+	nodeID            string
+	logger            *slog.Logger
+}
+
+// NewController создает контроллер. Параметр storageController - контроллер WAL
+// журнала, выполняющий команды через register.
+func NewController(
+	storageController StorageController,
+	register *Register,
+	clock *hlc.Clock,
+	nodeID string,
+	logger *slog.Logger,
+) *Controller {
+	// метки новых записей должны быть больше меток записей, восстановленных из журнала,
+	// даже если физическое время после перезапуска отстает
+	if timestamp := register.MaxTimestamp(); !timestamp.IsZero() {
+		clock.Update(timestamp)
+	}
+
+	return &Controller{
+		storageController: storageController,
+		register:          register,
+		clock:             clock,
+		nodeID:            nodeID,
+		logger:            logger,
+	}
+}
+
+func (c *Controller) Execute(ctx context.Context, command *querylang.Command) (string, error) {
+	if command.ID() == querylang.CommandSet || command.ID() == querylang.CommandDel {
+		command = command.WithVersion(querylang.Version{Timestamp: c.clock.Now(), NodeID: c.nodeID})
+	}
+
+	return c.storageController.Execute(ctx, command)
+}
+
+// ApplyRemote применяет команду записи, выполненную на другом узле. Команды,
+// перекрытые более новыми записями, не записываются в журнал.
+func (c *Controller) ApplyRemote(ctx context.Context, command *querylang.Command) error {
+	version := command.Version()
+	c.clock.Update(version.Timestamp)
+	if !c.register.IsNewer(command.Arguments()[0], version) {
+		return nil
+	}
+
+	_, err := c.storageController.Execute(ctx, command)
+
+	return err
+}
@@ -0,0 +1,8 @@
+package multileader
+
+import "errors"
+
+var (
+	ErrInvalidChange  = errors.New("invalid change format")
+	ErrStreamRejected = errors.New("changes stream rejected by peer")
+)
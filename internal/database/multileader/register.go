@@ -0,0 +1,110 @@
+package multileader
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+const registerStripes = 256
+
+type StorageController interface {
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
+}
+
+type registerStripe struct {
+	mu       sync.Mutex
+	versions map[string]querylang.Version
+}
+
+// Register - адаптер контроллера хранилища, разрешающий конфликты записи
+// по правилу "побеждает последняя запись" (last-writer-wins). Для каждого ключа
+// хранится версия последней примененной записи, в том числе для удаленных ключей
+// (tombstone), поэтому запоздавшая запись не восстанавливает удаленный ключ.
+// Команда применяется, только если ее версия больше версии ключа, поэтому
+// результат не зависит от порядка применения команд.
+//
+// Команды без версии (например, из WAL журнала, записанного до включения режима)
+// применяются безусловно и сбрасывают версию ключа.
+type Register struct {
+	storageController StorageController
+	stripes           [registerStripes]registerStripe
+
+	mu           sync.Mutex
+	maxTimestamp hlc.Timestamp
+}
+
+func NewRegister(storageController StorageController) *Register {
+	r := &Register{storageController: storageController}
+	for i := range r.stripes {
+		r.stripes[i].versions = make(map[string]querylang.Version)
+	}
+
+	return r
+}
+
+func (r *Register) Execute(ctx context.Context, command *querylang.Command) (string, error) {
+	if command.ID() != querylang.CommandSet && command.ID() != querylang.CommandDel {
+		return r.storageController.Execute(ctx, command)
+	}
+
+	key := command.Arguments()[0]
+	version := command.Version()
+	stripe := r.stripe(key)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+
+	current, exists := stripe.versions[key]
+	if exists && !version.IsZero() && version.Compare(current) <= 0 {
+		// более новая запись уже применена
+		return "OK", nil
+	}
+
+	result, err := r.storageController.Execute(ctx, command)
+	if err != nil {
+		return "", err
+	}
+	stripe.versions[key] = version
+	r.observe(version.Timestamp)
+
+	return result, nil
+}
+
+// IsNewer проверяет, что запись с версией version для ключа key еще не перекрыта
+// более новой записью.
+func (r *Register) IsNewer(key string, version querylang.Version) bool {
+	stripe := r.stripe(key)
+	stripe.mu.Lock()
+	defer stripe.mu.Unlock()
+
+	current, exists := stripe.versions[key]
+
+	return !exists || version.Compare(current) > 0
+}
+
+// MaxTimestamp возвращает наибольшую метку часов среди примененных записей.
+func (r *Register) MaxTimestamp() hlc.Timestamp {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.maxTimestamp
+}
+
+func (r *Register) observe(timestamp hlc.Timestamp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if timestamp.Compare(r.maxTimestamp) > 0 {
+		r.maxTimestamp = timestamp
+	}
+}
+
+func (r *Register) stripe(key string) *registerStripe {
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+
+	return &r.stripes[hash.Sum32()%registerStripes]
+}
@@ -0,0 +1,103 @@
+package multileader_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+	"github.com/strider2038/key-value-database/internal/database/multileader"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/storage"
+	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
+)
+
+func TestRegister_Execute(t *testing.T) {
+	tests := []struct {
+		name      string
+		commands  []*querylang.Command
+		wantValue string
+	}{
+		{
+			name: "later write wins regardless of order",
+			commands: []*querylang.Command{
+				set("value2", version(2, "a")),
+				set("value1", version(1, "b")),
+			},
+			wantValue: "value2",
+		},
+		{
+			name: "equal timestamps resolved by node ID",
+			commands: []*querylang.Command{
+				set("from-b", version(1, "b")),
+				set("from-a", version(1, "a")),
+			},
+			wantValue: "from-b",
+		},
+		{
+			name: "tombstone prevents resurrection by older write",
+			commands: []*querylang.Command{
+				set("value", version(1, "a")),
+				del(version(3, "b")),
+				set("stale", version(2, "a")),
+			},
+			wantValue: querylang.Nil,
+		},
+		{
+			name: "newer write after delete",
+			commands: []*querylang.Command{
+				del(version(1, "b")),
+				set("value", version(2, "a")),
+			},
+			wantValue: "value",
+		},
+		{
+			name: "unversioned write applied unconditionally",
+			commands: []*querylang.Command{
+				set("value2", version(2, "a")),
+				set("unversioned", querylang.Version{}),
+			},
+			wantValue: "unversioned",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			register := multileader.NewRegister(storage.NewController(inmemory.NewMapStorage()))
+
+			for _, command := range test.commands {
+				result, err := register.Execute(context.Background(), command)
+				require.NoError(t, err)
+				assert.Equal(t, "OK", result)
+			}
+
+			value, err := register.Execute(context.Background(), querylang.NewCommand(100, querylang.CommandGet, "key"))
+			require.NoError(t, err)
+			assert.Equal(t, test.wantValue, value)
+		})
+	}
+}
+
+func TestRegister_IsNewer(t *testing.T) {
+	register := multileader.NewRegister(storage.NewController(inmemory.NewMapStorage()))
+	_, err := register.Execute(context.Background(), del(version(2, "a")))
+	require.NoError(t, err)
+
+	assert.False(t, register.IsNewer("key", version(2, "a")))
+	assert.False(t, register.IsNewer("key", version(1, "b")))
+	assert.True(t, register.IsNewer("key", version(2, "b")))
+	assert.True(t, register.IsNewer("other", version(1, "a")))
+	assert.Equal(t, hlc.Timestamp{WallTime: 2}, register.MaxTimestamp())
+}
+
+func version(wallTime int64, nodeID string) querylang.Version {
+	return querylang.Version{Timestamp: hlc.Timestamp{WallTime: wallTime}, NodeID: nodeID}
+}
+
+func set(value string, version querylang.Version) *querylang.Command {
+	return querylang.NewCommand(1, querylang.CommandSet, "key", value).WithVersion(version)
+}
+
+func del(version querylang.Version) *querylang.Command {
+	return querylang.NewCommand(1, querylang.CommandDel, "key").WithVersion(version)
+}
@@ -0,0 +1,203 @@
+package multileader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/storage/wal"
+)
+
+// Peer - другой узел, принимающий запись в режиме нескольких лидеров.
+type Peer struct {
+	ID      string
+	Address string
+}
+
+type IDGenerator interface {
+	NextSeqID() uint64
+}
+
+// StreamClient - соединение с узлом для получения потока изменений.
+type StreamClient interface {
+	Stream(request []byte, receive func(message []byte)) error
+	Close() error
+}
+
+type Dialer func(address string) (StreamClient, error)
+
+// Replicator - сервис получения записей от других узлов. Для каждого узла
+// открывается поток изменений SUBSCRIBE-CHANGES, содержащий только записи,
+// выполненные на этом узле, поэтому записи не пересылаются по кругу.
+//
+// Позиция в потоке каждого узла хранится в памяти. После перезапуска записи
+// запрашиваются с начала журнала узла: повторное применение не меняет данные.
+type Replicator struct {
+	controller    *Controller
+	peers         []Peer
+	idGenerator   IDGenerator
+	dial          Dialer
+	retryInterval time.Duration
+	logger        *slog.Logger
+}
+
+func NewReplicator(
+	controller *Controller,
+	peers []Peer,
+	idGenerator IDGenerator,
+	dial Dialer,
+	retryInterval time.Duration,
+	logger *slog.Logger,
+) *Replicator {
+	return &Replicator{
+		controller:    controller,
+		peers:         peers,
+		idGenerator:   idGenerator,
+		dial:          dial,
+		retryInterval: retryInterval,
+		logger:        logger,
+	}
+}
+
+// Serve получает записи от всех узлов до отмены контекста. При обрыве соединения
+// поток изменений переоткрывается с последней примененной записи.
+func (r *Replicator) Serve(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+	for _, peer := range r.peers {
+		wg.Add(1)
+		go func(peer Peer) {
+			defer wg.Done()
+			r.replicatePeer(ctx, peer)
+		}(peer)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (r *Replicator) replicatePeer(ctx context.Context, peer Peer) {
+	from := "0.0"
+	for {
+		err := r.stream(ctx, peer, &from)
+		if ctx.Err() != nil {
+			return
+		}
+		r.logger.Warn("changes stream from peer interrupted", "peer", peer.ID, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(r.retryInterval):
+		}
+	}
+}
+
+func (r *Replicator) stream(ctx context.Context, peer Peer, from *string) error {
+	client, err := r.dial(peer.Address)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", peer.Address, err)
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var applyErr error
+	go func() {
+		<-streamCtx.Done()
+		client.Close()
+	}()
+
+	lines := lineBuffer{}
+	request := fmt.Sprintf("SUBSCRIBE-CHANGES FROM %s ORIGIN %s", *from, peer.ID)
+	r.logger.Info("changes stream from peer started", "peer", peer.ID, "from", *from)
+	err = client.Stream([]byte(request), func(message []byte) {
+		for _, line := range lines.Write(message) {
+			if applyErr != nil {
+				return
+			}
+			lsn, err := r.apply(streamCtx, line)
+			if err != nil {
+				applyErr = fmt.Errorf("apply change %q: %w", line, err)
+				cancel()
+
+				return
+			}
+			*from = lsn
+		}
+	})
+	if applyErr != nil {
+		return applyErr
+	}
+	if err != nil {
+		return err
+	}
+	if len(lines.pending) > 0 {
+		// ответ сервера с ошибкой не завершается переводом строки
+		return fmt.Errorf("%w: %s", ErrStreamRejected, lines.pending)
+	}
+
+	return errors.New("stream closed by peer")
+}
+
+// apply разбирает и применяет изменение в формате
+// "<lsn> <метка часов> <узел> <команда> <аргументы>". Аргументы с пробелами
+// и переводами строк передаются в кавычках. Возвращает LSN изменения.
+func (r *Replicator) apply(ctx context.Context, line string) (string, error) {
+	fields, err := wal.SplitChange(line)
+	if err != nil {
+		return "", err
+	}
+	if len(fields) < 5 {
+		return "", ErrInvalidChange
+	}
+
+	timestamp, err := hlc.ParseTimestamp(fields[1])
+	if err != nil {
+		return "", err
+	}
+	version := querylang.Version{Timestamp: timestamp, NodeID: fields[2]}
+	var commandID querylang.CommandID
+	switch fields[3] {
+	case querylang.CommandSet.String():
+		commandID = querylang.CommandSet
+	case querylang.CommandDel.String():
+		commandID = querylang.CommandDel
+	default:
+		// остальные команды не реплицируются
+		return fields[0], nil
+	}
+
+	command := querylang.NewCommand(r.idGenerator.NextSeqID(), commandID, fields[4:]...).WithVersion(version)
+	if err := r.controller.ApplyRemote(ctx, command); err != nil {
+		return "", err
+	}
+
+	return fields[0], nil
+}
+
+// lineBuffer собирает строки из сообщений потока: сообщение может содержать
+// несколько строк или только часть строки.
+type lineBuffer struct {
+	pending []byte
+}
+
+func (b *lineBuffer) Write(message []byte) []string {
+	b.pending = append(b.pending, message...)
+
+	var lines []string
+	for {
+		end := bytes.IndexByte(b.pending, '\n')
+		if end < 0 {
+			break
+		}
+		lines = append(lines, string(b.pending[:end]))
+		b.pending = b.pending[end+1:]
+	}
+
+	return lines
+}
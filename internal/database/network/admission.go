@@ -0,0 +1,96 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ErrTooManyConnections - соединение отклонено, так как достигнуто ограничение
+// количества соединений.
+var ErrTooManyConnections = errors.New("too many connections")
+
+// OverloadPolicy определяет, что происходит с новым соединением, когда
+// достигнуто ограничение MaxConnections.
+type OverloadPolicy string
+
+const (
+	// OverloadReject - клиенту сразу отправляется ошибка, соединение закрывается.
+	// Используется, если политика не задана.
+	OverloadReject OverloadPolicy = "reject"
+	// OverloadQueue - соединение ожидает освобождения места в очереди
+	// ограниченного размера не дольше QueueTimeout, затем отклоняется.
+	OverloadQueue OverloadPolicy = "queue"
+)
+
+// ConnectionRejecter может быть реализован обработчиком соединений, чтобы
+// сообщить клиенту об отказе в формате своего протокола. Соединение
+// закрывается сервером после возврата из RejectConnection.
+type ConnectionRejecter interface {
+	RejectConnection(connection net.Conn, err error)
+}
+
+type reservedConnectionKey struct{}
+
+// IsReservedConnection сообщает, что соединение принято из резерва для
+// администраторов: выполнять команды в нем могут только пользователи
+// с правами admin.
+func IsReservedConnection(ctx context.Context) bool {
+	reserved, _ := ctx.Value(reservedConnectionKey{}).(bool)
+
+	return reserved
+}
+
+func withReservedConnection(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reservedConnectionKey{}, true)
+}
+
+// admission распределяет места для соединений одного адреса: основные
+// (MaxConnections), резервные для администраторов (ReservedConnections)
+// и места в очереди ожидания (QueueSize) при политике OverloadQueue.
+type admission struct {
+	connections  *Semaphore
+	reserved     *Semaphore
+	queue        *Semaphore
+	queueTimeout time.Duration
+}
+
+func newAdmission(listener Listener) *admission {
+	a := &admission{connections: NewSemaphore(listener.MaxConnections)}
+	if listener.ReservedConnections > 0 {
+		a.reserved = NewSemaphore(listener.ReservedConnections)
+	}
+	if listener.Overload == OverloadQueue {
+		a.queue = NewSemaphore(listener.QueueSize)
+		a.queueTimeout = listener.QueueTimeout
+	}
+
+	return a
+}
+
+// admit занимает место для нового соединения и возвращает функцию его
+// освобождения. Резервное место выдается, только если заняты все основные,
+// а очередь используется, только если заняты и резервные места.
+func (a *admission) admit(ctx context.Context) (context.Context, func(), error) {
+	if a.connections.TryAcquire() {
+		return ctx, a.connections.Release, nil
+	}
+	if a.reserved != nil && a.reserved.TryAcquire() {
+		return withReservedConnection(ctx), a.reserved.Release, nil
+	}
+	if a.queue == nil {
+		return nil, nil, ErrTooManyConnections
+	}
+	if !a.queue.TryAcquire() {
+		return nil, nil, fmt.Errorf("%w: queue is full", ErrTooManyConnections)
+	}
+	defer a.queue.Release()
+
+	if !a.connections.AcquireContext(ctx, a.queueTimeout) {
+		return nil, nil, fmt.Errorf("%w: queue timeout exceeded", ErrTooManyConnections)
+	}
+
+	return ctx, a.connections.Release, nil
+}
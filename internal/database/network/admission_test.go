@@ -0,0 +1,133 @@
+package network_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestTCPServer_Serve_WhenMaxConnectionsReached_ExpectConnectionRejected(t *testing.T) {
+	const address = "127.0.0.1:10010"
+	startServerWithListener(t, network.Listener{Address: address, MaxConnections: 1}, echoHandler())
+	first, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer first.Close()
+
+	_, err = network.NewTCPClient(address, nil, messageSize, time.Second)
+
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, network.ErrTooManyConnections.Error(), serverErr.Message)
+	// принятое ранее соединение продолжает работать
+	response, err := first.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func TestTCPServer_Serve_WhenQueueEnabled_ExpectConnectionWaitsForFreeSlot(t *testing.T) {
+	const address = "127.0.0.1:10011"
+	startServerWithListener(t, network.Listener{
+		Address:        address,
+		MaxConnections: 1,
+		Overload:       network.OverloadQueue,
+		QueueSize:      1,
+		QueueTimeout:   100 * time.Millisecond,
+	}, echoHandler())
+	first, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+
+	// место не освободилось за время ожидания в очереди
+	_, err = network.NewTCPClient(address, nil, messageSize, time.Second)
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Contains(t, serverErr.Message, "queue timeout exceeded")
+
+	connected := make(chan *network.TCPClient)
+	go func() {
+		client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+		assert.NoError(t, err)
+		connected <- client
+	}()
+	time.Sleep(20 * time.Millisecond)
+	require.NoError(t, first.Close())
+	second := <-connected
+	require.NotNil(t, second)
+	defer second.Close()
+	response, err := second.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func TestTCPServer_Serve_WhenReservedConnectionsEnabled_ExpectReservedConnectionMarked(t *testing.T) {
+	const address = "127.0.0.1:10012"
+	startServerWithListener(t, network.Listener{
+		Address:             address,
+		MaxConnections:      1,
+		ReservedConnections: 1,
+	}, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		if network.IsReservedConnection(ctx) {
+			return []byte("reserved")
+		}
+
+		return []byte("regular")
+	}))
+
+	regular, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer regular.Close()
+	reserved, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer reserved.Close()
+	_, err = network.NewTCPClient(address, nil, messageSize, time.Second)
+
+	assert.ErrorContains(t, err, network.ErrTooManyConnections.Error())
+	response, err := regular.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "regular", string(response))
+	response, err = reserved.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "reserved", string(response))
+}
+
+func echoHandler() network.Handler {
+	return network.HandlerFunc(func(ctx context.Context, bytes []byte) []byte {
+		return append([]byte("echo to "), bytes...)
+	})
+}
+
+func startServerWithListener(tb testing.TB, listener network.Listener, handler network.Handler) {
+	tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	server, err := network.NewTCPServer(
+		[]network.Listener{listener},
+		nil,
+		messageSize,
+		0,
+		time.Second,
+		time.Second,
+		nil,
+		network.OutputLimits{},
+		func() { close(waitStartup) },
+		logger,
+	)
+	require.NoError(tb, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(tb, server.Serve(ctx, handler), "serve")
+	}()
+	tb.Cleanup(func() {
+		stop()
+		<-done
+	})
+	waitSecond(tb, waitStartup)
+}
@@ -0,0 +1,231 @@
+package network
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientInfo - сведения о клиентском соединении для команды CLIENT LIST.
+type ClientInfo struct {
+	ID          uint64
+	Address     string
+	Name        string
+	ConnectedAt time.Time
+	// LastCommand - последняя выполненная команда, пустая строка - если
+	// команды еще не выполнялись.
+	LastCommand string
+	// Idle - время, прошедшее с последней команды или с момента подключения.
+	Idle     time.Duration
+	BytesIn  int64
+	BytesOut int64
+}
+
+// String возвращает описание соединения в формате "id=1 addr=... name=...".
+// Время подключения и простоя указывается в секундах.
+func (c ClientInfo) String() string {
+	return fmt.Sprintf(
+		"id=%d addr=%s name=%s age=%d idle=%d cmd=%s in=%d out=%d",
+		c.ID,
+		c.Address,
+		c.Name,
+		int64(time.Since(c.ConnectedAt).Seconds()),
+		int64(c.Idle.Seconds()),
+		c.LastCommand,
+		c.BytesIn,
+		c.BytesOut,
+	)
+}
+
+// Client - клиентское соединение, зарегистрированное в ClientRegistry.
+// Обработчик получает его из контекста соединения через ClientFromContext.
+type Client struct {
+	id          uint64
+	address     string
+	connectedAt time.Time
+	kill        context.CancelFunc
+
+	bytesIn  atomic.Int64
+	bytesOut atomic.Int64
+
+	mu           sync.Mutex
+	name         string
+	lastCommand  string
+	lastActiveAt time.Time
+}
+
+func (c *Client) ID() uint64 { return c.id }
+
+func (c *Client) Name() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.name
+}
+
+// SetName задает имя соединения, по которому его можно найти в CLIENT LIST.
+func (c *Client) SetName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.name = name
+}
+
+// Kill закрывает соединение так же, как ClientRegistry.Kill: новые запросы
+// не читаются, ответ на выполняемый запрос отправляется клиенту.
+func (c *Client) Kill() {
+	c.kill()
+}
+
+// SetLastCommand отмечает выполнение команды и сбрасывает время простоя.
+func (c *Client) SetLastCommand(command string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.lastCommand = command
+	c.lastActiveAt = time.Now()
+}
+
+func (c *Client) Info() ClientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return ClientInfo{
+		ID:          c.id,
+		Address:     c.address,
+		Name:        c.name,
+		ConnectedAt: c.connectedAt,
+		LastCommand: c.lastCommand,
+		Idle:        time.Since(c.lastActiveAt),
+		BytesIn:     c.bytesIn.Load(),
+		BytesOut:    c.bytesOut.Load(),
+	}
+}
+
+type clientKey struct{}
+
+// ClientFromContext возвращает соединение, в рамках которого выполняется запрос.
+func ClientFromContext(ctx context.Context) (*Client, bool) {
+	client, ok := ctx.Value(clientKey{}).(*Client)
+
+	return client, ok
+}
+
+// ClientRegistry хранит открытые соединения одного или нескольких серверов.
+// Общий реестр позволяет видеть и закрывать соединения всех протоколов
+// (основного, RESP, memcached) с единой нумерацией.
+type ClientRegistry struct {
+	mu      sync.Mutex
+	lastID  uint64
+	clients map[uint64]*Client
+}
+
+func NewClientRegistry() *ClientRegistry {
+	return &ClientRegistry{clients: make(map[uint64]*Client)}
+}
+
+// List возвращает сведения об открытых соединениях в порядке подключения.
+func (r *ClientRegistry) List() []ClientInfo {
+	r.mu.Lock()
+	clients := make([]*Client, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client)
+	}
+	r.mu.Unlock()
+
+	slices.SortFunc(clients, func(a, b *Client) int {
+		return cmp.Compare(a.id, b.id)
+	})
+	list := make([]ClientInfo, len(clients))
+	for i, client := range clients {
+		list[i] = client.Info()
+	}
+
+	return list
+}
+
+// Kill закрывает соединение с идентификатором id. Выполняемый запрос
+// завершается так же, как при остановке сервера. Возвращает false,
+// если соединение не найдено.
+func (r *ClientRegistry) Kill(id uint64) bool {
+	r.mu.Lock()
+	client, ok := r.clients[id]
+	r.mu.Unlock()
+
+	if ok {
+		client.kill()
+	}
+
+	return ok
+}
+
+// KillByAddress закрывает соединения с адреса клиента address и возвращает
+// их количество.
+func (r *ClientRegistry) KillByAddress(address string) int {
+	r.mu.Lock()
+	var killed []*Client
+	for _, client := range r.clients {
+		if client.address == address {
+			killed = append(killed, client)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, client := range killed {
+		client.kill()
+	}
+
+	return len(killed)
+}
+
+// register добавляет соединение в реестр. Возвращает контекст соединения,
+// который отменяется при его закрытии через Kill, соединение, считающее
+// переданные байты, и функцию удаления из реестра.
+func (r *ClientRegistry) register(ctx context.Context, connection net.Conn) (context.Context, net.Conn, func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	now := time.Now()
+	client := &Client{
+		address:      connection.RemoteAddr().String(),
+		connectedAt:  now,
+		kill:         cancel,
+		lastActiveAt: now,
+	}
+
+	r.mu.Lock()
+	r.lastID++
+	client.id = r.lastID
+	r.clients[client.id] = client
+	r.mu.Unlock()
+
+	return context.WithValue(ctx, clientKey{}, client), &countingConn{Conn: connection, client: client}, func() {
+		cancel()
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		delete(r.clients, client.id)
+	}
+}
+
+// countingConn считает байты, прочитанные из соединения и записанные в него.
+type countingConn struct {
+	net.Conn
+	client *Client
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.client.bytesIn.Add(int64(n))
+
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.client.bytesOut.Add(int64(n))
+
+	return n, err
+}
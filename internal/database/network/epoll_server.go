@@ -0,0 +1,761 @@
+//go:build linux
+
+package network
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+const (
+	// epollWaitTimeout - период, с которым цикл ожидания событий проверяет
+	// остановку сервера.
+	epollWaitTimeout = 100 * time.Millisecond
+	// epollEvents - события готовности соединения к чтению. Соединение
+	// регистрируется однократно (EPOLLONESHOT): после обработки запроса
+	// обработчик снова подписывает его на события.
+	epollEvents = syscall.EPOLLIN | syscall.EPOLLRDHUP | syscall.EPOLLONESHOT
+)
+
+// EpollServer - реализация основного протокола на epoll. В отличие от
+// TCPServer, соединение без запросов не занимает горутину и буфер: готовые
+// к чтению соединения обрабатываются небольшим пулом горутин, а буферы
+// сообщений берутся из общего пула на время чтения и обработки запроса.
+// Это позволяет держать десятки тысяч простаивающих соединений.
+//
+// Данные читаются без ожидания: кадр, пришедший не целиком, дочитывается
+// при следующих событиях готовности соединения. Ответы отправляются через
+// буфер ответов соединения. Поэтому медленный клиент не занимает горутины
+// пула.
+//
+// Запросы соединения выполняются по одному: кадры FrameUnorderedRequest
+// выполняются так же, как FrameRequest. Потоковые команды (SUBSCRIBE-CHANGES),
+// передача значений по частям (FrameChunk), TLS и очередь ожидания соединений
+// не поддерживаются.
+type EpollServer struct {
+	listeners      []Listener
+	maxMessageSize int
+	workers        int
+	idleTimeout    time.Duration
+	drainTimeout   time.Duration
+	clients        *ClientRegistry
+	outputLimits   OutputLimits
+	onStartup      func()
+	logger         *slog.Logger
+
+	buffers sync.Pool
+	// lastSeq - номер последнего зарегистрированного соединения.
+	lastSeq atomic.Int32
+	// closing - закрываемые соединения, ответы которых еще отправляются.
+	closing sync.WaitGroup
+
+	mu          sync.Mutex
+	connections map[int32]*epollConnection
+}
+
+// NewEpollServer создает сервер, принимающий соединения на всех адресах
+// listeners. Запросы выполняются workers горутинами. Соединения сверх
+// MaxConnections адреса отклоняются. Открытые соединения регистрируются
+// в clients, если он не nil, иначе в собственном реестре сервера.
+// Буфер ответов соединения ограничен outputLimits для обычных клиентов.
+func NewEpollServer(
+	listeners []Listener,
+	maxMessageSize int,
+	workers int,
+	idleTimeout time.Duration,
+	drainTimeout time.Duration,
+	clients *ClientRegistry,
+	outputLimits OutputLimits,
+	onStartup func(),
+	logger *slog.Logger,
+) (*EpollServer, error) {
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("at least one listener is required")
+	}
+	for _, listener := range listeners {
+		if listener.MaxConnections <= 0 {
+			return nil, fmt.Errorf("max connections of %s should be > 0", listener.Address)
+		}
+		if listener.Overload == OverloadQueue || listener.ReservedConnections > 0 {
+			return nil, fmt.Errorf("queue and reserved connections of %s are not supported by epoll server", listener.Address)
+		}
+	}
+	if maxMessageSize <= 0 {
+		return nil, fmt.Errorf("max message size should be > 0")
+	}
+	if workers <= 0 {
+		return nil, fmt.Errorf("workers count should be > 0")
+	}
+	if idleTimeout <= 0 {
+		return nil, fmt.Errorf("idle timeout should be > 0")
+	}
+	if drainTimeout < 0 {
+		return nil, fmt.Errorf("drain timeout should be >= 0")
+	}
+	if err := outputLimits.Normal.validate(ClientClassNormal); err != nil {
+		return nil, err
+	}
+	if err := outputLimits.Stream.validate(ClientClassStream); err != nil {
+		return nil, err
+	}
+	if clients == nil {
+		clients = NewClientRegistry()
+	}
+	if onStartup == nil {
+		onStartup = func() {}
+	}
+
+	return &EpollServer{
+		listeners:      listeners,
+		maxMessageSize: maxMessageSize,
+		workers:        workers,
+		idleTimeout:    idleTimeout,
+		drainTimeout:   drainTimeout,
+		clients:        clients,
+		outputLimits:   outputLimits,
+		onStartup:      onStartup,
+		logger:         logger,
+		buffers: sync.Pool{New: func() any {
+			buffer := make([]byte, maxMessageSize)
+
+			return &buffer
+		}},
+		connections: make(map[int32]*epollConnection),
+	}, nil
+}
+
+// Clients возвращает реестр соединений сервера.
+func (s *EpollServer) Clients() *ClientRegistry {
+	return s.clients
+}
+
+// epollConnection - соединение, зарегистрированное в epoll. Мьютекс
+// удерживается на время обработки запроса, поэтому соединение не может быть
+// закрыто по таймауту простоя или командой CLIENT KILL посреди запроса.
+type epollConnection struct {
+	fd int
+	// seq передается в событиях epoll вместе с дескриптором: дескриптор
+	// закрытого соединения может быть сразу выдан новому соединению,
+	// а событие старого соединения - еще не обработано.
+	seq     int32
+	raw     net.Conn
+	rawConn syscall.RawConn
+	client  *Client
+	output  *outputBuffer
+	ctx     context.Context
+	release func()
+
+	mu           sync.Mutex
+	handshaken   bool
+	errorFrames  bool
+	frame        epollFrame
+	closed       bool
+	lastActiveAt time.Time
+	stopKill     func() bool
+	unregister   func()
+}
+
+// epollFrame - состояние чтения кадра запроса, который может поступать
+// по частям в нескольких событиях готовности соединения.
+type epollFrame struct {
+	// header - заголовок кадра, а для кадра с идентификатором запроса,
+	// превышающего ограничение размера, и идентификатор.
+	header    [frameHeaderSize + requestIDSize]byte
+	headerLen int
+	// buffer - буфер из пула сервера для содержимого кадра, nil - если
+	// содержимое кадра еще не читалось.
+	buffer *[]byte
+	// read - количество прочитанных (или пропущенных, если кадр превышает
+	// ограничение размера) байт содержимого кадра.
+	read int
+}
+
+// reset возвращает буфер кадра в пул и готовит чтение следующего кадра.
+func (f *epollFrame) reset(buffers *sync.Pool) {
+	if f.buffer != nil {
+		buffers.Put(f.buffer)
+	}
+	*f = epollFrame{}
+}
+
+// Serve принимает соединения и выполняет запросы до отмены ctx. При остановке
+// новые запросы не читаются, а начатые завершаются в течение drainTimeout.
+func (s *EpollServer) Serve(ctx context.Context, handler Handler) error {
+	epfd, err := syscall.EpollCreate1(syscall.EPOLL_CLOEXEC)
+	if err != nil {
+		return fmt.Errorf("create epoll: %w", err)
+	}
+	defer func() {
+		if err := syscall.Close(epfd); err != nil {
+			s.logger.Warn("close epoll", "error", err)
+		}
+	}()
+
+	listeners := make([]net.Listener, 0, len(s.listeners))
+	for _, options := range s.listeners {
+		listener, err := listen(options)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+
+			return fmt.Errorf("listen %s: %w", options.Address, err)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	s.onStartup()
+
+	drainContext, drained := drain(ctx, s.drainTimeout, s.logger)
+	// соединения закрываются сервером после завершения запросов, поэтому
+	// их контекст не отменяется вместе с ctx
+	connectionContext := context.WithValue(context.WithoutCancel(ctx), drainKey{}, drainContext)
+
+	accepting := sync.WaitGroup{}
+	for i, listener := range listeners {
+		accepting.Add(1)
+		go func(listener net.Listener, connections *Semaphore) {
+			defer accepting.Done()
+			s.accept(connectionContext, epfd, listener, connections, handler, &accepting)
+		}(listener, NewSemaphore(s.listeners[i].MaxConnections))
+	}
+
+	ready := make(chan *epollConnection, s.workers)
+	stopPolling := make(chan struct{})
+	polling := make(chan struct{})
+	go func() {
+		defer close(polling)
+		s.poll(epfd, ready, stopPolling)
+	}()
+
+	working := sync.WaitGroup{}
+	for i := 0; i < s.workers; i++ {
+		working.Add(1)
+		go func() {
+			defer working.Done()
+			for connection := range ready {
+				s.serveConnection(epfd, connection, handler)
+			}
+		}()
+	}
+
+	stopSweeping := make(chan struct{})
+	sweeping := make(chan struct{})
+	go func() {
+		defer close(sweeping)
+		s.sweep(epfd, stopSweeping)
+	}()
+
+	<-ctx.Done()
+	for _, listener := range listeners {
+		if err := listener.Close(); err != nil {
+			s.logger.Warn("close listener", "error", err)
+		}
+	}
+	accepting.Wait()
+	close(stopPolling)
+	<-polling
+	close(ready)
+	working.Wait()
+	close(stopSweeping)
+	<-sweeping
+	s.closeAll(epfd)
+	drained()
+
+	s.logger.Info("server shutdown")
+
+	return nil
+}
+
+// accept принимает соединения одного адреса и регистрирует их в epoll.
+func (s *EpollServer) accept(
+	ctx context.Context,
+	epfd int,
+	listener net.Listener,
+	connections *Semaphore,
+	handler Handler,
+	wg *sync.WaitGroup,
+) {
+	address := listener.Addr().String()
+	s.logger.Info("server started and ready to handle connections", "address", address, "io", "epoll")
+
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+
+			s.logger.Error("accept connection", "error", err, "address", address)
+
+			continue
+		}
+
+		if !connections.TryAcquire() {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.reject(connection, ErrTooManyConnections)
+			}()
+
+			continue
+		}
+
+		if err := s.register(ctx, epfd, connection, connections.Release, handler); err != nil {
+			s.logger.Warn("register connection", "error", err, "remoteAddress", connection.RemoteAddr().String())
+			_ = connection.Close()
+			connections.Release()
+		}
+	}
+}
+
+// reject отвечает на версию протокола кадром с ошибкой и закрывает соединение.
+func (s *EpollServer) reject(connection net.Conn, err error) {
+	defer connection.Close()
+
+	s.logger.Warn("connection rejected", "error", err, "remoteAddress", connection.RemoteAddr().String())
+	if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+		return
+	}
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(connection, version); err != nil {
+		return
+	}
+	if err := WriteFrame(connection, FrameError, []byte(err.Error())); err != nil {
+		s.logger.Debug("write to connection", "error", err)
+	}
+}
+
+func (s *EpollServer) register(
+	ctx context.Context,
+	epfd int,
+	connection net.Conn,
+	release func(),
+	handler Handler,
+) error {
+	fd, rawConn, err := connectionFD(connection)
+	if err != nil {
+		return err
+	}
+
+	connectionContext, counted, unregister := s.clients.register(ctx, connection)
+	client, _ := ClientFromContext(connectionContext)
+	if opener, ok := handler.(ConnectionOpener); ok {
+		connectionContext = opener.OpenConnection(connectionContext)
+	}
+	c := &epollConnection{
+		fd:           fd,
+		seq:          s.lastSeq.Add(1),
+		raw:          connection,
+		rawConn:      rawConn,
+		client:       client,
+		ctx:          connectionContext,
+		release:      release,
+		lastActiveAt: time.Now(),
+		unregister:   unregister,
+	}
+	c.output = newOutputBuffer(counted, s.idleTimeout, s.outputLimits, func(err error) {
+		s.logger.Warn("write to connection", "error", err)
+		// после закрытия на чтение соединение получает событие готовности
+		// и закрывается обработчиком
+		if reader, ok := connection.(interface{ CloseRead() error }); ok {
+			_ = reader.CloseRead()
+		}
+	})
+	// CLIENT KILL отменяет контекст соединения
+	c.stopKill = context.AfterFunc(connectionContext, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		s.closeConnection(epfd, c, false)
+	})
+
+	s.mu.Lock()
+	s.connections[int32(fd)] = c
+	s.mu.Unlock()
+
+	event := syscall.EpollEvent{Events: epollEvents, Fd: int32(fd), Pad: c.seq}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_ADD, fd, &event); err != nil {
+		c.stopKill()
+		s.mu.Lock()
+		delete(s.connections, int32(fd))
+		s.mu.Unlock()
+		unregister()
+
+		return fmt.Errorf("add connection to epoll: %w", err)
+	}
+
+	return nil
+}
+
+// connectionFD возвращает дескриптор сокета и доступ к нему для чтения
+// без ожидания. Сокет остается в неблокирующем режиме, ответы отправляются
+// через net.Conn.
+func connectionFD(connection net.Conn) (int, syscall.RawConn, error) {
+	conn, ok := connection.(syscall.Conn)
+	if !ok {
+		return 0, nil, fmt.Errorf("unsupported connection type %T", connection)
+	}
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, nil, fmt.Errorf("get raw connection: %w", err)
+	}
+	fd := 0
+	if err := raw.Control(func(descriptor uintptr) { fd = int(descriptor) }); err != nil {
+		return 0, nil, fmt.Errorf("get connection descriptor: %w", err)
+	}
+
+	return fd, raw, nil
+}
+
+// poll передает обработчикам соединения, готовые к чтению, до закрытия stop.
+func (s *EpollServer) poll(epfd int, ready chan<- *epollConnection, stop <-chan struct{}) {
+	events := make([]syscall.EpollEvent, 128)
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		n, err := syscall.EpollWait(epfd, events, int(epollWaitTimeout.Milliseconds()))
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil {
+			s.logger.Error("wait for epoll events", "error", err)
+
+			return
+		}
+
+		for _, event := range events[:n] {
+			s.mu.Lock()
+			connection, ok := s.connections[event.Fd]
+			s.mu.Unlock()
+			if !ok || connection.seq != event.Pad {
+				continue
+			}
+
+			select {
+			case ready <- connection:
+			case <-stop:
+				return
+			}
+		}
+	}
+}
+
+// serveConnection читает доступные данные запроса и выполняет его, если
+// запрос получен целиком, после чего снова подписывает соединение на события.
+// Если в соединении остались данные, событие готовности приходит сразу.
+func (s *EpollServer) serveConnection(epfd int, c *epollConnection, handler Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return
+	}
+
+	if !s.handleRequest(c, handler) {
+		s.closeConnection(epfd, c, true)
+
+		return
+	}
+
+	event := syscall.EpollEvent{Events: epollEvents, Fd: int32(c.fd), Pad: c.seq}
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_MOD, c.fd, &event); err != nil {
+		s.logger.Warn("rearm connection in epoll", "error", err)
+		s.closeConnection(epfd, c, true)
+	}
+}
+
+// handleRequest обрабатывает версию протокола или один кадр запроса.
+// Возвращает false, если соединение нужно закрыть.
+func (s *EpollServer) handleRequest(c *epollConnection, handler Handler) bool {
+	if !c.handshaken {
+		return s.handshake(c)
+	}
+
+	frameType, payload, complete, err := s.readFrame(c)
+	if !complete {
+		if err != nil && !errors.Is(err, io.EOF) {
+			s.logger.Debug("read from connection", "error", err)
+		}
+
+		return err == nil
+	}
+	defer c.frame.reset(&s.buffers)
+	// запрос, начавший поступать, должен быть прочитан целиком за idleTimeout:
+	// время простоя отсчитывается от последнего полученного запроса
+	c.lastActiveAt = time.Now()
+
+	next := request{frameType: frameType, err: err}
+	if frameType.isTagged() {
+		next.id, payload, err = SplitRequestID(payload)
+		if err != nil && next.err == nil {
+			next.err = err
+		}
+	}
+
+	switch {
+	case next.err != nil:
+		// содержимое кадра пропущено, соединение можно использовать дальше
+		return s.write(c, next, true, []byte(next.err.Error()))
+	case frameType == FrameChunk:
+		return s.write(c, next, true, []byte(ErrFrameNotSupported.Error()))
+	case frameType != FrameData && frameType != FrameRequest && frameType != FrameUnorderedRequest:
+		s.write(c, next, true, []byte(fmt.Sprintf("%s: %d", ErrUnknownFrameType, frameType)))
+
+		return false
+	}
+
+	requestContext, cancel := RequestContext(c.ctx)
+	response, isError := handle(requestContext, handler, payload, c.errorFrames)
+	cancel()
+	response, isError = limitResponse(response, isError, s.maxMessageSize)
+
+	return s.write(c, next, isError, response)
+}
+
+// readFrame дочитывает кадр запроса из доступных данных. Третье значение -
+// false, если кадр еще не получен целиком. Как и ReadFrame, для кадра,
+// превышающего ограничение размера, возвращает ErrMessageTooLarge
+// и идентификатор запроса вместо содержимого.
+func (s *EpollServer) readFrame(c *epollConnection) (FrameType, []byte, bool, error) {
+	f := &c.frame
+	if complete, err := c.readHeader(frameHeaderSize); !complete {
+		return 0, nil, false, err
+	}
+
+	frameType := FrameType(f.header[0])
+	size := int(binary.BigEndian.Uint32(f.header[1:frameHeaderSize]))
+	if size > s.maxMessageSize {
+		headerSize := frameHeaderSize
+		if frameType.isTagged() && s.maxMessageSize >= requestIDSize {
+			headerSize += requestIDSize
+		}
+		if complete, err := c.readHeader(headerSize); !complete {
+			return 0, nil, false, unexpectedEOF(err)
+		}
+		if complete, err := s.skipPayload(c, size-(headerSize-frameHeaderSize)); !complete {
+			return 0, nil, false, err
+		}
+
+		return frameType, f.header[frameHeaderSize:headerSize], true, fmt.Errorf(
+			"%w: size %d exceeds limit %d", ErrMessageTooLarge, size, s.maxMessageSize,
+		)
+	}
+
+	if f.buffer == nil {
+		f.buffer = s.buffers.Get().(*[]byte)
+	}
+	payload := (*f.buffer)[:size]
+	for f.read < size {
+		n, err := c.readAvailable(payload[f.read:])
+		if err != nil || n == 0 {
+			return 0, nil, false, unexpectedEOF(err)
+		}
+		f.read += n
+	}
+
+	return frameType, payload, true, nil
+}
+
+// readHeader дочитывает первые size байт заголовка кадра. Возвращает false,
+// если они еще не получены. Закрытие соединения до начала кадра возвращается
+// как io.EOF.
+func (c *epollConnection) readHeader(size int) (bool, error) {
+	f := &c.frame
+	for f.headerLen < size {
+		n, err := c.readAvailable(f.header[f.headerLen:size])
+		if err != nil && f.headerLen > 0 {
+			return false, unexpectedEOF(err)
+		}
+		if err != nil || n == 0 {
+			return false, err
+		}
+		f.headerLen += n
+	}
+
+	return true, nil
+}
+
+// skipPayload пропускает доступные данные кадра, превышающего ограничение
+// размера. Возвращает false, если пропущены еще не все size байт.
+func (s *EpollServer) skipPayload(c *epollConnection, size int) (bool, error) {
+	f := &c.frame
+	if f.read == size {
+		return true, nil
+	}
+
+	buffer := s.buffers.Get().(*[]byte)
+	defer s.buffers.Put(buffer)
+	for f.read < size {
+		n, err := c.readAvailable((*buffer)[:min(len(*buffer), size-f.read)])
+		if err != nil || n == 0 {
+			return false, unexpectedEOF(err)
+		}
+		f.read += n
+	}
+
+	return true, nil
+}
+
+// readAvailable читает доступные в сокете данные без ожидания. Возвращает 0
+// без ошибки, если данных нет, и io.EOF, если клиент закрыл соединение.
+func (c *epollConnection) readAvailable(buffer []byte) (int, error) {
+	n := 0
+	var readErr error
+	err := c.rawConn.Read(func(fd uintptr) bool {
+		for {
+			n, readErr = syscall.Read(int(fd), buffer)
+			if !errors.Is(readErr, syscall.EINTR) {
+				// данные не ожидаются
+				return true
+			}
+		}
+	})
+	switch {
+	case err != nil:
+		return 0, err
+	case errors.Is(readErr, syscall.EAGAIN):
+		return 0, nil
+	case readErr != nil:
+		return 0, readErr
+	case n == 0:
+		return 0, io.EOF
+	}
+	if c.client != nil {
+		c.client.bytesIn.Add(int64(n))
+	}
+
+	return n, nil
+}
+
+func (s *EpollServer) handshake(c *epollConnection) bool {
+	version := make([]byte, 1)
+	n, err := c.readAvailable(version)
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			s.logger.Debug("read protocol version", "error", err)
+		}
+
+		return false
+	}
+	if n == 0 {
+		return true
+	}
+	c.handshaken = true
+	c.lastActiveAt = time.Now()
+
+	if !isSupportedVersion(version[0]) {
+		err := fmt.Errorf("%w: %d", ErrUnsupportedProtocolVersion, version[0])
+		s.write(c, request{frameType: FrameData}, true, []byte(err.Error()))
+
+		return false
+	}
+	c.errorFrames = version[0] == ProtocolVersionErrorFrames
+
+	return s.write(c, request{frameType: FrameData}, false, version)
+}
+
+func (s *EpollServer) write(c *epollConnection, to request, isError bool, message []byte) bool {
+	if err := c.output.write(to, isError, message); err != nil {
+		s.logger.Warn("write to connection", "error", err)
+
+		return false
+	}
+
+	return true
+}
+
+// sweep закрывает соединения, простаивающие дольше idleTimeout.
+func (s *EpollServer) sweep(epfd int, stop <-chan struct{}) {
+	ticker := time.NewTicker(s.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		s.mu.Lock()
+		connections := make([]*epollConnection, 0, len(s.connections))
+		for _, connection := range s.connections {
+			connections = append(connections, connection)
+		}
+		s.mu.Unlock()
+
+		for _, c := range connections {
+			// соединение, запрос которого выполняется, не простаивает
+			if !c.mu.TryLock() {
+				continue
+			}
+			if time.Since(c.lastActiveAt) > s.idleTimeout {
+				s.closeConnection(epfd, c, true)
+			}
+			c.mu.Unlock()
+		}
+	}
+}
+
+func (s *EpollServer) closeAll(epfd int) {
+	s.mu.Lock()
+	connections := make([]*epollConnection, 0, len(s.connections))
+	for _, connection := range s.connections {
+		connections = append(connections, connection)
+	}
+	s.mu.Unlock()
+
+	for _, c := range connections {
+		c.mu.Lock()
+		s.closeConnection(epfd, c, true)
+		c.mu.Unlock()
+	}
+	s.closing.Wait()
+}
+
+// closeConnection удаляет соединение из epoll и закрывает его. Если flush
+// равен true, то соединение закрывается после отправки накопленных ответов,
+// иначе они отбрасываются. Вызывается с захваченным мьютексом соединения.
+func (s *EpollServer) closeConnection(epfd int, c *epollConnection, flush bool) {
+	if c.closed {
+		return
+	}
+	c.closed = true
+
+	s.mu.Lock()
+	delete(s.connections, int32(c.fd))
+	s.mu.Unlock()
+
+	if err := syscall.EpollCtl(epfd, syscall.EPOLL_CTL_DEL, c.fd, nil); err != nil {
+		s.logger.Debug("remove connection from epoll", "error", err)
+	}
+	c.stopKill()
+	c.frame.reset(&s.buffers)
+
+	// ответы медленному клиенту не должны задерживать горутины пула
+	s.closing.Add(1)
+	go func() {
+		defer s.closing.Done()
+		if flush {
+			c.output.close()
+		}
+		if err := c.raw.Close(); err != nil {
+			s.logger.Warn("close connection", "error", err)
+		}
+		// закрытие соединения прерывает отправку ответов
+		c.output.close()
+		c.unregister()
+		c.release()
+	}()
+}
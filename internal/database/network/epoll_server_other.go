@@ -0,0 +1,37 @@
+//go:build !linux
+
+package network
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+)
+
+var ErrEpollNotSupported = errors.New("epoll network server is supported only on linux")
+
+// EpollServer недоступен на платформах без epoll.
+type EpollServer struct{}
+
+func NewEpollServer(
+	listeners []Listener,
+	maxMessageSize int,
+	workers int,
+	idleTimeout time.Duration,
+	drainTimeout time.Duration,
+	clients *ClientRegistry,
+	outputLimits OutputLimits,
+	onStartup func(),
+	logger *slog.Logger,
+) (*EpollServer, error) {
+	return nil, ErrEpollNotSupported
+}
+
+func (s *EpollServer) Clients() *ClientRegistry {
+	return nil
+}
+
+func (s *EpollServer) Serve(ctx context.Context, handler Handler) error {
+	return ErrEpollNotSupported
+}
@@ -0,0 +1,306 @@
+//go:build linux
+
+package network_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestEpollServer_Serve_ExpectResponsesToRequests(t *testing.T) {
+	const address = "127.0.0.1:10019"
+	startEpollServer(t, network.Listener{Address: address, MaxConnections: 10}, time.Second)
+
+	for i := 0; i < 3; i++ {
+		client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+		require.NoError(t, err, "connect to epoll server")
+
+		response, err := client.Send([]byte("request"))
+		require.NoError(t, err)
+		assert.Equal(t, "echo to request", string(response))
+		_, err = client.Send(make([]byte, messageSize+1))
+		var serverErr *network.ServerError
+		require.ErrorAs(t, err, &serverErr)
+		assert.Contains(t, serverErr.Message, "message too large")
+		response, err = client.Send([]byte("request after error"))
+		require.NoError(t, err)
+		assert.Equal(t, "echo to request after error", string(response))
+		require.NoError(t, client.Close())
+	}
+
+	pipeline, err := network.NewPipelineClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer pipeline.Close()
+	response, err := pipeline.Send(context.Background(), []byte("tagged request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to tagged request", string(response))
+}
+
+func TestEpollServer_Serve_WhenClientUsesErrorFrames_ExpectErrorsInErrorFrames(t *testing.T) {
+	const address = "127.0.0.1:10034"
+	startEpollServerWithLimits(t, network.Listener{Address: address, MaxConnections: 10}, time.Second, network.OutputLimits{}, errorHandler{})
+
+	client, err := network.NewTCPClientWithErrorFrames(address, nil, messageSize, time.Second)
+	require.NoError(t, err, "connect to epoll server")
+	defer client.Close()
+
+	_, err = client.Send([]byte("fail"))
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, "failed: fail", serverErr.Message)
+	response, err := client.Send([]byte("failed: stored value"))
+	require.NoError(t, err)
+	assert.Equal(t, "failed: stored value", string(response))
+}
+
+func TestEpollServer_Serve_WhenConnectionIdle_ExpectConnectionClosed(t *testing.T) {
+	const address = "127.0.0.1:10020"
+	startEpollServer(t, network.Listener{Address: address, MaxConnections: 1}, 50*time.Millisecond)
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	time.Sleep(200 * time.Millisecond)
+	_, err = client.Send([]byte("request"))
+
+	assert.Error(t, err)
+	// место закрытого соединения освобождено
+	second, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer second.Close()
+	response, err := second.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func TestEpollServer_Serve_WhenMaxConnectionsReached_ExpectConnectionRejected(t *testing.T) {
+	const address = "127.0.0.1:10021"
+	startEpollServer(t, network.Listener{Address: address, MaxConnections: 1}, time.Second)
+	first, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer first.Close()
+
+	_, err = network.NewTCPClient(address, nil, messageSize, time.Second)
+
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, network.ErrTooManyConnections.Error(), serverErr.Message)
+}
+
+func TestEpollServer_Serve_WhenClientsSendRequestsSlowly_ExpectOtherClientsServed(t *testing.T) {
+	const address = "127.0.0.1:10026"
+	startEpollServer(t, network.Listener{Address: address, MaxConnections: 10}, time.Second)
+	// клиентов, начавших отправку запроса, больше, чем горутин сервера
+	slow := make([]net.Conn, 3)
+	for i := range slow {
+		slow[i] = openIdleConnection(t, address)
+		defer slow[i].Close()
+		_, err := slow[i].Write([]byte{byte(network.FrameData)})
+		require.NoError(t, err)
+	}
+
+	client, err := network.NewTCPClient(address, nil, messageSize, 300*time.Millisecond)
+	require.NoError(t, err)
+	defer client.Close()
+	response, err := client.Send([]byte("request"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+	// запрос, отправленный по частям, выполняется после получения остальных данных
+	_, err = slow[0].Write([]byte{0, 0, 0, 4, 's', 'l', 'o', 'w'})
+	require.NoError(t, err)
+	require.NoError(t, slow[0].SetReadDeadline(time.Now().Add(time.Second)))
+	frameType, payload, err := network.ReadFrame(slow[0], make([]byte, messageSize))
+	require.NoError(t, err)
+	assert.Equal(t, network.FrameData, frameType)
+	assert.Equal(t, "echo to slow", string(payload))
+}
+
+func TestEpollServer_Serve_WhenClientsDoNotReadResponses_ExpectOtherClientsServed(t *testing.T) {
+	const address = "127.0.0.1:10027"
+	startEpollServer(t, network.Listener{Address: address, MaxConnections: 10}, time.Second)
+	// ответы не помещаются в буферы сокетов клиентов, которые их не читают,
+	// таких клиентов столько же, сколько горутин сервера
+	request := bytes.Buffer{}
+	require.NoError(t, network.WriteFrame(&request, network.FrameData, bytes.Repeat([]byte("x"), messageSize-16)))
+	requests := bytes.Repeat(request.Bytes(), 10000)
+	for i := 0; i < 2; i++ {
+		connection := openIdleConnection(t, address)
+		defer connection.Close()
+		require.NoError(t, connection.SetWriteDeadline(time.Now().Add(5*time.Second)))
+		_, err := connection.Write(requests)
+		require.NoError(t, err)
+	}
+
+	client, err := network.NewTCPClient(address, nil, messageSize, 300*time.Millisecond)
+	require.NoError(t, err)
+	defer client.Close()
+	response, err := client.Send([]byte("request"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func TestEpollServer_Serve_WhenClientNotReadingResponses_ExpectDisconnectedByHardLimit(t *testing.T) {
+	const address = "127.0.0.1:10028"
+	response := make([]byte, messageSize)
+	startEpollServerWithLimits(t, network.Listener{Address: address, MaxConnections: 10}, time.Second, network.OutputLimits{
+		Normal: network.OutputLimit{HardLimit: 64 * messageSize},
+	}, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		return response
+	}))
+	disconnects := network.OutputLimitDisconnects(network.ClientClassNormal)
+	connection := openIdleConnection(t, address)
+	defer connection.Close()
+
+	// клиент отправляет запросы, не читая ответы, пока сервер не закроет соединение
+	deadline := time.Now().Add(5 * time.Second)
+	for network.OutputLimitDisconnects(network.ClientClassNormal) == disconnects && time.Now().Before(deadline) {
+		if !writeRequest(connection) {
+			break
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		return network.OutputLimitDisconnects(network.ClientClassNormal) == disconnects+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+// idleConnections - количество простаивающих соединений в BenchmarkIdleConnections.
+const idleConnections = 1000
+
+// BenchmarkIdleConnections измеряет память, занимаемую одним простаивающим
+// соединением после рукопожатия. В значение входит и память клиентской
+// стороны соединения, она одинакова для обоих серверов.
+func BenchmarkIdleConnections(b *testing.B) {
+	const maxMessageSize = 64 * 1024
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	servers := []struct {
+		name    string
+		address string
+	}{
+		{name: "goroutines", address: "127.0.0.1:10022"},
+		{name: "epoll", address: "127.0.0.1:10023"},
+	}
+	for _, server := range servers {
+		b.Run(server.name, func(b *testing.B) {
+			waitStartup := make(chan struct{})
+			listeners := []network.Listener{{Address: server.address, MaxConnections: idleConnections}}
+			var serve func(ctx context.Context, handler network.Handler) error
+			if server.name == "epoll" {
+				epollServer, err := network.NewEpollServer(listeners, maxMessageSize, 4, time.Minute, time.Second, nil, network.OutputLimits{}, func() { close(waitStartup) }, logger)
+				require.NoError(b, err)
+				serve = epollServer.Serve
+			} else {
+				tcpServer, err := network.NewTCPServer(listeners, nil, maxMessageSize, 0, time.Minute, time.Second, nil, network.OutputLimits{}, func() { close(waitStartup) }, logger)
+				require.NoError(b, err)
+				serve = tcpServer.Serve
+			}
+			ctx, stop := context.WithCancel(context.Background())
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				assert.NoError(b, serve(ctx, echoHandler()))
+			}()
+			defer func() {
+				stop()
+				<-done
+			}()
+			waitSecond(b, waitStartup)
+
+			used := int64(0)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				before := usedMemory()
+				connections := make([]net.Conn, 0, idleConnections)
+				for j := 0; j < idleConnections; j++ {
+					connections = append(connections, openIdleConnection(b, server.address))
+				}
+				used += usedMemory() - before
+
+				b.StopTimer()
+				for _, connection := range connections {
+					_ = connection.Close()
+				}
+				// сервер освобождает ресурсы закрытых соединений
+				time.Sleep(200 * time.Millisecond)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(used)/float64(b.N*idleConnections), "bytes/conn")
+		})
+	}
+}
+
+func startEpollServer(tb testing.TB, listener network.Listener, idleTimeout time.Duration) {
+	tb.Helper()
+
+	startEpollServerWithLimits(tb, listener, idleTimeout, network.OutputLimits{}, echoHandler())
+}
+
+func startEpollServerWithLimits(
+	tb testing.TB,
+	listener network.Listener,
+	idleTimeout time.Duration,
+	outputLimits network.OutputLimits,
+	handler network.Handler,
+) {
+	tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	server, err := network.NewEpollServer(
+		[]network.Listener{listener},
+		messageSize,
+		2,
+		idleTimeout,
+		time.Second,
+		nil,
+		outputLimits,
+		func() { close(waitStartup) },
+		logger,
+	)
+	require.NoError(tb, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(tb, server.Serve(ctx, handler), "serve")
+	}()
+	tb.Cleanup(func() {
+		stop()
+		<-done
+	})
+	waitSecond(tb, waitStartup)
+}
+
+// openIdleConnection подключается к серверу и выполняет рукопожатие.
+func openIdleConnection(tb testing.TB, address string) net.Conn {
+	tb.Helper()
+
+	connection, err := net.Dial("tcp", address)
+	require.NoError(tb, err)
+	_, err = connection.Write([]byte{network.ProtocolVersion})
+	require.NoError(tb, err)
+	_, _, err = network.ReadFrame(connection, make([]byte, 1))
+	require.NoError(tb, err)
+
+	return connection
+}
+
+func usedMemory() int64 {
+	runtime.GC()
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+
+	return int64(stats.HeapInuse + stats.StackInuse)
+}
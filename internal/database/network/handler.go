@@ -1,6 +1,9 @@
 package network
 
-import "context"
+import (
+	"context"
+	"net"
+)
 
 type Handler interface {
 	Handle(ctx context.Context, request []byte) []byte
@@ -11,3 +14,73 @@ type HandlerFunc func(ctx context.Context, request []byte) []byte
 func (f HandlerFunc) Handle(ctx context.Context, request []byte) []byte {
 	return f(ctx, request)
 }
+
+// ErrorHandler может быть реализован обработчиком Handler, чтобы отделять ошибки
+// выполнения запросов от результатов. Клиентам, подключившимся по протоколу
+// ProtocolVersionErrorFrames, ошибки отправляются кадрами FrameError
+// и FrameResponseError, остальным - как обычные ответы.
+type ErrorHandler interface {
+	HandleWithError(ctx context.Context, request []byte) (response []byte, isError bool)
+}
+
+// handle выполняет запрос. Второе значение - true, если ответ нужно отправить
+// кадром ошибки.
+func handle(ctx context.Context, handler Handler, request []byte, errorFrames bool) ([]byte, bool) {
+	if errorHandler, ok := handler.(ErrorHandler); ok && errorFrames {
+		return errorHandler.HandleWithError(ctx, request)
+	}
+
+	return handler.Handle(ctx, request), false
+}
+
+// ConnectionOpener может быть реализован обработчиком Handler, чтобы создать
+// контекст соединения (например, сессию клиента), общий для всех его запросов.
+type ConnectionOpener interface {
+	OpenConnection(ctx context.Context) context.Context
+}
+
+type chunkedValueKey struct{}
+
+// ChunkedValue возвращает значение, переданное перед запросом кадрами FrameChunk.
+func ChunkedValue(ctx context.Context) ([]byte, bool) {
+	value, ok := ctx.Value(chunkedValueKey{}).([]byte)
+
+	return value, ok
+}
+
+func withChunkedValue(ctx context.Context, value []byte) context.Context {
+	return context.WithValue(ctx, chunkedValueKey{}, value)
+}
+
+type drainKey struct{}
+
+// RequestContext возвращает контекст выполнения запроса, полученного из
+// соединения. В отличие от контекста соединения, он не отменяется в начале
+// graceful shutdown: начатый запрос может завершиться (например, дождаться
+// записи в WAL) в пределах времени ожидания, заданного серверу.
+func RequestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	drainContext, ok := ctx.Value(drainKey{}).(context.Context)
+	if !ok {
+		return context.WithCancel(ctx)
+	}
+
+	requestContext, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	stop := context.AfterFunc(drainContext, cancel)
+
+	return requestContext, func() {
+		stop()
+		cancel()
+	}
+}
+
+// ConnectionHandler обрабатывает соединение целиком, до его закрытия или отмены
+// контекста.
+type ConnectionHandler interface {
+	HandleConnection(ctx context.Context, connection net.Conn)
+}
+
+type ConnectionHandlerFunc func(ctx context.Context, connection net.Conn)
+
+func (f ConnectionHandlerFunc) HandleConnection(ctx context.Context, connection net.Conn) {
+	f(ctx, connection)
+}
@@ -0,0 +1,217 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// HTTPServer - HTTP сервер с теми же ограничениями, что и у TCPServer:
+// количество одновременных соединений, размер тела запроса и время ожидания.
+type HTTPServer struct {
+	address        string
+	maxConnections int
+	maxMessageSize int
+	idleTimeout    time.Duration
+	drainTimeout   time.Duration
+	onStartup      func()
+	logger         *slog.Logger
+}
+
+func NewHTTPServer(
+	address string,
+	maxConnections int,
+	maxMessageSize int,
+	idleTimeout time.Duration,
+	drainTimeout time.Duration,
+	onStartup func(),
+	logger *slog.Logger,
+) (*HTTPServer, error) {
+	if maxConnections <= 0 {
+		return nil, fmt.Errorf("max connections should be > 0")
+	}
+	if maxMessageSize <= 0 {
+		return nil, fmt.Errorf("max message size should be > 0")
+	}
+	if idleTimeout <= 0 {
+		return nil, fmt.Errorf("idle timeout should be > 0")
+	}
+	if drainTimeout < 0 {
+		return nil, fmt.Errorf("drain timeout should be >= 0")
+	}
+	if onStartup == nil {
+		onStartup = func() {}
+	}
+
+	return &HTTPServer{
+		address:        address,
+		maxConnections: maxConnections,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+		drainTimeout:   drainTimeout,
+		onStartup:      onStartup,
+		logger:         logger,
+	}, nil
+}
+
+func (s *HTTPServer) Serve(ctx context.Context, handler http.Handler) error {
+	listener, err := net.Listen("tcp", s.address)
+	if err != nil {
+		return fmt.Errorf("listen TCP: %w", err)
+	}
+
+	server := &http.Server{
+		Handler:           http.MaxBytesHandler(handler, int64(s.maxMessageSize)),
+		ReadHeaderTimeout: s.idleTimeout,
+		ReadTimeout:       s.idleTimeout,
+		WriteTimeout:      s.idleTimeout,
+		IdleTimeout:       s.idleTimeout,
+		MaxHeaderBytes:    s.maxMessageSize,
+		ErrorLog:          slog.NewLogLogger(s.logger.Handler(), slog.LevelWarn),
+	}
+
+	s.onStartup()
+
+	serveContext, stop := context.WithCancel(ctx)
+	defer stop()
+
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-serveContext.Done()
+
+		if s.drainTimeout == 0 {
+			if err := server.Close(); err != nil {
+				s.logger.Warn("close HTTP server", "error", err)
+			}
+
+			return
+		}
+		// Активным запросам дается время на завершение, после чего соединения закрываются.
+		shutdownContext, cancel := context.WithTimeout(context.Background(), s.drainTimeout)
+		defer cancel()
+		if err := server.Shutdown(shutdownContext); err != nil {
+			s.logger.Warn("shutdown HTTP server", "error", err)
+			if err := server.Close(); err != nil {
+				s.logger.Warn("close HTTP server", "error", err)
+			}
+		}
+	}()
+
+	s.logger.Info("HTTP server started and ready to handle connections")
+
+	err = server.Serve(newLimitedListener(listener, s.maxConnections, s.maxMessageSize, s.idleTimeout, s.logger))
+	stop()
+	wg.Wait()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return fmt.Errorf("serve HTTP: %w", err)
+	}
+
+	s.logger.Info("HTTP server shutdown")
+
+	return nil
+}
+
+// rejectResponse - ответ на соединение, отклоненное из-за ограничения
+// количества соединений.
+const rejectResponse = "HTTP/1.1 503 Service Unavailable\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"Content-Length: 21\r\n" +
+	"Connection: close\r\n" +
+	"\r\n" +
+	"too many connections\n"
+
+// limitedListener ограничивает количество одновременно открытых соединений
+// так же, как политика OverloadReject у TCPServer: соединение сверх ограничения
+// получает ответ 503 и закрывается, прием следующих соединений не блокируется.
+type limitedListener struct {
+	net.Listener
+	semaphore      *Semaphore
+	maxMessageSize int
+	idleTimeout    time.Duration
+	logger         *slog.Logger
+	// rejecting - отклоняемые соединения, которым еще отправляется ответ.
+	rejecting sync.WaitGroup
+}
+
+func newLimitedListener(
+	listener net.Listener,
+	maxConnections int,
+	maxMessageSize int,
+	idleTimeout time.Duration,
+	logger *slog.Logger,
+) net.Listener {
+	return &limitedListener{
+		Listener:       listener,
+		semaphore:      NewSemaphore(maxConnections),
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+		logger:         logger,
+	}
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	for {
+		connection, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.semaphore.TryAcquire() {
+			return &limitedConnection{Conn: connection, release: l.semaphore.Release}, nil
+		}
+
+		l.logger.Warn("connection rejected", "error", ErrTooManyConnections, "remoteAddress", connection.RemoteAddr().String())
+		l.rejecting.Add(1)
+		go l.reject(connection)
+	}
+}
+
+// Close прекращает прием соединений и дожидается отправки ответов
+// отклоненным соединениям.
+func (l *limitedListener) Close() error {
+	err := l.Listener.Close()
+	l.rejecting.Wait()
+
+	return err
+}
+
+// reject отправляет ответ 503 и закрывает соединение. Запрос клиента
+// вычитывается, чтобы закрытие соединения с непрочитанными данными
+// не привело к его сбросу до получения ответа клиентом.
+func (l *limitedListener) reject(connection net.Conn) {
+	defer l.rejecting.Done()
+	defer connection.Close()
+
+	if err := connection.SetDeadline(time.Now().Add(l.idleTimeout)); err != nil {
+		l.logger.Warn("set connection deadline", "error", err)
+
+		return
+	}
+	if _, err := io.WriteString(connection, rejectResponse); err != nil {
+		return
+	}
+	if writer, ok := connection.(interface{ CloseWrite() error }); ok {
+		_ = writer.CloseWrite()
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(connection, int64(l.maxMessageSize)))
+}
+
+type limitedConnection struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (c *limitedConnection) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+
+	return err
+}
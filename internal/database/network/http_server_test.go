@@ -0,0 +1,59 @@
+package network_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestHTTPServer_Serve_WhenMaxConnectionsReached_ExpectServiceUnavailable(t *testing.T) {
+	const address = "127.0.0.1:10030"
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	server, err := network.NewHTTPServer(address, 1, messageSize, time.Second, time.Second, func() { close(waitStartup) }, logger)
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, server.Serve(ctx, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_, _ = io.WriteString(writer, "OK")
+		})), "serve")
+	}()
+	defer func() {
+		stop()
+		<-done
+	}()
+	waitSecond(t, waitStartup)
+	client := &http.Client{Timeout: time.Second, Transport: &http.Transport{DisableKeepAlives: true}}
+
+	// соединение без запросов занимает единственное место
+	idle, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	response, err := client.Get("http://" + address)
+	require.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Equal(t, "too many connections\n", string(body))
+
+	require.NoError(t, idle.Close())
+	assert.Eventually(t, func() bool {
+		response, err := client.Get("http://" + address)
+		if err != nil {
+			return false
+		}
+		response.Body.Close()
+
+		return response.StatusCode == http.StatusOK
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,88 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const unixScheme = "unix://"
+
+// Listener - адрес, на котором сервер принимает соединения, и ограничение
+// количества одновременных соединений для этого адреса. Адрес задается
+// в виде "host:port" или "unix:///path/to/socket.sock".
+type Listener struct {
+	Address        string
+	MaxConnections int
+	// SocketPermissions - права доступа к файлу unix сокета, если не 0.
+	SocketPermissions fs.FileMode
+	// Overload - поведение при достижении MaxConnections, по умолчанию OverloadReject.
+	Overload OverloadPolicy
+	// QueueSize и QueueTimeout - размер очереди ожидающих соединений
+	// и время ожидания в ней при политике OverloadQueue.
+	QueueSize    int
+	QueueTimeout time.Duration
+	// ReservedConnections - дополнительные соединения сверх MaxConnections,
+	// в которых команды могут выполнять только администраторы.
+	ReservedConnections int
+}
+
+// ParseAddress возвращает сетевой протокол ("tcp" или "unix") и адрес без схемы.
+func ParseAddress(address string) (string, string) {
+	if path, ok := strings.CutPrefix(address, unixScheme); ok {
+		return "unix", path
+	}
+
+	return "tcp", strings.TrimPrefix(address, "tcp://")
+}
+
+// listen открывает слушающий сокет. Файл unix сокета, оставшийся после
+// аварийного завершения процесса, удаляется перед открытием.
+func listen(listener Listener) (net.Listener, error) {
+	network, address := ParseAddress(listener.Address)
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	if err := removeStaleSocket(address); err != nil {
+		return nil, err
+	}
+	socket, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	if listener.SocketPermissions != 0 {
+		if err := os.Chmod(address, listener.SocketPermissions); err != nil {
+			_ = socket.Close()
+
+			return nil, fmt.Errorf("set socket permissions: %w", err)
+		}
+	}
+
+	return socket, nil
+}
+
+func removeStaleSocket(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("check socket file: %w", err)
+	}
+	if info.Mode()&fs.ModeSocket == 0 {
+		return fmt.Errorf("file %s exists and is not a socket", path)
+	}
+	// Если сокет принимает соединения, он занят другим процессом.
+	if connection, err := net.Dial("unix", path); err == nil {
+		_ = connection.Close()
+
+		return fmt.Errorf("socket %s is already in use", path)
+	}
+
+	return os.Remove(path)
+}
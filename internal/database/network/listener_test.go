@@ -0,0 +1,100 @@
+package network_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestTCPServer_Serve_WhenMultipleListeners_ExpectRequestsOnEachAddressHandled(t *testing.T) {
+	const tcpAddress = "127.0.0.1:10009"
+	socketPath := filepath.Join(t.TempDir(), "kvdb.sock")
+	// файл сокета, оставшийся после аварийного завершения, не мешает запуску
+	stale, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, stale.Close())
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	server, err := network.NewTCPServer(
+		[]network.Listener{
+			{Address: tcpAddress, MaxConnections: 1},
+			{Address: "unix://" + socketPath, MaxConnections: 1, SocketPermissions: 0o600},
+		},
+		nil,
+		messageSize,
+		0,
+		time.Second,
+		time.Second,
+		nil,
+		network.OutputLimits{},
+		func() { close(waitStartup) },
+		logger,
+	)
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, server.Serve(ctx, network.HandlerFunc(func(ctx context.Context, bytes []byte) []byte {
+			return append([]byte("echo to "), bytes...)
+		})))
+	}()
+	waitSecond(t, waitStartup)
+
+	info, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// соединения ограничиваются отдельно для каждого адреса
+	tcpClient, err := network.NewTCPClient(tcpAddress, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer tcpClient.Close()
+	unixClient, err := network.NewTCPClient("unix://"+socketPath, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer unixClient.Close()
+
+	response, err := tcpClient.Send([]byte("tcp"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to tcp", string(response))
+	response, err = unixClient.Send([]byte("unix"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to unix", string(response))
+
+	tcpClient.Close()
+	unixClient.Close()
+	stop()
+	waitSecond(t, done)
+	_, err = os.Stat(socketPath)
+	assert.ErrorIs(t, err, os.ErrNotExist, "socket file must be removed on shutdown")
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address     string
+		wantNetwork string
+		wantAddress string
+	}{
+		{address: "localhost:3223", wantNetwork: "tcp", wantAddress: "localhost:3223"},
+		{address: "tcp://localhost:3223", wantNetwork: "tcp", wantAddress: "localhost:3223"},
+		{address: "unix:///var/run/kvdb.sock", wantNetwork: "unix", wantAddress: "/var/run/kvdb.sock"},
+	}
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			network, address := network.ParseAddress(test.address)
+
+			assert.Equal(t, test.wantNetwork, network)
+			assert.Equal(t, test.wantAddress, address)
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+var ErrOutputLimitExceeded = errors.New("output buffer limit exceeded")
+
+// ClientClass - класс клиента, определяющий ограничения буфера ответов.
+type ClientClass string
+
+const (
+	// ClientClassNormal - клиент, получающий ответы на запросы.
+	ClientClassNormal ClientClass = "normal"
+	// ClientClassStream - клиент, получающий поток сообщений (например,
+	// поток изменений). Соединение переходит в этот класс с первым сообщением.
+	ClientClassStream ClientClass = "stream"
+)
+
+// OutputLimit - ограничения буфера неотправленных ответов соединения.
+// Соединение закрывается, если размер буфера превышает HardLimit или
+// остается больше SoftLimit дольше SoftTimeout. Нулевое значение
+// ограничения означает его отсутствие.
+type OutputLimit struct {
+	HardLimit   int
+	SoftLimit   int
+	SoftTimeout time.Duration
+}
+
+func (l OutputLimit) validate(class ClientClass) error {
+	if l.HardLimit < 0 || l.SoftLimit < 0 || l.SoftTimeout < 0 {
+		return fmt.Errorf("output limits of %s clients should be >= 0", class)
+	}
+	if l.HardLimit > 0 && l.SoftLimit > l.HardLimit {
+		return fmt.Errorf("output soft limit of %s clients should be <= hard limit", class)
+	}
+
+	return nil
+}
+
+// OutputLimits - ограничения буфера ответов для каждого класса клиентов.
+type OutputLimits struct {
+	Normal OutputLimit
+	Stream OutputLimit
+}
+
+func (l OutputLimits) of(class ClientClass) OutputLimit {
+	if class == ClientClassStream {
+		return l.Stream
+	}
+
+	return l.Normal
+}
+
+// outputLimitDisconnects - количество соединений, закрытых из-за превышения
+// ограничений буфера ответов, по классам клиентов. Публикуется через expvar.
+var outputLimitDisconnects = expvar.NewMap("network_output_limit_disconnects")
+
+// OutputLimitDisconnects возвращает количество соединений класса class,
+// закрытых из-за превышения ограничений буфера ответов.
+func OutputLimitDisconnects(class ClientClass) int64 {
+	if count, ok := outputLimitDisconnects.Get(string(class)).(*expvar.Int); ok {
+		return count.Value()
+	}
+
+	return 0
+}
+
+// outputBuffer накапливает ответы соединения и отправляет их отдельной
+// горутиной, поэтому клиент, переставший читать ответы, не блокирует
+// выполнение запросов. Размер буфера проверяется по ограничениям класса
+// клиента. При превышении ограничений или ошибке записи буфер переходит
+// в состояние ошибки и вызывает onFailure, дальнейшие ответы отбрасываются.
+type outputBuffer struct {
+	connection   net.Conn
+	writeTimeout time.Duration
+	limits       OutputLimits
+	onFailure    func(err error)
+
+	mu       sync.Mutex
+	flushed  *sync.Cond
+	class    ClientClass
+	pending  bytes.Buffer
+	inFlight int
+	// overSoftSince - момент, с которого размер буфера превышает SoftLimit.
+	overSoftSince time.Time
+	// softTimer закрывает соединение, если размер буфера превышает SoftLimit
+	// дольше SoftTimeout, даже когда новые ответы не поступают.
+	softTimer *time.Timer
+	flushing  bool
+	err       error
+}
+
+func newOutputBuffer(connection net.Conn, writeTimeout time.Duration, limits OutputLimits, onFailure func(err error)) *outputBuffer {
+	b := &outputBuffer{
+		connection:   connection,
+		writeTimeout: writeTimeout,
+		limits:       limits,
+		onFailure:    onFailure,
+		class:        ClientClassNormal,
+	}
+	b.flushed = sync.NewCond(&b.mu)
+
+	return b
+}
+
+// setClass меняет класс клиента и, соответственно, действующие ограничения.
+func (b *outputBuffer) setClass(class ClientClass) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.class = class
+}
+
+// write добавляет в буфер ответ на запрос: кадр FrameData или FrameError
+// на обычный запрос и кадр с идентификатором запроса на запрос конвейерного
+// режима.
+func (b *outputBuffer) write(to request, isError bool, message []byte) error {
+	return b.append(func(pending *bytes.Buffer) error {
+		return writeResponseFrame(pending, to, isError, message)
+	})
+}
+
+// Write добавляет в буфер данные протокола, отличного от основного.
+func (b *outputBuffer) Write(data []byte) (int, error) {
+	err := b.append(func(pending *bytes.Buffer) error {
+		_, err := pending.Write(data)
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(data), nil
+}
+
+// append дописывает данные в буфер функцией add и запускает их отправку.
+func (b *outputBuffer) append(add func(pending *bytes.Buffer) error) error {
+	b.mu.Lock()
+	if b.err != nil {
+		err := b.err
+		b.mu.Unlock()
+
+		return err
+	}
+
+	size := b.pending.Len()
+	if err := add(&b.pending); err != nil {
+		b.pending.Truncate(size)
+		b.mu.Unlock()
+
+		return fmt.Errorf("write to output buffer: %w", err)
+	}
+	if err := b.checkLimits(); err != nil {
+		b.mu.Unlock()
+		b.fail(err, true)
+
+		return err
+	}
+	if !b.flushing {
+		b.flushing = true
+		go b.flush()
+	}
+	b.mu.Unlock()
+
+	return nil
+}
+
+// checkLimits проверяет размер буфера вместе с отправляемыми данными.
+func (b *outputBuffer) checkLimits() error {
+	limit := b.limits.of(b.class)
+	size := b.pending.Len() + b.inFlight
+
+	if limit.HardLimit > 0 && size > limit.HardLimit {
+		return fmt.Errorf("%w: %s client buffer size %d exceeds hard limit %d", ErrOutputLimitExceeded, b.class, size, limit.HardLimit)
+	}
+	if limit.SoftLimit == 0 || size <= limit.SoftLimit {
+		b.overSoftSince = time.Time{}
+		b.stopSoftTimer()
+
+		return nil
+	}
+	if b.overSoftSince.IsZero() {
+		since := time.Now()
+		b.overSoftSince = since
+		b.softTimer = time.AfterFunc(limit.SoftTimeout, func() {
+			b.softTimeoutExceeded(since)
+		})
+	}
+	if over := time.Since(b.overSoftSince); over > limit.SoftTimeout {
+		return fmt.Errorf(
+			"%w: %s client buffer size %d exceeds soft limit %d for %s",
+			ErrOutputLimitExceeded, b.class, size, limit.SoftLimit, over.Round(time.Millisecond),
+		)
+	}
+
+	return nil
+}
+
+// softTimeoutExceeded закрывает соединение, если размер буфера превышает
+// SoftLimit с момента since.
+func (b *outputBuffer) softTimeoutExceeded(since time.Time) {
+	b.mu.Lock()
+	if b.err != nil || !b.overSoftSince.Equal(since) {
+		b.mu.Unlock()
+
+		return
+	}
+	err := fmt.Errorf(
+		"%w: %s client buffer size %d exceeds soft limit %d for %s",
+		ErrOutputLimitExceeded, b.class, b.pending.Len()+b.inFlight, b.limits.of(b.class).SoftLimit,
+		time.Since(since).Round(time.Millisecond),
+	)
+	b.mu.Unlock()
+
+	b.fail(err, true)
+}
+
+func (b *outputBuffer) stopSoftTimer() {
+	if b.softTimer != nil {
+		b.softTimer.Stop()
+		b.softTimer = nil
+	}
+}
+
+// flush отправляет накопленные ответы, пока буфер не опустеет.
+func (b *outputBuffer) flush() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.pending.Len() > 0 && b.err == nil {
+		data := bytes.Clone(b.pending.Bytes())
+		b.pending.Reset()
+		b.inFlight = len(data)
+		b.mu.Unlock()
+
+		err := b.send(data)
+
+		b.mu.Lock()
+		b.inFlight = 0
+		if err != nil && b.err == nil {
+			b.mu.Unlock()
+			b.fail(err, false)
+			b.mu.Lock()
+		}
+	}
+	if b.err == nil {
+		// клиент прочитал ответы, время превышения SoftLimit отсчитывается заново
+		_ = b.checkLimits()
+	}
+
+	// буфер освобождается, чтобы простаивающее соединение не удерживало память
+	b.pending = bytes.Buffer{}
+	b.flushing = false
+	b.flushed.Broadcast()
+}
+
+func (b *outputBuffer) send(data []byte) error {
+	if err := b.connection.SetWriteDeadline(time.Now().Add(b.writeTimeout)); err != nil {
+		return fmt.Errorf("set connection write deadline: %w", err)
+	}
+	if _, err := b.connection.Write(data); err != nil {
+		return fmt.Errorf("write to connection: %w", err)
+	}
+
+	return nil
+}
+
+// fail переводит буфер в состояние ошибки. При превышении ограничений
+// прерывается и текущая запись в соединение.
+func (b *outputBuffer) fail(err error, exceeded bool) {
+	b.mu.Lock()
+	if b.err != nil {
+		b.mu.Unlock()
+
+		return
+	}
+	b.err = err
+	b.pending.Reset()
+	b.stopSoftTimer()
+	class := b.class
+	b.mu.Unlock()
+
+	if exceeded {
+		outputLimitDisconnects.Add(string(class), 1)
+		_ = b.connection.SetWriteDeadline(time.Now())
+	}
+	b.onFailure(err)
+}
+
+// close дожидается отправки накопленных ответов.
+func (b *outputBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.flushing {
+		b.flushed.Wait()
+	}
+	b.stopSoftTimer()
+}
+
+// bufferedConn - соединение протокола, отличного от основного, ответы
+// в которое отправляются через буфер ответов.
+type bufferedConn struct {
+	net.Conn
+	output *outputBuffer
+}
+
+func (c *bufferedConn) Write(data []byte) (int, error) {
+	return c.output.Write(data)
+}
@@ -0,0 +1,144 @@
+package network_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestTCPServer_Serve_WhenStreamClientNotReading_ExpectDisconnectedByHardLimit(t *testing.T) {
+	const address = "127.0.0.1:10024"
+	sendErr := make(chan error, 1)
+	startServerWithLimits(t, address, nil, network.OutputLimits{
+		Stream: network.OutputLimit{HardLimit: 64 * messageSize},
+	}, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		stream, _ := network.StreamFromContext(ctx)
+		message := make([]byte, messageSize)
+		for i := 0; i < 100_000; i++ {
+			if err := stream.Send(message); err != nil {
+				sendErr <- err
+
+				return nil
+			}
+		}
+		sendErr <- nil
+
+		return nil
+	}))
+	disconnects := network.OutputLimitDisconnects(network.ClientClassStream)
+	normalDisconnects := network.OutputLimitDisconnects(network.ClientClassNormal)
+	connection := openIdleConnection(t, address)
+	defer connection.Close()
+
+	require.NoError(t, network.WriteFrame(connection, network.FrameData, []byte("stream")))
+
+	select {
+	case err := <-sendErr:
+		assert.ErrorIs(t, err, network.ErrOutputLimitExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not interrupted")
+	}
+	assert.Equal(t, disconnects+1, network.OutputLimitDisconnects(network.ClientClassStream))
+	assert.Equal(t, normalDisconnects, network.OutputLimitDisconnects(network.ClientClassNormal))
+}
+
+func TestTCPServer_Serve_WhenClientNotReadingResponses_ExpectDisconnectedBySoftLimit(t *testing.T) {
+	const address = "127.0.0.1:10025"
+	response := make([]byte, messageSize)
+	startServerWithLimits(t, address, nil, network.OutputLimits{
+		Normal: network.OutputLimit{SoftLimit: 16 * messageSize, SoftTimeout: 50 * time.Millisecond},
+	}, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		return response
+	}))
+	disconnects := network.OutputLimitDisconnects(network.ClientClassNormal)
+	connection := openIdleConnection(t, address)
+	defer connection.Close()
+
+	// клиент отправляет запросы, не читая ответы, пока сервер не закроет соединение
+	deadline := time.Now().Add(5 * time.Second)
+	for network.OutputLimitDisconnects(network.ClientClassNormal) == disconnects && time.Now().Before(deadline) {
+		if !writeRequest(connection) {
+			break
+		}
+	}
+
+	assert.Eventually(t, func() bool {
+		return network.OutputLimitDisconnects(network.ClientClassNormal) == disconnects+1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestTCPServer_ServeConnections_WhenClientNotReadingResponses_ExpectDisconnectedByHardLimit(t *testing.T) {
+	const address = "127.0.0.1:10029"
+	writeErr := make(chan error, 1)
+	handler := network.ConnectionHandlerFunc(func(ctx context.Context, connection net.Conn) {
+		response := make([]byte, messageSize)
+		for i := 0; i < 100_000; i++ {
+			if _, err := connection.Write(response); err != nil {
+				writeErr <- err
+
+				return
+			}
+		}
+		writeErr <- nil
+	})
+	startTCPServer(t, address, nil, network.OutputLimits{
+		Normal: network.OutputLimit{HardLimit: 64 * messageSize},
+	}, func(ctx context.Context, server *network.TCPServer) error {
+		return server.ServeConnections(ctx, handler)
+	})
+	disconnects := network.OutputLimitDisconnects(network.ClientClassNormal)
+	connection, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer connection.Close()
+
+	select {
+	case err := <-writeErr:
+		assert.ErrorIs(t, err, network.ErrOutputLimitExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("writing was not interrupted")
+	}
+	assert.Equal(t, disconnects+1, network.OutputLimitDisconnects(network.ClientClassNormal))
+}
+
+func TestTCPServer_ServeConnections_WhenClientStopsReading_ExpectDisconnectedBySoftTimeout(t *testing.T) {
+	const address = "127.0.0.1:10031"
+	disconnected := make(chan struct{})
+	handler := network.ConnectionHandlerFunc(func(ctx context.Context, connection net.Conn) {
+		// ответ не помещается в буферы сокетов, новые ответы не поступают
+		if _, err := connection.Write(make([]byte, 32*1024*1024)); err != nil {
+			return
+		}
+		<-ctx.Done()
+		close(disconnected)
+	})
+	startTCPServer(t, address, nil, network.OutputLimits{
+		Normal: network.OutputLimit{SoftLimit: 64 * messageSize, SoftTimeout: 50 * time.Millisecond},
+	}, func(ctx context.Context, server *network.TCPServer) error {
+		return server.ServeConnections(ctx, handler)
+	})
+	disconnects := network.OutputLimitDisconnects(network.ClientClassNormal)
+	connection, err := net.Dial("tcp", address)
+	require.NoError(t, err)
+	defer connection.Close()
+
+	// соединение закрывается по SoftTimeout раньше, чем истечет время записи
+	select {
+	case <-disconnected:
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("connection was not closed by soft timeout")
+	}
+	assert.Equal(t, disconnects+1, network.OutputLimitDisconnects(network.ClientClassNormal))
+}
+
+func writeRequest(connection net.Conn) bool {
+	if err := connection.SetWriteDeadline(time.Now().Add(100 * time.Millisecond)); err != nil {
+		return false
+	}
+
+	return network.WriteFrame(connection, network.FrameData, []byte("request")) == nil
+}
@@ -0,0 +1,269 @@
+package network
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// pipelineDepth - количество запросов, которые сервер читает из соединения
+// с опережением, пока выполняются предыдущие запросы.
+const pipelineDepth = 32
+
+// request - запрос, прочитанный из соединения.
+type request struct {
+	frameType FrameType
+	id        uint32
+	payload   []byte
+	// value - значение, переданное перед запросом кадрами FrameChunk, nil - если
+	// значение не передавалось.
+	value []byte
+	// err - ошибка чтения кадра, после которой соединение можно использовать,
+	// например, ErrMessageTooLarge.
+	err error
+}
+
+func (r request) isTagged() bool {
+	return r.frameType.isTagged()
+}
+
+// requestReader читает запросы из соединения в отдельной горутине с опережением
+// на pipelineDepth запросов. Таймаут простоя отсчитывается только тогда, когда
+// все прочитанные запросы выполнены: медленный запрос или поток изменений
+// не приводят к закрытию соединения.
+type requestReader struct {
+	connection   net.Conn
+	idleTimeout  time.Duration
+	maxValueSize int
+	buffer       []byte
+
+	// value и valueErr - значение, собираемое из кадров FrameChunk для
+	// следующего запроса, и ошибка его получения.
+	value    []byte
+	valueErr error
+
+	requests      chan request
+	quit          chan struct{}
+	finished      chan struct{}
+	streamStarted chan struct{}
+	err           error
+
+	mu sync.Mutex
+	// pending - количество прочитанных, но еще не выполненных запросов.
+	pending   int
+	stopped   bool
+	streaming bool
+	// onClose вызывается при закрытии соединения клиентом в потоковом режиме.
+	onClose func()
+}
+
+func newRequestReader(connection net.Conn, maxMessageSize, maxValueSize int, idleTimeout time.Duration) *requestReader {
+	return &requestReader{
+		connection:    connection,
+		idleTimeout:   idleTimeout,
+		maxValueSize:  maxValueSize,
+		buffer:        make([]byte, maxMessageSize),
+		requests:      make(chan request, pipelineDepth),
+		quit:          make(chan struct{}),
+		finished:      make(chan struct{}),
+		streamStarted: make(chan struct{}),
+	}
+}
+
+// run читает запросы, пока чтение не завершится ошибкой или не будет вызван stop.
+// По завершении канал requests закрывается, а ошибка сохраняется в err.
+func (r *requestReader) run() {
+	defer close(r.finished)
+	defer close(r.requests)
+
+	for {
+		if err := r.armDeadline(); err != nil {
+			r.err = err
+
+			return
+		}
+
+		frameType, payload, err := ReadFrame(r.connection, r.buffer)
+		if err != nil && !errors.Is(err, ErrMessageTooLarge) {
+			r.err = err
+			r.closed()
+
+			return
+		}
+
+		if frameType == FrameChunk {
+			r.appendChunk(payload, err)
+
+			continue
+		}
+
+		next := request{frameType: frameType, err: err}
+		next.value, r.value = r.value, nil
+		if r.valueErr != nil && next.err == nil {
+			next.err = r.valueErr
+		}
+		r.valueErr = nil
+		if frameType.isTagged() {
+			next.id, payload, err = SplitRequestID(payload)
+			if err != nil && next.err == nil {
+				next.err = err
+			}
+		}
+		// буфер используется повторно, поэтому запрос копируется
+		next.payload = bytes.Clone(payload)
+
+		if !r.enqueue(next) {
+			return
+		}
+	}
+}
+
+// appendChunk добавляет содержимое кадра FrameChunk к значению следующего
+// запроса. Если значение превышает maxValueSize, оставшиеся кадры
+// пропускаются, а запрос завершается ошибкой.
+func (r *requestReader) appendChunk(chunk []byte, err error) {
+	if r.valueErr != nil {
+		return
+	}
+	if err == nil && len(r.value)+len(chunk) > r.maxValueSize {
+		err = fmt.Errorf("%w: size exceeds limit %d", ErrValueTooLarge, r.maxValueSize)
+	}
+	if err != nil {
+		r.value = nil
+		r.valueErr = err
+
+		return
+	}
+	if r.value == nil {
+		r.value = make([]byte, 0, len(chunk))
+	}
+	r.value = append(r.value, chunk...)
+}
+
+// enqueue передает запрос на выполнение. В потоковом режиме запросы
+// не выполняются и отбрасываются.
+func (r *requestReader) enqueue(next request) bool {
+	r.mu.Lock()
+	if r.streaming {
+		r.mu.Unlock()
+
+		return true
+	}
+	r.pending++
+	r.mu.Unlock()
+
+	select {
+	case r.requests <- next:
+		return true
+	case <-r.streamStarted:
+		return true
+	case <-r.quit:
+		return false
+	}
+}
+
+// armDeadline устанавливает таймаут простоя, если нет выполняемых запросов.
+func (r *requestReader) armDeadline() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return nil
+	}
+
+	deadline := time.Time{}
+	if r.pending == 0 && !r.streaming {
+		deadline = time.Now().Add(r.idleTimeout)
+	}
+	if err := r.connection.SetReadDeadline(deadline); err != nil {
+		return fmt.Errorf("set connection read deadline: %w", err)
+	}
+
+	return nil
+}
+
+// done отмечает выполнение запроса. После выполнения последнего запроса
+// начинается отсчет таймаута простоя.
+func (r *requestReader) done() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending--
+	if r.pending == 0 && !r.stopped && !r.streaming {
+		_ = r.connection.SetReadDeadline(time.Now().Add(r.idleTimeout))
+	}
+}
+
+// startStream переводит соединение в потоковый режим: входящие запросы
+// отбрасываются, а закрытие соединения клиентом вызывает onClose.
+func (r *requestReader) startStream(onClose func()) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.streaming = true
+	r.onClose = onClose
+	close(r.streamStarted)
+	if r.stopped {
+		return nil
+	}
+	if err := r.connection.SetReadDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("reset connection read deadline: %w", err)
+	}
+
+	return nil
+}
+
+func (r *requestReader) closed() {
+	r.mu.Lock()
+	onClose := r.onClose
+	r.mu.Unlock()
+
+	if onClose != nil {
+		onClose()
+	}
+}
+
+// stop прерывает чтение. Уже прочитанные запросы остаются в канале requests.
+func (r *requestReader) stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stopped {
+		return
+	}
+
+	r.stopped = true
+	_ = r.connection.SetReadDeadline(time.Now())
+}
+
+// close прерывает чтение и дожидается завершения горутины.
+func (r *requestReader) close() {
+	r.stop()
+	close(r.quit)
+	<-r.finished
+}
+
+// isExpectedError проверяет, что чтение прервано закрытием соединения
+// клиентом или остановкой сервера, а не сетевой ошибкой.
+func (r *requestReader) isExpectedError() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.stopped || errors.Is(r.err, io.EOF)
+}
+
+// writeResponseFrame записывает кадр ответа на запрос to.
+func writeResponseFrame(writer io.Writer, to request, isError bool, message []byte) error {
+	switch {
+	case to.isTagged() && isError:
+		return WriteTaggedFrame(writer, FrameResponseError, to.id, message)
+	case to.isTagged():
+		return WriteTaggedFrame(writer, FrameResponse, to.id, message)
+	case isError:
+		return WriteFrame(writer, FrameError, message)
+	default:
+		return WriteFrame(writer, FrameData, message)
+	}
+}
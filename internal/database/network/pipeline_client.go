@@ -0,0 +1,222 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrClientClosed = errors.New("client is closed")
+
+type pipelineResult struct {
+	response []byte
+	err      error
+}
+
+// PipelineClient отправляет запросы в одном соединении, не дожидаясь ответов
+// на предыдущие запросы. Ответы сопоставляются с запросами по идентификатору,
+// поэтому клиент можно использовать из нескольких горутин одновременно.
+// Потоковые команды (SUBSCRIBE-CHANGES) через этот клиент не поддерживаются.
+type PipelineClient struct {
+	connection     net.Conn
+	maxMessageSize int
+	idleTimeout    time.Duration
+
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan pipelineResult
+	err     error
+
+	done chan struct{}
+}
+
+// NewPipelineClient подключается к серверу так же, как NewTCPClient, и запускает
+// горутину чтения ответов.
+func NewPipelineClient(address string, tlsConfig *tls.Config, maxMessageSize int, idleTimeout time.Duration) (*PipelineClient, error) {
+	connection, err := dial(address, tlsConfig, maxMessageSize, idleTimeout, ProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	// ответы читаются без таймаута: соединение без запросов закрывает сервер
+	if err := connection.SetDeadline(time.Time{}); err != nil {
+		_ = connection.Close()
+
+		return nil, fmt.Errorf("reset connection deadline: %w", err)
+	}
+
+	c := &PipelineClient{
+		connection:     connection,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+		pending:        make(map[uint32]chan pipelineResult),
+		done:           make(chan struct{}),
+	}
+	go c.receive()
+
+	return c, nil
+}
+
+// Send отправляет запрос, который сервер выполнит после всех ранее
+// отправленных запросов, и ожидает ответ не дольше idleTimeout и срока контекста.
+func (c *PipelineClient) Send(ctx context.Context, request []byte) ([]byte, error) {
+	return c.send(ctx, FrameRequest, request)
+}
+
+// SendRead отправляет независимый запрос чтения, который сервер может выполнить
+// одновременно с другими такими запросами. Команды записи отправлять так нельзя:
+// порядок их выполнения относительно соседних чтений не определен.
+func (c *PipelineClient) SendRead(ctx context.Context, request []byte) ([]byte, error) {
+	return c.send(ctx, FrameUnorderedRequest, request)
+}
+
+// Close закрывает соединение. Ожидающие ответа запросы завершаются с ошибкой
+// ErrClientClosed.
+func (c *PipelineClient) Close() error {
+	c.fail(ErrClientClosed)
+	err := c.connection.Close()
+	<-c.done
+
+	return err
+}
+
+func (c *PipelineClient) send(ctx context.Context, frameType FrameType, request []byte) ([]byte, error) {
+	result := make(chan pipelineResult, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+
+		return nil, err
+	}
+	c.nextID++
+	id := c.nextID
+	c.pending[id] = result
+	c.mu.Unlock()
+
+	if err := c.write(frameType, id, request); err != nil {
+		c.fail(err)
+	}
+
+	timer := time.NewTimer(c.idleTimeout)
+	defer timer.Stop()
+	select {
+	case result := <-result:
+		return result.response, result.err
+	case <-ctx.Done():
+		c.forget(id)
+
+		return nil, ctx.Err()
+	case <-timer.C:
+		c.forget(id)
+
+		return nil, fmt.Errorf("wait for response: %w", os.ErrDeadlineExceeded)
+	}
+}
+
+func (c *PipelineClient) write(frameType FrameType, id uint32, request []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	if err := c.connection.SetWriteDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+		return fmt.Errorf("set connection write deadline: %w", err)
+	}
+	if err := WriteTaggedFrame(c.connection, frameType, id, request); err != nil {
+		return fmt.Errorf("write to connection: %w", err)
+	}
+
+	return nil
+}
+
+// receive читает ответы и передает их ожидающим запросам до закрытия соединения.
+func (c *PipelineClient) receive() {
+	defer close(c.done)
+
+	buffer := make([]byte, c.maxMessageSize)
+	for {
+		frameType, payload, err := ReadFrame(c.connection, buffer)
+		if errors.Is(err, ErrMessageTooLarge) && len(payload) == requestIDSize {
+			c.deliver(binary.BigEndian.Uint32(payload), pipelineResult{err: fmt.Errorf("read from connection: %w", err)})
+
+			continue
+		}
+		if errors.Is(err, io.EOF) {
+			c.fail(err)
+
+			return
+		}
+		if err != nil {
+			c.fail(fmt.Errorf("read from connection: %w", err))
+
+			return
+		}
+
+		switch frameType {
+		case FrameResponse, FrameResponseError:
+			id, message, err := SplitRequestID(payload)
+			if err != nil {
+				c.fail(err)
+
+				return
+			}
+			if frameType == FrameResponseError {
+				c.deliver(id, pipelineResult{err: &ServerError{Message: string(message)}})
+			} else {
+				c.deliver(id, pipelineResult{response: bytes.Clone(message)})
+			}
+		case FrameError:
+			// ошибка без идентификатора относится к соединению целиком
+			c.fail(&ServerError{Message: string(payload)})
+
+			return
+		default:
+			c.fail(fmt.Errorf("%w: %d", ErrUnknownFrameType, frameType))
+
+			return
+		}
+	}
+}
+
+// deliver передает результат запросу id. Ответ на запрос, который больше
+// не ожидается (например, из-за отмены контекста), отбрасывается.
+func (c *PipelineClient) deliver(id uint32, result pipelineResult) {
+	c.mu.Lock()
+	pending, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+
+	if ok {
+		pending <- result
+	}
+}
+
+func (c *PipelineClient) forget(id uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, id)
+}
+
+// fail завершает все ожидающие запросы ошибкой. Последующие запросы
+// завершаются первой полученной ошибкой.
+func (c *PipelineClient) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.err == nil {
+		c.err = err
+	}
+	for id, pending := range c.pending {
+		pending <- pipelineResult{err: c.err}
+		delete(c.pending, id)
+	}
+}
@@ -0,0 +1,110 @@
+package network_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+func TestPipelineClient_Send_ExpectResponsesMatchedToRequests(t *testing.T) {
+	const address = "127.0.0.1:10015"
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mu := sync.Mutex{}
+	var executed []string
+	startServer(t, address, nil, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		if string(request) == "request0" {
+			close(started)
+			<-release
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		executed = append(executed, string(request))
+
+		return append([]byte("echo to "), request...)
+	}))
+	client, err := network.NewPipelineClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// запросы отправляются, пока первый запрос еще выполняется
+	wg := sync.WaitGroup{}
+	send := func(i int) {
+		defer wg.Done()
+		request := fmt.Sprintf("request%d", i)
+		response, err := client.Send(context.Background(), []byte(request))
+		assert.NoError(t, err)
+		assert.Equal(t, "echo to "+request, string(response))
+	}
+	wg.Add(1)
+	go send(0)
+	waitSecond(t, started)
+	for i := 1; i < 10; i++ {
+		wg.Add(1)
+		go send(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, executed, 10)
+	assert.Equal(t, "request0", executed[0])
+}
+
+func TestPipelineClient_SendRead_ExpectFastReadCompletedBeforeSlowRead(t *testing.T) {
+	const address = "127.0.0.1:10016"
+	release := make(chan struct{})
+	startServer(t, address, nil, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		if strings.HasPrefix(string(request), "slow") {
+			<-release
+		}
+
+		return append([]byte("echo to "), request...)
+	}))
+	client, err := network.NewPipelineClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	slow := make(chan string, 1)
+	go func() {
+		response, err := client.SendRead(context.Background(), []byte("slow read"))
+		assert.NoError(t, err)
+		slow <- string(response)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	response, err := client.SendRead(context.Background(), []byte("fast read"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to fast read", string(response))
+
+	close(release)
+	assert.Equal(t, "echo to slow read", <-slow)
+	response, err = client.Send(context.Background(), []byte("write"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to write", string(response))
+}
+
+func TestPipelineClient_Send_WhenMessageTooLarge_ExpectErrorForRequestOnly(t *testing.T) {
+	const address = "127.0.0.1:10017"
+	startEchoServer(t, address)
+	client, err := network.NewPipelineClient(address, nil, 2*messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	_, err = client.Send(context.Background(), make([]byte, messageSize))
+
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Contains(t, serverErr.Message, "message too large")
+	response, err := client.Send(context.Background(), []byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
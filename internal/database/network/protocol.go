@@ -0,0 +1,170 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Протокол обмена сообщениями поверх TCP.
+//
+// После установки соединения клиент отправляет один байт с версией протокола.
+// Сервер отвечает кадром FrameData с этим же байтом, если версия поддерживается,
+// или кадром FrameError с описанием ошибки, после чего закрывает соединение.
+// Версии различаются только кадром, которым передаются ошибки выполнения
+// команд (см. ProtocolVersionErrorFrames).
+//
+// Далее запросы и ответы передаются кадрами: 1 байт с типом кадра, 4 байта
+// с длиной содержимого (big endian) и само содержимое. Кадры, превышающие
+// максимальный размер сообщения, пропускаются принимающей стороной; в ответ
+// на такой запрос сервер отправляет кадр FrameError.
+//
+// Запросы FrameData выполняются строго по очереди: клиент ждет ответа перед
+// отправкой следующего запроса. Для конвейерной отправки клиент использует
+// кадры FrameRequest, содержимое которых начинается с 4 байт идентификатора
+// запроса (big endian), и не дожидается ответов. Сервер выполняет такие запросы
+// по порядку и отвечает кадрами FrameResponse или FrameResponseError с тем же
+// идентификатором. Запросы FrameUnorderedRequest (независимые чтения) могут
+// выполняться одновременно друг с другом, поэтому ответы на них приходят
+// в порядке завершения, но не раньше ответов на предшествующие запросы
+// FrameRequest и не позже ответов на последующие.
+//
+// Значение, превышающее максимальный размер сообщения, передается перед
+// запросом последовательностью кадров FrameChunk. Сервер объединяет их
+// содержимое (не более максимального размера значения) и передает результат
+// следующему за ними запросу, например, SETCHUNKED <key>.
+
+const (
+	// ProtocolVersion - основная версия протокола. Ошибки выполнения команд
+	// передаются в ней тем же кадром, что и результаты, текстом ответа.
+	ProtocolVersion byte = 1
+	// ProtocolVersionErrorFrames - версия протокола, в которой ошибки
+	// выполнения команд передаются кадрами FrameError и FrameResponseError,
+	// поэтому результат не может быть принят клиентом за ошибку.
+	ProtocolVersionErrorFrames byte = 2
+)
+
+func isSupportedVersion(version byte) bool {
+	return version == ProtocolVersion || version == ProtocolVersionErrorFrames
+}
+
+type FrameType byte
+
+const (
+	FrameData             FrameType = 1
+	FrameError            FrameType = 2
+	FrameRequest          FrameType = 3
+	FrameUnorderedRequest FrameType = 4
+	FrameResponse         FrameType = 5
+	FrameResponseError    FrameType = 6
+	FrameChunk            FrameType = 7
+)
+
+const (
+	frameHeaderSize = 5
+	requestIDSize   = 4
+)
+
+// isTagged проверяет, начинается ли содержимое кадра с идентификатора запроса.
+func (t FrameType) isTagged() bool {
+	return t >= FrameRequest && t <= FrameResponseError
+}
+
+var (
+	ErrMessageTooLarge            = errors.New("message too large")
+	ErrUnsupportedProtocolVersion = errors.New("unsupported protocol version")
+	ErrUnknownFrameType           = errors.New("unknown frame type")
+	ErrMissingRequestID           = errors.New("missing request ID")
+	ErrValueTooLarge              = errors.New("value too large")
+	ErrFrameNotSupported          = errors.New("frame type is not supported by server")
+)
+
+// ServerError - ошибка, полученная от сервера в кадре FrameError.
+type ServerError struct {
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	return fmt.Sprintf("server error: %s", e.Message)
+}
+
+// WriteFrame записывает кадр одной операцией записи.
+func WriteFrame(writer io.Writer, frameType FrameType, payload []byte) error {
+	frame := make([]byte, frameHeaderSize+len(payload))
+	frame[0] = byte(frameType)
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(len(payload)))
+	copy(frame[frameHeaderSize:], payload)
+
+	_, err := writer.Write(frame)
+
+	return err
+}
+
+// WriteTaggedFrame записывает кадр с идентификатором запроса одной операцией записи.
+func WriteTaggedFrame(writer io.Writer, frameType FrameType, id uint32, payload []byte) error {
+	frame := make([]byte, frameHeaderSize+requestIDSize+len(payload))
+	frame[0] = byte(frameType)
+	binary.BigEndian.PutUint32(frame[1:frameHeaderSize], uint32(requestIDSize+len(payload)))
+	binary.BigEndian.PutUint32(frame[frameHeaderSize:frameHeaderSize+requestIDSize], id)
+	copy(frame[frameHeaderSize+requestIDSize:], payload)
+
+	_, err := writer.Write(frame)
+
+	return err
+}
+
+// SplitRequestID отделяет идентификатор запроса от содержимого кадра
+// FrameRequest, FrameUnorderedRequest, FrameResponse или FrameResponseError.
+func SplitRequestID(payload []byte) (uint32, []byte, error) {
+	if len(payload) < requestIDSize {
+		return 0, nil, ErrMissingRequestID
+	}
+
+	return binary.BigEndian.Uint32(payload[:requestIDSize]), payload[requestIDSize:], nil
+}
+
+// ReadFrame читает кадр в буфер buffer. Если содержимое кадра больше буфера,
+// то оно вычитывается без сохранения и возвращается ошибка ErrMessageTooLarge,
+// после которой из соединения можно продолжать читать кадры. Для кадров
+// с идентификатором запроса вместе с ошибкой возвращается идентификатор,
+// чтобы на такой запрос можно было ответить.
+func ReadFrame(reader io.Reader, buffer []byte) (FrameType, []byte, error) {
+	header := make([]byte, frameHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, nil, err
+	}
+
+	frameType := FrameType(header[0])
+	size := binary.BigEndian.Uint32(header[1:])
+	if uint64(size) > uint64(len(buffer)) {
+		var id []byte
+		if frameType.isTagged() && len(buffer) >= requestIDSize {
+			id = buffer[:requestIDSize]
+			if _, err := io.ReadFull(reader, id); err != nil {
+				return 0, nil, unexpectedEOF(err)
+			}
+			size -= requestIDSize
+		}
+		if _, err := io.CopyN(io.Discard, reader, int64(size)); err != nil {
+			return 0, nil, unexpectedEOF(err)
+		}
+
+		return frameType, id, fmt.Errorf("%w: size %d exceeds limit %d", ErrMessageTooLarge, size+uint32(len(id)), len(buffer))
+	}
+
+	payload := buffer[:size]
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return 0, nil, unexpectedEOF(err)
+	}
+
+	return frameType, payload, nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -1,5 +1,10 @@
 package network
 
+import (
+	"context"
+	"time"
+)
+
 type Semaphore struct {
 	tickets chan struct{}
 }
@@ -15,3 +20,29 @@ func (s *Semaphore) Acquire() {
 func (s *Semaphore) Release() {
 	<-s.tickets
 }
+
+// TryAcquire занимает билет без ожидания. Возвращает false, если свободных билетов нет.
+func (s *Semaphore) TryAcquire() bool {
+	select {
+	case s.tickets <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// AcquireContext ожидает свободный билет не дольше timeout. Возвращает false,
+// если время ожидания истекло или контекст отменен.
+func (s *Semaphore) AcquireContext(ctx context.Context, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case s.tickets <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,60 @@
+package network
+
+import (
+	"context"
+	"sync"
+)
+
+// Stream позволяет обработчику отправить клиенту несколько сообщений (кадров) в ответ
+// на один запрос. После отправки первого сообщения соединение переходит в потоковый режим:
+// новые запросы из него не выполняются, а по завершении обработчика соединение закрывается.
+// В конвейерном режиме сообщения передаются кадрами с идентификатором запроса.
+// Контекст обработчика отменяется, когда клиент закрывает соединение.
+type Stream interface {
+	Send(message []byte) error
+}
+
+type streamKey struct{}
+
+func StreamFromContext(ctx context.Context) (Stream, bool) {
+	stream, ok := ctx.Value(streamKey{}).(Stream)
+
+	return stream, ok
+}
+
+func withStream(ctx context.Context, stream Stream) context.Context {
+	return context.WithValue(ctx, streamKey{}, stream)
+}
+
+type connectionStream struct {
+	writer  *outputBuffer
+	reader  *requestReader
+	request request
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	isStarted bool
+}
+
+func (s *connectionStream) Send(message []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.isStarted {
+		s.isStarted = true
+		s.writer.setClass(ClientClassStream)
+		// закрытие соединения клиентом прерывает поток
+		if err := s.reader.startStream(s.cancel); err != nil {
+			return err
+		}
+	}
+
+	return s.writer.write(s.request, false, message)
+}
+
+func (s *connectionStream) started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.isStarted
+}
@@ -1,7 +1,11 @@
 package network
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"time"
 )
@@ -12,10 +16,24 @@ type TCPClient struct {
 	idleTimeout    time.Duration
 }
 
-func NewTCPClient(address string, maxMessageSize int, idleTimeout time.Duration) (*TCPClient, error) {
-	connection, err := net.Dial("tcp", address)
+// NewTCPClient подключается к серверу по адресу "host:port" или "unix:///path.sock".
+// Если tlsConfig не nil, соединение по TCP устанавливается по TLS, имя сервера
+// для проверки сертификата берется из адреса.
+func NewTCPClient(address string, tlsConfig *tls.Config, maxMessageSize int, idleTimeout time.Duration) (*TCPClient, error) {
+	return newTCPClient(address, tlsConfig, maxMessageSize, idleTimeout, ProtocolVersion)
+}
+
+// NewTCPClientWithErrorFrames подключается к серверу так же, как NewTCPClient,
+// по протоколу ProtocolVersionErrorFrames: ошибки выполнения запросов
+// возвращаются как ServerError, а не как результат.
+func NewTCPClientWithErrorFrames(address string, tlsConfig *tls.Config, maxMessageSize int, idleTimeout time.Duration) (*TCPClient, error) {
+	return newTCPClient(address, tlsConfig, maxMessageSize, idleTimeout, ProtocolVersionErrorFrames)
+}
+
+func newTCPClient(address string, tlsConfig *tls.Config, maxMessageSize int, idleTimeout time.Duration, version byte) (*TCPClient, error) {
+	connection, err := dial(address, tlsConfig, maxMessageSize, idleTimeout, version)
 	if err != nil {
-		return nil, fmt.Errorf("dial TCP: %w", err)
+		return nil, err
 	}
 
 	return &TCPClient{
@@ -25,24 +43,193 @@ func NewTCPClient(address string, maxMessageSize int, idleTimeout time.Duration)
 	}, nil
 }
 
+// dial устанавливает соединение и согласовывает версию протокола.
+func dial(address string, tlsConfig *tls.Config, maxMessageSize int, timeout time.Duration, version byte) (net.Conn, error) {
+	network, address := ParseAddress(address)
+	netDialer := &net.Dialer{Timeout: timeout}
+	var connection net.Conn
+	var err error
+	if tlsConfig != nil && network == "tcp" {
+		dialer := &tls.Dialer{NetDialer: netDialer, Config: tlsConfig}
+		connection, err = dialer.Dial(network, address)
+	} else {
+		connection, err = netDialer.Dial(network, address)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial %s: %w", network, err)
+	}
+
+	if err := handshake(connection, maxMessageSize, timeout, version); err != nil {
+		_ = connection.Close()
+
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+
+	return connection, nil
+}
+
 func (c *TCPClient) Send(request []byte) ([]byte, error) {
-	if err := c.connection.SetDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+	return c.SendContext(context.Background(), request)
+}
+
+// SendContext отправляет запрос и ожидает ответ не дольше idleTimeout и срока
+// контекста. При отмене контекста операция прерывается, а соединение остается
+// в неопределенном состоянии и должно быть закрыто.
+func (c *TCPClient) SendContext(ctx context.Context, request []byte) ([]byte, error) {
+	return c.send(ctx, request, nil)
+}
+
+// SendChunked передает значение value кадрами FrameChunk размером не более
+// maxMessageSize, а затем запрос, которому сервер передаст это значение,
+// например, SETCHUNKED <key>. Таймаут idleTimeout отсчитывается для каждого
+// кадра, поэтому передача большого значения ограничена только сроком контекста.
+func (c *TCPClient) SendChunked(ctx context.Context, request, value []byte) ([]byte, error) {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return c.send(ctx, request, value)
+}
+
+func (c *TCPClient) send(ctx context.Context, request, value []byte) ([]byte, error) {
+	if err := c.connection.SetDeadline(c.deadline(ctx)); err != nil {
 		return nil, fmt.Errorf("set connection deadline: %w", err)
 	}
+	stop := context.AfterFunc(ctx, func() {
+		_ = c.connection.SetDeadline(time.Now())
+	})
+	defer stop()
 
-	if _, err := c.connection.Write(request); err != nil {
-		return nil, fmt.Errorf("write to connection: %w", err)
+	if value != nil {
+		if err := c.writeChunks(ctx, value); err != nil {
+			return nil, contextError(ctx, err)
+		}
+	}
+	if err := WriteFrame(c.connection, FrameData, request); err != nil {
+		return nil, contextError(ctx, fmt.Errorf("write to connection: %w", err))
 	}
 
-	response := make([]byte, c.maxMessageSize)
-	count, err := c.connection.Read(response)
+	response, err := readMessage(c.connection, make([]byte, c.maxMessageSize))
 	if err != nil {
-		return nil, fmt.Errorf("read from connection: %w", err)
+		return nil, contextError(ctx, err)
+	}
+
+	return response, nil
+}
+
+func (c *TCPClient) writeChunks(ctx context.Context, value []byte) error {
+	for {
+		size := min(len(value), c.maxMessageSize)
+		if err := WriteFrame(c.connection, FrameChunk, value[:size]); err != nil {
+			return fmt.Errorf("write to connection: %w", err)
+		}
+		value = value[size:]
+		if len(value) == 0 {
+			return nil
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := c.connection.SetDeadline(c.deadline(ctx)); err != nil {
+			return fmt.Errorf("set connection deadline: %w", err)
+		}
+	}
+}
+
+// deadline возвращает срок операции с соединением: idleTimeout, но не позже
+// срока контекста.
+func (c *TCPClient) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(c.idleTimeout)
+	if contextDeadline, ok := ctx.Deadline(); ok && contextDeadline.Before(deadline) {
+		deadline = contextDeadline
 	}
 
-	return response[:count], nil
+	return deadline
+}
+
+// Stream отправляет запрос, переводящий соединение в потоковый режим, и передает
+// все последующие сообщения сервера в функцию receive до закрытия соединения.
+// Время ожидания сообщений не ограничено, т.к. они могут приходить сколь угодно редко.
+func (c *TCPClient) Stream(request []byte, receive func(message []byte)) error {
+	if err := c.connection.SetDeadline(time.Now().Add(c.idleTimeout)); err != nil {
+		return fmt.Errorf("set connection deadline: %w", err)
+	}
+	if err := WriteFrame(c.connection, FrameData, request); err != nil {
+		return fmt.Errorf("write to connection: %w", err)
+	}
+	if err := c.connection.SetReadDeadline(time.Time{}); err != nil {
+		return fmt.Errorf("reset connection read deadline: %w", err)
+	}
+
+	buffer := make([]byte, c.maxMessageSize)
+	for {
+		message, err := readMessage(c.connection, buffer)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+		receive(message)
+	}
 }
 
 func (c *TCPClient) Close() error {
 	return c.connection.Close()
 }
+
+func handshake(connection net.Conn, maxMessageSize int, timeout time.Duration, version byte) error {
+	if err := connection.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return fmt.Errorf("set connection deadline: %w", err)
+	}
+	if _, err := connection.Write([]byte{version}); err != nil {
+		return fmt.Errorf("write protocol version: %w", err)
+	}
+
+	// буфер вмещает кадр с ошибкой, например, об отказе в соединении
+	accepted, err := readMessage(connection, make([]byte, maxMessageSize))
+	if err != nil {
+		return err
+	}
+	if len(accepted) != 1 || accepted[0] != version {
+		return fmt.Errorf("%w: %v", ErrUnsupportedProtocolVersion, accepted)
+	}
+
+	return nil
+}
+
+// readMessage читает кадр с данными. Кадр с ошибкой возвращается как ServerError.
+func readMessage(connection net.Conn, buffer []byte) ([]byte, error) {
+	frameType, message, err := ReadFrame(connection, buffer)
+	if errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read from connection: %w", err)
+	}
+
+	switch frameType {
+	case FrameData:
+		return message, nil
+	case FrameError:
+		return nil, &ServerError{Message: string(message)}
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownFrameType, frameType)
+	}
+}
+
+// contextError дополняет сетевую ошибку ошибкой контекста, если операция
+// прервана из-за его отмены или истечения срока.
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
+	// срок соединения может истечь раньше, чем сработает таймер контекста
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+
+	return err
+}
@@ -1,8 +1,10 @@
 package network_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
 	"testing"
 	"time"
@@ -45,16 +47,23 @@ func (s *EchoServer) Run() error {
 func (s *EchoServer) handleConnection(connection net.Conn) error {
 	defer connection.Close()
 
-	request := make([]byte, messageSize)
-	count, err := connection.Read(request)
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(connection, version); err != nil {
+		return fmt.Errorf("read protocol version: %w", err)
+	}
+	if err := network.WriteFrame(connection, network.FrameData, version); err != nil {
+		return fmt.Errorf("write protocol version: %w", err)
+	}
+
+	_, request, err := network.ReadFrame(connection, make([]byte, messageSize))
 	if err != nil {
 		return fmt.Errorf("read from connection: %w", err)
 	}
 
 	time.Sleep(s.ResponseTimeout)
 
-	response := append([]byte("echo to "), request[:count]...)
-	if _, err := connection.Write(response); err != nil {
+	response := append([]byte("echo to "), request...)
+	if err := network.WriteFrame(connection, network.FrameData, response); err != nil {
 		return fmt.Errorf("write to connection: %w", err)
 	}
 
@@ -74,7 +83,7 @@ func TestTCPClient_Send(t *testing.T) {
 		require.NoError(t, server.Run())
 	}()
 
-	client, err := network.NewTCPClient(address, messageSize, time.Second)
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
 	require.NoError(t, err)
 	response, err := client.Send([]byte("request"))
 	require.NoError(t, err)
@@ -92,7 +101,7 @@ func TestTCPClient_Send_IdleTimeout(t *testing.T) {
 		require.NoError(t, server.Run())
 	}()
 
-	client, err := network.NewTCPClient(address, messageSize, 10*time.Millisecond)
+	client, err := network.NewTCPClient(address, nil, messageSize, 10*time.Millisecond)
 	require.NoError(t, err)
 	_, err = client.Send([]byte("request"))
 
@@ -100,3 +109,23 @@ func TestTCPClient_Send_IdleTimeout(t *testing.T) {
 	require.ErrorAs(t, err, &timeoutErr)
 	assert.True(t, timeoutErr.Timeout())
 }
+
+func TestTCPClient_SendContext_WhenContextCanceled_ExpectRequestInterrupted(t *testing.T) {
+	const address = ":10014"
+	server, err := NewEchoServer(address)
+	require.NoError(t, err)
+	server.ResponseTimeout = 100 * time.Millisecond
+	defer server.Close()
+	go func() {
+		require.NoError(t, server.Run())
+	}()
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = client.SendContext(ctx, []byte("request"))
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
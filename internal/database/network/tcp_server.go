@@ -2,6 +2,7 @@ package network
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -12,157 +13,545 @@ import (
 )
 
 type TCPServer struct {
-	address        string
-	semaphore      *Semaphore
+	listeners      []Listener
+	tlsConfig      *tls.Config
 	maxMessageSize int
+	maxValueSize   int
 	idleTimeout    time.Duration
+	drainTimeout   time.Duration
+	clients        *ClientRegistry
+	outputLimits   OutputLimits
 	onStartup      func()
 	logger         *slog.Logger
 }
 
+// NewTCPServer создает сервер, принимающий соединения на всех адресах listeners.
+// Каждый адрес имеет собственное ограничение количества соединений. Если tlsConfig
+// не nil, соединения по TCP принимаются по TLS (unix сокеты работают без TLS).
+// При graceful shutdown запросам, обработка которых уже началась, дается
+// drainTimeout на завершение, после чего их контекст отменяется
+// (при нулевом drainTimeout - сразу).
+// Значения больше maxMessageSize передаются кадрами FrameChunk, их суммарный
+// размер ограничен maxValueSize (0 - передача значений по частям запрещена).
+// Открытые соединения регистрируются в clients, если он не nil, иначе
+// в собственном реестре сервера. Ответы отправляются через буфер соединения,
+// размер которого ограничен outputLimits для класса клиента.
 func NewTCPServer(
-	address string,
-	maxConnections int,
+	listeners []Listener,
+	tlsConfig *tls.Config,
 	maxMessageSize int,
+	maxValueSize int,
 	idleTimeout time.Duration,
+	drainTimeout time.Duration,
+	clients *ClientRegistry,
+	outputLimits OutputLimits,
 	onStartup func(),
 	logger *slog.Logger,
 ) (*TCPServer, error) {
-	if maxConnections <= 0 {
-		return nil, fmt.Errorf("max connections should be > 0")
+	if len(listeners) == 0 {
+		return nil, fmt.Errorf("at least one listener is required")
+	}
+	for _, listener := range listeners {
+		if listener.MaxConnections <= 0 {
+			return nil, fmt.Errorf("max connections of %s should be > 0", listener.Address)
+		}
+		if listener.ReservedConnections < 0 {
+			return nil, fmt.Errorf("reserved connections of %s should be >= 0", listener.Address)
+		}
+		switch listener.Overload {
+		case "", OverloadReject:
+		case OverloadQueue:
+			if listener.QueueSize <= 0 || listener.QueueTimeout <= 0 {
+				return nil, fmt.Errorf("queue size and queue timeout of %s should be > 0", listener.Address)
+			}
+		default:
+			return nil, fmt.Errorf("unknown overload policy %q of %s", listener.Overload, listener.Address)
+		}
 	}
 	if maxMessageSize <= 0 {
 		return nil, fmt.Errorf("max message size should be > 0")
 	}
+	if maxValueSize < 0 {
+		return nil, fmt.Errorf("max value size should be >= 0")
+	}
 	if idleTimeout <= 0 {
 		return nil, fmt.Errorf("idle timeout should be > 0")
 	}
+	if drainTimeout < 0 {
+		return nil, fmt.Errorf("drain timeout should be >= 0")
+	}
+	if err := outputLimits.Normal.validate(ClientClassNormal); err != nil {
+		return nil, err
+	}
+	if err := outputLimits.Stream.validate(ClientClassStream); err != nil {
+		return nil, err
+	}
+	if clients == nil {
+		clients = NewClientRegistry()
+	}
 	if onStartup == nil {
 		onStartup = func() {}
 	}
 
 	return &TCPServer{
-		address:        address,
-		semaphore:      NewSemaphore(maxConnections),
+		listeners:      listeners,
+		tlsConfig:      tlsConfig,
 		maxMessageSize: maxMessageSize,
+		maxValueSize:   maxValueSize,
 		idleTimeout:    idleTimeout,
+		drainTimeout:   drainTimeout,
+		clients:        clients,
+		outputLimits:   outputLimits,
 		onStartup:      onStartup,
 		logger:         logger,
 	}, nil
 }
 
+// Clients возвращает реестр соединений сервера.
+func (s *TCPServer) Clients() *ClientRegistry {
+	return s.clients
+}
+
 func (s *TCPServer) Serve(ctx context.Context, handler Handler) error {
-	listener, err := net.Listen("tcp", s.address)
-	if err != nil {
-		return fmt.Errorf("listen TCP: %w", err)
-	}
+	return s.serve(ctx, &frameConnectionHandler{server: s, handler: handler})
+}
 
-	s.onStartup()
+// frameConnectionHandler обрабатывает соединения основного протокола.
+type frameConnectionHandler struct {
+	server  *TCPServer
+	handler Handler
+}
 
-	wg := sync.WaitGroup{}
-	wg.Add(2)
+func (h *frameConnectionHandler) HandleConnection(ctx context.Context, connection net.Conn) {
+	h.server.handleConnection(ctx, connection, h.handler)
+}
 
-	go func() {
-		defer wg.Done()
+// RejectConnection отвечает на версию протокола кадром с ошибкой. Версия
+// вычитывается, чтобы закрытие соединения с непрочитанными данными
+// не привело к его сбросу до получения ответа клиентом.
+func (h *frameConnectionHandler) RejectConnection(connection net.Conn, err error) {
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(connection, version); err != nil {
+		return
+	}
+	h.server.writeError(connection, err)
+}
 
-		s.logger.Info("server started and ready to handle connections")
+// ServeConnections принимает соединения и передает их в handler, не навязывая
+// формат сообщений. Используется для протоколов, отличных от основного
+// (например, RESP). Соединение закрывается после завершения handler'а.
+// Если достигнуто ограничение соединений, то новое соединение отклоняется
+// через ConnectionRejecter, если handler его реализует, или ожидает в очереди
+// при политике OverloadQueue. Цикл приема соединений при этом не блокируется.
+//
+// Отмена ctx запускает graceful shutdown: прием соединений прекращается,
+// контекст соединений отменяется, а запросы, выполняемые с контекстом
+// из RequestContext, могут завершиться в течение drainTimeout.
+//
+// Ответы, записанные handler'ом в соединение, отправляются через буфер
+// ответов с ограничениями outputLimits для обычных клиентов. При превышении
+// ограничений контекст соединения отменяется так же, как командой CLIENT KILL.
+func (s *TCPServer) ServeConnections(ctx context.Context, handler ConnectionHandler) error {
+	return s.serve(ctx, &bufferedConnectionHandler{server: s, handler: handler})
+}
 
-		for {
-			connection, err := listener.Accept()
-			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
-					return
-				}
+// bufferedConnectionHandler передает соединения handler'у протокола,
+// отличного от основного, с записью ответов через буфер ответов.
+type bufferedConnectionHandler struct {
+	server  *TCPServer
+	handler ConnectionHandler
+}
 
-				s.logger.Error("accept connection", "error", err)
+func (h *bufferedConnectionHandler) HandleConnection(ctx context.Context, connection net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	output := newOutputBuffer(connection, h.server.idleTimeout, h.server.outputLimits, func(err error) {
+		h.server.logger.Warn("write to connection", "error", err)
+		cancel()
+	})
+	defer output.close()
 
-				continue
-			}
+	h.handler.HandleConnection(ctx, &bufferedConn{Conn: connection, output: output})
+}
 
-			wg.Add(1)
-			s.semaphore.Acquire()
-			go func(connection net.Conn) {
-				defer func() {
-					wg.Done()
-					s.semaphore.Release()
-				}()
+// RejectConnection передает отказ в соединении handler'у, если он это поддерживает.
+func (h *bufferedConnectionHandler) RejectConnection(connection net.Conn, err error) {
+	if rejecter, ok := h.handler.(ConnectionRejecter); ok {
+		rejecter.RejectConnection(connection, err)
+	}
+}
 
-				s.handleConnection(ctx, connection, handler)
-			}(connection)
+func (s *TCPServer) serve(ctx context.Context, handler ConnectionHandler) error {
+	listeners := make([]net.Listener, 0, len(s.listeners))
+	for _, options := range s.listeners {
+		listener, err := listen(options)
+		if err != nil {
+			for _, opened := range listeners {
+				_ = opened.Close()
+			}
+
+			return fmt.Errorf("listen %s: %w", options.Address, err)
 		}
-	}()
+		if s.tlsConfig != nil && listener.Addr().Network() == "tcp" {
+			listener = tls.NewListener(listener, s.tlsConfig)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	s.onStartup()
+
+	drainContext, drained := drain(ctx, s.drainTimeout, s.logger)
+	ctx = context.WithValue(ctx, drainKey{}, drainContext)
+
+	wg := sync.WaitGroup{}
+	wg.Add(len(listeners) + 1)
+
+	for i, listener := range listeners {
+		go func(listener net.Listener, admission *admission) {
+			defer wg.Done()
+			s.accept(ctx, listener, admission, handler, &wg)
+		}(listener, newAdmission(s.listeners[i]))
+	}
 
 	go func() {
 		defer wg.Done()
 		<-ctx.Done()
-		if err := listener.Close(); err != nil {
-			s.logger.Warn("close listener", "error", err)
+		for _, listener := range listeners {
+			if err := listener.Close(); err != nil {
+				s.logger.Warn("close listener", "error", err)
+			}
 		}
 	}()
 
 	wg.Wait()
+	drained()
 
 	s.logger.Info("server shutdown")
 
 	return nil
 }
 
-func (s *TCPServer) handleConnection(ctx context.Context, connection net.Conn, handler Handler) {
-	defer func() {
-		if err := connection.Close(); err != nil {
-			s.logger.Warn("close connection", "error", err)
+// drain возвращает контекст, который отменяется через timeout после
+// отмены ctx, и функцию, которую нужно вызвать после закрытия всех соединений.
+func drain(ctx context.Context, timeout time.Duration, logger *slog.Logger) (context.Context, func()) {
+	drainContext, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+		case <-drainContext.Done():
+			return
+		}
+		if timeout == 0 {
+			cancel()
+
+			return
+		}
+
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			logger.Warn("drain timeout exceeded, canceling in-flight requests")
+			cancel()
+		case <-drainContext.Done():
 		}
 	}()
 
-	// Постоянный буфер для входящих сообщений соединения
-	request := make([]byte, s.maxMessageSize)
+	return drainContext, func() {
+		cancel()
+		<-done
+	}
+}
+
+// accept принимает соединения одного адреса, пока он не будет закрыт.
+func (s *TCPServer) accept(
+	ctx context.Context,
+	listener net.Listener,
+	admission *admission,
+	handler ConnectionHandler,
+	wg *sync.WaitGroup,
+) {
+	address := listener.Addr().String()
+	s.logger.Info("server started and ready to handle connections", "address", address)
 
 	for {
-		deadline := time.Now().Add(s.idleTimeout)
-		if err := connection.SetDeadline(deadline); err != nil {
-			s.logger.Warn("set connection deadline", "error", err)
+		connection, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
 
-			return
+			s.logger.Error("accept connection", "error", err, "address", address)
+
+			continue
 		}
 
-		// Вычитываем входящий запрос асинхронно, по завершению чтения
-		// канал done закрывается
-		done := make(chan struct{})
-		var count int
-		var err error
-		go func() {
-			count, err = connection.Read(request)
-			close(done)
-		}()
-
-		// Ждем либо успешного вычитывания запроса (по закрытию канала done),
-		// либо сигнала graceful shutdown из контекста.
-		select {
-		case <-ctx.Done():
-			// Если получили сигнал завершения, то прерываем чтение из соединения
-			// выходим из цикла и принудительно закрываем соединение.
-			s.logger.Warn("connection closed by context", "error", ctx.Err())
+		wg.Add(1)
+		go func(connection net.Conn) {
+			defer wg.Done()
+			defer func() {
+				if err := connection.Close(); err != nil {
+					s.logger.Warn("close connection", "error", err)
+				}
+			}()
 
-			return
-			// Если успели вычитать сигнал, то дальнейшая работа гарантирует
-			// запуск handler'а для обработки запроса и формирование ответа.
-		case <-done:
+			connectionContext, release, err := admission.admit(ctx)
+			if err != nil {
+				s.reject(connection, handler, err)
+
+				return
+			}
+			defer release()
+
+			connectionContext, err = s.handshakeTLS(connectionContext, connection)
+			if err != nil {
+				s.logger.Warn("TLS handshake", "error", err, "remoteAddress", connection.RemoteAddr().String())
+
+				return
+			}
+
+			connectionContext, counted, unregister := s.clients.register(connectionContext, connection)
+			defer unregister()
+
+			handler.HandleConnection(connectionContext, counted)
+		}(connection)
+	}
+}
+
+// reject сообщает клиенту об отказе в соединении, если обработчик это поддерживает.
+func (s *TCPServer) reject(connection net.Conn, handler ConnectionHandler, err error) {
+	s.logger.Warn("connection rejected", "error", err, "remoteAddress", connection.RemoteAddr().String())
+
+	rejecter, ok := handler.(ConnectionRejecter)
+	if !ok {
+		return
+	}
+	if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+		s.logger.Warn("set connection deadline", "error", err)
+
+		return
+	}
+	rejecter.RejectConnection(connection, err)
+}
+
+// handleConnection выполняет запросы соединения. Запросы читаются с опережением
+// отдельной горутиной и выполняются по порядку, кроме независимых чтений
+// (FrameUnorderedRequest), которые выполняются одновременно друг с другом.
+func (s *TCPServer) handleConnection(ctx context.Context, connection net.Conn, handler Handler) {
+	version, err := s.handshake(ctx, connection)
+	if err != nil {
+		s.logger.Warn("handshake", "error", err)
+
+		return
+	}
+	errorFrames := version == ProtocolVersionErrorFrames
+	if opener, ok := handler.(ConnectionOpener); ok {
+		ctx = opener.OpenConnection(ctx)
+	}
+
+	reader := newRequestReader(connection, s.maxMessageSize, s.maxValueSize, s.idleTimeout)
+	// при ошибке отправки ответов остальные запросы соединения не выполняются
+	writer := newOutputBuffer(connection, s.idleTimeout, s.outputLimits, func(err error) {
+		s.logger.Warn("write to connection", "error", err)
+		reader.stop()
+	})
+	defer writer.close()
+	go reader.run()
+	defer reader.close()
+	// при завершении работы новые запросы не читаются, прочитанные выполняются
+	stopReading := context.AfterFunc(ctx, reader.stop)
+	defer stopReading()
+
+	unordered := sync.WaitGroup{}
+	defer unordered.Wait()
+	slots := make(chan struct{}, pipelineDepth)
+
+	for next := range reader.requests {
+		if next.err != nil {
+			// содержимое кадра пропущено, соединение можно использовать дальше
+			err := writer.write(next, true, []byte(next.err.Error()))
+			reader.done()
+			if err != nil {
+				return
+			}
+
+			continue
 		}
 
-		// В случае ошибок соединения, цикл прерывается.
-		if err != nil {
-			if !errors.Is(err, io.EOF) {
-				s.logger.Warn("read from connection", "error", err)
+		switch next.frameType {
+		case FrameData, FrameRequest:
+			unordered.Wait()
+			if !s.handleRequest(ctx, handler, reader, writer, next, errorFrames) {
+				return
 			}
+		case FrameUnorderedRequest:
+			slots <- struct{}{}
+			unordered.Add(1)
+			go func(next request) {
+				defer unordered.Done()
+				defer func() { <-slots }()
+				s.handleUnorderedRequest(ctx, handler, reader, writer, next, errorFrames)
+			}(next)
+		default:
+			_ = writer.write(next, true, []byte(fmt.Sprintf("%s: %d", ErrUnknownFrameType, next.frameType)))
 
 			return
 		}
+	}
+
+	if !reader.isExpectedError() {
+		s.logger.Warn("read from connection", "error", reader.err)
+	}
+}
+
+// handleRequest выполняет запрос, который может перевести соединение
+// в потоковый режим. Возвращает false, если соединение нужно закрыть.
+func (s *TCPServer) handleRequest(
+	ctx context.Context,
+	handler Handler,
+	reader *requestReader,
+	writer *outputBuffer,
+	next request,
+	errorFrames bool,
+) bool {
+	defer reader.done()
+
+	requestContext, cancel := newRequestContext(ctx, next)
+	stream := &connectionStream{writer: writer, reader: reader, request: next, cancel: cancel}
+	// поток изменений бесконечен, поэтому при завершении работы он прерывается сразу
+	stopStream := context.AfterFunc(ctx, func() {
+		if stream.started() {
+			cancel()
+		}
+	})
+	response, isError := handle(withStream(requestContext, stream), handler, next.payload, errorFrames)
+	stopStream()
+	cancel()
 
-		response := handler.Handle(ctx, request[:count])
-		if _, err := connection.Write(response); err != nil {
-			s.logger.Warn("write to connection", "error", err)
+	if stream.started() {
+		// После потоковой передачи соединение закрывается, итоговый ответ
+		// отправляется только при его наличии (например, сообщение об ошибке).
+		if len(response) > 0 {
+			if err := stream.Send(response); err != nil {
+				s.logger.Debug("write to connection", "error", err)
+			}
+		}
 
-			return
+		return false
+	}
+
+	return s.writeResponse(writer, next, response, isError)
+}
+
+// handleUnorderedRequest выполняет независимое чтение. Потоковый режим
+// для таких запросов недоступен.
+func (s *TCPServer) handleUnorderedRequest(
+	ctx context.Context,
+	handler Handler,
+	reader *requestReader,
+	writer *outputBuffer,
+	next request,
+	errorFrames bool,
+) {
+	defer reader.done()
+
+	requestContext, cancel := newRequestContext(ctx, next)
+	response, isError := handle(requestContext, handler, next.payload, errorFrames)
+	cancel()
+
+	// при ошибке остальные запросы соединения прерываются буфером ответов
+	s.writeResponse(writer, next, response, isError)
+}
+
+// newRequestContext создает контекст выполнения запроса со значением,
+// переданным кадрами FrameChunk.
+func newRequestContext(ctx context.Context, next request) (context.Context, context.CancelFunc) {
+	ctx, cancel := RequestContext(ctx)
+	if next.value != nil {
+		ctx = withChunkedValue(ctx, next.value)
+	}
+
+	return ctx, cancel
+}
+
+// writeResponse отправляет ответ или ошибку о превышении его размера.
+// Возвращает false, если ответ не может быть отправлен. Ошибка записывается
+// в журнал буфером ответов.
+func (s *TCPServer) writeResponse(writer *outputBuffer, to request, response []byte, isError bool) bool {
+	response, isError = limitResponse(response, isError, s.maxMessageSize)
+
+	return writer.write(to, isError, response) == nil
+}
+
+// limitResponse заменяет ответ больше maxMessageSize сообщением об ошибке.
+// Второе значение - true, если ответ нужно отправить как ошибку.
+func limitResponse(response []byte, isError bool, maxMessageSize int) ([]byte, bool) {
+	if len(response) <= maxMessageSize {
+		return response, isError
+	}
+	err := fmt.Errorf("%w: response size %d exceeds limit %d", ErrMessageTooLarge, len(response), maxMessageSize)
+
+	return []byte(err.Error()), true
+}
+
+// handshakeTLS устанавливает TLS соединение до передачи его обработчику, чтобы
+// субъект проверенного сертификата клиента был доступен через контекст.
+func (s *TCPServer) handshakeTLS(ctx context.Context, connection net.Conn) (context.Context, error) {
+	tlsConnection, ok := connection.(*tls.Conn)
+	if !ok {
+		return ctx, nil
+	}
+
+	if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+		return nil, fmt.Errorf("set connection deadline: %w", err)
+	}
+	if err := tlsConnection.HandshakeContext(ctx); err != nil {
+		return nil, err
+	}
+
+	state := tlsConnection.ConnectionState()
+	if len(state.VerifiedChains) > 0 && len(state.VerifiedChains[0]) > 0 {
+		ctx = withVerifiedSubject(ctx, state.VerifiedChains[0][0].Subject)
+	}
+
+	return ctx, nil
+}
+
+// handshake проверяет версию протокола, которую клиент отправляет первым байтом,
+// и возвращает ее.
+func (s *TCPServer) handshake(ctx context.Context, connection net.Conn) (byte, error) {
+	if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+		return 0, fmt.Errorf("set connection deadline: %w", err)
+	}
+
+	// при завершении работы ожидание версии прерывается
+	stop := context.AfterFunc(ctx, func() {
+		_ = connection.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	version := make([]byte, 1)
+	if _, err := io.ReadFull(connection, version); err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
 		}
+
+		return 0, fmt.Errorf("read protocol version: %w", err)
+	}
+	if !isSupportedVersion(version[0]) {
+		err := fmt.Errorf("%w: %d", ErrUnsupportedProtocolVersion, version[0])
+		s.writeError(connection, err)
+
+		return 0, err
+	}
+
+	return version[0], WriteFrame(connection, FrameData, version)
+}
+
+func (s *TCPServer) writeError(connection net.Conn, err error) {
+	if err := WriteFrame(connection, FrameError, []byte(err.Error())); err != nil {
+		s.logger.Debug("write to connection", "error", err)
 	}
 }
@@ -1,7 +1,10 @@
 package network_test
 
 import (
+	"bytes"
 	"context"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"log/slog"
 	"net"
@@ -13,14 +16,35 @@ import (
 	"github.com/strider2038/key-value-database/internal/database/network"
 )
 
-const messageSize = 1024
+const (
+	messageSize = 1024
+	valueSize   = 4 * messageSize
+)
 
 func TestTCPServer_Serve_WhenServerSendResponse_ExpectResponseToRequestReceived(t *testing.T) {
 	const address = ":10001"
 	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
 	waitStartup := make(chan struct{})
 	onStartup := func() { close(waitStartup) }
-	server, err := network.NewTCPServer(address, 1, messageSize, time.Second, onStartup, logger)
+	server, err := network.NewTCPServer(
+		[]network.Listener{{
+			Address:        address,
+			MaxConnections: 1,
+			// следующий клиент дожидается закрытия соединения предыдущего
+			Overload:     network.OverloadQueue,
+			QueueSize:    1,
+			QueueTimeout: time.Second,
+		}},
+		nil,
+		messageSize,
+		0,
+		time.Second,
+		time.Second,
+		nil,
+		network.OutputLimits{},
+		onStartup,
+		logger,
+	)
 	require.NoError(t, err)
 	ctx, stop := context.WithCancel(context.Background())
 	defer stop()
@@ -36,18 +60,287 @@ func TestTCPServer_Serve_WhenServerSendResponse_ExpectResponseToRequestReceived(
 
 	{
 		waitSecond(t, waitStartup)
-		connection, err := net.Dial("tcp", address)
+		client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
 		require.NoError(t, err, "connect to TCP server")
-		defer connection.Close()
+		defer client.Close()
+
+		response, err := client.Send([]byte("request"))
+		require.NoError(t, err, "send request to TCP server")
+		assert.Equal(t, "echo to request", string(response))
+	}
+}
+
+func TestTCPServer_Serve_WhenMessageTooLarge_ExpectErrorFrameAndConnectionKept(t *testing.T) {
+	const address = ":10004"
+	startEchoServer(t, address)
 
-		_, err = connection.Write([]byte("request"))
-		require.NoError(t, err, "write payload to TCP server")
+	client, err := network.NewTCPClient(address, nil, 2*messageSize, time.Second)
+	require.NoError(t, err, "connect to TCP server")
+	defer client.Close()
 
-		response := make([]byte, messageSize)
-		count, err := connection.Read(response)
-		require.NoError(t, err, "read from connection")
-		assert.Equal(t, "echo to request", string(response[:count]))
+	_, err = client.Send(make([]byte, messageSize+1))
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Contains(t, serverErr.Message, "message too large")
+
+	response, err := client.Send([]byte("request"))
+	require.NoError(t, err, "send request after oversized message")
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func TestTCPServer_Serve_WhenValueSentInChunks_ExpectValueAssembled(t *testing.T) {
+	const address = ":10018"
+	startServer(t, address, nil, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		value, ok := network.ChunkedValue(ctx)
+		if !ok {
+			return []byte("no value")
+		}
+
+		return []byte(fmt.Sprintf("%s: %d bytes, checksum %d", request, len(value), checksum(value)))
+	}))
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err, "connect to TCP server")
+	defer client.Close()
+	value := make([]byte, 3*messageSize+1)
+	for i := range value {
+		value[i] = byte(i)
 	}
+
+	response, err := client.SendChunked(context.Background(), []byte("upload"), value)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("upload: %d bytes, checksum %d", len(value), checksum(value)), string(response))
+
+	_, err = client.SendChunked(context.Background(), []byte("upload"), make([]byte, valueSize+1))
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Contains(t, serverErr.Message, "value too large")
+
+	response, err = client.Send([]byte("request"))
+	require.NoError(t, err, "value is not attached to the next request")
+	assert.Equal(t, "no value", string(response))
+}
+
+func TestTCPServer_Serve_WhenFrameFragmented_ExpectRequestAssembled(t *testing.T) {
+	const address = ":10005"
+	startEchoServer(t, address)
+
+	connection, err := net.Dial("tcp", address)
+	require.NoError(t, err, "connect to TCP server")
+	defer connection.Close()
+	_, err = connection.Write([]byte{network.ProtocolVersion})
+	require.NoError(t, err, "write protocol version")
+	_, version, err := network.ReadFrame(connection, make([]byte, 1))
+	require.NoError(t, err, "read handshake response")
+	assert.Equal(t, []byte{network.ProtocolVersion}, version)
+
+	frame := &bytes.Buffer{}
+	require.NoError(t, network.WriteFrame(frame, network.FrameData, []byte("fragmented request")))
+	for _, fragment := range [][]byte{frame.Bytes()[:3], frame.Bytes()[3:10], frame.Bytes()[10:]} {
+		_, err = connection.Write(fragment)
+		require.NoError(t, err, "write fragment")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	frameType, response, err := network.ReadFrame(connection, make([]byte, messageSize))
+	require.NoError(t, err, "read response")
+	assert.Equal(t, network.FrameData, frameType)
+	assert.Equal(t, "echo to fragmented request", string(response))
+}
+
+func TestTCPServer_Serve_WhenProtocolVersionUnsupported_ExpectErrorFrame(t *testing.T) {
+	const address = ":10006"
+	startEchoServer(t, address)
+
+	connection, err := net.Dial("tcp", address)
+	require.NoError(t, err, "connect to TCP server")
+	defer connection.Close()
+	_, err = connection.Write([]byte{network.ProtocolVersionErrorFrames + 1})
+	require.NoError(t, err, "write protocol version")
+
+	frameType, message, err := network.ReadFrame(connection, make([]byte, messageSize))
+	require.NoError(t, err, "read handshake response")
+	assert.Equal(t, network.FrameError, frameType)
+	assert.Contains(t, string(message), "unsupported protocol version")
+	_, _, err = network.ReadFrame(connection, make([]byte, messageSize))
+	assert.ErrorIs(t, err, io.EOF, "connection must be closed")
+}
+
+func TestTCPServer_Serve_WhenClientUsesErrorFrames_ExpectErrorsInErrorFrames(t *testing.T) {
+	const address = ":10032"
+	startServer(t, address, nil, errorHandler{})
+
+	client, err := network.NewTCPClientWithErrorFrames(address, nil, messageSize, time.Second)
+	require.NoError(t, err, "connect to TCP server")
+	defer client.Close()
+
+	_, err = client.Send([]byte("fail"))
+	var serverErr *network.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, "failed: fail", serverErr.Message)
+	response, err := client.Send([]byte("failed: stored value"))
+	require.NoError(t, err, "result with error text is not an error")
+	assert.Equal(t, "failed: stored value", string(response))
+}
+
+func TestTCPServer_Serve_WhenClientUsesBaseProtocol_ExpectErrorsAsResponses(t *testing.T) {
+	const address = ":10033"
+	startServer(t, address, nil, errorHandler{})
+
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err, "connect to TCP server")
+	defer client.Close()
+
+	response, err := client.Send([]byte("fail"))
+	require.NoError(t, err)
+	assert.Equal(t, "failed: fail", string(response))
+}
+
+func TestTCPServer_Serve_WhenShutdownDuringRequest_ExpectRequestCompleted(t *testing.T) {
+	const address = "127.0.0.1:10013"
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	server, err := network.NewTCPServer(
+		[]network.Listener{{Address: address, MaxConnections: 1}},
+		nil,
+		messageSize,
+		0,
+		time.Second,
+		time.Second,
+		nil,
+		network.OutputLimits{},
+		func() { close(waitStartup) },
+		logger,
+	)
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	requestStarted := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, server.Serve(ctx, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+			close(requestStarted)
+			time.Sleep(50 * time.Millisecond)
+			if ctx.Err() != nil {
+				return []byte("canceled")
+			}
+
+			return []byte("completed")
+		})))
+	}()
+	waitSecond(t, waitStartup)
+	client, err := network.NewTCPClient(address, nil, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	go func() {
+		<-requestStarted
+		stop()
+	}()
+	response, err := client.Send([]byte("request"))
+
+	require.NoError(t, err)
+	assert.Equal(t, "completed", string(response))
+	waitSecond(t, done)
+}
+
+func startEchoServer(tb testing.TB, address string) {
+	tb.Helper()
+
+	startServer(tb, address, nil, network.HandlerFunc(func(ctx context.Context, bytes []byte) []byte {
+		return append([]byte("echo to "), bytes...)
+	}))
+}
+
+func startServer(tb testing.TB, address string, tlsConfig *tls.Config, handler network.Handler) {
+	tb.Helper()
+
+	startServerWithLimits(tb, address, tlsConfig, network.OutputLimits{}, handler)
+}
+
+func startServerWithLimits(
+	tb testing.TB,
+	address string,
+	tlsConfig *tls.Config,
+	outputLimits network.OutputLimits,
+	handler network.Handler,
+) {
+	tb.Helper()
+
+	startTCPServer(tb, address, tlsConfig, outputLimits, func(ctx context.Context, server *network.TCPServer) error {
+		return server.Serve(ctx, handler)
+	})
+}
+
+func startTCPServer(
+	tb testing.TB,
+	address string,
+	tlsConfig *tls.Config,
+	outputLimits network.OutputLimits,
+	serve func(ctx context.Context, server *network.TCPServer) error,
+) {
+	tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	waitStartup := make(chan struct{})
+	onStartup := func() { close(waitStartup) }
+	server, err := network.NewTCPServer(
+		[]network.Listener{{
+			Address:        address,
+			MaxConnections: 1,
+			// следующий клиент дожидается закрытия соединения предыдущего
+			Overload:     network.OverloadQueue,
+			QueueSize:    1,
+			QueueTimeout: time.Second,
+		}},
+		tlsConfig,
+		messageSize,
+		valueSize,
+		time.Second,
+		time.Second,
+		nil,
+		outputLimits,
+		onStartup,
+		logger,
+	)
+	require.NoError(tb, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(tb, serve(ctx, server), "serve")
+	}()
+	tb.Cleanup(func() {
+		stop()
+		<-done
+	})
+	waitSecond(tb, waitStartup)
+}
+
+// errorHandler возвращает запрос как результат, а запрос "fail" выполняет
+// с ошибкой.
+type errorHandler struct{}
+
+func (h errorHandler) Handle(ctx context.Context, request []byte) []byte {
+	response, _ := h.HandleWithError(ctx, request)
+
+	return response
+}
+
+func (h errorHandler) HandleWithError(_ context.Context, request []byte) ([]byte, bool) {
+	if string(request) == "fail" {
+		return []byte("failed: fail"), true
+	}
+
+	return request, false
+}
+
+func checksum(value []byte) int {
+	sum := 0
+	for _, b := range value {
+		sum += int(b)
+	}
+
+	return sum
 }
 
 func waitSecond(tb testing.TB, wait chan struct{}) {
@@ -0,0 +1,226 @@
+package network
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// NewServerTLSConfig создает настройки TLS сервера. Сертификат и ключ перечитываются
+// с диска при изменении файлов, новые настройки применяются к новым соединениям
+// без перезапуска сервера. Если задан caFile, то сертификаты клиентов проверяются
+// по этому набору: при verifyClient = true сертификат обязателен, иначе проверяется
+// только предъявленный сертификат.
+func NewServerTLSConfig(
+	certFile string,
+	keyFile string,
+	caFile string,
+	minVersion uint16,
+	verifyClient bool,
+	logger *slog.Logger,
+) (*tls.Config, error) {
+	if verifyClient && caFile == "" {
+		return nil, fmt.Errorf("CA file is required to verify client certificates")
+	}
+
+	reloader := &certificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		logger:   logger,
+	}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+
+	clientAuth := tls.NoClientCert
+	if verifyClient {
+		clientAuth = tls.RequireAndVerifyClientCert
+	} else if caFile != "" {
+		clientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return &tls.Config{
+		MinVersion: minVersion,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			certificate, clientCAs := reloader.current()
+
+			return &tls.Config{
+				MinVersion:   minVersion,
+				Certificates: []tls.Certificate{*certificate},
+				ClientCAs:    clientCAs,
+				ClientAuth:   clientAuth,
+			}, nil
+		},
+	}, nil
+}
+
+// NewClientTLSConfig создает настройки TLS клиента. Если caFile не задан, то
+// сертификат сервера проверяется по системному набору корневых сертификатов.
+// Сертификат клиента (certFile и keyFile) необязателен.
+func NewClientTLSConfig(certFile, keyFile, caFile string, minVersion uint16) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: minVersion}
+
+	if certFile != "" || keyFile != "" {
+		certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+	if caFile != "" {
+		rootCAs, err := loadCertPool(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = rootCAs
+	}
+
+	return config, nil
+}
+
+// ParseTLSVersion преобразует версию TLS из формата "1.2" в константу пакета tls.
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q", version)
+	}
+}
+
+type verifiedSubjectKey struct{}
+
+// VerifiedSubjectFromContext возвращает субъект сертификата клиента, прошедшего
+// проверку при установке TLS соединения.
+func VerifiedSubjectFromContext(ctx context.Context) (pkix.Name, bool) {
+	subject, ok := ctx.Value(verifiedSubjectKey{}).(pkix.Name)
+
+	return subject, ok
+}
+
+func withVerifiedSubject(ctx context.Context, subject pkix.Name) context.Context {
+	return context.WithValue(ctx, verifiedSubjectKey{}, subject)
+}
+
+// certificateReloader хранит сертификат сервера и набор CA для проверки клиентов
+// и перечитывает их при изменении времени модификации или размера файлов.
+// Если новые файлы некорректны, продолжают использоваться прежние.
+type certificateReloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+	logger   *slog.Logger
+
+	mu          sync.Mutex
+	certificate *tls.Certificate
+	clientCAs   *x509.CertPool
+	versions    []fileVersion
+}
+
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+func (r *certificateReloader) current() (*tls.Certificate, *x509.CertPool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	versions, err := r.fileVersions()
+	if err != nil {
+		r.logger.Warn("check TLS files", "error", err)
+	} else if !equalVersions(versions, r.versions) {
+		if err := r.loadLocked(); err != nil {
+			r.logger.Warn("reload TLS files", "error", err)
+		} else {
+			r.logger.Info("TLS certificate reloaded")
+		}
+	}
+
+	return r.certificate, r.clientCAs
+}
+
+func (r *certificateReloader) load() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.loadLocked()
+}
+
+func (r *certificateReloader) loadLocked() error {
+	// версии файлов запоминаются до чтения, чтобы не пропустить изменение во время загрузки
+	versions, err := r.fileVersions()
+	if err != nil {
+		return err
+	}
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load server certificate: %w", err)
+	}
+	var clientCAs *x509.CertPool
+	if r.caFile != "" {
+		clientCAs, err = loadCertPool(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.certificate = &certificate
+	r.clientCAs = clientCAs
+	r.versions = versions
+
+	return nil
+}
+
+func (r *certificateReloader) fileVersions() ([]fileVersion, error) {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+
+	versions := make([]fileVersion, 0, len(files))
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, fmt.Errorf("stat %q: %w", file, err)
+		}
+		versions = append(versions, fileVersion{modTime: info.ModTime(), size: info.Size()})
+	}
+
+	return versions, nil
+}
+
+func equalVersions(a, b []fileVersion) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].modTime.Equal(b[i].modTime) || a[i].size != b[i].size {
+			return false
+		}
+	}
+
+	return true
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("CA file %q does not contain PEM certificates", file)
+	}
+
+	return pool, nil
+}
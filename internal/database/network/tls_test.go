@@ -0,0 +1,165 @@
+package network_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+type TestCA struct {
+	Certificate *x509.Certificate
+	Key         *ecdsa.PrivateKey
+	File        string
+}
+
+func NewTestCA(tb testing.TB, name string) *TestCA {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(tb, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(tb, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(tb, err)
+
+	file := filepath.Join(tb.TempDir(), "ca.pem")
+	writePEM(tb, file, "CERTIFICATE", der)
+
+	return &TestCA{Certificate: certificate, Key: key, File: file}
+}
+
+// Issue выпускает сертификат и записывает его и ключ в файлы certFile и keyFile.
+func (ca *TestCA) Issue(tb testing.TB, commonName string, certFile, keyFile string) {
+	tb.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(tb, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.Certificate, &key.PublicKey, ca.Key)
+	require.NoError(tb, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(tb, err)
+
+	writePEM(tb, certFile, "CERTIFICATE", der)
+	writePEM(tb, keyFile, "EC PRIVATE KEY", keyDER)
+}
+
+func writePEM(tb testing.TB, file, blockType string, data []byte) {
+	tb.Helper()
+
+	err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0o600)
+	require.NoError(tb, err)
+}
+
+func TestTCPServer_Serve_WhenMutualTLS_ExpectVerifiedSubjectInContext(t *testing.T) {
+	const address = "127.0.0.1:10007"
+	dir := t.TempDir()
+	ca := NewTestCA(t, "test CA")
+	ca.Issue(t, "server", filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"))
+	ca.Issue(t, "client-1", filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key"))
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	serverTLS, err := network.NewServerTLSConfig(
+		filepath.Join(dir, "server.pem"),
+		filepath.Join(dir, "server.key"),
+		ca.File,
+		tls.VersionTLS12,
+		true,
+		logger,
+	)
+	require.NoError(t, err)
+	startServer(t, address, serverTLS, network.HandlerFunc(func(ctx context.Context, request []byte) []byte {
+		subject, ok := network.VerifiedSubjectFromContext(ctx)
+		if !ok {
+			return []byte("anonymous")
+		}
+
+		return []byte(subject.CommonName)
+	}))
+
+	clientTLS, err := network.NewClientTLSConfig(
+		filepath.Join(dir, "client.pem"),
+		filepath.Join(dir, "client.key"),
+		ca.File,
+		tls.VersionTLS12,
+	)
+	require.NoError(t, err)
+	client, err := network.NewTCPClient(address, clientTLS, messageSize, time.Second)
+	require.NoError(t, err)
+	response, err := client.Send([]byte("WHOAMI"))
+	require.NoError(t, err)
+	assert.Equal(t, "client-1", string(response))
+	require.NoError(t, client.Close())
+
+	anonymousTLS, err := network.NewClientTLSConfig("", "", ca.File, tls.VersionTLS12)
+	require.NoError(t, err)
+	_, err = network.NewTCPClient(address, anonymousTLS, messageSize, time.Second)
+	assert.Error(t, err, "client without certificate must be rejected")
+}
+
+func TestTCPServer_Serve_WhenCertificateFilesChanged_ExpectCertificateReloaded(t *testing.T) {
+	const address = "127.0.0.1:10008"
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key")
+	oldCA := NewTestCA(t, "old CA")
+	newCA := NewTestCA(t, "new CA")
+	oldCA.Issue(t, "server", certFile, keyFile)
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	serverTLS, err := network.NewServerTLSConfig(certFile, keyFile, "", tls.VersionTLS12, false, logger)
+	require.NoError(t, err)
+	startEchoServerWithTLS(t, address, serverTLS)
+	clientTLS, err := network.NewClientTLSConfig("", "", newCA.File, tls.VersionTLS12)
+	require.NoError(t, err)
+
+	_, err = network.NewTCPClient(address, clientTLS, messageSize, time.Second)
+	require.Error(t, err, "certificate of old CA must not be trusted")
+	newCA.Issue(t, "server", certFile, keyFile)
+
+	client, err := network.NewTCPClient(address, clientTLS, messageSize, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+	response, err := client.Send([]byte("request"))
+	require.NoError(t, err)
+	assert.Equal(t, "echo to request", string(response))
+}
+
+func startEchoServerWithTLS(tb testing.TB, address string, tlsConfig *tls.Config) {
+	tb.Helper()
+
+	startServer(tb, address, tlsConfig, network.HandlerFunc(func(ctx context.Context, bytes []byte) []byte {
+		return append([]byte("echo to "), bytes...)
+	}))
+}
@@ -6,10 +6,24 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/database/antientropy"
 	"github.com/strider2038/key-value-database/internal/database/engine"
 	"github.com/strider2038/key-value-database/internal/database/network"
+	"github.com/strider2038/key-value-database/internal/database/raft"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
 )
 
+// clusterRequestErrors - ошибки команд управления кластером,
+// вызванные некорректным запросом.
+var clusterRequestErrors = []error{
+	antientropy.ErrInvalidNode,
+	sharding.ErrInvalidSlot,
+	sharding.ErrSlotNotOwned,
+	sharding.ErrSlotOwned,
+	sharding.ErrKeyNotInSlot,
+}
+
 type Network interface {
 	Serve(ctx context.Context, handler network.Handler) error
 }
@@ -33,25 +47,101 @@ func NewNetworkService(
 }
 
 func (s *NetworkService) Serve(ctx context.Context) error {
-	if err := s.network.Serve(ctx, network.HandlerFunc(s.handleRequest)); err != nil {
+	if err := s.network.Serve(ctx, s); err != nil {
 		return fmt.Errorf("serve: %w", err)
 	}
 
 	return nil
 }
 
-func (s *NetworkService) handleRequest(ctx context.Context, request []byte) []byte {
-	response, err := s.controller.Execute(ctx, string(request))
+// OpenConnection создает сессию клиента на время соединения.
+func (s *NetworkService) OpenConnection(ctx context.Context) context.Context {
+	return engine.WithSession(ctx, newConnectionSession(ctx))
+}
+
+// newConnectionSession создает сессию соединения с учетом того, принято ли
+// оно из резерва для администраторов.
+func newConnectionSession(ctx context.Context) *engine.Session {
+	if network.IsReservedConnection(ctx) {
+		return engine.NewReservedSession()
+	}
+
+	return engine.NewSession()
+}
+
+func (s *NetworkService) Handle(ctx context.Context, request []byte) []byte {
+	response, _ := s.HandleWithError(ctx, request)
+
+	return response
+}
+
+// HandleWithError выполняет запрос так же, как Handle, и сообщает, является ли
+// ответ описанием ошибки выполнения команды.
+func (s *NetworkService) HandleWithError(ctx context.Context, request []byte) ([]byte, bool) {
+	if stream, ok := network.StreamFromContext(ctx); ok {
+		ctx = engine.WithSender(ctx, func(message string) error {
+			return stream.Send([]byte(message + "\n"))
+		})
+	}
+
+	var response string
+	var err error
+	if value, ok := network.ChunkedValue(ctx); ok {
+		response, err = s.controller.ExecuteWithValue(ctx, string(request), string(value))
+	} else {
+		response, err = s.controller.Execute(ctx, string(request))
+	}
 	if err != nil {
-		var badRequest *engine.BadRequestError
-		if errors.As(err, &badRequest) {
-			return []byte(fmt.Sprintf("Bad request: %s", badRequest.Unwrap()))
-		}
+		return []byte(formatError(err, s.logger)), true
+	}
 
-		s.logger.Error("Internal server error", "error", err)
+	return []byte(response), false
+}
 
-		return []byte("Internal server error")
+// formatError преобразует ошибку выполнения команды в текст ответа клиенту.
+// Внутренние ошибки не раскрываются клиенту и записываются в журнал.
+func formatError(err error, logger *slog.Logger) string {
+	var badRequest *engine.BadRequestError
+	if errors.As(err, &badRequest) {
+		return fmt.Sprintf("Bad request: %s", badRequest.Unwrap())
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return "TIMEOUT request deadline exceeded"
+	}
+	if errors.Is(err, engine.ErrReservedConnection) {
+		return engine.ErrReservedConnection.Error()
 	}
+	if errors.Is(err, acl.ErrNotAuthenticated) {
+		return "NOAUTH " + acl.ErrNotAuthenticated.Error()
+	}
+	if errors.Is(err, acl.ErrInvalidCredentials) {
+		return "WRONGPASS " + acl.ErrInvalidCredentials.Error()
+	}
+	if errors.Is(err, acl.ErrForbidden) {
+		return "NOPERM " + err.Error()
+	}
+	var notLeader *raft.NotLeaderError
+	if errors.As(err, &notLeader) {
+		if notLeader.Leader == nil {
+			return "Not leader: leader is unknown"
+		}
+
+		return "REDIRECT " + notLeader.Leader.ClientAddress
+	}
+	var moved *sharding.MovedError
+	if errors.As(err, &moved) {
+		return fmt.Sprintf("MOVED %d %s", moved.Slot, moved.Address)
+	}
+	if errors.Is(err, sharding.ErrSlotNotServed) {
+		return "CLUSTERDOWN " + sharding.ErrSlotNotServed.Error()
+	}
+	for _, clusterErr := range clusterRequestErrors {
+		if errors.Is(err, clusterErr) {
+			return fmt.Sprintf("Bad request: %s", clusterErr)
+		}
+	}
+
+	logger.Error("Internal server error", "error", err)
 
-	return []byte(response)
+	return "Internal server error"
 }
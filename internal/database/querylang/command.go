@@ -1,5 +1,7 @@
 package querylang
 
+import "github.com/strider2038/key-value-database/internal/database/hlc"
+
 type CommandID int
 
 func (c CommandID) String() string {
@@ -10,6 +12,46 @@ func (c CommandID) String() string {
 		return "SET"
 	case CommandDel:
 		return "DEL"
+	case CommandClusterJoin:
+		return "CLUSTER JOIN"
+	case CommandClusterLeave:
+		return "CLUSTER LEAVE"
+	case CommandClusterNodes:
+		return "CLUSTER NODES"
+	case CommandSubscribeChanges:
+		return "SUBSCRIBE-CHANGES"
+	case CommandClusterSlots:
+		return "CLUSTER SLOTS"
+	case CommandClusterSetSlot:
+		return "CLUSTER SETSLOT"
+	case CommandClusterImport:
+		return "CLUSTER IMPORT"
+	case CommandClusterMigrate:
+		return "CLUSTER MIGRATE"
+	case CommandDigest:
+		return "DIGEST"
+	case CommandMerkle:
+		return "MERKLE"
+	case CommandRepair:
+		return "REPAIR"
+	case CommandAuth:
+		return "AUTH"
+	case CommandACLWhoAmI:
+		return "ACL WHOAMI"
+	case CommandACLList:
+		return "ACL LIST"
+	case CommandGetRange:
+		return "GETRANGE"
+	case CommandClientList:
+		return "CLIENT LIST"
+	case CommandClientSetName:
+		return "CLIENT SETNAME"
+	case CommandClientGetName:
+		return "CLIENT GETNAME"
+	case CommandClientID:
+		return "CLIENT ID"
+	case CommandClientKill:
+		return "CLIENT KILL"
 	default:
 		return ""
 	}
@@ -20,20 +62,76 @@ const (
 	CommandSet
 	CommandGet
 	CommandDel
+	CommandClusterJoin
+	CommandClusterLeave
+	CommandClusterNodes
+	CommandSubscribeChanges
+	CommandClusterSlots
+	CommandClusterSetSlot
+	CommandClusterImport
+	CommandClusterMigrate
+	CommandDigest
+	CommandMerkle
+	CommandRepair
+	CommandAuth
+	CommandACLWhoAmI
+	CommandACLList
+	CommandGetRange
+	CommandClientList
+	CommandClientSetName
+	CommandClientGetName
+	CommandClientID
+	CommandClientKill
 )
 
+// Version - версия записи в режиме нескольких лидеров: метка гибридных логических
+// часов и идентификатор узла, на котором выполнена запись. Версии упорядочены
+// по метке, а при равенстве меток - по идентификатору узла.
+type Version struct {
+	Timestamp hlc.Timestamp
+	NodeID    string
+}
+
+func (v Version) Compare(compared Version) int {
+	if result := v.Timestamp.Compare(compared.Timestamp); result != 0 {
+		return result
+	}
+	if v.NodeID < compared.NodeID {
+		return -1
+	}
+	if v.NodeID > compared.NodeID {
+		return 1
+	}
+
+	return 0
+}
+
+func (v Version) IsZero() bool {
+	return v == Version{}
+}
+
 type Command struct {
 	seqID     uint64
 	id        CommandID
 	arguments []string
+	version   Version
 }
 
 func (c *Command) SeqID() uint64       { return c.seqID }
 func (c *Command) ID() CommandID       { return c.id }
 func (c *Command) Arguments() []string { return c.arguments }
+func (c *Command) Version() Version    { return c.version }
+
+// WithVersion возвращает копию команды с версией записи.
+func (c *Command) WithVersion(version Version) *Command {
+	command := *c
+	command.version = version
+
+	return &command
+}
 
 func (c *Command) IsReadOperation() bool {
-	return c.id == CommandGet
+	return c.id == CommandGet || c.id == CommandGetRange
 }
 
 func NewCommand(seqID uint64, id CommandID, arguments ...string) *Command {
@@ -0,0 +1,49 @@
+package raft
+
+import "github.com/strider2038/key-value-database/internal/database/querylang"
+
+type EntryType int
+
+const (
+	EntryCommand EntryType = iota + 1
+	EntryConfiguration
+	EntryNoop
+)
+
+// Entry - запись журнала Raft. Позиция записи в журнале однозначно определяется
+// парой Term (срок лидера, создавшего запись) и Index (порядковый номер записи).
+type Entry struct {
+	Term      uint64
+	Index     uint64
+	Type      EntryType
+	CommandID querylang.CommandID
+	Arguments []string
+	Peers     []Peer
+}
+
+// Peer - участник кластера. Address - адрес для обмена сообщениями Raft,
+// ClientAddress - адрес для клиентских подключений к базе данных.
+type Peer struct {
+	ID            string
+	Address       string
+	ClientAddress string
+}
+
+// Snapshot - снимок состояния хранилища, включающий все записи журнала
+// до LastIndex. После создания снимка эти записи удаляются из журнала.
+type Snapshot struct {
+	LastIndex uint64
+	LastTerm  uint64
+	Peers     []Peer
+	Data      []byte
+}
+
+func findPeer(peers []Peer, id string) (Peer, bool) {
+	for _, peer := range peers {
+		if peer.ID == id {
+			return peer, true
+		}
+	}
+
+	return Peer{}, false
+}
@@ -0,0 +1,29 @@
+package raft
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrLeadershipLost              = errors.New("leadership lost before entry was committed")
+	ErrCommitTimeout               = errors.New("timeout waiting for entry to be committed")
+	ErrConfigurationChangeConflict = errors.New("another configuration change is in progress")
+	ErrNodeStopped                 = errors.New("node stopped")
+	ErrPeerExists                  = errors.New("peer already exists")
+	ErrPeerNotFound                = errors.New("peer not found")
+)
+
+// NotLeaderError возвращается, когда команда отправлена на узел, не являющийся лидером.
+// Если лидер известен, то Leader содержит его адреса для перенаправления клиента.
+type NotLeaderError struct {
+	Leader *Peer
+}
+
+func (e *NotLeaderError) Error() string {
+	if e.Leader == nil {
+		return "node is not a leader, leader is unknown"
+	}
+
+	return fmt.Sprintf("node is not a leader, leader is %q at %s", e.Leader.ID, e.Leader.ClientAddress)
+}
@@ -0,0 +1,1195 @@
+package raft
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+// StateMachine - конечный автомат, к которому применяются зафиксированные записи журнала.
+type StateMachine interface {
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
+	Snapshot() ([]byte, error)
+	Restore(snapshot []byte) error
+}
+
+type role int
+
+const (
+	follower role = iota
+	candidate
+	leader
+)
+
+func (r role) String() string {
+	switch r {
+	case follower:
+		return "follower"
+	case candidate:
+		return "candidate"
+	case leader:
+		return "leader"
+	default:
+		return ""
+	}
+}
+
+type proposal struct {
+	term   uint64
+	result chan proposalResult
+}
+
+type proposalResult struct {
+	value string
+	err   error
+}
+
+// Node - узел кластера Raft. Является адаптером контроллера хранилища: команды
+// записи добавляются в реплицируемый журнал и применяются к хранилищу только после
+// фиксации записи большинством узлов кластера. Операции чтения выполняются только
+// на лидере после подтверждения его полномочий (алгоритм ReadIndex).
+type Node struct {
+	id           string
+	store        *Store
+	transport    *Transport
+	stateMachine StateMachine
+	logger       *slog.Logger
+
+	electionTimeout   time.Duration
+	heartbeatInterval time.Duration
+	commitTimeout     time.Duration
+	snapshotThreshold uint64
+	// snapshotOnShutdown - создать снимок при остановке узла, если с момента
+	// прошлого снимка были применены записи.
+	snapshotOnShutdown bool
+
+	// applyMu сериализует изменения конечного автомата: применение записей,
+	// создание и установку снимков. Захватывается строго до mu.
+	applyMu sync.Mutex
+
+	mu          sync.Mutex
+	role        role
+	term        uint64
+	votedFor    string
+	leaderID    string
+	snapshot    *Snapshot
+	entries     []Entry
+	peers       []Peer
+	commitIndex uint64
+	lastApplied uint64
+	// leaderIndex - индекс пустой записи, добавленной лидером в начале срока.
+	// Чтение на лидере возможно только после ее применения.
+	leaderIndex      uint64
+	electionDeadline time.Time
+	lastContact      time.Time
+	nextIndex        map[string]uint64
+	matchIndex       map[string]uint64
+	inflight         map[string]bool
+	proposals        map[uint64]*proposal
+	applied          chan struct{}
+	stopped          bool
+
+	applyNotify     chan struct{}
+	replicateNotify chan struct{}
+	workers         sync.WaitGroup
+}
+
+func NewNode(
+	id string,
+	address string,
+	peers []Peer,
+	stateMachine StateMachine,
+	fs afero.Fs,
+	logger *slog.Logger,
+	electionTimeout time.Duration,
+	heartbeatInterval time.Duration,
+	commitTimeout time.Duration,
+	snapshotThreshold int,
+	snapshotOnShutdown bool,
+	dataDirectory string,
+) (*Node, error) {
+	if id == "" {
+		return nil, fmt.Errorf("node id must not be empty")
+	}
+	if electionTimeout <= 0 {
+		return nil, fmt.Errorf("election timeout must be > 0")
+	}
+	if heartbeatInterval <= 0 || heartbeatInterval >= electionTimeout {
+		return nil, fmt.Errorf("heartbeat interval must be > 0 and < election timeout")
+	}
+	if commitTimeout <= 0 {
+		return nil, fmt.Errorf("commit timeout must be > 0")
+	}
+	if snapshotThreshold < 0 {
+		return nil, fmt.Errorf("snapshot threshold must be >= 0")
+	}
+
+	store, err := NewStore(fs, dataDirectory)
+	if err != nil {
+		return nil, err
+	}
+	state, snapshot, entries, err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+	if snapshot == nil {
+		// при первом запуске конфигурация кластера берется из настроек
+		snapshot = &Snapshot{Peers: peers}
+	} else if err := stateMachine.Restore(snapshot.Data); err != nil {
+		return nil, fmt.Errorf("restore state from raft snapshot: %w", err)
+	}
+
+	n := &Node{
+		id:                 id,
+		store:              store,
+		transport:          NewTransport(address, electionTimeout, logger),
+		stateMachine:       stateMachine,
+		logger:             logger.With(slog.String("raftNodeID", id)),
+		electionTimeout:    electionTimeout,
+		heartbeatInterval:  heartbeatInterval,
+		commitTimeout:      commitTimeout,
+		snapshotThreshold:  uint64(snapshotThreshold),
+		snapshotOnShutdown: snapshotOnShutdown,
+		term:               state.Term,
+		votedFor:           state.VotedFor,
+		snapshot:           snapshot,
+		entries:            entries,
+		commitIndex:        snapshot.LastIndex,
+		lastApplied:        snapshot.LastIndex,
+		proposals:          make(map[uint64]*proposal),
+		applied:            make(chan struct{}),
+		applyNotify:        make(chan struct{}, 1),
+		replicateNotify:    make(chan struct{}, 1),
+	}
+	n.updateConfiguration()
+
+	n.logger.Info(
+		"raft node initialized",
+		slog.Uint64("term", n.term),
+		slog.Uint64("snapshotIndex", snapshot.LastIndex),
+		slog.Uint64("lastIndex", n.lastIndex()),
+		slog.Int("peersCount", len(n.peers)),
+	)
+
+	return n, nil
+}
+
+// Execute адаптер для выполнения команд БД. Команды записи реплицируются через журнал
+// Raft, команды чтения выполняются после подтверждения лидерства. Если узел не является
+// лидером, то возвращается ошибка NotLeaderError с адресом текущего лидера.
+// При отмене контекста ожидание фиксации прерывается, но добавленная в журнал
+// запись может быть зафиксирована и применена позже.
+func (n *Node) Execute(ctx context.Context, command *querylang.Command) (string, error) {
+	switch command.ID() {
+	case querylang.CommandClusterJoin:
+		arguments := command.Arguments()
+
+		return n.addPeer(ctx, Peer{ID: arguments[0], Address: arguments[1], ClientAddress: arguments[2]})
+	case querylang.CommandClusterLeave:
+		return n.removePeer(ctx, command.Arguments()[0])
+	case querylang.CommandClusterNodes:
+		return n.describePeers(), nil
+	}
+
+	if command.IsReadOperation() {
+		return n.read(ctx, command)
+	}
+
+	return n.propose(ctx, Entry{
+		Type:      EntryCommand,
+		CommandID: command.ID(),
+		Arguments: command.Arguments(),
+	})
+}
+
+func (n *Node) IsLeader() bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return n.role == leader
+}
+
+// Serve - сервисная функция узла. Обслуживает входящие сообщения от других узлов,
+// запускает выборы лидера, репликацию журнала и применение зафиксированных записей.
+// Завершается по получению сигнала отмены контекста.
+func (n *Node) Serve(ctx context.Context) error {
+	n.withLock(n.resetElectionDeadline)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var serveErr error
+	wg := sync.WaitGroup{}
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		if err := n.transport.Serve(ctx, &rpcService{node: n}); err != nil {
+			serveErr = err
+			cancel()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		n.run(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		n.applyLoop(ctx)
+	}()
+	wg.Wait()
+	n.workers.Wait()
+
+	if n.snapshotOnShutdown {
+		n.applyMu.Lock()
+		if err := n.saveSnapshot(1); err != nil {
+			n.logger.Error("take raft snapshot on shutdown", "error", err)
+		}
+		n.applyMu.Unlock()
+	}
+	n.shutdown()
+
+	return serveErr
+}
+
+func (n *Node) run(ctx context.Context) {
+	ticker := time.NewTicker(n.heartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n.tick()
+		case <-n.replicateNotify:
+			n.replicate()
+		}
+	}
+}
+
+func (n *Node) tick() {
+	n.mu.Lock()
+	isLeader := n.role == leader
+	isElectionDue := !isLeader && n.isMember() && time.Now().After(n.electionDeadline)
+	n.mu.Unlock()
+
+	if isLeader {
+		n.replicate()
+	} else if isElectionDue {
+		n.startElection()
+	}
+}
+
+func (n *Node) startElection() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.role = candidate
+	n.term++
+	n.votedFor = n.id
+	n.leaderID = ""
+	n.resetElectionDeadline()
+	if err := n.persistState(); err != nil {
+		n.logger.Error("persist raft state", "error", err)
+
+		return
+	}
+
+	n.logger.Info("election started", slog.Uint64("term", n.term))
+
+	votes := 1
+	if votes >= n.quorum() {
+		n.becomeLeader()
+
+		return
+	}
+
+	term := n.term
+	args := &RequestVoteArgs{
+		Term:         n.term,
+		CandidateID:  n.id,
+		LastLogIndex: n.lastIndex(),
+		LastLogTerm:  n.lastTerm(),
+	}
+	for _, peer := range n.otherPeers() {
+		n.workers.Add(1)
+		go func(peer Peer) {
+			defer n.workers.Done()
+
+			reply, err := n.transport.RequestVote(peer.Address, args)
+			if err != nil {
+				n.logger.Debug("request vote", slog.String("peerID", peer.ID), slog.Any("error", err))
+
+				return
+			}
+
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			if reply.Term > n.term {
+				n.stepDown(reply.Term)
+
+				return
+			}
+			if n.role != candidate || n.term != term || !reply.VoteGranted {
+				return
+			}
+			votes++
+			if votes >= n.quorum() {
+				n.becomeLeader()
+			}
+		}(peer)
+	}
+}
+
+func (n *Node) becomeLeader() {
+	n.role = leader
+	n.leaderID = n.id
+	n.nextIndex = make(map[string]uint64)
+	n.matchIndex = make(map[string]uint64)
+	n.inflight = make(map[string]bool)
+
+	// пустая запись нового срока позволяет зафиксировать записи предыдущих сроков
+	entry := Entry{Term: n.term, Index: n.lastIndex() + 1, Type: EntryNoop}
+	if err := n.appendLocal(entry); err != nil {
+		n.logger.Error("append noop entry", "error", err)
+		n.stepDown(n.term)
+
+		return
+	}
+	n.leaderIndex = entry.Index
+
+	n.logger.Info("leader elected", slog.Uint64("term", n.term), slog.Uint64("lastIndex", n.lastIndex()))
+
+	n.advanceCommitIndex()
+	n.triggerReplication()
+}
+
+func (n *Node) stepDown(term uint64) {
+	if term > n.term {
+		n.term = term
+		n.votedFor = ""
+		if err := n.persistState(); err != nil {
+			n.logger.Error("persist raft state", "error", err)
+		}
+	}
+	if n.role != follower {
+		n.logger.Info("stepped down to follower", slog.Uint64("term", n.term), slog.String("role", n.role.String()))
+		n.role = follower
+		n.resetElectionDeadline()
+	}
+}
+
+func (n *Node) handleRequestVote(args *RequestVoteArgs, reply *RequestVoteReply) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reply.Term = n.term
+	if args.Term < n.term {
+		return nil
+	}
+	// Пока лидер активен, запросы на голосование игнорируются. Это защищает
+	// кластер от перевыборов, инициированных удаленными из кластера узлами.
+	if n.role == leader || n.leaderID != "" && time.Since(n.lastContact) < n.electionTimeout {
+		return nil
+	}
+	if args.Term > n.term {
+		n.stepDown(args.Term)
+		reply.Term = n.term
+	}
+
+	isUpToDate := args.LastLogTerm > n.lastTerm() ||
+		args.LastLogTerm == n.lastTerm() && args.LastLogIndex >= n.lastIndex()
+	if (n.votedFor == "" || n.votedFor == args.CandidateID) && isUpToDate {
+		n.votedFor = args.CandidateID
+		if err := n.persistState(); err != nil {
+			return err
+		}
+		n.resetElectionDeadline()
+		reply.VoteGranted = true
+	}
+
+	return nil
+}
+
+func (n *Node) handleAppendEntries(args *AppendEntriesArgs, reply *AppendEntriesReply) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reply.Term = n.term
+	if args.Term < n.term {
+		return nil
+	}
+	n.followLeader(args.Term, args.LeaderID)
+	reply.Term = n.term
+
+	if args.PrevLogIndex > n.lastIndex() {
+		reply.ConflictIndex = n.lastIndex() + 1
+
+		return nil
+	}
+
+	entries := args.Entries
+	if args.PrevLogIndex < n.snapshot.LastIndex {
+		// записи, вошедшие в снимок, уже зафиксированы и совпадают с журналом лидера
+		skip := n.snapshot.LastIndex - args.PrevLogIndex
+		if uint64(len(entries)) <= skip {
+			entries = nil
+		} else {
+			entries = entries[skip:]
+		}
+	} else if term, _ := n.termAt(args.PrevLogIndex); term != args.PrevLogTerm {
+		conflictIndex := args.PrevLogIndex
+		for conflictIndex > n.snapshot.LastIndex+1 {
+			if previousTerm, _ := n.termAt(conflictIndex - 1); previousTerm != term {
+				break
+			}
+			conflictIndex--
+		}
+		reply.ConflictIndex = conflictIndex
+
+		return nil
+	}
+
+	truncateFrom := uint64(0)
+	var newEntries []Entry
+	for i, entry := range entries {
+		if entry.Index > n.lastIndex() {
+			newEntries = entries[i:]
+
+			break
+		}
+		if term, _ := n.termAt(entry.Index); term != entry.Term {
+			truncateFrom = entry.Index
+			newEntries = entries[i:]
+
+			break
+		}
+	}
+	if truncateFrom > 0 || len(newEntries) > 0 {
+		if err := n.store.Append(truncateFrom, newEntries); err != nil {
+			return err
+		}
+		if truncateFrom > 0 {
+			n.truncate(truncateFrom)
+		}
+		n.entries = append(n.entries, newEntries...)
+		n.updateConfiguration()
+	}
+
+	lastNewIndex := args.PrevLogIndex + uint64(len(args.Entries))
+	if commitIndex := min(args.LeaderCommit, lastNewIndex); commitIndex > n.commitIndex {
+		n.commitIndex = commitIndex
+		n.notifyApply()
+	}
+
+	reply.Success = true
+	reply.MatchIndex = lastNewIndex
+
+	return nil
+}
+
+func (n *Node) handleInstallSnapshot(args *InstallSnapshotArgs, reply *InstallSnapshotReply) error {
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	reply.Term = n.term
+	if args.Term < n.term {
+		return nil
+	}
+	n.followLeader(args.Term, args.LeaderID)
+	reply.Term = n.term
+
+	snapshot := args.Snapshot
+	if snapshot.LastIndex <= n.snapshot.LastIndex || snapshot.LastIndex <= n.lastApplied {
+		return nil
+	}
+
+	var entries []Entry
+	if term, exists := n.termAt(snapshot.LastIndex); exists && term == snapshot.LastTerm {
+		entries = n.entriesFrom(snapshot.LastIndex + 1)
+	}
+	if err := n.store.SaveSnapshot(snapshot, entries); err != nil {
+		return err
+	}
+	if err := n.stateMachine.Restore(snapshot.Data); err != nil {
+		return err
+	}
+
+	n.failProposals(0, ErrLeadershipLost)
+	n.snapshot = snapshot
+	n.entries = entries
+	n.commitIndex = max(n.commitIndex, snapshot.LastIndex)
+	n.lastApplied = snapshot.LastIndex
+	n.updateConfiguration()
+	n.notifyApplied()
+
+	n.logger.Info("snapshot installed", slog.Uint64("snapshotIndex", snapshot.LastIndex))
+
+	return nil
+}
+
+// followLeader обновляет состояние узла при получении сообщения от действующего лидера.
+func (n *Node) followLeader(term uint64, leaderID string) {
+	if term > n.term || n.role != follower {
+		n.stepDown(term)
+	}
+	n.leaderID = leaderID
+	n.lastContact = time.Now()
+	n.resetElectionDeadline()
+}
+
+// replicate запускает отправку записей журнала всем участникам кластера. Для каждого
+// участника одновременно выполняется не более одного запроса.
+func (n *Node) replicate() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.role != leader {
+		return
+	}
+
+	for _, peer := range n.otherPeers() {
+		if n.inflight[peer.ID] {
+			continue
+		}
+		n.inflight[peer.ID] = true
+
+		n.workers.Add(1)
+		go func(peer Peer) {
+			defer n.workers.Done()
+
+			_, hasMore := n.replicateTo(peer)
+
+			n.withLock(func() { delete(n.inflight, peer.ID) })
+			if hasMore {
+				n.triggerReplication()
+			}
+		}(peer)
+	}
+}
+
+// replicateTo отправляет участнику очередную порцию записей журнала или снимок, если
+// нужные записи уже удалены из журнала. Возвращает признак того, что участник признал
+// текущий срок лидера, и признак наличия неотправленных записей.
+func (n *Node) replicateTo(peer Peer) (acknowledged, hasMore bool) {
+	n.mu.Lock()
+	if n.role != leader {
+		n.mu.Unlock()
+
+		return false, false
+	}
+
+	term := n.term
+	nextIndex, exists := n.nextIndex[peer.ID]
+	if !exists {
+		nextIndex = n.lastIndex() + 1
+		n.nextIndex[peer.ID] = nextIndex
+	}
+
+	if nextIndex <= n.snapshot.LastIndex {
+		args := &InstallSnapshotArgs{Term: term, LeaderID: n.id, Snapshot: n.snapshot}
+		n.mu.Unlock()
+
+		reply, err := n.transport.InstallSnapshot(peer.Address, args)
+		if err != nil {
+			n.logger.Debug("install snapshot", slog.String("peerID", peer.ID), slog.Any("error", err))
+
+			return false, false
+		}
+
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		if !n.isCurrentLeaderReply(term, reply.Term) {
+			return false, false
+		}
+		n.updatePeerProgress(peer.ID, args.Snapshot.LastIndex)
+
+		return true, n.nextIndex[peer.ID] <= n.lastIndex()
+	}
+
+	args := &AppendEntriesArgs{
+		Term:         term,
+		LeaderID:     n.id,
+		PrevLogIndex: nextIndex - 1,
+		Entries:      n.entriesFrom(nextIndex),
+		LeaderCommit: n.commitIndex,
+	}
+	args.PrevLogTerm, _ = n.termAt(args.PrevLogIndex)
+	n.mu.Unlock()
+
+	reply, err := n.transport.AppendEntries(peer.Address, args)
+	if err != nil {
+		n.logger.Debug("append entries", slog.String("peerID", peer.ID), slog.Any("error", err))
+
+		return false, false
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.isCurrentLeaderReply(term, reply.Term) {
+		return false, false
+	}
+	if reply.Success {
+		n.updatePeerProgress(peer.ID, reply.MatchIndex)
+	} else {
+		n.nextIndex[peer.ID] = max(1, reply.ConflictIndex, n.matchIndex[peer.ID]+1)
+	}
+
+	return true, n.nextIndex[peer.ID] <= n.lastIndex()
+}
+
+func (n *Node) isCurrentLeaderReply(term, replyTerm uint64) bool {
+	if replyTerm > n.term {
+		n.stepDown(replyTerm)
+
+		return false
+	}
+
+	return n.role == leader && n.term == term
+}
+
+func (n *Node) updatePeerProgress(peerID string, matchIndex uint64) {
+	if matchIndex > n.matchIndex[peerID] {
+		n.matchIndex[peerID] = matchIndex
+	}
+	n.nextIndex[peerID] = max(n.nextIndex[peerID], n.matchIndex[peerID]+1)
+	n.advanceCommitIndex()
+}
+
+// advanceCommitIndex фиксирует записи текущего срока, сохраненные большинством участников.
+func (n *Node) advanceCommitIndex() {
+	for index := n.lastIndex(); index > n.commitIndex; index-- {
+		if term, _ := n.termAt(index); term != n.term {
+			break
+		}
+
+		count := 0
+		for _, peer := range n.peers {
+			if peer.ID == n.id || n.matchIndex[peer.ID] >= index {
+				count++
+			}
+		}
+		if count >= n.quorum() {
+			n.commitIndex = index
+			n.notifyApply()
+
+			break
+		}
+	}
+}
+
+func (n *Node) applyLoop(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-n.applyNotify:
+			n.applyCommitted()
+		}
+	}
+}
+
+func (n *Node) applyCommitted() {
+	n.applyMu.Lock()
+	defer n.applyMu.Unlock()
+
+	n.mu.Lock()
+	var entries []Entry
+	if n.commitIndex > n.lastApplied {
+		entries = n.entriesFrom(n.lastApplied + 1)
+		entries = entries[:min(uint64(len(entries)), n.commitIndex-n.lastApplied)]
+	}
+	n.mu.Unlock()
+
+	for _, entry := range entries {
+		value, err := n.applyEntry(entry)
+
+		n.mu.Lock()
+		n.lastApplied = entry.Index
+		if p, exists := n.proposals[entry.Index]; exists {
+			delete(n.proposals, entry.Index)
+			if p.term == entry.Term {
+				p.result <- proposalResult{value: value, err: err}
+			} else {
+				p.result <- proposalResult{err: ErrLeadershipLost}
+			}
+		}
+		if entry.Type == EntryConfiguration && n.role == leader && !n.isMember() {
+			// лидер, удаленный из кластера, слагает полномочия после фиксации конфигурации
+			n.stepDown(n.term)
+		}
+		n.notifyApplied()
+		n.mu.Unlock()
+	}
+
+	if err := n.takeSnapshot(); err != nil {
+		n.logger.Error("take raft snapshot", "error", err)
+	}
+}
+
+func (n *Node) applyEntry(entry Entry) (string, error) {
+	if entry.Type != EntryCommand {
+		return "OK", nil
+	}
+
+	command := querylang.NewCommand(entry.Index, entry.CommandID, entry.Arguments...)
+	value, err := n.stateMachine.Execute(context.Background(), command)
+
+	n.logger.Debug(
+		"command applied from raft log",
+		slog.Uint64("term", entry.Term),
+		slog.Uint64("index", entry.Index),
+		slog.String("commandID", entry.CommandID.String()),
+	)
+
+	return value, err
+}
+
+// takeSnapshot создает снимок хранилища, если с момента прошлого снимка было применено
+// не менее snapshotThreshold записей. Записи, вошедшие в снимок, удаляются из журнала.
+func (n *Node) takeSnapshot() error {
+	if n.snapshotThreshold == 0 {
+		return nil
+	}
+
+	return n.saveSnapshot(n.snapshotThreshold)
+}
+
+// saveSnapshot создает снимок, если с момента прошлого снимка было применено
+// не менее minApplied записей.
+func (n *Node) saveSnapshot(minApplied uint64) error {
+	n.mu.Lock()
+	if n.lastApplied-n.snapshot.LastIndex < minApplied {
+		n.mu.Unlock()
+
+		return nil
+	}
+	index := n.lastApplied
+	term, _ := n.termAt(index)
+	peers := n.configurationAt(index)
+	n.mu.Unlock()
+
+	data, err := n.stateMachine.Snapshot()
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	snapshot := &Snapshot{LastIndex: index, LastTerm: term, Peers: peers, Data: data}
+	entries := n.entriesFrom(index + 1)
+	if err := n.store.SaveSnapshot(snapshot, entries); err != nil {
+		return err
+	}
+	n.snapshot = snapshot
+	n.entries = entries
+
+	n.logger.Info("raft snapshot created", slog.Uint64("snapshotIndex", index))
+
+	return nil
+}
+
+func (n *Node) propose(ctx context.Context, entry Entry) (string, error) {
+	n.mu.Lock()
+	p, err := n.appendProposal(entry)
+	n.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	return n.waitProposal(ctx, p)
+}
+
+// appendProposal добавляет запись в журнал лидера и регистрирует ожидание ее применения.
+func (n *Node) appendProposal(entry Entry) (*proposal, error) {
+	if n.stopped {
+		return nil, ErrNodeStopped
+	}
+	if n.role != leader {
+		return nil, n.notLeaderError()
+	}
+
+	entry.Term = n.term
+	entry.Index = n.lastIndex() + 1
+	if err := n.appendLocal(entry); err != nil {
+		return nil, err
+	}
+
+	p := &proposal{term: entry.Term, result: make(chan proposalResult, 1)}
+	n.proposals[entry.Index] = p
+	n.advanceCommitIndex()
+	n.triggerReplication()
+
+	return p, nil
+}
+
+func (n *Node) waitProposal(ctx context.Context, p *proposal) (string, error) {
+	timer := time.NewTimer(n.commitTimeout)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case result := <-p.result:
+		return result.value, result.err
+	case <-timer.C:
+		err = ErrCommitTimeout
+	case <-ctx.Done():
+		err = fmt.Errorf("wait for commit: %w", ctx.Err())
+	}
+
+	n.withLock(func() {
+		for index, registered := range n.proposals {
+			if registered == p {
+				delete(n.proposals, index)
+			}
+		}
+	})
+
+	return "", err
+}
+
+func (n *Node) addPeer(ctx context.Context, peer Peer) (string, error) {
+	return n.changeConfiguration(ctx, func(peers []Peer) ([]Peer, error) {
+		if _, exists := findPeer(peers, peer.ID); exists {
+			return nil, fmt.Errorf("%w: %q", ErrPeerExists, peer.ID)
+		}
+
+		return append(peers, peer), nil
+	})
+}
+
+func (n *Node) removePeer(ctx context.Context, id string) (string, error) {
+	return n.changeConfiguration(ctx, func(peers []Peer) ([]Peer, error) {
+		if _, exists := findPeer(peers, id); !exists {
+			return nil, fmt.Errorf("%w: %q", ErrPeerNotFound, id)
+		}
+
+		return slices.DeleteFunc(peers, func(peer Peer) bool { return peer.ID == id }), nil
+	})
+}
+
+// changeConfiguration изменяет состав кластера. Изменения применяются по одному
+// участнику за раз: новая конфигурация не может быть предложена, пока предыдущая
+// не зафиксирована.
+func (n *Node) changeConfiguration(ctx context.Context, change func(peers []Peer) ([]Peer, error)) (string, error) {
+	n.mu.Lock()
+	if n.role == leader && n.latestConfigurationIndex() > n.commitIndex {
+		n.mu.Unlock()
+
+		return "", ErrConfigurationChangeConflict
+	}
+	peers, err := change(slices.Clone(n.peers))
+	if err != nil {
+		n.mu.Unlock()
+
+		return "", err
+	}
+	p, err := n.appendProposal(Entry{Type: EntryConfiguration, Peers: peers})
+	n.mu.Unlock()
+	if err != nil {
+		return "", err
+	}
+
+	return n.waitProposal(ctx, p)
+}
+
+func (n *Node) describePeers() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	lines := make([]string, 0, len(n.peers))
+	for _, peer := range n.peers {
+		peerRole := follower
+		if peer.ID == n.leaderID {
+			peerRole = leader
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s %s", peer.ID, peer.Address, peer.ClientAddress, peerRole))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (n *Node) read(ctx context.Context, command *querylang.Command) (string, error) {
+	n.mu.Lock()
+	if n.stopped {
+		n.mu.Unlock()
+
+		return "", ErrNodeStopped
+	}
+	if n.role != leader {
+		err := n.notLeaderError()
+		n.mu.Unlock()
+
+		return "", err
+	}
+	readIndex := max(n.commitIndex, n.leaderIndex)
+	n.mu.Unlock()
+
+	if !n.confirmLeadership() {
+		return "", ErrLeadershipLost
+	}
+	if err := n.waitApplied(ctx, readIndex); err != nil {
+		return "", err
+	}
+
+	return n.stateMachine.Execute(ctx, command)
+}
+
+// confirmLeadership проверяет, что большинство участников кластера признает
+// полномочия лидера, отправляя им сообщения AppendEntries.
+func (n *Node) confirmLeadership() bool {
+	n.mu.Lock()
+	peers := n.otherPeers()
+	quorum := n.quorum()
+	acknowledged := 0
+	if n.isMember() {
+		acknowledged++
+	}
+	n.mu.Unlock()
+
+	if acknowledged >= quorum {
+		return true
+	}
+
+	results := make(chan bool, len(peers))
+	for _, peer := range peers {
+		go func(peer Peer) {
+			isAcknowledged, _ := n.replicateTo(peer)
+			results <- isAcknowledged
+		}(peer)
+	}
+	for range peers {
+		if <-results {
+			acknowledged++
+			if acknowledged >= quorum {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (n *Node) waitApplied(ctx context.Context, index uint64) error {
+	timer := time.NewTimer(n.commitTimeout)
+	defer timer.Stop()
+
+	for {
+		n.mu.Lock()
+		if n.stopped {
+			n.mu.Unlock()
+
+			return ErrNodeStopped
+		}
+		if n.lastApplied >= index {
+			n.mu.Unlock()
+
+			return nil
+		}
+		applied := n.applied
+		n.mu.Unlock()
+
+		select {
+		case <-applied:
+		case <-timer.C:
+			return ErrCommitTimeout
+		case <-ctx.Done():
+			return fmt.Errorf("wait for apply: %w", ctx.Err())
+		}
+	}
+}
+
+func (n *Node) shutdown() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.stopped = true
+	n.failProposals(0, ErrNodeStopped)
+	n.notifyApplied()
+	n.transport.Close()
+	if err := n.store.Close(); err != nil {
+		n.logger.Warn("close raft store", "error", err)
+	}
+}
+
+func (n *Node) appendLocal(entry Entry) error {
+	if err := n.store.Append(0, []Entry{entry}); err != nil {
+		return err
+	}
+	n.entries = append(n.entries, entry)
+	if entry.Type == EntryConfiguration {
+		n.updateConfiguration()
+	}
+
+	return nil
+}
+
+// truncate удаляет из журнала записи начиная с индекса from. Ожидающие их клиенты
+// получают ошибку, т.к. записи были перезаписаны новым лидером.
+func (n *Node) truncate(from uint64) {
+	n.entries = n.entries[:from-n.snapshot.LastIndex-1]
+	n.failProposals(from, ErrLeadershipLost)
+}
+
+func (n *Node) failProposals(from uint64, err error) {
+	for index, p := range n.proposals {
+		if index >= from {
+			p.result <- proposalResult{err: err}
+			delete(n.proposals, index)
+		}
+	}
+}
+
+func (n *Node) persistState() error {
+	return n.store.SaveState(HardState{Term: n.term, VotedFor: n.votedFor})
+}
+
+func (n *Node) lastIndex() uint64 {
+	if len(n.entries) > 0 {
+		return n.entries[len(n.entries)-1].Index
+	}
+
+	return n.snapshot.LastIndex
+}
+
+func (n *Node) lastTerm() uint64 {
+	if len(n.entries) > 0 {
+		return n.entries[len(n.entries)-1].Term
+	}
+
+	return n.snapshot.LastTerm
+}
+
+func (n *Node) termAt(index uint64) (uint64, bool) {
+	if index == n.snapshot.LastIndex {
+		return n.snapshot.LastTerm, true
+	}
+	if index < n.snapshot.LastIndex || index > n.lastIndex() {
+		return 0, false
+	}
+
+	return n.entries[index-n.snapshot.LastIndex-1].Term, true
+}
+
+func (n *Node) entriesFrom(index uint64) []Entry {
+	if index > n.lastIndex() {
+		return nil
+	}
+
+	return slices.Clone(n.entries[index-n.snapshot.LastIndex-1:])
+}
+
+// configurationAt возвращает состав кластера, действующий на момент записи с индексом index.
+// Новая конфигурация вступает в силу сразу после добавления в журнал, не дожидаясь фиксации.
+func (n *Node) configurationAt(index uint64) []Peer {
+	for i := len(n.entries) - 1; i >= 0; i-- {
+		if n.entries[i].Index <= index && n.entries[i].Type == EntryConfiguration {
+			return n.entries[i].Peers
+		}
+	}
+
+	return n.snapshot.Peers
+}
+
+func (n *Node) latestConfigurationIndex() uint64 {
+	for i := len(n.entries) - 1; i >= 0; i-- {
+		if n.entries[i].Type == EntryConfiguration {
+			return n.entries[i].Index
+		}
+	}
+
+	return 0
+}
+
+func (n *Node) updateConfiguration() {
+	n.peers = n.configurationAt(n.lastIndex())
+}
+
+func (n *Node) isMember() bool {
+	_, exists := findPeer(n.peers, n.id)
+
+	return exists
+}
+
+func (n *Node) otherPeers() []Peer {
+	peers := make([]Peer, 0, len(n.peers))
+	for _, peer := range n.peers {
+		if peer.ID != n.id {
+			peers = append(peers, peer)
+		}
+	}
+
+	return peers
+}
+
+func (n *Node) quorum() int {
+	return len(n.peers)/2 + 1
+}
+
+func (n *Node) notLeaderError() error {
+	if peer, exists := findPeer(n.peers, n.leaderID); exists {
+		return &NotLeaderError{Leader: &peer}
+	}
+
+	return &NotLeaderError{}
+}
+
+func (n *Node) resetElectionDeadline() {
+	//nolint:gosec // для рандомизации таймаута выборов не требуется криптостойкий генератор
+	jitter := time.Duration(rand.Int63n(int64(n.electionTimeout)))
+	n.electionDeadline = time.Now().Add(n.electionTimeout + jitter)
+}
+
+func (n *Node) triggerReplication() {
+	select {
+	case n.replicateNotify <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) notifyApply() {
+	select {
+	case n.applyNotify <- struct{}{}:
+	default:
+	}
+}
+
+func (n *Node) notifyApplied() {
+	close(n.applied)
+	n.applied = make(chan struct{})
+}
+
+func (n *Node) withLock(f func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	f()
+}
+
+// rpcService - обработчик сообщений Raft от других узлов для net/rpc.
+type rpcService struct {
+	node *Node
+}
+
+func (s *rpcService) RequestVote(args *RequestVoteArgs, reply *RequestVoteReply) error {
+	return s.node.handleRequestVote(args, reply)
+}
+
+func (s *rpcService) AppendEntries(args *AppendEntriesArgs, reply *AppendEntriesReply) error {
+	return s.node.handleAppendEntries(args, reply)
+}
+
+func (s *rpcService) InstallSnapshot(args *InstallSnapshotArgs, reply *InstallSnapshotReply) error {
+	return s.node.handleInstallSnapshot(args, reply)
+}
@@ -0,0 +1,311 @@
+package raft_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/raft"
+	"github.com/strider2038/key-value-database/internal/database/storage"
+	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+type TestNode struct {
+	Peer    raft.Peer
+	Node    *raft.Node
+	Storage *inmemory.MapStorage
+	FS      afero.Fs
+
+	stop context.CancelFunc
+	done chan struct{}
+}
+
+type TestCluster struct {
+	tb    testing.TB
+	nodes map[string]*TestNode
+
+	snapshotThreshold  int
+	snapshotOnShutdown bool
+}
+
+func NewTestCluster(tb testing.TB, basePort, size, snapshotThreshold int) *TestCluster {
+	tb.Helper()
+
+	cluster := &TestCluster{tb: tb, nodes: make(map[string]*TestNode), snapshotThreshold: snapshotThreshold}
+	peers := make([]raft.Peer, 0, size)
+	for i := 1; i <= size; i++ {
+		peers = append(peers, newPeer(basePort, i))
+	}
+	for _, peer := range peers {
+		cluster.Start(peer, peers, afero.NewMemMapFs())
+	}
+	tb.Cleanup(cluster.StopAll)
+
+	return cluster
+}
+
+func (c *TestCluster) Start(peer raft.Peer, peers []raft.Peer, fs afero.Fs) *TestNode {
+	c.tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	mapStorage := inmemory.NewMapStorage()
+	node, err := raft.NewNode(
+		peer.ID,
+		peer.Address,
+		peers,
+		storage.NewController(mapStorage),
+		fs,
+		logger,
+		100*time.Millisecond,
+		20*time.Millisecond,
+		2*time.Second,
+		c.snapshotThreshold,
+		c.snapshotOnShutdown,
+		"/raft",
+	)
+	require.NoError(c.tb, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	testNode := &TestNode{Peer: peer, Node: node, Storage: mapStorage, FS: fs, stop: stop, done: make(chan struct{})}
+	go func() {
+		defer close(testNode.done)
+		assert.NoError(c.tb, node.Serve(ctx))
+	}()
+	c.nodes[peer.ID] = testNode
+
+	return testNode
+}
+
+func (c *TestCluster) Stop(id string) {
+	node := c.nodes[id]
+	node.stop()
+	<-node.done
+	delete(c.nodes, id)
+}
+
+func (c *TestCluster) StopAll() {
+	for id := range c.nodes {
+		c.Stop(id)
+	}
+}
+
+func (c *TestCluster) WaitLeader() *TestNode {
+	c.tb.Helper()
+
+	var found *TestNode
+	require.Eventually(c.tb, func() bool {
+		for _, node := range c.nodes {
+			if node.Node.IsLeader() {
+				found = node
+
+				return true
+			}
+		}
+
+		return false
+	}, 3*time.Second, 10*time.Millisecond, "waiting for leader")
+
+	return found
+}
+
+func (c *TestCluster) WaitValue(node *TestNode, key, want string) {
+	c.tb.Helper()
+
+	require.Eventually(c.tb, func() bool {
+		value, err := node.Storage.Get(context.Background(), key)
+
+		return err == nil && value == want
+	}, 3*time.Second, 10*time.Millisecond, "waiting for %q on %s", key, node.Peer.ID)
+}
+
+func TestNode_Execute_ReplicatesCommandsToFollowers(t *testing.T) {
+	cluster := NewTestCluster(t, 12100, 3, 0)
+	leader := cluster.WaitLeader()
+
+	result, err := leader.Node.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "key", "value"))
+	require.NoError(t, err)
+	assert.Equal(t, "OK", result)
+
+	for _, node := range cluster.nodes {
+		cluster.WaitValue(node, "key", "value")
+		if node == leader {
+			continue
+		}
+		_, err := node.Node.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandGet, "key"))
+		var notLeader *raft.NotLeaderError
+		require.ErrorAs(t, err, &notLeader)
+		require.NotNil(t, notLeader.Leader)
+		assert.Equal(t, leader.Peer.ClientAddress, notLeader.Leader.ClientAddress)
+	}
+
+	value, err := leader.Node.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandGet, "key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestNode_Execute_WhenLeaderStopped_ExpectNewLeaderElected(t *testing.T) {
+	cluster := NewTestCluster(t, 12200, 3, 0)
+	leader := cluster.WaitLeader()
+	_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "key", "value"))
+	require.NoError(t, err)
+
+	cluster.Stop(leader.Peer.ID)
+	newLeader := cluster.WaitLeader()
+
+	assert.NotEqual(t, leader.Peer.ID, newLeader.Peer.ID)
+	value, err := newLeader.Node.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandGet, "key"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+	_, err = newLeader.Node.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandSet, "key", "updated"))
+	require.NoError(t, err)
+	for _, node := range cluster.nodes {
+		cluster.WaitValue(node, "key", "updated")
+	}
+}
+
+func TestNode_Execute_WhenPeerJoined_ExpectStateTransferredBySnapshot(t *testing.T) {
+	const snapshotThreshold = 5
+	cluster := NewTestCluster(t, 12300, 3, snapshotThreshold)
+	leader := cluster.WaitLeader()
+	for i := 0; i < 3*snapshotThreshold; i++ {
+		_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(uint64(i), querylang.CommandSet, fmt.Sprintf("key%d", i), "value"))
+		require.NoError(t, err)
+	}
+
+	newPeer := newPeer(12300, 4)
+	joined := cluster.Start(newPeer, nil, afero.NewMemMapFs())
+	_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(
+		100, querylang.CommandClusterJoin, newPeer.ID, newPeer.Address, newPeer.ClientAddress,
+	))
+	require.NoError(t, err)
+
+	for i := 0; i < 3*snapshotThreshold; i++ {
+		cluster.WaitValue(joined, fmt.Sprintf("key%d", i), "value")
+	}
+	nodes, err := joined.Node.Execute(context.Background(), querylang.NewCommand(101, querylang.CommandClusterNodes))
+	require.NoError(t, err)
+	assert.Contains(t, nodes, "node4 "+newPeer.Address)
+	logs, err := afero.Glob(leader.FS, "/raft/log_*")
+	require.NoError(t, err)
+	assert.Len(t, logs, 1, "compacted raft log")
+}
+
+func TestNode_Execute_WhenPeerLeft_ExpectClusterContinuesWithoutIt(t *testing.T) {
+	cluster := NewTestCluster(t, 12400, 3, 0)
+	leader := cluster.WaitLeader()
+	var removed *TestNode
+	for _, node := range cluster.nodes {
+		if node != leader {
+			removed = node
+
+			break
+		}
+	}
+
+	_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandClusterLeave, removed.Peer.ID))
+	require.NoError(t, err)
+	cluster.Stop(removed.Peer.ID)
+
+	_, err = leader.Node.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandSet, "key", "value"))
+	require.NoError(t, err)
+	nodes, err := leader.Node.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandClusterNodes))
+	require.NoError(t, err)
+	assert.NotContains(t, nodes, removed.Peer.ID)
+}
+
+func TestNode_Execute_WhenRestarted_ExpectStateRestoredFromLog(t *testing.T) {
+	for _, snapshotThreshold := range []int{0, 2} {
+		t.Run(fmt.Sprintf("snapshot threshold %d", snapshotThreshold), func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			cluster := &TestCluster{tb: t, nodes: make(map[string]*TestNode), snapshotThreshold: snapshotThreshold}
+			peer := newPeer(12500, 1)
+			peers := []raft.Peer{peer}
+			cluster.Start(peer, peers, fs)
+			leader := cluster.WaitLeader()
+			_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "foo", "1"))
+			require.NoError(t, err)
+			_, err = leader.Node.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandSet, "bar", "2"))
+			require.NoError(t, err)
+			_, err = leader.Node.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandDel, "foo"))
+			require.NoError(t, err)
+			cluster.Stop(peer.ID)
+
+			cluster.Start(peer, peers, fs)
+			defer cluster.StopAll()
+			leader = cluster.WaitLeader()
+
+			value, err := leader.Node.Execute(context.Background(), querylang.NewCommand(4, querylang.CommandGet, "bar"))
+			require.NoError(t, err)
+			assert.Equal(t, "2", value)
+			_, err = leader.Storage.Get(context.Background(), "foo")
+			assert.ErrorIs(t, err, storage.ErrNotFound)
+		})
+	}
+}
+
+func TestNode_Serve_WhenSnapshotOnShutdownEnabled_ExpectLogCompacted(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	cluster := &TestCluster{tb: t, nodes: make(map[string]*TestNode), snapshotOnShutdown: true}
+	peer := newPeer(12700, 1)
+	peers := []raft.Peer{peer}
+	cluster.Start(peer, peers, fs)
+	leader := cluster.WaitLeader()
+	_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "foo", "1"))
+	require.NoError(t, err)
+
+	cluster.Stop(peer.ID)
+
+	exists, err := afero.Exists(fs, "/raft/snapshot")
+	require.NoError(t, err)
+	assert.True(t, exists, "snapshot saved on shutdown")
+	cluster.Start(peer, peers, fs)
+	defer cluster.StopAll()
+	leader = cluster.WaitLeader()
+	value, err := leader.Node.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandGet, "foo"))
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+}
+
+func TestNode_Execute_ConcurrentWrites(t *testing.T) {
+	cluster := NewTestCluster(t, 12600, 3, 10)
+	leader := cluster.WaitLeader()
+
+	const count = 50
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			_, err := leader.Node.Execute(context.Background(), querylang.NewCommand(uint64(i), querylang.CommandSet, fmt.Sprintf("key%d", i), "value"))
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	for _, node := range cluster.nodes {
+		for i := 0; i < count; i++ {
+			cluster.WaitValue(node, fmt.Sprintf("key%d", i), "value")
+		}
+	}
+}
+
+func newPeer(basePort, i int) raft.Peer {
+	return raft.Peer{
+		ID:            fmt.Sprintf("node%d", i),
+		Address:       fmt.Sprintf("127.0.0.1:%d", basePort+i),
+		ClientAddress: fmt.Sprintf("127.0.0.1:%d", basePort+50+i),
+	}
+}
@@ -0,0 +1,291 @@
+package raft
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	stateFilename    = "state"
+	snapshotFilename = "snapshot"
+)
+
+// HardState - состояние узла, которое должно быть сохранено на диск
+// до ответа на любой RPC запрос.
+type HardState struct {
+	Term     uint64
+	VotedFor string
+}
+
+// logBatch - пачка записей, добавляемая в файл журнала. Если TruncateFrom > 0,
+// то перед добавлением из журнала удаляются все записи начиная с этого индекса.
+type logBatch struct {
+	TruncateFrom uint64
+	Entries      []Entry
+}
+
+// Store - хранилище журнала Raft на диске. Журнал хранится в файлах
+// log_<snapshot_index>.log, где snapshot_index - индекс последней записи,
+// вошедшей в снимок на момент создания файла. При создании нового снимка
+// создается новый файл журнала, а старые файлы удаляются (компактификация журнала).
+type Store struct {
+	fs        afero.Fs
+	directory string
+	file      afero.File
+}
+
+func NewStore(fs afero.Fs, directory string) (*Store, error) {
+	directory = strings.TrimSuffix(directory, "/")
+	if err := fs.MkdirAll(directory, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("create raft directory: %w", err)
+	}
+
+	return &Store{fs: fs, directory: directory}, nil
+}
+
+// Load вычитывает сохраненное состояние узла: HardState, последний снимок
+// и записи журнала, следующие за снимком. Файл журнала, из которого прочитаны
+// записи, открывается для добавления новых записей.
+func (s *Store) Load() (HardState, *Snapshot, []Entry, error) {
+	var state HardState
+	if err := s.readFile(stateFilename, &state); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return state, nil, nil, fmt.Errorf("read raft state: %w", err)
+	}
+
+	var snapshot *Snapshot
+	if err := s.readFile(snapshotFilename, &snapshot); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return state, nil, nil, fmt.Errorf("read raft snapshot: %w", err)
+	}
+	snapshotIndex := uint64(0)
+	if snapshot != nil {
+		snapshotIndex = snapshot.LastIndex
+	}
+
+	entries, logIndex, err := s.readEntries(snapshotIndex)
+	if err != nil {
+		return state, nil, nil, err
+	}
+	if err := s.Close(); err != nil {
+		return state, nil, nil, fmt.Errorf("close raft log: %w", err)
+	}
+	if err := s.openLog(logIndex); err != nil {
+		return state, nil, nil, err
+	}
+
+	return state, snapshot, entries, nil
+}
+
+// SaveState перезаписывает HardState на диске.
+func (s *Store) SaveState(state HardState) error {
+	return s.writeFile(stateFilename, state)
+}
+
+// Append добавляет записи в конец журнала. Если truncateFrom > 0, то
+// предварительно отбрасываются все записи, начиная с этого индекса.
+func (s *Store) Append(truncateFrom uint64, entries []Entry) error {
+	if s.file == nil {
+		if err := s.openLog(0); err != nil {
+			return err
+		}
+	}
+
+	return s.appendBatch(logBatch{TruncateFrom: truncateFrom, Entries: entries})
+}
+
+// SaveSnapshot сохраняет снимок и переносит оставшиеся после него записи
+// в новый файл журнала. Новый файл записывается атомарно, поэтому при сбое
+// до его появления записи восстанавливаются из предыдущего файла. Файлы
+// журнала, полностью покрытые снимком, удаляются после записи нового файла.
+func (s *Store) SaveSnapshot(snapshot *Snapshot, entries []Entry) error {
+	if err := s.writeFile(snapshotFilename, snapshot); err != nil {
+		return fmt.Errorf("write raft snapshot: %w", err)
+	}
+
+	if err := s.Close(); err != nil {
+		return fmt.Errorf("close raft log: %w", err)
+	}
+
+	current := logFilename(snapshot.LastIndex)
+	if err := s.writeFile(current, logBatch{Entries: entries}); err != nil {
+		return fmt.Errorf("write raft log: %w", err)
+	}
+	if err := s.openLog(snapshot.LastIndex); err != nil {
+		return err
+	}
+
+	filenames, err := s.logFilenames()
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		if filename == current {
+			continue
+		}
+		if err := s.fs.Remove(s.directory + "/" + filename); err != nil {
+			return fmt.Errorf("remove compacted raft log %q: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+func (s *Store) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+func (s *Store) openLog(snapshotIndex uint64) error {
+	filename := s.directory + "/" + logFilename(snapshotIndex)
+	file, err := s.fs.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("open raft log: %w", err)
+	}
+	s.file = file
+
+	return nil
+}
+
+func (s *Store) appendBatch(batch logBatch) error {
+	buffer := bytes.Buffer{}
+	if err := gob.NewEncoder(&buffer).Encode(batch); err != nil {
+		return fmt.Errorf("encode raft log entries: %w", err)
+	}
+	if _, err := s.file.Write(buffer.Bytes()); err != nil {
+		return fmt.Errorf("write raft log: %w", err)
+	}
+	if err := s.file.Sync(); err != nil {
+		return fmt.Errorf("sync raft log: %w", err)
+	}
+
+	return nil
+}
+
+// readEntries восстанавливает записи журнала после снимка. Используется последний
+// файл журнала, созданный не позднее снимка: если запись нового файла была прервана
+// сбоем, то записи вычитываются из предыдущего файла. Вторым значением возвращается
+// индекс снимка в имени использованного файла (snapshotIndex, если файла нет).
+func (s *Store) readEntries(snapshotIndex uint64) ([]Entry, uint64, error) {
+	filenames, err := s.logFilenames()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var filename string
+	logIndex := snapshotIndex
+	for _, name := range filenames {
+		var index uint64
+		if _, err := fmt.Sscanf(name, "log_%d.log", &index); err != nil || index > snapshotIndex {
+			continue
+		}
+		filename = name
+		logIndex = index
+	}
+	if filename == "" {
+		return nil, logIndex, nil
+	}
+
+	data, err := afero.ReadFile(s.fs, s.directory+"/"+filename)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read raft log %q: %w", filename, err)
+	}
+
+	var entries []Entry
+	buffer := bytes.NewBuffer(data)
+	for buffer.Len() > 0 {
+		var batch logBatch
+		if err := gob.NewDecoder(buffer).Decode(&batch); err != nil {
+			return nil, 0, fmt.Errorf("decode raft log %q: %w", filename, err)
+		}
+		if batch.TruncateFrom > 0 {
+			entries = slices.DeleteFunc(entries, func(entry Entry) bool {
+				return entry.Index >= batch.TruncateFrom
+			})
+		}
+		entries = append(entries, batch.Entries...)
+	}
+
+	return slices.DeleteFunc(entries, func(entry Entry) bool {
+		return entry.Index <= snapshotIndex
+	}), logIndex, nil
+}
+
+func (s *Store) logFilenames() ([]string, error) {
+	files, err := afero.ReadDir(s.fs, s.directory)
+	if err != nil {
+		return nil, fmt.Errorf("read raft directory: %w", err)
+	}
+
+	filenames := make([]string, 0, len(files))
+	for _, file := range files {
+		// временные файлы незавершенной записи журнала пропускаются
+		if !file.IsDir() && strings.HasPrefix(file.Name(), "log_") && strings.HasSuffix(file.Name(), ".log") {
+			filenames = append(filenames, file.Name())
+		}
+	}
+	slices.Sort(filenames)
+
+	return filenames, nil
+}
+
+func (s *Store) readFile(name string, value any) error {
+	file, err := s.fs.Open(s.directory + "/" + name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+// writeFile атомарно перезаписывает файл: данные пишутся во временный файл,
+// который после синхронизации переименовывается.
+func (s *Store) writeFile(name string, value any) error {
+	buffer := bytes.Buffer{}
+	if err := gob.NewEncoder(&buffer).Encode(value); err != nil {
+		return fmt.Errorf("encode %s: %w", name, err)
+	}
+
+	filename := s.directory + "/" + name
+	file, err := s.fs.OpenFile(filename+".tmp", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", name, err)
+	}
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		file.Close()
+
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+
+		return fmt.Errorf("sync %s: %w", name, err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("close %s: %w", name, err)
+	}
+
+	return s.fs.Rename(filename+".tmp", filename)
+}
+
+func logFilename(snapshotIndex uint64) string {
+	return fmt.Sprintf("log_%020d.log", snapshotIndex)
+}
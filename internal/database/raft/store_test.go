@@ -0,0 +1,92 @@
+package raft_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/raft"
+)
+
+func TestStore_Load_WhenRestartedAfterSnapshot_ExpectAppendedEntriesRestored(t *testing.T) {
+	fs := afero.NewMemMapFs()
+	store, err := raft.NewStore(fs, "/raft")
+	require.NoError(t, err)
+	_, _, _, err = store.Load()
+	require.NoError(t, err)
+	require.NoError(t, store.Append(0, []raft.Entry{newStoreEntry(1), newStoreEntry(2)}))
+	require.NoError(t, store.SaveSnapshot(&raft.Snapshot{LastIndex: 2, LastTerm: 1}, nil))
+	require.NoError(t, store.Append(0, []raft.Entry{newStoreEntry(3)}))
+	require.NoError(t, store.Close())
+
+	restarted, err := raft.NewStore(fs, "/raft")
+	require.NoError(t, err)
+	_, snapshot, entries, err := restarted.Load()
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, uint64(2), snapshot.LastIndex)
+	assert.Equal(t, []uint64{3}, entryIndexes(entries))
+	require.NoError(t, restarted.Append(0, []raft.Entry{newStoreEntry(4)}))
+	require.NoError(t, restarted.Close())
+
+	restarted, err = raft.NewStore(fs, "/raft")
+	require.NoError(t, err)
+	_, _, entries, err = restarted.Load()
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{3, 4}, entryIndexes(entries))
+	require.NoError(t, restarted.Close())
+}
+
+func TestStore_SaveSnapshot_WhenNewLogNotWritten_ExpectEntriesRestoredFromPreviousLog(t *testing.T) {
+	fs := &failingRenameFs{Fs: afero.NewMemMapFs(), suffix: ".log"}
+	store, err := raft.NewStore(fs, "/raft")
+	require.NoError(t, err)
+	_, _, _, err = store.Load()
+	require.NoError(t, err)
+	require.NoError(t, store.Append(0, []raft.Entry{newStoreEntry(1), newStoreEntry(2), newStoreEntry(3)}))
+
+	// сбой после записи снимка, но до появления нового файла журнала
+	err = store.SaveSnapshot(&raft.Snapshot{LastIndex: 2, LastTerm: 1}, []raft.Entry{newStoreEntry(3)})
+	require.ErrorIs(t, err, errRenameFailed)
+
+	restarted, err := raft.NewStore(fs.Fs, "/raft")
+	require.NoError(t, err)
+	_, snapshot, entries, err := restarted.Load()
+	require.NoError(t, err)
+	require.NotNil(t, snapshot)
+	assert.Equal(t, uint64(2), snapshot.LastIndex)
+	assert.Equal(t, []uint64{3}, entryIndexes(entries))
+	require.NoError(t, restarted.Close())
+}
+
+var errRenameFailed = errors.New("rename failed")
+
+// failingRenameFs имитирует сбой при переименовании файлов с суффиксом suffix.
+type failingRenameFs struct {
+	afero.Fs
+	suffix string
+}
+
+func (fs *failingRenameFs) Rename(oldname, newname string) error {
+	if strings.HasSuffix(newname, fs.suffix) {
+		return errRenameFailed
+	}
+
+	return fs.Fs.Rename(oldname, newname)
+}
+
+func newStoreEntry(index uint64) raft.Entry {
+	return raft.Entry{Term: 1, Index: index, Type: raft.EntryCommand, CommandID: 1, Arguments: []string{"key", "value"}}
+}
+
+func entryIndexes(entries []raft.Entry) []uint64 {
+	indexes := make([]uint64, 0, len(entries))
+	for _, entry := range entries {
+		indexes = append(indexes, entry.Index)
+	}
+
+	return indexes
+}
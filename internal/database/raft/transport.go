@@ -0,0 +1,216 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+)
+
+type RequestVoteArgs struct {
+	Term         uint64
+	CandidateID  string
+	LastLogIndex uint64
+	LastLogTerm  uint64
+}
+
+type RequestVoteReply struct {
+	Term        uint64
+	VoteGranted bool
+}
+
+type AppendEntriesArgs struct {
+	Term         uint64
+	LeaderID     string
+	PrevLogIndex uint64
+	PrevLogTerm  uint64
+	Entries      []Entry
+	LeaderCommit uint64
+}
+
+type AppendEntriesReply struct {
+	Term    uint64
+	Success bool
+	// MatchIndex - индекс последней записи, совпадающей с журналом лидера
+	// (при Success = true).
+	MatchIndex uint64
+	// ConflictIndex - индекс, с которого лидеру следует повторить отправку
+	// записей (при Success = false).
+	ConflictIndex uint64
+}
+
+type InstallSnapshotArgs struct {
+	Term     uint64
+	LeaderID string
+	Snapshot *Snapshot
+}
+
+type InstallSnapshotReply struct {
+	Term uint64
+}
+
+// Transport - сетевой транспорт для обмена сообщениями Raft между узлами
+// поверх net/rpc. Соединения с другими узлами устанавливаются лениво и
+// переиспользуются до первой сетевой ошибки.
+type Transport struct {
+	address string
+	timeout time.Duration
+	logger  *slog.Logger
+
+	mu      sync.Mutex
+	clients map[string]*rpc.Client
+}
+
+func NewTransport(address string, timeout time.Duration, logger *slog.Logger) *Transport {
+	return &Transport{
+		address: address,
+		timeout: timeout,
+		logger:  logger,
+		clients: make(map[string]*rpc.Client),
+	}
+}
+
+// Serve принимает входящие соединения от других узлов и обслуживает их до отмены контекста.
+func (t *Transport) Serve(ctx context.Context, service any) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Raft", service); err != nil {
+		return fmt.Errorf("register raft service: %w", err)
+	}
+
+	listener, err := net.Listen("tcp", t.address)
+	if err != nil {
+		return fmt.Errorf("listen raft TCP: %w", err)
+	}
+
+	connections := make(map[net.Conn]struct{})
+	mu := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		if err := listener.Close(); err != nil {
+			t.logger.Warn("close raft listener", "error", err)
+		}
+		mu.Lock()
+		for connection := range connections {
+			connection.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				break
+			}
+			t.logger.Warn("accept raft connection", "error", err)
+
+			continue
+		}
+
+		mu.Lock()
+		connections[connection] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server.ServeConn(connection)
+			mu.Lock()
+			delete(connections, connection)
+			mu.Unlock()
+		}()
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+func (t *Transport) RequestVote(address string, args *RequestVoteArgs) (*RequestVoteReply, error) {
+	reply := &RequestVoteReply{}
+
+	return reply, t.call(address, "Raft.RequestVote", args, reply)
+}
+
+func (t *Transport) AppendEntries(address string, args *AppendEntriesArgs) (*AppendEntriesReply, error) {
+	reply := &AppendEntriesReply{}
+
+	return reply, t.call(address, "Raft.AppendEntries", args, reply)
+}
+
+func (t *Transport) InstallSnapshot(address string, args *InstallSnapshotArgs) (*InstallSnapshotReply, error) {
+	reply := &InstallSnapshotReply{}
+
+	return reply, t.call(address, "Raft.InstallSnapshot", args, reply)
+}
+
+// Close закрывает все исходящие соединения.
+func (t *Transport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for address, client := range t.clients {
+		client.Close()
+		delete(t.clients, address)
+	}
+}
+
+func (t *Transport) call(address, method string, args, reply any) error {
+	client, err := t.client(address)
+	if err != nil {
+		return err
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		var serverError rpc.ServerError
+		if call.Error != nil && !errors.As(call.Error, &serverError) {
+			t.dropClient(address, client)
+		}
+
+		return call.Error
+	case <-timer.C:
+		t.dropClient(address, client)
+
+		return fmt.Errorf("call %s on %s: timeout", method, address)
+	}
+}
+
+func (t *Transport) client(address string) (*rpc.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if client, exists := t.clients[address]; exists {
+		return client, nil
+	}
+
+	connection, err := net.DialTimeout("tcp", address, t.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("dial raft peer %s: %w", address, err)
+	}
+	client := rpc.NewClient(connection)
+	t.clients[address] = client
+
+	return client, nil
+}
+
+func (t *Transport) dropClient(address string, client *rpc.Client) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.clients[address] == client {
+		delete(t.clients, address)
+	}
+	client.Close()
+}
@@ -0,0 +1,8 @@
+package resp
+
+import "errors"
+
+var (
+	ErrProtocol        = errors.New("protocol error")
+	ErrMessageTooLarge = errors.New("message too large")
+)
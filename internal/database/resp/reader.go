@@ -0,0 +1,115 @@
+package resp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Reader читает команды протокола RESP: массивы строк (bulk strings).
+// Строковые (inline) команды не поддерживаются.
+type Reader struct {
+	reader         *bufio.Reader
+	maxMessageSize int
+}
+
+func NewReader(reader io.Reader, maxMessageSize int) *Reader {
+	return &Reader{reader: bufio.NewReader(reader), maxMessageSize: maxMessageSize}
+}
+
+// ReadCommand читает команду и возвращает ее аргументы. Суммарный размер аргументов
+// ограничен maxMessageSize, при его превышении возвращается ErrMessageTooLarge.
+// После ошибок ErrProtocol и ErrMessageTooLarge соединение следует закрыть,
+// т.к. положение в потоке данных не определено.
+func (r *Reader) ReadCommand() ([]string, error) {
+	prefix, err := r.reader.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if prefix != '*' {
+		return nil, fmt.Errorf("%w: expected '*', got %q", ErrProtocol, prefix)
+	}
+	count, err := r.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if count <= 0 {
+		return nil, fmt.Errorf("%w: empty command", ErrProtocol)
+	}
+	if count > r.maxMessageSize {
+		return nil, fmt.Errorf("%w: %d arguments", ErrMessageTooLarge, count)
+	}
+
+	arguments := make([]string, 0, count)
+	size := 0
+	for i := 0; i < count; i++ {
+		argument, err := r.readBulkString(r.maxMessageSize - size)
+		if err != nil {
+			return nil, err
+		}
+		size += len(argument)
+		arguments = append(arguments, argument)
+	}
+
+	return arguments, nil
+}
+
+func (r *Reader) readBulkString(limit int) (string, error) {
+	prefix, err := r.reader.ReadByte()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if prefix != '$' {
+		return "", fmt.Errorf("%w: expected '$', got %q", ErrProtocol, prefix)
+	}
+	length, err := r.readLength()
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", fmt.Errorf("%w: null argument", ErrProtocol)
+	}
+	if length > limit {
+		return "", fmt.Errorf("%w: limit %d bytes", ErrMessageTooLarge, r.maxMessageSize)
+	}
+
+	data := make([]byte, length+2)
+	if _, err := io.ReadFull(r.reader, data); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	if data[length] != '\r' || data[length+1] != '\n' {
+		return "", fmt.Errorf("%w: bulk string is not terminated by CRLF", ErrProtocol)
+	}
+
+	return string(data[:length]), nil
+}
+
+// readLength читает число до CRLF. Длина строки ограничена размером буфера чтения.
+func (r *Reader) readLength() (int, error) {
+	line, err := r.reader.ReadSlice('\n')
+	if err != nil {
+		if err == bufio.ErrBufferFull {
+			return 0, fmt.Errorf("%w: line is too long", ErrProtocol)
+		}
+
+		return 0, unexpectedEOF(err)
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return 0, fmt.Errorf("%w: line is not terminated by CRLF", ErrProtocol)
+	}
+	length, err := strconv.Atoi(string(line[:len(line)-2]))
+	if err != nil {
+		return 0, fmt.Errorf("%w: invalid length %q", ErrProtocol, line[:len(line)-2])
+	}
+
+	return length, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+
+	return err
+}
@@ -0,0 +1,85 @@
+package resp_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/resp"
+)
+
+func TestReader_ReadCommand(t *testing.T) {
+	reader := resp.NewReader(
+		strings.NewReader("*3\r\n$3\r\nSET\r\n$7\r\nkey one\r\n$0\r\n\r\n*1\r\n$4\r\nPING\r\n"),
+		1024,
+	)
+
+	arguments, err := reader.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"SET", "key one", ""}, arguments)
+	arguments, err = reader.ReadCommand()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"PING"}, arguments)
+	_, err = reader.ReadCommand()
+	assert.ErrorIs(t, err, io.EOF)
+}
+
+func TestReader_ReadCommand_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantError error
+	}{
+		{
+			name:      "inline command",
+			input:     "PING\r\n",
+			wantError: resp.ErrProtocol,
+		},
+		{
+			name:      "empty array",
+			input:     "*0\r\n",
+			wantError: resp.ErrProtocol,
+		},
+		{
+			name:      "invalid length",
+			input:     "*x\r\n",
+			wantError: resp.ErrProtocol,
+		},
+		{
+			name:      "not bulk string",
+			input:     "*1\r\n:1\r\n",
+			wantError: resp.ErrProtocol,
+		},
+		{
+			name:      "bulk string without CRLF",
+			input:     "*1\r\n$4\r\nPINGxx",
+			wantError: resp.ErrProtocol,
+		},
+		{
+			name:      "argument too large",
+			input:     "*1\r\n$11\r\nhello world\r\n",
+			wantError: resp.ErrMessageTooLarge,
+		},
+		{
+			name:      "arguments too large in total",
+			input:     "*2\r\n$6\r\nhello \r\n$5\r\nworld\r\n",
+			wantError: resp.ErrMessageTooLarge,
+		},
+		{
+			name:      "unexpected end of stream",
+			input:     "*2\r\n$4\r\nPING\r\n",
+			wantError: io.ErrUnexpectedEOF,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reader := resp.NewReader(strings.NewReader(test.input), 10)
+
+			_, err := reader.ReadCommand()
+
+			assert.ErrorIs(t, err, test.wantError)
+		})
+	}
+}
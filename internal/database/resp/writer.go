@@ -0,0 +1,85 @@
+package resp
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	Version2 = 2
+	Version3 = 3
+)
+
+// Writer кодирует ответы в формате RESP2 или RESP3. Версия протокола
+// переключается командой HELLO. Данные отправляются вызовом Flush.
+type Writer struct {
+	writer  *bufio.Writer
+	version int
+}
+
+func NewWriter(writer io.Writer) *Writer {
+	return &Writer{writer: bufio.NewWriter(writer), version: Version2}
+}
+
+func (w *Writer) Version() int {
+	return w.version
+}
+
+func (w *Writer) SetVersion(version int) {
+	w.version = version
+}
+
+func (w *Writer) WriteSimpleString(s string) {
+	w.writeLine('+', s)
+}
+
+// WriteError записывает ошибку. Первое слово сообщения - код ошибки, например "ERR".
+// Переводы строк в сообщении заменяются пробелами.
+func (w *Writer) WriteError(message string) {
+	w.writeLine('-', strings.NewReplacer("\r", " ", "\n", " ").Replace(message))
+}
+
+func (w *Writer) WriteInteger(n int) {
+	w.writeLine(':', strconv.Itoa(n))
+}
+
+func (w *Writer) WriteBulkString(s string) {
+	w.writeLine('$', strconv.Itoa(len(s)))
+	w.writer.WriteString(s)
+	w.writer.WriteString("\r\n")
+}
+
+// WriteNull записывает отсутствующее значение: "_" в RESP3 и "$-1" в RESP2.
+func (w *Writer) WriteNull() {
+	if w.version == Version3 {
+		w.writer.WriteString("_\r\n")
+	} else {
+		w.writer.WriteString("$-1\r\n")
+	}
+}
+
+func (w *Writer) WriteArrayHeader(count int) {
+	w.writeLine('*', strconv.Itoa(count))
+}
+
+// WriteMapHeader записывает заголовок словаря из count пар. В RESP2 словарь
+// передается массивом из 2*count элементов.
+func (w *Writer) WriteMapHeader(count int) {
+	if w.version == Version3 {
+		w.writeLine('%', strconv.Itoa(count))
+	} else {
+		w.writeLine('*', strconv.Itoa(2*count))
+	}
+}
+
+func (w *Writer) Flush() error {
+	return w.writer.Flush()
+}
+
+func (w *Writer) writeLine(prefix byte, s string) {
+	w.writer.WriteByte(prefix)
+	w.writer.WriteString(s)
+	w.writer.WriteString("\r\n")
+}
@@ -0,0 +1,47 @@
+package resp_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/resp"
+)
+
+func TestWriter(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+		want    string
+	}{
+		{
+			name:    "RESP2",
+			version: resp.Version2,
+			want:    "+OK\r\n-ERR bad request: line 2\r\n:3\r\n$5\r\nvalue\r\n$-1\r\n*2\r\n*4\r\n",
+		},
+		{
+			name:    "RESP3",
+			version: resp.Version3,
+			want:    "+OK\r\n-ERR bad request: line 2\r\n:3\r\n$5\r\nvalue\r\n_\r\n*2\r\n%2\r\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			output := &bytes.Buffer{}
+			writer := resp.NewWriter(output)
+			writer.SetVersion(test.version)
+
+			writer.WriteSimpleString("OK")
+			writer.WriteError("ERR bad request:\nline 2")
+			writer.WriteInteger(3)
+			writer.WriteBulkString("value")
+			writer.WriteNull()
+			writer.WriteArrayHeader(2)
+			writer.WriteMapHeader(2)
+			require.NoError(t, writer.Flush())
+
+			assert.Equal(t, test.want, output.String())
+		})
+	}
+}
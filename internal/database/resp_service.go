@@ -0,0 +1,287 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/network"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/resp"
+)
+
+const (
+	serverName      = "key-value-database"
+	respDefaultUser = "default"
+)
+
+// respErrorCodes - ответы об ошибках, первое слово которых совпадает с кодом
+// ошибки Redis и передается клиенту без префикса "ERR".
+var respErrorCodes = []string{"MOVED ", "REDIRECT ", "CLUSTERDOWN ", "NOAUTH ", "WRONGPASS ", "NOPERM ", "TIMEOUT "}
+
+type ConnectionNetwork interface {
+	ServeConnections(ctx context.Context, handler network.ConnectionHandler) error
+}
+
+// RESPService принимает команды по протоколу Redis (RESP2 и RESP3), что позволяет
+// подключаться к базе данных через redis-cli и клиентские библиотеки Redis.
+// Команды PING, HELLO, COMMAND и QUIT обрабатываются самим сервисом, остальные
+// передаются контроллеру списком аргументов, без разбора строки запроса.
+// Команда AUTH с одним паролем аутентифицирует пользователя "default", как в Redis.
+// Потоковые команды (SUBSCRIBE-CHANGES) по этому протоколу недоступны.
+type RESPService struct {
+	controller     *engine.Controller
+	network        ConnectionNetwork
+	maxMessageSize int
+	idleTimeout    time.Duration
+	logger         *slog.Logger
+
+	lastConnectionID atomic.Int64
+}
+
+func NewRESPService(
+	controller *engine.Controller,
+	network ConnectionNetwork,
+	maxMessageSize int,
+	idleTimeout time.Duration,
+	logger *slog.Logger,
+) *RESPService {
+	return &RESPService{
+		controller:     controller,
+		network:        network,
+		maxMessageSize: maxMessageSize,
+		idleTimeout:    idleTimeout,
+		logger:         logger,
+	}
+}
+
+func (s *RESPService) Serve(ctx context.Context) error {
+	if err := s.network.ServeConnections(ctx, s); err != nil {
+		return fmt.Errorf("serve RESP: %w", err)
+	}
+
+	return nil
+}
+
+// RejectConnection отвечает ошибкой Redis, когда достигнуто ограничение соединений.
+func (s *RESPService) RejectConnection(connection net.Conn, _ error) {
+	writer := resp.NewWriter(connection)
+	writer.WriteError("ERR max number of clients reached")
+	if err := writer.Flush(); err != nil {
+		s.logger.Debug("write to connection", "error", err)
+	}
+}
+
+func (s *RESPService) HandleConnection(ctx context.Context, connection net.Conn) {
+	// При graceful shutdown ожидание запроса прерывается установкой истекшего
+	// дедлайна чтения. Начатая команда выполняется с контекстом запроса
+	// и успевает отправить ответ.
+	stop := context.AfterFunc(ctx, func() {
+		_ = connection.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	ctx = engine.WithSession(ctx, newConnectionSession(ctx))
+	session := &respSession{
+		id:     s.lastConnectionID.Add(1),
+		reader: resp.NewReader(connection, s.maxMessageSize),
+		writer: resp.NewWriter(connection),
+	}
+
+	for {
+		if err := connection.SetDeadline(time.Now().Add(s.idleTimeout)); err != nil {
+			s.logger.Warn("set connection deadline", "error", err)
+
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+
+		arguments, err := session.reader.ReadCommand()
+		if errors.Is(err, resp.ErrProtocol) || errors.Is(err, resp.ErrMessageTooLarge) {
+			// положение в потоке данных не определено, поэтому соединение закрывается
+			session.writer.WriteError("ERR " + err.Error())
+			if err := session.writer.Flush(); err != nil {
+				s.logger.Debug("write to connection", "error", err)
+			}
+
+			return
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logger.Warn("read from connection", "error", err)
+			}
+
+			return
+		}
+
+		requestContext, cancel := network.RequestContext(ctx)
+		quit := s.handleCommand(requestContext, session, arguments)
+		cancel()
+		if err := session.writer.Flush(); err != nil {
+			s.logger.Warn("write to connection", "error", err)
+
+			return
+		}
+		if quit {
+			return
+		}
+	}
+}
+
+type respSession struct {
+	id     int64
+	reader *resp.Reader
+	writer *resp.Writer
+}
+
+// handleCommand выполняет команду и записывает ответ. Возвращает true,
+// если соединение нужно закрыть.
+func (s *RESPService) handleCommand(ctx context.Context, session *respSession, arguments []string) bool {
+	writer := session.writer
+	name := strings.ToUpper(arguments[0])
+
+	switch name {
+	case "PING":
+		s.ping(writer, arguments[1:])
+	case "HELLO":
+		s.hello(ctx, session, arguments[1:])
+	case "COMMAND":
+		// Описания команд не предоставляются, redis-cli и клиентские библиотеки
+		// в этом случае используют команды без подсказок.
+		if len(arguments) > 1 && strings.EqualFold(arguments[1], "COUNT") {
+			writer.WriteInteger(0)
+		} else {
+			writer.WriteArrayHeader(0)
+		}
+	case "QUIT":
+		writer.WriteSimpleString("OK")
+
+		return true
+	default:
+		arguments = normalizeRESPCommand(arguments)
+		response, err := s.controller.ExecuteArguments(ctx, arguments)
+		if err != nil {
+			writer.WriteError(formatRESPError(formatError(err, s.logger)))
+		} else {
+			writeRESPResponse(writer, response)
+		}
+	}
+
+	return false
+}
+
+func (s *RESPService) ping(writer *resp.Writer, arguments []string) {
+	switch len(arguments) {
+	case 0:
+		writer.WriteSimpleString("PONG")
+	case 1:
+		writer.WriteBulkString(arguments[0])
+	default:
+		writer.WriteError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// hello обрабатывает команду HELLO [protover [AUTH username password]]
+// и переключает версию протокола. Опция SETNAME не поддерживается.
+func (s *RESPService) hello(ctx context.Context, session *respSession, arguments []string) {
+	writer := session.writer
+
+	if len(arguments) > 0 {
+		version, err := strconv.Atoi(arguments[0])
+		if err != nil {
+			writer.WriteError("ERR Protocol version is not an integer or out of range")
+
+			return
+		}
+		if version != resp.Version2 && version != resp.Version3 {
+			writer.WriteError("NOPROTO unsupported protocol version")
+
+			return
+		}
+		options := arguments[1:]
+		if len(options) > 0 && strings.EqualFold(options[0], "AUTH") {
+			if len(options) < 3 {
+				writer.WriteError("ERR Syntax error in HELLO option 'AUTH'")
+
+				return
+			}
+			if err := s.controller.Authenticate(ctx, options[1], options[2]); err != nil {
+				writer.WriteError(formatRESPError(formatError(err, s.logger)))
+
+				return
+			}
+			options = options[3:]
+		}
+		if len(options) > 0 {
+			writer.WriteError(fmt.Sprintf("ERR HELLO option '%s' is not supported", options[0]))
+
+			return
+		}
+		writer.SetVersion(version)
+	}
+
+	writer.WriteMapHeader(7)
+	writer.WriteBulkString("server")
+	writer.WriteBulkString(serverName)
+	writer.WriteBulkString("version")
+	writer.WriteBulkString("1.0.0")
+	writer.WriteBulkString("proto")
+	writer.WriteInteger(writer.Version())
+	writer.WriteBulkString("id")
+	writer.WriteInteger(int(session.id))
+	writer.WriteBulkString("mode")
+	writer.WriteBulkString("standalone")
+	writer.WriteBulkString("role")
+	writer.WriteBulkString("master")
+	writer.WriteBulkString("modules")
+	writer.WriteArrayHeader(0)
+}
+
+func writeRESPResponse(writer *resp.Writer, response string) {
+	switch response {
+	case querylang.Nil:
+		writer.WriteNull()
+	case "OK":
+		writer.WriteSimpleString(response)
+	default:
+		writer.WriteBulkString(response)
+	}
+}
+
+// normalizeRESPCommand приводит названия команды и подкоманды к верхнему
+// регистру, в том числе после префикса TIMEOUT <duration>.
+func normalizeRESPCommand(arguments []string) []string {
+	name := strings.ToUpper(arguments[0])
+	arguments[0] = name
+	if name == "TIMEOUT" && len(arguments) > 2 && !strings.EqualFold(arguments[2], "TIMEOUT") {
+		return append(arguments[:2:2], normalizeRESPCommand(arguments[2:])...)
+	}
+	if (name == "CLUSTER" || name == "ACL" || name == "CLIENT") && len(arguments) > 1 {
+		arguments[1] = strings.ToUpper(arguments[1])
+	}
+	if name == "AUTH" && len(arguments) == 2 {
+		arguments = []string{name, respDefaultUser, arguments[1]}
+	}
+
+	return arguments
+}
+
+func formatRESPError(message string) string {
+	for _, code := range respErrorCodes {
+		if strings.HasPrefix(message, code) {
+			return message
+		}
+	}
+
+	return "ERR " + message
+}
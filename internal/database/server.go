@@ -11,16 +11,35 @@ type Service interface {
 	Serve(ctx context.Context) error
 }
 
+type serviceEntry struct {
+	service Service
+	// dependencies - индексы сервисов, которые использует этот сервис.
+	dependencies []int
+}
+
 type Server struct {
-	services []Service
+	services []serviceEntry
 }
 
 func NewServer() *Server {
 	return &Server{}
 }
 
-func (s *Server) AddService(service Service) {
-	s.services = append(s.services, service)
+// AddService добавляет сервис. В dependencies перечисляются ранее добавленные
+// сервисы, которые использует добавляемый сервис. Все сервисы запускаются
+// одновременно, а при завершении работы сервис останавливается только после
+// остановки всех зависящих от него сервисов. Так, сетевые сервисы завершают
+// обработку запросов до остановки хранилища и WAL журнала.
+func (s *Server) AddService(service Service, dependencies ...Service) {
+	entry := serviceEntry{service: service}
+	for _, dependency := range dependencies {
+		for i, added := range s.services {
+			if added.service == dependency {
+				entry.dependencies = append(entry.dependencies, i)
+			}
+		}
+	}
+	s.services = append(s.services, entry)
 }
 
 func (s *Server) Serve(ctx context.Context) error {
@@ -28,26 +47,67 @@ func (s *Server) Serve(ctx context.Context) error {
 		return fmt.Errorf("empty services")
 	}
 
-	serveContext, cancel := context.WithCancel(ctx)
-	defer cancel()
+	shutdownContext, shutdown := context.WithCancel(ctx)
+	defer shutdown()
+
+	// Каждый сервис получает собственный контекст, который отменяется
+	// в порядке зависимостей, а не сразу по сигналу завершения.
+	stops := make([]context.CancelFunc, len(s.services))
+	contexts := make([]context.Context, len(s.services))
+	// dependents - количество работающих сервисов, зависящих от сервиса.
+	dependents := make([]int, len(s.services))
+	for i, entry := range s.services {
+		contexts[i], stops[i] = context.WithCancel(context.WithoutCancel(ctx))
+		for _, dependency := range entry.dependencies {
+			dependents[dependency]++
+		}
+	}
 
 	mu := sync.Mutex{}
+	stopping := false
 	var errs []error
 
 	waiter := sync.WaitGroup{}
 	waiter.Add(len(s.services))
-	for _, service := range s.services {
-		go func(s Service) {
+	for i, entry := range s.services {
+		go func(i int, entry serviceEntry) {
 			defer waiter.Done()
-			if err := s.Serve(serveContext); err != nil {
-				mu.Lock()
+			defer stops[i]()
+			err := entry.service.Serve(contexts[i])
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
 				errs = append(errs, err)
-				mu.Unlock()
-				cancel()
+				shutdown()
+			}
+			for _, dependency := range entry.dependencies {
+				dependents[dependency]--
+				if stopping && dependents[dependency] == 0 {
+					stops[dependency]()
+				}
 			}
-		}(service)
+		}(i, entry)
 	}
+
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-shutdownContext.Done()
+
+		mu.Lock()
+		defer mu.Unlock()
+		stopping = true
+		for i := range s.services {
+			if dependents[i] == 0 {
+				stops[i]()
+			}
+		}
+	}()
+
 	waiter.Wait()
+	shutdown()
+	<-stopped
 
 	return errors.Join(errs...)
 }
@@ -0,0 +1,66 @@
+//go:build linux
+
+package database_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/config"
+	"github.com/strider2038/key-value-database/internal/database/network"
+	"github.com/strider2038/key-value-database/internal/di"
+)
+
+func TestServer_Serve_EpollIOModel(t *testing.T) {
+	waitServer := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 10,
+			MaxMessageSize: 1000,
+			IdleTimeout:    time.Second,
+			IOModel:        "epoll",
+			Workers:        2,
+			OnServerStart:  func() { close(waitServer) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+
+	sendCommandsToServer(t, []ServerTestStep{
+		{Request: "SET key value", WantResponse: "OK"},
+		{Request: "CLIENT SETNAME epoll", WantResponse: "OK"},
+	})
+	sendCommandsToServer(t, []ServerTestStep{
+		{Request: "GET key", WantResponse: "value"},
+		{Request: "CLIENT ID", WantResponse: "2"},
+		{
+			Request:      "SUBSCRIBE-CHANGES",
+			WantResponse: "Bad request: changes subscription requires WAL to be enabled",
+		},
+	})
+	client, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+	assert.Eventually(t, func() bool {
+		response, err := client.Send([]byte("CLIENT LIST"))
+
+		return err == nil && !strings.Contains(string(response), "\n")
+	}, time.Second, 10*time.Millisecond, "closed connections are unregistered")
+
+	client.Close()
+	stop()
+	waitSecond(t, waitFinish)
+}
@@ -1,7 +1,14 @@
 package database_test
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -10,6 +17,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/strider2038/key-value-database/internal/config"
 	"github.com/strider2038/key-value-database/internal/database"
+	"github.com/strider2038/key-value-database/internal/database/acl"
 	"github.com/strider2038/key-value-database/internal/database/network"
 	"github.com/strider2038/key-value-database/internal/di"
 	"go.uber.org/goleak"
@@ -74,6 +82,23 @@ func TestServer_Serve_NonPersistentMode(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "timeout prefix",
+			steps: []ServerTestStep{
+				{
+					Request:      "TIMEOUT 1s SET key value",
+					WantResponse: "OK",
+				},
+				{
+					Request:      "TIMEOUT 1s GET key",
+					WantResponse: "value",
+				},
+				{
+					Request:      "TIMEOUT 0s GET key",
+					WantResponse: `Bad request: parse command: analyze command: invalid "TIMEOUT" prefix: invalid argument: duration must be positive, for example 500ms`,
+				},
+			},
+		},
 		{
 			name: "invalid command",
 			steps: []ServerTestStep{
@@ -108,7 +133,7 @@ func TestServer_Serve_NonPersistentMode(t *testing.T) {
 			}()
 
 			waitSecond(t, waitServer)
-			client, err := network.NewTCPClient(ServerAddress, 10_000, time.Second)
+			client, err := network.NewTCPClient(ServerAddress, nil, 10_000, time.Second)
 			require.NoError(t, err)
 			defer client.Close()
 
@@ -175,6 +200,724 @@ func TestServer_Serve_WithWAL(t *testing.T) {
 	waitSecond(t, waitFinish)
 }
 
+func TestServer_Serve_SubscribeChanges(t *testing.T) {
+	waitServer := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 10_000,
+			MaxValueSize:   10_000,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		WAL: config.WAL{
+			Enabled:              true,
+			FlushingBatchSize:    10,
+			FlushingBatchTimeout: time.Millisecond,
+			MaxSegmentSize:       config.DefaultWALMaxSegmentSize,
+			DataDirectory:        "/wal",
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+
+	client, err := network.NewTCPClient(ServerAddress, nil, 10_000, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+	sendCommands(t, client, []ServerTestStep{
+		{Request: "SET key1 foo", WantResponse: "OK"},
+		{Request: "SET other bar", WantResponse: "OK"},
+	})
+
+	subscriber, err := network.NewTCPClient(ServerAddress, nil, 10_000, time.Second)
+	require.NoError(t, err)
+	defer subscriber.Close()
+	changes := make(chan string, 10)
+	waitStream := make(chan struct{})
+	go func() {
+		defer close(waitStream)
+		_ = subscriber.Stream([]byte("SUBSCRIBE-CHANGES FROM 0.0 PREFIX key"), func(message []byte) {
+			changes <- strings.TrimSuffix(string(message), "\n")
+		})
+	}()
+	sendCommands(t, client, []ServerTestStep{
+		{Request: "DEL key1", WantResponse: "OK"},
+		{Request: "SET key2 baz", WantResponse: "OK"},
+	})
+	response, err := client.SendChunked(context.Background(), []byte("SETCHUNKED key3"), []byte("first line\nsecond \"line\""))
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(response))
+
+	for _, want := range []string{"SET key1 foo", "DEL key1", "SET key2 baz", `SET key3 "first line\nsecond \"line\""`} {
+		select {
+		case message := <-changes:
+			lsn, change, _ := strings.Cut(message, " ")
+			assert.Regexp(t, `^\d+\.\d+$`, lsn)
+			assert.Equal(t, want, change)
+		case <-time.After(time.Second):
+			require.FailNow(t, "waiting for change", want)
+		}
+	}
+
+	client.Close()
+	subscriber.Close()
+	stop()
+	waitSecond(t, waitFinish)
+	waitSecond(t, waitStream)
+}
+
+func TestServer_Serve_ShardedCluster(t *testing.T) {
+	const (
+		node1Address = "127.0.0.1:11001"
+		node2Address = "127.0.0.1:11002"
+	)
+	nodes := []config.ShardingNode{
+		{Address: node1Address, Slots: "0-8191"},
+		{Address: node2Address, Slots: "8192-16383"},
+	}
+	ctx, stop := context.WithCancel(context.Background())
+	waitFinish := make(chan struct{}, 2)
+	for _, address := range []string{node1Address, node2Address} {
+		waitServer := make(chan struct{})
+		server, err := di.NewServer(&config.ServerOptions{
+			FS: afero.NewMemMapFs(),
+			Network: config.Network{
+				Address:        address,
+				MaxConnections: 4,
+				MaxMessageSize: 10_000,
+				MaxValueSize:   10_000,
+				IdleTimeout:    time.Second,
+				OnServerStart:  func() { close(waitServer) },
+			},
+			Sharding: config.Sharding{
+				Enabled:       true,
+				Address:       address,
+				Nodes:         nodes,
+				DataDirectory: "/sharding",
+			},
+		})
+		require.NoError(t, err)
+		go func() {
+			assert.NoError(t, server.Serve(ctx))
+			waitFinish <- struct{}{}
+		}()
+		waitSecond(t, waitServer)
+	}
+
+	clusterClient, err := di.NewClusterClient(config.ClientOptions{
+		Address:        node1Address,
+		MaxMessageSize: 10_000,
+		IdleTimeout:    time.Second,
+	})
+	require.NoError(t, err)
+	node1Client, err := network.NewTCPClient(node1Address, nil, 10_000, time.Second)
+	require.NoError(t, err)
+
+	// "foo" - слот 12182 на node2, "bar" - слот 5061 на node1
+	for i, step := range []ServerTestStep{
+		{Request: "SET foo 1", WantResponse: "OK"},
+		{Request: "SET bar 2", WantResponse: "OK"},
+		{Request: "GET foo", WantResponse: "1"},
+	} {
+		response, err := clusterClient.Send([]byte(step.Request))
+		require.NoError(t, err, "step %d", i)
+		assert.Equal(t, step.WantResponse, string(response), "step %d", i)
+	}
+	// значение с пробелами и переводом строки переносится на другой узел без изменений
+	response, err := node1Client.SendChunked(context.Background(), []byte("SETCHUNKED bar"), []byte("first line\nsecond line"))
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(response))
+	sendCommands(t, node1Client, []ServerTestStep{
+		{Request: "GET foo", WantResponse: "MOVED 12182 " + node2Address},
+		{Request: "CLUSTER MIGRATE 5061 " + node2Address, WantResponse: "OK"},
+		{Request: "GET bar", WantResponse: "MOVED 5061 " + node2Address},
+		{Request: "CLUSTER MIGRATE 5061 " + node2Address, WantResponse: "Bad request: slot is not owned by this node"},
+	})
+	response, err = clusterClient.Send([]byte("GET bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "first line\nsecond line", string(response))
+
+	require.NoError(t, clusterClient.Close())
+	require.NoError(t, node1Client.Close())
+	stop()
+	waitSecond(t, waitFinish)
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_MultiLeader(t *testing.T) {
+	addresses := map[string]string{"dc1": "127.0.0.1:11003", "dc2": "127.0.0.1:11004"}
+	peers := map[string]string{"dc1": "dc2", "dc2": "dc1"}
+	ctx, stop := context.WithCancel(context.Background())
+	waitFinish := make(chan struct{}, len(addresses))
+	clients := make(map[string]*network.TCPClient)
+	for _, nodeID := range []string{"dc1", "dc2"} {
+		waitServer := make(chan struct{})
+		server, err := di.NewServer(&config.ServerOptions{
+			FS: afero.NewMemMapFs(),
+			Network: config.Network{
+				Address:        addresses[nodeID],
+				MaxConnections: 4,
+				MaxMessageSize: 10_000,
+				MaxValueSize:   10_000,
+				IdleTimeout:    time.Second,
+				OnServerStart:  func() { close(waitServer) },
+			},
+			WAL: config.WAL{
+				Enabled:              true,
+				FlushingBatchSize:    10,
+				FlushingBatchTimeout: time.Millisecond,
+				MaxSegmentSize:       config.DefaultWALMaxSegmentSize,
+				DataDirectory:        "/wal",
+			},
+			MultiLeader: config.MultiLeader{
+				Enabled: true,
+				NodeID:  nodeID,
+				Peers: []config.MultiLeaderPeer{
+					{ID: peers[nodeID], Address: addresses[peers[nodeID]]},
+				},
+				RetryInterval: 20 * time.Millisecond,
+			},
+		})
+		require.NoError(t, err)
+		go func() {
+			assert.NoError(t, server.Serve(ctx))
+			waitFinish <- struct{}{}
+		}()
+		waitSecond(t, waitServer)
+		clients[nodeID], err = network.NewTCPClient(addresses[nodeID], nil, 10_000, time.Second)
+		require.NoError(t, err)
+	}
+	waitValue := func(nodeID, key, want string) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			response, err := clients[nodeID].Send([]byte("GET " + key))
+
+			return err == nil && string(response) == want
+		}, 2*time.Second, 10*time.Millisecond, "waiting %s = %q on %s", key, want, nodeID)
+	}
+
+	sendCommands(t, clients["dc1"], []ServerTestStep{{Request: "SET key1 foo", WantResponse: "OK"}})
+	waitValue("dc2", "key1", "foo")
+
+	// конкурентная запись одного ключа: на обоих узлах остается одно и то же значение
+	sendCommands(t, clients["dc1"], []ServerTestStep{{Request: "SET key2 from-dc1", WantResponse: "OK"}})
+	sendCommands(t, clients["dc2"], []ServerTestStep{{Request: "SET key2 from-dc2", WantResponse: "OK"}})
+	sendCommands(t, clients["dc1"], []ServerTestStep{{Request: "SET marker 1", WantResponse: "OK"}})
+	waitValue("dc2", "marker", "1")
+	response, err := clients["dc2"].Send([]byte("GET key2"))
+	require.NoError(t, err)
+	waitValue("dc1", "key2", string(response))
+
+	sendCommands(t, clients["dc2"], []ServerTestStep{{Request: "DEL key1", WantResponse: "OK"}})
+	waitValue("dc1", "key1", "$_")
+
+	// значение с пробелами и переводами строк передается в потоке изменений в кавычках
+	response, err = clients["dc1"].SendChunked(context.Background(), []byte("SETCHUNKED key3"), []byte("first line\nsecond line"))
+	require.NoError(t, err)
+	assert.Equal(t, "OK", string(response))
+	waitValue("dc2", "key3", "first line\nsecond line")
+
+	for _, client := range clients {
+		require.NoError(t, client.Close())
+	}
+	stop()
+	waitSecond(t, waitFinish)
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_RESP(t *testing.T) {
+	const respAddress = "127.0.0.1:11005"
+	waitServer := make(chan struct{})
+	waitRESP := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 10_000,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		RESP: config.RESP{
+			Enabled:       true,
+			Address:       respAddress,
+			OnServerStart: func() { close(waitRESP) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+	waitSecond(t, waitRESP)
+
+	connection, err := net.Dial("tcp", respAddress)
+	require.NoError(t, err)
+	defer connection.Close()
+	require.NoError(t, connection.SetDeadline(time.Now().Add(time.Second)))
+	reader := bufio.NewReader(connection)
+	steps := []struct {
+		request      string
+		wantResponse string
+	}{
+		{request: "*1\r\n$4\r\nping\r\n", wantResponse: "+PONG\r\n"},
+		{request: "*3\r\n$3\r\nset\r\n$7\r\nkey one\r\n$5\r\nvalue\r\n", wantResponse: "+OK\r\n"},
+		{request: "*2\r\n$3\r\nGET\r\n$7\r\nkey one\r\n", wantResponse: "$5\r\nvalue\r\n"},
+		{request: "*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n", wantResponse: "$-1\r\n"},
+		{request: "*1\r\n$7\r\nCOMMAND\r\n", wantResponse: "*0\r\n"},
+		{request: "*1\r\n$7\r\nUNKNOWN\r\n", wantResponse: "-ERR Bad request: parse command: analyze command: unknown command\r\n"},
+		{
+			request: "*2\r\n$5\r\nHELLO\r\n$1\r\n3\r\n",
+			wantResponse: "%7\r\n$6\r\nserver\r\n$18\r\nkey-value-database\r\n$7\r\nversion\r\n$5\r\n1.0.0\r\n" +
+				"$5\r\nproto\r\n:3\r\n$2\r\nid\r\n:1\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n" +
+				"$4\r\nrole\r\n$6\r\nmaster\r\n$7\r\nmodules\r\n*0\r\n",
+		},
+		{request: "*2\r\n$3\r\nGET\r\n$7\r\nmissing\r\n", wantResponse: "_\r\n"},
+		{request: "*3\r\n$6\r\nclient\r\n$7\r\nsetname\r\n$1\r\nx\r\n", wantResponse: "+OK\r\n"},
+		{request: "*2\r\n$6\r\nclient\r\n$7\r\ngetname\r\n", wantResponse: "$1\r\nx\r\n"},
+	}
+	for i, step := range steps {
+		_, err := connection.Write([]byte(step.request))
+		require.NoError(t, err, "step %d", i)
+		response := make([]byte, len(step.wantResponse))
+		_, err = io.ReadFull(reader, response)
+		require.NoError(t, err, "step %d", i)
+		assert.Equal(t, step.wantResponse, string(response), "step %d", i)
+	}
+
+	_, err = connection.Write([]byte("*2\r\n$6\r\nclient\r\n$4\r\nlist\r\n"))
+	require.NoError(t, err)
+	header, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	size, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, "$"), "\r\n"))
+	require.NoError(t, err, "bulk string header %q", header)
+	clients := make([]byte, size+2)
+	_, err = io.ReadFull(reader, clients)
+	require.NoError(t, err)
+	assert.Regexp(t, `^id=1 addr=\S+ name=x .* cmd=CLIENT LIST `, string(clients))
+
+	_, err = connection.Write([]byte("*1\r\n$4\r\nQUIT\r\n"))
+	require.NoError(t, err)
+	response := make([]byte, len("+OK\r\n"))
+	_, err = io.ReadFull(reader, response)
+	require.NoError(t, err)
+	assert.Equal(t, "+OK\r\n", string(response))
+	_, err = reader.ReadByte()
+	assert.ErrorIs(t, err, io.EOF, "connection must be closed after QUIT")
+
+	stop()
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_HTTP(t *testing.T) {
+	const httpAddress = "127.0.0.1:11006"
+	waitServer := make(chan struct{})
+	waitHTTP := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 100,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		HTTP: config.HTTP{
+			Enabled:       true,
+			Address:       httpAddress,
+			OnServerStart: func() { close(waitHTTP) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+	waitSecond(t, waitHTTP)
+
+	client := &http.Client{Timeout: time.Second}
+	steps := []struct {
+		method     string
+		path       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{method: http.MethodPut, path: "/keys/key%20one", body: `{"value":"foo bar"}`, wantStatus: 200, wantBody: `{"result":"OK"}`},
+		{method: http.MethodGet, path: "/keys/key%20one", wantStatus: 200, wantBody: `{"key":"key one","value":"foo bar"}`},
+		{method: http.MethodPost, path: "/command", body: `{"command":"SET key2 baz"}`, wantStatus: 200, wantBody: `{"result":"OK"}`},
+		{method: http.MethodPost, path: "/command", body: `{"command":"GET key2"}`, wantStatus: 200, wantBody: `{"result":"baz"}`},
+		{method: http.MethodDelete, path: "/keys/key2", wantStatus: 200, wantBody: `{"result":"OK"}`},
+		{method: http.MethodGet, path: "/keys/key2", wantStatus: 404, wantBody: `{"error":"key not found"}`},
+		{
+			method:     http.MethodPost,
+			path:       "/command",
+			body:       `{"command":"UNKNOWN"}`,
+			wantStatus: 400,
+			wantBody:   `{"error":"Bad request: parse command: analyze command: unknown command"}`,
+		},
+		{method: http.MethodPut, path: "/keys/key", body: `{}`, wantStatus: 400, wantBody: `{"error":"field \"value\" is required"}`},
+		{
+			method:     http.MethodPut,
+			path:       "/keys/key",
+			body:       `{"value":"` + strings.Repeat("x", 100) + `"}`,
+			wantStatus: 413,
+			wantBody:   `{"error":"request body exceeds limit of 100 bytes"}`,
+		},
+		{method: http.MethodPatch, path: "/keys/key", wantStatus: 405, wantBody: `{"error":"method not allowed"}`},
+	}
+	for i, step := range steps {
+		request, err := http.NewRequest(step.method, "http://"+httpAddress+step.path, strings.NewReader(step.body))
+		require.NoError(t, err, "step %d", i)
+		response, err := client.Do(request)
+		require.NoError(t, err, "step %d", i)
+		body, err := io.ReadAll(response.Body)
+		response.Body.Close()
+		require.NoError(t, err, "step %d", i)
+		assert.Equal(t, step.wantStatus, response.StatusCode, "step %d", i)
+		assert.JSONEq(t, step.wantBody, string(body), "step %d", i)
+	}
+	response, err := client.Get("http://" + httpAddress + "/debug/vars")
+	require.NoError(t, err)
+	vars := map[string]any{}
+	require.NoError(t, json.NewDecoder(response.Body).Decode(&vars))
+	response.Body.Close()
+	assert.Contains(t, vars, "network_output_limit_disconnects")
+
+	client.CloseIdleConnections()
+	stop()
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_Memcached(t *testing.T) {
+	const memcachedAddress = "127.0.0.1:11007"
+	waitServer := make(chan struct{})
+	waitMemcached := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 100,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		Memcached: config.Memcached{
+			Enabled:       true,
+			Address:       memcachedAddress,
+			OnServerStart: func() { close(waitMemcached) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+	waitSecond(t, waitMemcached)
+
+	connection, err := net.Dial("tcp", memcachedAddress)
+	require.NoError(t, err)
+	defer connection.Close()
+	require.NoError(t, connection.SetDeadline(time.Now().Add(time.Second)))
+	reader := bufio.NewReader(connection)
+	steps := []struct {
+		request      string
+		wantResponse string
+	}{
+		{request: "set key 5 0 3\r\nfoo\r\n", wantResponse: "STORED\r\n"},
+		{request: "get key missing\r\n", wantResponse: "VALUE key 5 3\r\nfoo\r\nEND\r\n"},
+		{request: "add key 0 0 1\r\nx\r\n", wantResponse: "NOT_STORED\r\n"},
+		{request: "replace key 1 0 2\r\n10\r\n", wantResponse: "STORED\r\n"},
+		{request: "incr key 5\r\n", wantResponse: "15\r\n"},
+		{request: "decr key 20\r\n", wantResponse: "0\r\n"},
+		{request: "cas key 0 0 1 1\r\nx\r\n", wantResponse: "EXISTS\r\n"},
+		{request: "touch key 100\r\n", wantResponse: "TOUCHED\r\n"},
+		{request: "set big 0 0 101\r\n" + strings.Repeat("x", 101) + "\r\n", wantResponse: "SERVER_ERROR object too large for cache\r\n"},
+		{request: "set other 0 0 2 noreply\r\nab\r\ndelete other\r\n", wantResponse: "DELETED\r\n"},
+		{request: "delete other\r\n", wantResponse: "NOT_FOUND\r\n"},
+		{request: "incr text 1\r\n", wantResponse: "NOT_FOUND\r\n"},
+		{request: "unknown\r\n", wantResponse: "ERROR\r\n"},
+		{request: "flush_all\r\n", wantResponse: "OK\r\n"},
+		{request: "get key\r\n", wantResponse: "END\r\n"},
+	}
+	for i, step := range steps {
+		_, err := connection.Write([]byte(step.request))
+		require.NoError(t, err, "step %d", i)
+		response := make([]byte, len(step.wantResponse))
+		_, err = io.ReadFull(reader, response)
+		require.NoError(t, err, "step %d", i)
+		assert.Equal(t, step.wantResponse, string(response), "step %d", i)
+	}
+
+	_, err = connection.Write([]byte("gets key2\r\n"))
+	require.NoError(t, err)
+	_, err = connection.Write([]byte("set key2 0 0 1\r\na\r\ngets key2\r\n"))
+	require.NoError(t, err)
+	for _, want := range []string{"END", "STORED"} {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, want+"\r\n", line)
+	}
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Regexp(t, `^VALUE key2 0 1 \d+\r\n$`, line)
+
+	connection.Close()
+	stop()
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_ACL(t *testing.T) {
+	const httpAddress = "127.0.0.1:11008"
+	passwordHash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	waitServer := make(chan struct{})
+	waitHTTP := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 1000,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		HTTP: config.HTTP{
+			Enabled:       true,
+			Address:       httpAddress,
+			OnServerStart: func() { close(waitHTTP) },
+		},
+		ACL: config.ACL{
+			Enabled: true,
+			Users: []config.ACLUser{
+				{
+					Name:     "alice",
+					Password: passwordHash,
+					Rules:    []config.ACLRule{{Categories: []string{"read", "write"}, Keys: "user:*"}},
+				},
+				{
+					Name:     "admin",
+					Password: passwordHash,
+					Rules:    []config.ACLRule{{Categories: []string{"admin"}, Keys: "*"}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+	waitSecond(t, waitHTTP)
+
+	sendCommandsToServer(t, []ServerTestStep{
+		{Request: "GET user:1", WantResponse: "NOAUTH authentication required"},
+		{Request: "AUTH alice wrong", WantResponse: "WRONGPASS invalid username-password pair"},
+		{Request: "AUTH alice secret", WantResponse: "OK"},
+		{Request: "ACL WHOAMI", WantResponse: "alice"},
+		{Request: "SET user:1 foo", WantResponse: "OK"},
+		{Request: "GET user:1", WantResponse: "foo"},
+		{
+			Request:      "SET order:1 foo",
+			WantResponse: `NOPERM no permissions to run the command: user "alice" has no write access for SET command`,
+		},
+		{
+			Request:      "ACL LIST",
+			WantResponse: `NOPERM no permissions to run the command: user "alice" has no admin access for ACL LIST command`,
+		},
+	})
+	sendCommandsToServer(t, []ServerTestStep{
+		{Request: "AUTH admin secret", WantResponse: "OK"},
+		{Request: "ACL LIST", WantResponse: "user alice read,write~user:*\nuser admin admin~*"},
+		{
+			Request:      "GET user:1",
+			WantResponse: `NOPERM no permissions to run the command: user "admin" has no read access for GET command`,
+		},
+	})
+
+	client := &http.Client{Timeout: time.Second}
+	request, err := http.NewRequest(http.MethodGet, "http://"+httpAddress+"/keys/user:1", nil)
+	require.NoError(t, err)
+	response, err := client.Do(request)
+	require.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+	request.SetBasicAuth("alice", "secret")
+	response, err = client.Do(request)
+	require.NoError(t, err)
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"key":"user:1","value":"foo"}`, string(body))
+
+	// метрики доступны только администраторам
+	for _, test := range []struct {
+		user       string
+		wantStatus int
+	}{
+		{user: "", wantStatus: http.StatusUnauthorized},
+		{user: "alice", wantStatus: http.StatusForbidden},
+		{user: "admin", wantStatus: http.StatusOK},
+	} {
+		request, err := http.NewRequest(http.MethodGet, "http://"+httpAddress+"/debug/vars", nil)
+		require.NoError(t, err)
+		if test.user != "" {
+			request.SetBasicAuth(test.user, "secret")
+		}
+		response, err := client.Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+		assert.Equal(t, test.wantStatus, response.StatusCode, "user %q", test.user)
+	}
+
+	client.CloseIdleConnections()
+	stop()
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_WhenReservedConnectionUsedByNonAdmin_ExpectConnectionClosed(t *testing.T) {
+	passwordHash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	waitServer := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:             ServerAddress,
+			MaxConnections:      1,
+			ReservedConnections: 1,
+			MaxMessageSize:      1000,
+			IdleTimeout:         time.Second,
+			OnServerStart:       func() { close(waitServer) },
+		},
+		ACL: config.ACL{
+			Enabled: true,
+			Users: []config.ACLUser{
+				{
+					Name:     "alice",
+					Password: passwordHash,
+					Rules:    []config.ACLRule{{Categories: []string{"read", "write"}, Keys: "*"}},
+				},
+				{
+					Name:     "admin",
+					Password: passwordHash,
+					Rules:    []config.ACLRule{{Categories: []string{"admin"}, Keys: "*"}},
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+
+	// соединение занимает единственное основное место
+	client, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+	require.NoError(t, err)
+	defer client.Close()
+
+	// резервное место освобождается после первой команды клиента без прав admin
+	for _, request := range []string{"AUTH alice secret", "GET key"} {
+		reserved, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+		require.NoError(t, err)
+		response, err := reserved.Send([]byte(request))
+		require.NoError(t, err, request)
+		assert.Equal(t, "too many connections: reserved connections are available only to admin users", string(response), request)
+		_, err = reserved.Send([]byte("PING"))
+		assert.Error(t, err, "connection must be closed after %q", request)
+		require.NoError(t, reserved.Close())
+	}
+
+	admin, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+	require.NoError(t, err)
+	defer admin.Close()
+	sendCommands(t, admin, []ServerTestStep{
+		{Request: "AUTH admin secret", WantResponse: "OK"},
+		{Request: "ACL WHOAMI", WantResponse: "admin"},
+	})
+
+	stop()
+	waitSecond(t, waitFinish)
+}
+
+func TestServer_Serve_ClientCommands(t *testing.T) {
+	waitServer := make(chan struct{})
+	waitFinish := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        ServerAddress,
+			MaxConnections: 2,
+			MaxMessageSize: 1000,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+	})
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	go func() {
+		assert.NoError(t, server.Serve(ctx))
+		close(waitFinish)
+	}()
+	waitSecond(t, waitServer)
+
+	worker, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+	require.NoError(t, err)
+	defer worker.Close()
+	sendCommands(t, worker, []ServerTestStep{
+		{Request: "CLIENT GETNAME", WantResponse: "$_"},
+		{Request: "CLIENT SETNAME worker", WantResponse: "OK"},
+		{Request: "CLIENT GETNAME", WantResponse: "worker"},
+		{Request: "CLIENT ID", WantResponse: "1"},
+		{Request: "SET key value", WantResponse: "OK"},
+	})
+
+	admin, err := network.NewTCPClient(ServerAddress, nil, 1000, time.Second)
+	require.NoError(t, err)
+	defer admin.Close()
+	response, err := admin.Send([]byte("CLIENT LIST"))
+	require.NoError(t, err)
+	clients := strings.Split(string(response), "\n")
+	require.Len(t, clients, 2)
+	assert.Regexp(t, `^id=1 addr=127\.0\.0\.1:\d+ name=worker age=0 idle=0 cmd=SET in=\d+ out=\d+$`, clients[0])
+	assert.Regexp(t, `^id=2 addr=\S+ name= age=0 idle=0 cmd=CLIENT LIST in=\d+ out=\d+$`, clients[1])
+	sendCommands(t, admin, []ServerTestStep{
+		{Request: "CLIENT KILL 3", WantResponse: "Bad request: no such client"},
+		{Request: "CLIENT KILL 1", WantResponse: "OK"},
+	})
+
+	_, err = worker.Send([]byte("GET key"))
+	assert.Error(t, err, "connection closed by server")
+
+	admin.Close()
+	stop()
+	waitSecond(t, waitFinish)
+}
+
 func createServerWithWAL(tb testing.TB, fs afero.Fs, wait chan<- struct{}) *database.Server {
 	tb.Helper()
 
@@ -200,13 +943,56 @@ func createServerWithWAL(tb testing.TB, fs afero.Fs, wait chan<- struct{}) *data
 	return server
 }
 
+type orderedService struct {
+	name    string
+	stopped chan<- string
+}
+
+func (s orderedService) Serve(ctx context.Context) error {
+	<-ctx.Done()
+	s.stopped <- s.name
+
+	return nil
+}
+
+func TestServer_Serve_WhenStopped_ExpectServicesStoppedInDependencyOrder(t *testing.T) {
+	stopped := make(chan string, 3)
+	storage := orderedService{name: "storage", stopped: stopped}
+	replicator := orderedService{name: "replicator", stopped: stopped}
+	frontend := orderedService{name: "network", stopped: stopped}
+	server := database.NewServer()
+	server.AddService(storage)
+	server.AddService(replicator, storage)
+	server.AddService(frontend, storage)
+	ctx, stop := context.WithCancel(context.Background())
+
+	stop()
+	err := server.Serve(ctx)
+
+	require.NoError(t, err)
+	close(stopped)
+	order := make([]string, 0, 3)
+	for name := range stopped {
+		order = append(order, name)
+	}
+	require.Len(t, order, 3)
+	assert.ElementsMatch(t, []string{"replicator", "network"}, order[:2])
+	assert.Equal(t, "storage", order[2])
+}
+
 func sendCommandsToServer(tb testing.TB, writeSteps []ServerTestStep) {
 	tb.Helper()
 
-	client, err := network.NewTCPClient(ServerAddress, 10_000, time.Second)
+	client, err := network.NewTCPClient(ServerAddress, nil, 10_000, time.Second)
 	require.NoError(tb, err)
 	defer client.Close()
 
+	sendCommands(tb, client, writeSteps)
+}
+
+func sendCommands(tb testing.TB, client *network.TCPClient, writeSteps []ServerTestStep) {
+	tb.Helper()
+
 	for i, step := range writeSteps {
 		response, err := client.Send([]byte(step.Request))
 		require.NoError(tb, err, "step %d", i)
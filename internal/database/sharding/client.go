@@ -0,0 +1,136 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const maxRedirects = 5
+
+// Client - клиент шардированного кластера. Клиент загружает карту слотов с
+// начального узла и отправляет команды с ключами напрямую узлу-владельцу слота.
+// При получении ответа MOVED карта слотов обновляется, а команда повторяется
+// на указанном узле.
+//
+// Клиент не предназначен для конкурентного использования.
+type Client struct {
+	seedAddress string
+	dial        Dialer
+	slots       *SlotMap
+	nodes       map[string]NodeClient
+}
+
+func NewClient(seedAddress string, dial Dialer) (*Client, error) {
+	c := &Client{
+		seedAddress: seedAddress,
+		dial:        dial,
+		nodes:       make(map[string]NodeClient),
+	}
+	if err := c.RefreshSlots(); err != nil {
+		return nil, errors.Join(err, c.Close())
+	}
+
+	return c, nil
+}
+
+// RefreshSlots загружает карту слотов с начального узла.
+func (c *Client) RefreshSlots() error {
+	response, err := c.sendTo(c.seedAddress, []byte("CLUSTER SLOTS"))
+	if err != nil {
+		return fmt.Errorf("load slot map: %w", err)
+	}
+	ranges, err := ParseSlotRanges(string(response))
+	if err != nil {
+		return fmt.Errorf("load slot map: %w", err)
+	}
+	c.slots = NewSlotMap(ranges)
+
+	return nil
+}
+
+func (c *Client) Send(request []byte) ([]byte, error) {
+	address := c.seedAddress
+	if key, ok := requestKey(string(request)); ok {
+		if owner := c.slots.Owner(SlotForKey(key)); owner != "" {
+			address = owner
+		}
+	}
+
+	for i := 0; i <= maxRedirects; i++ {
+		response, err := c.sendTo(address, request)
+		if err != nil {
+			return nil, err
+		}
+
+		slot, owner, isMoved := parseMoved(string(response))
+		if !isMoved {
+			return response, nil
+		}
+		c.slots.Assign(slot, owner)
+		address = owner
+	}
+
+	return nil, fmt.Errorf("send %q: %w", request, ErrTooManyRedirects)
+}
+
+func (c *Client) Close() error {
+	errs := make([]error, 0, len(c.nodes))
+	for address, node := range c.nodes {
+		errs = append(errs, node.Close())
+		delete(c.nodes, address)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (c *Client) sendTo(address string, request []byte) ([]byte, error) {
+	node, exists := c.nodes[address]
+	if !exists {
+		var err error
+		node, err = c.dial(address)
+		if err != nil {
+			return nil, fmt.Errorf("connect to %s: %w", address, err)
+		}
+		c.nodes[address] = node
+	}
+
+	response, err := node.Send(request)
+	if err != nil {
+		// после сетевой ошибки соединение пересоздается при следующем запросе
+		delete(c.nodes, address)
+		node.Close()
+
+		return nil, err
+	}
+
+	return response, nil
+}
+
+// requestKey извлекает ключ из команд GET, GETRANGE, SET и DEL.
+func requestKey(request string) (string, bool) {
+	fields := strings.Fields(request)
+	if len(fields) < 2 {
+		return "", false
+	}
+	switch fields[0] {
+	case "GET", "GETRANGE", "SET", "DEL":
+		return fields[1], true
+	}
+
+	return "", false
+}
+
+// parseMoved разбирает ответ вида "MOVED <slot> <address>".
+func parseMoved(response string) (int, string, bool) {
+	fields := strings.Fields(response)
+	if len(fields) != 3 || fields[0] != "MOVED" {
+		return 0, "", false
+	}
+	slot, err := ParseSlot(fields[1])
+	if err != nil {
+		return 0, "", false
+	}
+
+	return slot, fields[2], true
+}
@@ -0,0 +1,338 @@
+package sharding
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+)
+
+const slotsFilename = "slots.txt"
+
+type StorageController interface {
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
+}
+
+// ValueSource - источник значений для переноса ключей слота на другой узел.
+type ValueSource interface {
+	Select(match func(key string) bool) map[string]string
+}
+
+type IDGenerator interface {
+	NextSeqID() uint64
+}
+
+// NodeClient - соединение с другим узлом кластера.
+type NodeClient interface {
+	Send(request []byte) ([]byte, error)
+	// SendChunked передает значение value отдельно от запроса, например,
+	// для команды CLUSTER IMPORTCHUNKED <slot> <key>.
+	SendChunked(ctx context.Context, request, value []byte) ([]byte, error)
+	Close() error
+}
+
+type Dialer func(address string) (NodeClient, error)
+
+// Controller - адаптер контроллера базы данных для режима шардированного кластера.
+// Команды с ключами выполняются, только если слот ключа обслуживается этим узлом,
+// иначе возвращается ошибка MovedError с адресом узла-владельца слота.
+//
+// Карта слотов хранится в файле и после первого запуска имеет приоритет над
+// настройками, т.к. может быть изменена переносом слотов.
+type Controller struct {
+	storageController StorageController
+	values            ValueSource
+	idGenerator       IDGenerator
+	dial              Dialer
+	address           string
+	fs                afero.Fs
+	dataDirectory     string
+	logger            *slog.Logger
+
+	// slotLocks блокирует слот на запись на время переноса ключей,
+	// команды в остальных слотах продолжают выполняться
+	slotLocks [SlotCount]sync.RWMutex
+
+	mu    sync.RWMutex
+	slots *SlotMap
+}
+
+func NewController(
+	storageController StorageController,
+	values ValueSource,
+	idGenerator IDGenerator,
+	dial Dialer,
+	address string,
+	ranges []SlotRange,
+	fs afero.Fs,
+	logger *slog.Logger,
+	dataDirectory string,
+) (*Controller, error) {
+	c := &Controller{
+		storageController: storageController,
+		values:            values,
+		idGenerator:       idGenerator,
+		dial:              dial,
+		address:           address,
+		fs:                fs,
+		dataDirectory:     dataDirectory,
+		logger:            logger,
+	}
+
+	slots, err := c.loadSlots()
+	if err != nil {
+		return nil, fmt.Errorf("load slot map: %w", err)
+	}
+	if slots == nil {
+		slots = NewSlotMap(ranges)
+		if err := c.saveSlots(slots); err != nil {
+			return nil, fmt.Errorf("save slot map: %w", err)
+		}
+	}
+	c.slots = slots
+
+	return c, nil
+}
+
+func (c *Controller) Execute(ctx context.Context, command *querylang.Command) (string, error) {
+	switch command.ID() {
+	case querylang.CommandGet, querylang.CommandGetRange, querylang.CommandSet, querylang.CommandDel:
+		return c.executeKeyCommand(ctx, command)
+	case querylang.CommandClusterSlots:
+		return c.handleSlots(), nil
+	case querylang.CommandClusterSetSlot:
+		return c.handleSetSlot(command.Arguments())
+	case querylang.CommandClusterImport:
+		return c.handleImport(ctx, command)
+	case querylang.CommandClusterMigrate:
+		return c.handleMigrate(ctx, command)
+	default:
+		return c.storageController.Execute(ctx, command)
+	}
+}
+
+func (c *Controller) executeKeyCommand(ctx context.Context, command *querylang.Command) (string, error) {
+	slot := SlotForKey(command.Arguments()[0])
+	c.slotLocks[slot].RLock()
+	defer c.slotLocks[slot].RUnlock()
+
+	if err := c.checkOwner(slot); err != nil {
+		return "", err
+	}
+
+	return c.storageController.Execute(ctx, command)
+}
+
+func (c *Controller) checkOwner(slot int) error {
+	owner := c.owner(slot)
+	if owner == "" {
+		return fmt.Errorf("slot %d: %w", slot, ErrSlotNotServed)
+	}
+	if owner != c.address {
+		return &MovedError{Slot: slot, Address: owner}
+	}
+
+	return nil
+}
+
+func (c *Controller) handleSlots() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.slots.String()
+}
+
+// handleSetSlot назначает слоту нового владельца. Слот, обслуживаемый этим узлом,
+// можно передать другому узлу только вместе с ключами через CLUSTER MIGRATE.
+func (c *Controller) handleSetSlot(arguments []string) (string, error) {
+	slot, err := ParseSlot(arguments[0])
+	if err != nil {
+		return "", err
+	}
+	address := arguments[1]
+
+	c.slotLocks[slot].Lock()
+	defer c.slotLocks[slot].Unlock()
+
+	if c.owner(slot) == c.address && address != c.address {
+		return "", ErrSlotOwned
+	}
+	if err := c.assign(slot, address); err != nil {
+		return "", err
+	}
+
+	return "OK", nil
+}
+
+// handleImport сохраняет ключ, переносимый с другого узла. Проверка владельца слота
+// не выполняется: слот передается этому узлу только после переноса всех ключей.
+func (c *Controller) handleImport(ctx context.Context, command *querylang.Command) (string, error) {
+	arguments := command.Arguments()
+	slot, err := ParseSlot(arguments[0])
+	if err != nil {
+		return "", err
+	}
+	if SlotForKey(arguments[1]) != slot {
+		return "", fmt.Errorf("%w: key %q, slot %d", ErrKeyNotInSlot, arguments[1], slot)
+	}
+
+	return c.storageController.Execute(
+		ctx,
+		querylang.NewCommand(command.SeqID(), querylang.CommandSet, arguments[1], arguments[2]),
+	)
+}
+
+// handleMigrate переносит слот со всеми ключами на другой узел. На время переноса
+// команды с ключами слота ожидают его завершения, после чего получают MovedError.
+// После передачи слота перенесенные ключи удаляются независимо от отмены контекста.
+func (c *Controller) handleMigrate(ctx context.Context, command *querylang.Command) (string, error) {
+	arguments := command.Arguments()
+	slot, err := ParseSlot(arguments[0])
+	if err != nil {
+		return "", err
+	}
+	target := arguments[1]
+
+	c.slotLocks[slot].Lock()
+	defer c.slotLocks[slot].Unlock()
+
+	if c.owner(slot) != c.address {
+		return "", fmt.Errorf("slot %d: %w", slot, ErrSlotNotOwned)
+	}
+	if target == c.address {
+		return "OK", nil
+	}
+
+	start := time.Now()
+	values := c.values.Select(func(key string) bool {
+		return SlotForKey(key) == slot
+	})
+	if err := c.sendSlot(ctx, slot, target, values); err != nil {
+		return "", err
+	}
+	if err := c.assign(slot, target); err != nil {
+		return "", err
+	}
+	for key := range values {
+		del := querylang.NewCommand(c.idGenerator.NextSeqID(), querylang.CommandDel, key)
+		if _, err := c.storageController.Execute(context.WithoutCancel(ctx), del); err != nil {
+			return "", fmt.Errorf("delete migrated key %q: %w", key, err)
+		}
+	}
+
+	c.logger.Info(
+		"slot migration completed",
+		slog.Int("slot", slot),
+		slog.String("target", target),
+		slog.Int("keys", len(values)),
+		slog.Duration("duration", time.Since(start)),
+	)
+
+	return "OK", nil
+}
+
+// sendSlot передает ключи слота на узел target и назначает ему слот.
+// Значения передаются отдельно от запроса, поэтому могут содержать
+// пробелы, переводы строк и любые другие байты.
+func (c *Controller) sendSlot(ctx context.Context, slot int, target string, values map[string]string) error {
+	client, err := c.dial(target)
+	if err != nil {
+		return fmt.Errorf("connect to %s: %w", target, err)
+	}
+	defer client.Close()
+
+	slotArgument := strconv.Itoa(slot)
+	for key, value := range values {
+		if err := sendToNode(ctx, client, target, []byte(value), "CLUSTER IMPORTCHUNKED", slotArgument, key); err != nil {
+			return err
+		}
+	}
+
+	return sendToNode(ctx, client, target, nil, "CLUSTER SETSLOT", slotArgument, target)
+}
+
+// sendToNode выполняет команду на узле address. Если value не nil, то оно
+// передается отдельно от запроса.
+func sendToNode(ctx context.Context, client NodeClient, address string, value []byte, command string, arguments ...string) error {
+	request := command
+	for _, argument := range arguments {
+		request += " " + argument
+	}
+
+	var response []byte
+	var err error
+	if value != nil {
+		response, err = client.SendChunked(ctx, []byte(request), value)
+	} else {
+		response, err = client.Send([]byte(request))
+	}
+	if err != nil {
+		return fmt.Errorf("send to %s: %w", address, err)
+	}
+	if string(response) != "OK" {
+		return &MigrationError{Address: address, Request: request, Response: string(response)}
+	}
+
+	return nil
+}
+
+func (c *Controller) owner(slot int) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.slots.Owner(slot)
+}
+
+func (c *Controller) assign(slot int, address string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := c.slots.Owner(slot)
+	c.slots.Assign(slot, address)
+	if err := c.saveSlots(c.slots); err != nil {
+		c.slots.Assign(slot, previous)
+
+		return fmt.Errorf("save slot map: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Controller) loadSlots() (*SlotMap, error) {
+	data, err := afero.ReadFile(c.fs, filepath.Join(c.dataDirectory, slotsFilename))
+	if err != nil {
+		exists, existsErr := afero.Exists(c.fs, filepath.Join(c.dataDirectory, slotsFilename))
+		if existsErr == nil && !exists {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	ranges, err := ParseSlotRanges(string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSlotMap(ranges), nil
+}
+
+// saveSlots атомарно перезаписывает файл карты слотов.
+func (c *Controller) saveSlots(slots *SlotMap) error {
+	if err := c.fs.MkdirAll(c.dataDirectory, 0o755); err != nil {
+		return fmt.Errorf("create directory: %w", err)
+	}
+
+	filename := filepath.Join(c.dataDirectory, slotsFilename)
+	if err := afero.WriteFile(c.fs, filename+".tmp", []byte(slots.String()), 0o644); err != nil {
+		return err
+	}
+
+	return c.fs.Rename(filename+".tmp", filename)
+}
@@ -0,0 +1,243 @@
+package sharding_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
+	"github.com/strider2038/key-value-database/internal/database/storage"
+	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
+)
+
+const (
+	node1 = "node1:3434"
+	node2 = "node2:3434"
+)
+
+// slotRanges - слоты 0-8191 (в т.ч. "bar") обслуживает node1,
+// 8192-16382 (в т.ч. "foo") - node2, слот 16383 не назначен.
+var slotRanges = []sharding.SlotRange{
+	{From: 0, To: 8191, Address: node1},
+	{From: 8192, To: 16382, Address: node2},
+}
+
+type TestNode struct {
+	Controller *sharding.Controller
+	Storage    *inmemory.MapStorage
+	FS         afero.Fs
+}
+
+// Send выполняет команды CLUSTER IMPORT и CLUSTER SETSLOT, отправленные узлу по сети.
+func (n *TestNode) Send(request []byte) ([]byte, error) {
+	return n.execute(strings.Fields(string(request)))
+}
+
+// SendChunked выполняет команду CLUSTER IMPORTCHUNKED со значением, переданным
+// отдельно от запроса.
+func (n *TestNode) SendChunked(ctx context.Context, request, value []byte) ([]byte, error) {
+	return n.execute(append(strings.Fields(string(request)), string(value)))
+}
+
+func (n *TestNode) execute(fields []string) ([]byte, error) {
+	commandIDs := map[string]querylang.CommandID{
+		"IMPORT":        querylang.CommandClusterImport,
+		"IMPORTCHUNKED": querylang.CommandClusterImport,
+		"SETSLOT":       querylang.CommandClusterSetSlot,
+	}
+	response, err := n.Controller.Execute(context.Background(), querylang.NewCommand(100, commandIDs[fields[1]], fields[2:]...))
+	if err != nil {
+		return []byte(err.Error()), nil
+	}
+
+	return []byte(response), nil
+}
+
+func (n *TestNode) Close() error {
+	return nil
+}
+
+type TestCluster map[string]*TestNode
+
+func (c TestCluster) Dial(address string) (sharding.NodeClient, error) {
+	node, exists := c[address]
+	if !exists {
+		return nil, errors.New("connection refused")
+	}
+
+	return node, nil
+}
+
+func NewTestCluster(tb testing.TB) TestCluster {
+	tb.Helper()
+
+	cluster := TestCluster{}
+	for _, address := range []string{node1, node2} {
+		cluster.Start(tb, address, afero.NewMemMapFs())
+	}
+
+	return cluster
+}
+
+func (c TestCluster) Start(tb testing.TB, address string, fs afero.Fs) *TestNode {
+	tb.Helper()
+
+	mapStorage := inmemory.NewMapStorage()
+	storageController := storage.NewController(mapStorage)
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	controller, err := sharding.NewController(
+		storageController,
+		storageController,
+		&engine.IDGenerator{},
+		c.Dial,
+		address,
+		slotRanges,
+		fs,
+		logger,
+		"/sharding",
+	)
+	require.NoError(tb, err)
+	node := &TestNode{Controller: controller, Storage: mapStorage, FS: fs}
+	c[address] = node
+
+	return node
+}
+
+func TestController_Execute_RoutesKeysBySlot(t *testing.T) {
+	cluster := NewTestCluster(t)
+
+	result, err := cluster[node1].Controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "bar", "value"))
+	require.NoError(t, err)
+	assert.Equal(t, "OK", result)
+
+	_, err = cluster[node1].Controller.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandGet, "foo"))
+	var moved *sharding.MovedError
+	require.ErrorAs(t, err, &moved)
+	assert.Equal(t, sharding.SlotForKey("foo"), moved.Slot)
+	assert.Equal(t, node2, moved.Address)
+
+	slots, err := cluster[node1].Controller.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandClusterSlots))
+	require.NoError(t, err)
+	assert.Equal(t, "0-8191 node1:3434\n8192-16382 node2:3434", slots)
+}
+
+func TestController_Execute_WhenSlotNotAssigned_ExpectError(t *testing.T) {
+	cluster := NewTestCluster(t)
+	key := findKey(t, 16383)
+
+	_, err := cluster[node1].Controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandGet, key))
+
+	assert.ErrorIs(t, err, sharding.ErrSlotNotServed)
+}
+
+func TestController_Execute_MigrateSlot(t *testing.T) {
+	cluster := NewTestCluster(t)
+	source, target := cluster[node1], cluster[node2]
+	slot := sharding.SlotForKey("bar")
+	otherKey := findKey(t, slot+1)
+	// значение с пробелами и переводом строки переносится без изменений
+	const barValue = "first line\nsecond line"
+	_, err := source.Controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "bar", barValue))
+	require.NoError(t, err)
+	_, err = source.Controller.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandSet, otherKey, "other"))
+	require.NoError(t, err)
+
+	result, err := source.Controller.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandClusterMigrate, strconv.Itoa(slot), node2))
+
+	require.NoError(t, err)
+	assert.Equal(t, "OK", result)
+	value, err := target.Controller.Execute(context.Background(), querylang.NewCommand(4, querylang.CommandGet, "bar"))
+	require.NoError(t, err)
+	assert.Equal(t, barValue, value)
+	_, err = source.Controller.Execute(context.Background(), querylang.NewCommand(5, querylang.CommandGet, "bar"))
+	var moved *sharding.MovedError
+	require.ErrorAs(t, err, &moved)
+	assert.Equal(t, node2, moved.Address)
+	_, err = source.Storage.Get(context.Background(), "bar")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	value, err = source.Storage.Get(context.Background(), otherKey)
+	require.NoError(t, err)
+	assert.Equal(t, "other", value)
+
+	restarted := cluster.Start(t, node1, source.FS)
+	_, err = restarted.Controller.Execute(context.Background(), querylang.NewCommand(6, querylang.CommandGet, "bar"))
+	require.ErrorAs(t, err, &moved, "slot map must be restored from file")
+	assert.Equal(t, node2, moved.Address)
+}
+
+func TestController_Execute_WhenMigrationFailed_ExpectSlotKept(t *testing.T) {
+	cluster := NewTestCluster(t)
+	source := cluster[node1]
+	_, err := source.Controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "bar", "value"))
+	require.NoError(t, err)
+
+	_, err = source.Controller.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandClusterMigrate, "5061", "node3:3434"))
+
+	assert.Error(t, err)
+	value, err := source.Controller.Execute(context.Background(), querylang.NewCommand(3, querylang.CommandGet, "bar"))
+	require.NoError(t, err)
+	assert.Equal(t, "value", value)
+}
+
+func TestController_Execute_ClusterCommandErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		command   *querylang.Command
+		wantError error
+	}{
+		{
+			name:      "set owned slot",
+			command:   querylang.NewCommand(1, querylang.CommandClusterSetSlot, "0", node2),
+			wantError: sharding.ErrSlotOwned,
+		},
+		{
+			name:      "migrate not owned slot",
+			command:   querylang.NewCommand(1, querylang.CommandClusterMigrate, "8192", node2),
+			wantError: sharding.ErrSlotNotOwned,
+		},
+		{
+			name:      "import key of other slot",
+			command:   querylang.NewCommand(1, querylang.CommandClusterImport, "0", "foo", "value"),
+			wantError: sharding.ErrKeyNotInSlot,
+		},
+		{
+			name:      "slot out of range",
+			command:   querylang.NewCommand(1, querylang.CommandClusterMigrate, "16384", node2),
+			wantError: sharding.ErrInvalidSlot,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cluster := NewTestCluster(t)
+
+			_, err := cluster[node1].Controller.Execute(context.Background(), test.command)
+
+			assert.ErrorIs(t, err, test.wantError)
+		})
+	}
+}
+
+// findKey подбирает ключ, принадлежащий слоту.
+func findKey(tb testing.TB, slot int) string {
+	tb.Helper()
+
+	for i := 0; i < 10*sharding.SlotCount; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if sharding.SlotForKey(key) == slot {
+			return key
+		}
+	}
+	tb.Fatalf("key for slot %d is not found", slot)
+
+	return ""
+}
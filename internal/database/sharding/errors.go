@@ -0,0 +1,37 @@
+package sharding
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidSlot      = errors.New("invalid slot")
+	ErrInvalidSlotMap   = errors.New("invalid slot map")
+	ErrSlotNotServed    = errors.New("slot is not served by any node")
+	ErrSlotNotOwned     = errors.New("slot is not owned by this node")
+	ErrSlotOwned        = errors.New("slot is owned by this node, use CLUSTER MIGRATE to move it")
+	ErrKeyNotInSlot     = errors.New("key does not belong to slot")
+	ErrTooManyRedirects = errors.New("too many redirects")
+)
+
+// MovedError - ключ принадлежит слоту, который обслуживается другим узлом.
+type MovedError struct {
+	Slot    int
+	Address string
+}
+
+func (e *MovedError) Error() string {
+	return fmt.Sprintf("slot %d moved to %s", e.Slot, e.Address)
+}
+
+// MigrationError - ошибка выполнения команды на узле, принимающем слот.
+type MigrationError struct {
+	Address  string
+	Request  string
+	Response string
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migrate to %s: unexpected response to %q: %q", e.Address, e.Request, e.Response)
+}
@@ -0,0 +1,156 @@
+package sharding
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SlotCount - количество хеш-слотов, на которые делится пространство ключей.
+const SlotCount = 16384
+
+// SlotForKey возвращает хеш-слот ключа: CRC16 (XMODEM) по модулю SlotCount,
+// как в Redis Cluster.
+func SlotForKey(key string) int {
+	return int(crc16(key) % SlotCount)
+}
+
+func crc16(data string) uint16 {
+	var crc uint16
+	for i := 0; i < len(data); i++ {
+		crc ^= uint16(data[i]) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+
+	return crc
+}
+
+// SlotRange - непрерывный диапазон слотов [From, To], обслуживаемый узлом Address.
+type SlotRange struct {
+	From    int
+	To      int
+	Address string
+}
+
+func (r SlotRange) String() string {
+	return fmt.Sprintf("%d-%d %s", r.From, r.To, r.Address)
+}
+
+// ParseSlots разбирает список слотов узла в формате "0-5460,5462".
+func ParseSlots(slots string, address string) ([]SlotRange, error) {
+	var ranges []SlotRange
+	for _, part := range strings.Split(slots, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		if !isRange {
+			to = from
+		}
+		slotRange := SlotRange{Address: address}
+		var err error
+		if slotRange.From, err = ParseSlot(from); err != nil {
+			return nil, err
+		}
+		if slotRange.To, err = ParseSlot(to); err != nil {
+			return nil, err
+		}
+		if slotRange.From > slotRange.To {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSlot, part)
+		}
+		ranges = append(ranges, slotRange)
+	}
+
+	return ranges, nil
+}
+
+// ParseSlot разбирает номер слота.
+func ParseSlot(slot string) (int, error) {
+	number, err := strconv.Atoi(slot)
+	if err != nil || number < 0 || number >= SlotCount {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidSlot, slot)
+	}
+
+	return number, nil
+}
+
+// ParseSlotRanges разбирает карту слотов в формате ответа команды CLUSTER SLOTS:
+// по одному диапазону "<from>-<to> <address>" на строку.
+func ParseSlotRanges(text string) ([]SlotRange, error) {
+	var ranges []SlotRange
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		slots, address, found := strings.Cut(line, " ")
+		if !found || address == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSlotMap, line)
+		}
+		lineRanges, err := ParseSlots(slots, address)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, lineRanges...)
+	}
+
+	return ranges, nil
+}
+
+// SlotMap - распределение слотов по узлам кластера. Для неназначенных
+// слотов адрес узла пустой.
+type SlotMap struct {
+	owners [SlotCount]string
+}
+
+func NewSlotMap(ranges []SlotRange) *SlotMap {
+	slotMap := &SlotMap{}
+	for _, slotRange := range ranges {
+		for slot := slotRange.From; slot <= slotRange.To; slot++ {
+			slotMap.owners[slot] = slotRange.Address
+		}
+	}
+
+	return slotMap
+}
+
+func (m *SlotMap) Owner(slot int) string {
+	return m.owners[slot]
+}
+
+func (m *SlotMap) Assign(slot int, address string) {
+	m.owners[slot] = address
+}
+
+// Ranges возвращает назначенные слоты, объединенные в непрерывные диапазоны.
+func (m *SlotMap) Ranges() []SlotRange {
+	var ranges []SlotRange
+	for slot := 0; slot < SlotCount; slot++ {
+		address := m.owners[slot]
+		if address == "" {
+			continue
+		}
+		last := len(ranges) - 1
+		if last >= 0 && ranges[last].To == slot-1 && ranges[last].Address == address {
+			ranges[last].To = slot
+		} else {
+			ranges = append(ranges, SlotRange{From: slot, To: slot, Address: address})
+		}
+	}
+
+	return ranges
+}
+
+// String форматирует карту слотов в формате ответа команды CLUSTER SLOTS.
+func (m *SlotMap) String() string {
+	ranges := m.Ranges()
+	lines := make([]string, 0, len(ranges))
+	for _, slotRange := range ranges {
+		lines = append(lines, slotRange.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,79 @@
+package sharding_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
+)
+
+func TestSlotForKey(t *testing.T) {
+	tests := []struct {
+		key      string
+		wantSlot int
+	}{
+		// значения совпадают с CLUSTER KEYSLOT в Redis
+		{key: "foo", wantSlot: 12182},
+		{key: "bar", wantSlot: 5061},
+		{key: "123456789", wantSlot: 0x31C3 % sharding.SlotCount},
+		{key: "", wantSlot: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.key, func(t *testing.T) {
+			assert.Equal(t, test.wantSlot, sharding.SlotForKey(test.key))
+		})
+	}
+}
+
+func TestParseSlots(t *testing.T) {
+	tests := []struct {
+		name       string
+		slots      string
+		wantRanges []sharding.SlotRange
+		wantError  error
+	}{
+		{
+			name:  "ranges and single slots",
+			slots: "0-100, 200,16383",
+			wantRanges: []sharding.SlotRange{
+				{From: 0, To: 100, Address: "node"},
+				{From: 200, To: 200, Address: "node"},
+				{From: 16383, To: 16383, Address: "node"},
+			},
+		},
+		{name: "slot out of range", slots: "0-16384", wantError: sharding.ErrInvalidSlot},
+		{name: "reversed range", slots: "10-5", wantError: sharding.ErrInvalidSlot},
+		{name: "not a number", slots: "a-b", wantError: sharding.ErrInvalidSlot},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ranges, err := sharding.ParseSlots(test.slots, "node")
+
+			if test.wantError != nil {
+				assert.ErrorIs(t, err, test.wantError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.wantRanges, ranges)
+			}
+		})
+	}
+}
+
+func TestSlotMap_String(t *testing.T) {
+	slotMap := sharding.NewSlotMap([]sharding.SlotRange{
+		{From: 0, To: 8191, Address: "node1"},
+		{From: 8192, To: 16383, Address: "node2"},
+	})
+	slotMap.Assign(100, "node2")
+	slotMap.Assign(8191, "node2")
+	slotMap.Assign(16383, "")
+
+	text := slotMap.String()
+	ranges, err := sharding.ParseSlotRanges(text)
+
+	require.NoError(t, err)
+	assert.Equal(t, "0-99 node1\n100-100 node2\n101-8190 node1\n8191-16382 node2", text)
+	assert.Equal(t, slotMap.Ranges(), ranges)
+	assert.Equal(t, "", sharding.NewSlotMap(ranges).Owner(16383))
+}
@@ -1,16 +1,24 @@
 package storage
 
 import (
+	"bytes"
+	"context"
+	"encoding/gob"
 	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/strider2038/key-value-database/internal/database/querylang"
 )
 
+// Storage - хранилище значений. Операции записи выполняются после фиксации
+// команды в журнале, поэтому реализация не должна прерывать их по отмене контекста.
 type Storage interface {
-	Get(key string) (string, error)
-	Set(key, value string) error
-	Del(key string) error
+	Get(ctx context.Context, key string) (string, error)
+	Set(ctx context.Context, key, value string) error
+	Del(ctx context.Context, key string) error
+	Snapshot() map[string]string
+	Restore(values map[string]string)
 }
 
 type Controller struct {
@@ -21,21 +29,27 @@ func NewController(storage Storage) *Controller {
 	return &Controller{storage: storage}
 }
 
-func (c *Controller) Execute(command *querylang.Command) (string, error) {
+func (c *Controller) Execute(ctx context.Context, command *querylang.Command) (string, error) {
 	switch command.ID() {
 	case querylang.CommandGet:
-		return c.handleGet(command.Arguments())
+		return c.handleGet(ctx, command.Arguments())
+	case querylang.CommandGetRange:
+		return c.handleGetRange(ctx, command.Arguments())
 	case querylang.CommandSet:
-		return c.handleSet(command.Arguments())
+		return c.handleSet(ctx, command.Arguments())
 	case querylang.CommandDel:
-		return c.handleDel(command.Arguments())
+		return c.handleDel(ctx, command.Arguments())
 	default:
 		return "", fmt.Errorf("unsupported command: %s", command.ID().String())
 	}
 }
 
-func (c *Controller) handleGet(arguments []string) (string, error) {
-	value, err := c.storage.Get(arguments[0])
+func (c *Controller) handleGet(ctx context.Context, arguments []string) (string, error) {
+	// чтение не изменяет состояние, поэтому прерывается, если клиент уже не ждет ответа
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	value, err := c.storage.Get(ctx, arguments[0])
 	if err != nil {
 		if errors.Is(err, ErrNotFound) {
 			return querylang.Nil, nil
@@ -47,18 +61,76 @@ func (c *Controller) handleGet(arguments []string) (string, error) {
 	return value, nil
 }
 
-func (c *Controller) handleSet(arguments []string) (string, error) {
-	if err := c.storage.Set(arguments[0], arguments[1]); err != nil {
+// handleGetRange возвращает часть значения, начиная с байта offset, длиной
+// не более length байт. За пределами значения возвращается пустая строка.
+func (c *Controller) handleGetRange(ctx context.Context, arguments []string) (string, error) {
+	value, err := c.handleGet(ctx, arguments[:1])
+	if err != nil || value == querylang.Nil {
+		return value, err
+	}
+	offset, err := strconv.Atoi(arguments[1])
+	if err != nil {
+		return "", fmt.Errorf("parse offset: %w", err)
+	}
+	length, err := strconv.Atoi(arguments[2])
+	if err != nil {
+		return "", fmt.Errorf("parse length: %w", err)
+	}
+	if offset >= len(value) {
+		return "", nil
+	}
+
+	return value[offset:min(offset+length, len(value))], nil
+}
+
+func (c *Controller) handleSet(ctx context.Context, arguments []string) (string, error) {
+	if err := c.storage.Set(ctx, arguments[0], arguments[1]); err != nil {
 		return "", err
 	}
 
 	return "OK", nil
 }
 
-func (c *Controller) handleDel(arguments []string) (string, error) {
-	if err := c.storage.Del(arguments[0]); err != nil {
+func (c *Controller) handleDel(ctx context.Context, arguments []string) (string, error) {
+	if err := c.storage.Del(ctx, arguments[0]); err != nil {
 		return "", err
 	}
 
 	return "OK", nil
 }
+
+// Select возвращает значения ключей, для которых match возвращает true.
+func (c *Controller) Select(match func(key string) bool) map[string]string {
+	values := c.storage.Snapshot()
+	for key := range values {
+		if !match(key) {
+			delete(values, key)
+		}
+	}
+
+	return values
+}
+
+// Snapshot сериализует текущее состояние хранилища.
+func (c *Controller) Snapshot() ([]byte, error) {
+	buffer := bytes.Buffer{}
+	if err := gob.NewEncoder(&buffer).Encode(c.storage.Snapshot()); err != nil {
+		return nil, fmt.Errorf("encode storage snapshot: %w", err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// Restore восстанавливает состояние хранилища из снимка, полученного через Snapshot.
+func (c *Controller) Restore(snapshot []byte) error {
+	values := make(map[string]string)
+	if len(snapshot) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(snapshot)).Decode(&values); err != nil {
+			return fmt.Errorf("decode storage snapshot: %w", err)
+		}
+	}
+
+	c.storage.Restore(values)
+
+	return nil
+}
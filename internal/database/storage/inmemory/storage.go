@@ -1,6 +1,7 @@
 package inmemory
 
 import (
+	"context"
 	"sync"
 
 	"github.com/strider2038/key-value-database/internal/database/storage"
@@ -15,7 +16,7 @@ func NewMapStorage() *MapStorage {
 	return &MapStorage{values: make(map[string]string)}
 }
 
-func (s *MapStorage) Get(key string) (string, error) {
+func (s *MapStorage) Get(_ context.Context, key string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -26,7 +27,7 @@ func (s *MapStorage) Get(key string) (string, error) {
 	return "", storage.ErrNotFound
 }
 
-func (s *MapStorage) Set(key, value string) error {
+func (s *MapStorage) Set(_ context.Context, key, value string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -35,7 +36,7 @@ func (s *MapStorage) Set(key, value string) error {
 	return nil
 }
 
-func (s *MapStorage) Del(key string) error {
+func (s *MapStorage) Del(_ context.Context, key string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -43,3 +44,29 @@ func (s *MapStorage) Del(key string) error {
 
 	return nil
 }
+
+// Snapshot возвращает копию всех значений хранилища.
+func (s *MapStorage) Snapshot() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	values := make(map[string]string, len(s.values))
+	for key, value := range s.values {
+		values[key] = value
+	}
+
+	return values
+}
+
+// Restore полностью заменяет содержимое хранилища переданными значениями.
+func (s *MapStorage) Restore(values map[string]string) {
+	restored := make(map[string]string, len(values))
+	for key, value := range values {
+		restored[key] = value
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.values = restored
+}
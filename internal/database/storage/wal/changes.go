@@ -0,0 +1,270 @@
+package wal
+
+import (
+	"context"
+	"fmt"
+)
+
+// changesBufferSize - максимальное количество записей, ожидающих отправки подписчику.
+// При переполнении буфера подписчик отключается от потока новых записей и
+// дочитывает пропущенные записи из сегментов на диске.
+const changesBufferSize = 1024
+
+type subscription struct {
+	records chan *LogRecord
+}
+
+// changeCursor - позиция подписчика в журнале. Записи отдаются в порядке их записи
+// на диск, который может не совпадать с порядком меток LSN. Поэтому сначала ищется
+// запись с точно совпадающей меткой lsn, и отдаются все записи после нее.
+// Если такой записи нет, то курсор переключается на сравнение меток.
+type changeCursor struct {
+	lsn     LSN
+	isExact bool
+	isFound bool
+}
+
+func (c *changeCursor) accept(record *LogRecord) bool {
+	switch {
+	case c.isFound:
+	case c.isExact:
+		c.isFound = record.LSN == c.lsn
+
+		return false
+	case record.LSN.Compare(c.lsn) > 0:
+		c.isFound = true
+	default:
+		return false
+	}
+
+	c.lsn = record.LSN
+
+	return true
+}
+
+// StreamChanges передает в функцию send зафиксированные записи журнала до отмены
+// контекста или ошибки отправки. Если from не задан, то передаются только записи,
+// добавленные после вызова. Иначе сначала передаются записи с диска, сделанные
+// после записи с меткой from, а затем новые записи.
+//
+// Функция send может блокироваться сколь угодно долго: медленный подписчик не
+// задерживает запись в журнал и не накапливает записи в памяти сверх changesBufferSize,
+// а после переполнения буфера продолжает чтение с диска.
+func (l *Log) StreamChanges(ctx context.Context, from *LSN, send func(record *LogRecord) error) error {
+	var cursor *changeCursor
+	if from != nil {
+		cursor = &changeCursor{lsn: *from, isExact: *from != LSN{}}
+	}
+
+	for {
+		sub, tail, err := l.subscribe(ctx, cursor, send)
+		if err != nil {
+			return err
+		}
+		cursor = tail.cursor
+
+		err = l.sendRecords(tail.records, cursor, send)
+		if err == nil {
+			err = l.streamSubscription(ctx, sub, cursor, send)
+		}
+		l.unsubscribe(sub)
+		if err != nil {
+			return err
+		}
+
+		// подписчик не успевал обрабатывать записи, продолжаем с последней отправленной
+		cursor = &changeCursor{lsn: cursor.lsn, isExact: true}
+	}
+}
+
+type changesTail struct {
+	cursor  *changeCursor
+	records []*LogRecord
+}
+
+// subscribe дочитывает записи с диска и регистрирует подписку. Закрытые сегменты
+// читаются без блокировки записи журнала, а последний (текущий) сегмент - под
+// блокировкой changesMu, чтобы не пропустить и не продублировать записи на стыке
+// чтения с диска и потока новых записей.
+func (l *Log) subscribe(
+	ctx context.Context,
+	cursor *changeCursor,
+	send func(record *LogRecord) error,
+) (*subscription, *changesTail, error) {
+	for {
+		lastSegment := ""
+		if cursor != nil {
+			var err error
+			lastSegment, err = l.sendClosedSegments(ctx, cursor, send)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		l.changesMu.Lock()
+		tail := &changesTail{cursor: cursor}
+		if cursor == nil {
+			tail.cursor = &changeCursor{lsn: l.lastLSN, isFound: true}
+		} else {
+			records, err := l.readSegmentsAfter(lastSegment, cursor.lsn.SessionID)
+			if err != nil {
+				l.changesMu.Unlock()
+
+				return nil, nil, err
+			}
+			for _, record := range records {
+				if cursor.accept(record) {
+					tail.records = append(tail.records, record)
+				}
+			}
+		}
+
+		if tail.cursor.isFound || !tail.cursor.isExact {
+			// все записи на диске просмотрены, новые записи идут после них
+			tail.cursor.isFound = true
+			sub := &subscription{records: make(chan *LogRecord, changesBufferSize)}
+			l.subscribers[sub] = struct{}{}
+			l.changesMu.Unlock()
+
+			return sub, tail, nil
+		}
+		l.changesMu.Unlock()
+
+		// запись с меткой from не найдена, повторяем поиск сравнением меток
+		cursor = &changeCursor{lsn: cursor.lsn}
+	}
+}
+
+// sendClosedSegments отправляет записи из сегментов, запись в которые завершена.
+// Возвращает имя последнего прочитанного сегмента.
+func (l *Log) sendClosedSegments(
+	ctx context.Context,
+	cursor *changeCursor,
+	send func(record *LogRecord) error,
+) (string, error) {
+	segments, err := l.reader.Segments()
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 {
+		return "", nil
+	}
+
+	lastSegment := ""
+	for _, segment := range segments[:len(segments)-1] {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		lastSegment = segment
+		if segmentSessionID(segment) < cursor.lsn.SessionID {
+			continue
+		}
+
+		records, err := l.reader.ReadSegment(segment)
+		if err != nil {
+			return "", err
+		}
+		if err := l.sendRecords(records, cursor, send); err != nil {
+			return "", err
+		}
+	}
+
+	return lastSegment, nil
+}
+
+func (l *Log) readSegmentsAfter(lastSegment string, sessionID uint64) ([]*LogRecord, error) {
+	segments, err := l.reader.Segments()
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*LogRecord
+	for _, segment := range segments {
+		if segment <= lastSegment || segmentSessionID(segment) < sessionID {
+			continue
+		}
+		segmentRecords, err := l.reader.ReadSegment(segment)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, segmentRecords...)
+	}
+
+	return records, nil
+}
+
+func (l *Log) sendRecords(records []*LogRecord, cursor *changeCursor, send func(record *LogRecord) error) error {
+	for _, record := range records {
+		if cursor.accept(record) {
+			if err := send(record); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// streamSubscription отправляет новые записи журнала. Возвращает nil, если подписка
+// была закрыта из-за переполнения буфера.
+func (l *Log) streamSubscription(
+	ctx context.Context,
+	sub *subscription,
+	cursor *changeCursor,
+	send func(record *LogRecord) error,
+) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case record, ok := <-sub.records:
+			if !ok {
+				l.logger.Warn("changes subscriber is lagging, switching to reading from disk")
+
+				return nil
+			}
+			cursor.lsn = record.LSN
+			if err := send(record); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publish рассылает записи подписчикам. Вызывается под блокировкой changesMu.
+func (l *Log) publish(records []*LogRecord) {
+	for sub := range l.subscribers {
+		for _, record := range records {
+			select {
+			case sub.records <- record:
+				continue
+			default:
+			}
+
+			delete(l.subscribers, sub)
+			close(sub.records)
+
+			break
+		}
+	}
+}
+
+func (l *Log) unsubscribe(sub *subscription) {
+	l.changesMu.Lock()
+	defer l.changesMu.Unlock()
+
+	if _, exists := l.subscribers[sub]; exists {
+		delete(l.subscribers, sub)
+		close(sub.records)
+	}
+}
+
+// segmentSessionID извлекает идентификатор сессии из имени файла сегмента.
+func segmentSessionID(segment string) uint64 {
+	var sessionID, segmentNo uint64
+	if _, err := fmt.Sscanf(segment, "wal_%d_%d.log", &sessionID, &segmentNo); err != nil {
+		return 0
+	}
+
+	return sessionID
+}
@@ -0,0 +1,195 @@
+package wal_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/storage/wal"
+)
+
+type ChangesRecorder struct {
+	mu      sync.Mutex
+	records []*wal.LogRecord
+	delay   time.Duration
+}
+
+func (r *ChangesRecorder) Send(record *wal.LogRecord) error {
+	time.Sleep(r.delay)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+
+	return nil
+}
+
+func (r *ChangesRecorder) Keys() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]string, 0, len(r.records))
+	for _, record := range r.records {
+		keys = append(keys, record.Arguments[0])
+	}
+
+	return keys
+}
+
+func TestLog_StreamChanges(t *testing.T) {
+	tests := []struct {
+		name      string
+		from      func(lsns []wal.LSN) *wal.LSN
+		wantFirst int
+	}{
+		{
+			name:      "from beginning",
+			from:      func(lsns []wal.LSN) *wal.LSN { return &wal.LSN{} },
+			wantFirst: 0,
+		},
+		{
+			name:      "from stored LSN",
+			from:      func(lsns []wal.LSN) *wal.LSN { return &lsns[4] },
+			wantFirst: 5,
+		},
+		{
+			name:      "from missing LSN",
+			from:      func(lsns []wal.LSN) *wal.LSN { return &wal.LSN{SessionID: lsns[2].SessionID, SeqID: 3} },
+			wantFirst: 3,
+		},
+		{
+			name:      "only new changes",
+			from:      func(lsns []wal.LSN) *wal.LSN { return nil },
+			wantFirst: 10,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fs := afero.NewMemMapFs()
+			log := startLog(t, fs, 1)
+			addCommands(t, log, 0, 10)
+			lsns := readLSNs(t, fs)
+			recorder := &ChangesRecorder{}
+
+			stopStream := streamChanges(t, log, test.from(lsns), recorder)
+			defer stopStream()
+			if test.from(lsns) == nil {
+				// ждем регистрации подписчика
+				time.Sleep(50 * time.Millisecond)
+			}
+			addCommands(t, log, 10, 15)
+
+			wantKeys := makeKeys(test.wantFirst, 15)
+			require.Eventually(t, func() bool {
+				return len(recorder.Keys()) >= len(wantKeys)
+			}, time.Second, 10*time.Millisecond)
+			assert.Equal(t, wantKeys, recorder.Keys())
+		})
+	}
+}
+
+func TestLog_StreamChanges_WhenConsumerIsSlow_ExpectAllChangesReadFromDisk(t *testing.T) {
+	log := startLog(t, afero.NewMemMapFs(), 500)
+	recorder := &ChangesRecorder{delay: 10 * time.Microsecond}
+
+	stopStream := streamChanges(t, log, &wal.LSN{}, recorder)
+	defer stopStream()
+	const count = 3000
+	addCommandsConcurrently(t, log, count)
+
+	require.Eventually(t, func() bool {
+		return len(recorder.Keys()) >= count
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(t, makeKeys(0, count), recorder.Keys())
+}
+
+func startLog(tb testing.TB, fs afero.Fs, batchSize int) *wal.Log {
+	tb.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	log, err := wal.NewLog(fs, logger, batchSize, time.Millisecond, 300, walDirectory)
+	require.NoError(tb, err)
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		log.Serve(ctx)
+	}()
+	tb.Cleanup(func() {
+		stop()
+		<-done
+	})
+
+	return log
+}
+
+func streamChanges(tb testing.TB, log *wal.Log, from *wal.LSN, recorder *ChangesRecorder) func() {
+	tb.Helper()
+
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		err := log.StreamChanges(ctx, from, recorder.Send)
+		assert.ErrorIs(tb, err, context.Canceled)
+	}()
+
+	return func() {
+		stop()
+		<-done
+	}
+}
+
+func addCommands(tb testing.TB, log *wal.Log, from, to int) {
+	tb.Helper()
+
+	for i := from; i < to; i++ {
+		err := log.Add(context.Background(), querylang.NewCommand(uint64(i+1), querylang.CommandSet, fmt.Sprintf("key%d", i), "value"))
+		require.NoError(tb, err)
+	}
+}
+
+func addCommandsConcurrently(tb testing.TB, log *wal.Log, count int) {
+	tb.Helper()
+
+	wg := sync.WaitGroup{}
+	wg.Add(count)
+	for i := 0; i < count; i++ {
+		go func(i int) {
+			defer wg.Done()
+			err := log.Add(context.Background(), querylang.NewCommand(uint64(i+1), querylang.CommandSet, fmt.Sprintf("key%d", i), "value"))
+			assert.NoError(tb, err)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func readLSNs(tb testing.TB, fs afero.Fs) []wal.LSN {
+	tb.Helper()
+
+	records, err := wal.NewReader(fs, walDirectory).ReadRecords()
+	require.NoError(tb, err)
+	lsns := make([]wal.LSN, 0, len(records))
+	for _, record := range records {
+		lsns = append(lsns, record.LSN)
+	}
+
+	return lsns
+}
+
+func makeKeys(from, to int) []string {
+	keys := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		keys = append(keys, fmt.Sprintf("key%d", i))
+	}
+
+	return keys
+}
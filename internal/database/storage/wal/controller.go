@@ -4,14 +4,17 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/spf13/afero"
 	"github.com/strider2038/key-value-database/internal/database/querylang"
 )
 
 type StorageController interface {
-	Execute(command *querylang.Command) (string, error)
+	Execute(ctx context.Context, command *querylang.Command) (string, error)
 }
 
 // Controller - адаптер контроллера базы данных для работы WAL журнала предзаписи.
@@ -50,17 +53,55 @@ func NewController(
 // Execute адаптер для выполнения команд БД. Все операции чтения напрямую делегируются
 // нижележащему контроллеру. Операции записи перед выполнением добавляются в WAL журнал.
 // Команда записи делегируется нижележащему контроллеру только в случае успешной
-// записи в WAL журнал.
-func (c *Controller) Execute(command *querylang.Command) (string, error) {
+// записи в WAL журнал. Зафиксированная команда применяется к хранилищу даже
+// при отмене контекста, чтобы состояние хранилища не расходилось с журналом.
+func (c *Controller) Execute(ctx context.Context, command *querylang.Command) (string, error) {
 	if command.IsReadOperation() {
-		return c.storageController.Execute(command)
+		return c.storageController.Execute(ctx, command)
 	}
 
-	if err := c.log.Add(command); err != nil {
+	if err := c.log.Add(ctx, command); err != nil {
 		return "", fmt.Errorf("add to WAL: %w", err)
 	}
 
-	return c.storageController.Execute(command)
+	return c.storageController.Execute(context.WithoutCancel(ctx), command)
+}
+
+// StreamChanges передает в функцию send зафиксированные в журнале команды записи
+// для ключей с префиксом prefix в формате "<lsn> <команда> <аргументы>".
+// Если from задан, то сначала передаются команды, записанные после метки from.
+// Если задан origin, то передаются только команды, выполненные на узле origin
+// в режиме нескольких лидеров, вместе с версией записи в формате
+// "<lsn> <метка часов> <узел> <команда> <аргументы>". Аргументы, содержащие
+// пробелы, переводы строк, кавычки или непечатаемые символы, а также пустые
+// аргументы передаются в двойных кавычках с экранированием по правилам Go
+// (строку можно разобрать функцией SplitChange).
+func (c *Controller) StreamChanges(
+	ctx context.Context,
+	from string,
+	prefix string,
+	origin string,
+	send func(change string) error,
+) error {
+	var fromLSN *LSN
+	if from != "" {
+		lsn, err := ParseLSN(from)
+		if err != nil {
+			return err
+		}
+		fromLSN = &lsn
+	}
+
+	return c.log.StreamChanges(ctx, fromLSN, func(record *LogRecord) error {
+		if len(record.Arguments) == 0 || !strings.HasPrefix(record.Arguments[0], prefix) {
+			return nil
+		}
+		if origin != "" && record.Version.NodeID != origin {
+			return nil
+		}
+
+		return send(formatChange(record, origin != ""))
+	})
 }
 
 // Serve - сервисная функция для обслуживания WAL журнала. Ее необходимо запускать
@@ -82,7 +123,7 @@ func (c *Controller) restore() error {
 	}
 
 	for _, command := range commands {
-		if _, err := c.storageController.Execute(command); err != nil {
+		if _, err := c.storageController.Execute(context.Background(), command); err != nil {
 			return fmt.Errorf("execute command %s %v", command.ID(), command.Arguments())
 		}
 
@@ -106,3 +147,68 @@ func (c *Controller) restore() error {
 
 	return nil
 }
+
+func formatChange(record *LogRecord, withVersion bool) string {
+	change := strings.Builder{}
+	change.WriteString(record.LSN.String())
+	change.WriteString(" ")
+	if withVersion {
+		change.WriteString(record.Version.Timestamp.String())
+		change.WriteString(" ")
+		change.WriteString(record.Version.NodeID)
+		change.WriteString(" ")
+	}
+	change.WriteString(record.CommandID.String())
+	for _, argument := range record.Arguments {
+		change.WriteString(" ")
+		if isPlainArgument(argument) {
+			change.WriteString(argument)
+		} else {
+			change.WriteString(strconv.Quote(argument))
+		}
+	}
+
+	return change.String()
+}
+
+// isPlainArgument проверяет, что аргумент можно передать в строке изменения
+// без кавычек.
+func isPlainArgument(argument string) bool {
+	return argument != "" && !strings.ContainsFunc(argument, func(c rune) bool {
+		return c == '"' || unicode.IsSpace(c) || !unicode.IsPrint(c)
+	})
+}
+
+// SplitChange разбивает строку потока изменений на поля, разделенные
+// пробелами. Поля в кавычках возвращаются без кавычек и экранирования.
+func SplitChange(change string) ([]string, error) {
+	var fields []string
+	for {
+		change = strings.TrimLeft(change, " ")
+		if change == "" {
+			return fields, nil
+		}
+
+		if change[0] != '"' {
+			field, rest, _ := strings.Cut(change, " ")
+			fields = append(fields, field)
+			change = rest
+
+			continue
+		}
+
+		quoted, err := strconv.QuotedPrefix(change)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidChange, err)
+		}
+		field, err := strconv.Unquote(quoted)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidChange, err)
+		}
+		change = change[len(quoted):]
+		if change != "" && change[0] != ' ' {
+			return nil, fmt.Errorf("%w: no space after quoted field %s", ErrInvalidChange, quoted)
+		}
+		fields = append(fields, field)
+	}
+}
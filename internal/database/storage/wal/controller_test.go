@@ -103,7 +103,7 @@ func TestController_Execute(t *testing.T) {
 			go func() {
 				defer waiter.Done()
 				for i, command := range test.applyCommands {
-					_, err := controller.Execute(command)
+					_, err := controller.Execute(context.Background(), command)
 					require.NoError(t, err, "command %d: %s", i, command.ID())
 				}
 				stop()
@@ -111,7 +111,7 @@ func TestController_Execute(t *testing.T) {
 			waiter.Wait()
 
 			for key, want := range test.wantData {
-				value, err := mapStorage.Get(key)
+				value, err := mapStorage.Get(context.Background(), key)
 				if want.err != nil {
 					assert.ErrorIs(t, err, want.err, "key %q", key)
 				} else {
@@ -131,6 +131,82 @@ func TestController_Execute(t *testing.T) {
 	}
 }
 
+func TestController_Execute_WhenDeadlineExceededBeforeCommit_ExpectCommandNotApplied(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	fs := afero.NewMemMapFs()
+	mapStorage := inmemory.NewMapStorage()
+	controller, err := wal.NewController(
+		storage.NewController(mapStorage),
+		fs,
+		logger,
+		10,
+		200*time.Millisecond,
+		10_000,
+		walDirectory,
+	)
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		controller.Serve(ctx)
+	}()
+
+	requestContext, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = controller.Execute(requestContext, querylang.NewCommand(1, querylang.CommandSet, "key1", "foo"))
+	assert.ErrorIs(t, err, wal.ErrNotCommitted)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = controller.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandSet, "key2", "bar"))
+	require.NoError(t, err)
+	stop()
+	<-done
+
+	_, err = mapStorage.Get(context.Background(), "key1")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	records := readRecords(t, fs)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{"key2", "bar"}, records[0].Arguments)
+}
+
+func TestController_Serve_WhenStopped_ExpectBufferedCommandsFlushed(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
+	fs := afero.NewMemMapFs()
+	controller, err := wal.NewController(
+		storage.NewController(inmemory.NewMapStorage()),
+		fs,
+		logger,
+		10,
+		time.Hour,
+		10_000,
+		walDirectory,
+	)
+	require.NoError(t, err)
+	ctx, stop := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		controller.Serve(ctx)
+	}()
+
+	executed := make(chan error)
+	go func() {
+		_, err := controller.Execute(context.Background(), querylang.NewCommand(1, querylang.CommandSet, "key", "value"))
+		executed <- err
+	}()
+	// команда ожидает в буфере, так как ни размер пачки, ни таймаут не достигнуты
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	require.NoError(t, <-executed)
+	<-done
+
+	_, err = controller.Execute(context.Background(), querylang.NewCommand(2, querylang.CommandSet, "key", "value"))
+	assert.ErrorIs(t, err, wal.ErrLogClosed)
+	records := readRecords(t, fs)
+	require.Len(t, records, 1)
+	assert.Equal(t, []string{"key", "value"}, records[0].Arguments)
+}
+
 func writeRecords(tb testing.TB, fs afero.Fs, filename string, records []*wal.LogRecord) {
 	tb.Helper()
 	if len(records) == 0 {
@@ -178,3 +254,50 @@ func readRecords(tb testing.TB, fs afero.Fs) []*wal.LogRecord {
 
 	return records
 }
+
+func TestSplitChange(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     string
+		wantFields []string
+		wantError  error
+	}{
+		{
+			name:       "plain arguments",
+			change:     "1.2 SET key value",
+			wantFields: []string{"1.2", "SET", "key", "value"},
+		},
+		{
+			name:       "quoted value with spaces and line breaks",
+			change:     `1.2 SET key "first line\nsecond \"line\""`,
+			wantFields: []string{"1.2", "SET", "key", "first line\nsecond \"line\""},
+		},
+		{
+			name:       "quoted empty value",
+			change:     `1.2 SET key ""`,
+			wantFields: []string{"1.2", "SET", "key", ""},
+		},
+		{
+			name:      "unterminated quote",
+			change:    `1.2 SET key "value`,
+			wantError: wal.ErrInvalidChange,
+		},
+		{
+			name:      "no space after quoted argument",
+			change:    `1.2 SET "key"value`,
+			wantError: wal.ErrInvalidChange,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fields, err := wal.SplitChange(test.change)
+
+			if test.wantError != nil {
+				assert.ErrorIs(t, err, test.wantError)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.wantFields, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package wal
+
+import "errors"
+
+var (
+	ErrInvalidLSN = errors.New("invalid LSN")
+	// ErrInvalidChange - строка потока изменений содержит аргумент
+	// с некорректным экранированием.
+	ErrInvalidChange = errors.New("invalid change format")
+	// ErrNotCommitted - команда не записана в журнал, так как контекст запроса
+	// был отменен или истек его срок до сброса буфера на диск.
+	ErrNotCommitted = errors.New("command is not committed to WAL")
+	// ErrLogClosed - журнал остановлен и больше не принимает команды.
+	ErrLogClosed = errors.New("WAL is closed")
+)
@@ -4,6 +4,8 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -37,10 +39,35 @@ func (lsn LSN) Compare(compared LSN) int {
 	return 0
 }
 
+func (lsn LSN) String() string {
+	return fmt.Sprintf("%d.%d", lsn.SessionID, lsn.SeqID)
+}
+
+// ParseLSN разбирает метку LSN в формате <session_id>.<seq_id>.
+func ParseLSN(s string) (LSN, error) {
+	sessionID, seqID, found := strings.Cut(s, ".")
+	if !found {
+		return LSN{}, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+
+	var lsn LSN
+	var err error
+	if lsn.SessionID, err = strconv.ParseUint(sessionID, 10, 64); err != nil {
+		return LSN{}, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+	if lsn.SeqID, err = strconv.ParseUint(seqID, 10, 64); err != nil {
+		return LSN{}, fmt.Errorf("%w: %q", ErrInvalidLSN, s)
+	}
+
+	return lsn, nil
+}
+
 type LogRecord struct {
 	LSN       LSN
 	CommandID querylang.CommandID
 	Arguments []string
+	// Version - версия записи в режиме нескольких лидеров, в остальных режимах пустая.
+	Version querylang.Version
 }
 
 type LogTask struct {
@@ -63,6 +90,15 @@ type Log struct {
 	mu     sync.Mutex
 	buffer []*LogTask
 	queue  chan []*LogTask
+	closed bool
+	// done закрывается при остановке журнала и прерывает ожидание сброса по таймеру.
+	done chan struct{}
+
+	// changesMu упорядочивает запись пачек на диск относительно подписки
+	// на поток изменений, см. StreamChanges.
+	changesMu   sync.Mutex
+	lastLSN     LSN
+	subscribers map[*subscription]struct{}
 }
 
 func NewLog(
@@ -85,15 +121,23 @@ func NewLog(
 	if err != nil {
 		return nil, err
 	}
+	reader := NewReader(fs, dataDirectory)
+	lastLSN, err := reader.LastLSN()
+	if err != nil {
+		return nil, err
+	}
 
 	return &Log{
-		reader:               NewReader(fs, dataDirectory),
+		reader:               reader,
 		writer:               writer,
 		logger:               logger,
 		flushingBatchSize:    flushingBatchSize,
 		flushingBatchTimeout: flushingBatchTimeout,
 		sessionID:            sessionID,
 		queue:                make(chan []*LogTask),
+		done:                 make(chan struct{}),
+		lastLSN:              lastLSN,
+		subscribers:          make(map[*subscription]struct{}),
 	}, nil
 }
 
@@ -101,8 +145,14 @@ func NewLog(
 // Сброс команд из буфера в журнал записи осуществляется по достижении лимита
 // flushingBatchSize или по срабатыванию таймера flushingBatchTimeout.
 // Операция возвращает управление только после записи всех данных на жесткий диск.
-func (l *Log) Add(command *querylang.Command) error {
+// Если контекст отменяется, пока команда находится в буфере, она удаляется из буфера
+// и возвращается ошибка ErrNotCommitted. Запись пачки, уже переданной на диск,
+// прервать нельзя, поэтому в этом случае дожидается ее результата.
+func (l *Log) Add(ctx context.Context, command *querylang.Command) error {
 	start := time.Now()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrNotCommitted, err)
+	}
 
 	task := &LogTask{
 		Record: &LogRecord{
@@ -112,11 +162,18 @@ func (l *Log) Add(command *querylang.Command) error {
 			},
 			CommandID: command.ID(),
 			Arguments: command.Arguments(),
+			Version:   command.Version(),
 		},
 		Err: make(chan error),
 	}
 
+	closed := false
 	l.withLock(func() {
+		if l.closed {
+			closed = true
+
+			return
+		}
 		l.buffer = append(l.buffer, task)
 		if len(l.buffer) >= l.flushingBatchSize {
 			// если буфер заполнился, то сразу сбрасываем его
@@ -127,8 +184,19 @@ func (l *Log) Add(command *querylang.Command) error {
 			go l.flushByTimeout()
 		}
 	})
+	if closed {
+		return fmt.Errorf("%w: %w", ErrNotCommitted, ErrLogClosed)
+	}
 
-	err := <-task.Err
+	var err error
+	select {
+	case err = <-task.Err:
+	case <-ctx.Done():
+		if l.cancel(task) {
+			return fmt.Errorf("%w: %w", ErrNotCommitted, ctx.Err())
+		}
+		err = <-task.Err
+	}
 	if err == nil {
 		l.logger.Debug(
 			"command added to WAL",
@@ -144,13 +212,19 @@ func (l *Log) Add(command *querylang.Command) error {
 // Serve - сервисная функция для обслуживания WAL журнала. Ее необходимо запускать
 // в фоне работы приложения для корректной работы журнала.
 // Функция обеспечивает периодический сброс накопленных команд на жесткий диск.
-// Завершается по получению сигнала отмены контекста.
+// По сигналу отмены контекста журнал перестает принимать команды (Add возвращает
+// ErrLogClosed), записывает на диск оставшийся буфер и закрывает файл сегмента.
 func (l *Log) Serve(ctx context.Context) {
 	waiter := sync.WaitGroup{}
 	waiter.Add(2)
 	go func() {
 		defer waiter.Done()
 		<-ctx.Done()
+		l.withLock(func() {
+			l.closed = true
+			l.flush()
+		})
+		close(l.done)
 		close(l.queue)
 	}()
 	go func() {
@@ -158,6 +232,10 @@ func (l *Log) Serve(ctx context.Context) {
 		l.serveQueue()
 	}()
 	waiter.Wait()
+
+	if err := l.writer.Close(); err != nil {
+		l.logger.Error("close WAL", "error", err)
+	}
 }
 
 // Restore - восстанавливает команды из WAL журнала.
@@ -169,18 +247,38 @@ func (l *Log) Restore() ([]*querylang.Command, error) {
 
 	commands := make([]*querylang.Command, 0, len(records))
 	for _, record := range records {
-		commands = append(commands, querylang.NewCommand(record.LSN.SeqID, record.CommandID, record.Arguments...))
+		command := querylang.NewCommand(record.LSN.SeqID, record.CommandID, record.Arguments...)
+		commands = append(commands, command.WithVersion(record.Version))
 	}
 
 	return commands, nil
 }
 
+// cancel удаляет задачу из буфера. Возвращает false, если буфер уже сброшен.
+func (l *Log) cancel(task *LogTask) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, buffered := range l.buffer {
+		if buffered == task {
+			l.buffer = append(l.buffer[:i], l.buffer[i+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
+
 func (l *Log) flushByTimeout() {
 	timer := time.NewTimer(l.flushingBatchTimeout)
 	defer timer.Stop()
 
-	<-timer.C
-	l.withLock(l.flush)
+	select {
+	case <-timer.C:
+		l.withLock(l.flush)
+	case <-l.done:
+	}
 }
 
 // flush запускает процедуру записи накопленного буфер команд на жесткий диск.
@@ -201,7 +299,7 @@ func (l *Log) serveQueue() {
 			records = append(records, task.Record)
 		}
 
-		err := l.writer.WriteRecords(records)
+		err := l.writeRecords(records)
 		if err != nil {
 			for _, task := range tasks {
 				task.Err <- err
@@ -214,6 +312,21 @@ func (l *Log) serveQueue() {
 	}
 }
 
+// writeRecords записывает пачку на диск и рассылает ее подписчикам потока изменений.
+func (l *Log) writeRecords(records []*LogRecord) error {
+	l.changesMu.Lock()
+	defer l.changesMu.Unlock()
+
+	if err := l.writer.WriteRecords(records); err != nil {
+		return err
+	}
+
+	l.lastLSN = records[len(records)-1].LSN
+	l.publish(records)
+
+	return nil
+}
+
 func (l *Log) withLock(f func()) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
@@ -28,26 +28,17 @@ func NewReader(fs afero.Fs, directory string) *Reader {
 // ReadRecords - вычитывает ранее записанные команды из WAL журнала.
 // Для этого последовательно читает данные из файлов, а затем сортирует их по меткам LSN.
 func (r *Reader) ReadRecords() ([]*LogRecord, error) {
-	files, err := afero.ReadDir(r.fs, r.directory)
+	segments, err := r.Segments()
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
-
-		return nil, fmt.Errorf("read WAL directory: %w", err)
+		return nil, err
 	}
 
 	var records []*LogRecord
 
-	for _, fileInfo := range files {
-		if fileInfo.IsDir() {
-			continue
-		}
-
-		filename := r.directory + "/" + fileInfo.Name()
-		segmentRecords, err := r.readSegment(filename)
+	for _, segment := range segments {
+		segmentRecords, err := r.ReadSegment(segment)
 		if err != nil {
-			return nil, fmt.Errorf("read segment: %w", err)
+			return nil, err
 		}
 		records = append(records, segmentRecords...)
 	}
@@ -59,6 +50,57 @@ func (r *Reader) ReadRecords() ([]*LogRecord, error) {
 	return records, nil
 }
 
+// LastLSN возвращает метку последней записанной на диск записи журнала.
+func (r *Reader) LastLSN() (LSN, error) {
+	segments, err := r.Segments()
+	if err != nil {
+		return LSN{}, err
+	}
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		records, err := r.ReadSegment(segments[i])
+		if err != nil {
+			return LSN{}, err
+		}
+		if len(records) > 0 {
+			return records[len(records)-1].LSN, nil
+		}
+	}
+
+	return LSN{}, nil
+}
+
+// Segments возвращает имена файлов сегментов в порядке их создания.
+func (r *Reader) Segments() ([]string, error) {
+	files, err := afero.ReadDir(r.fs, r.directory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("read WAL directory: %w", err)
+	}
+
+	segments := make([]string, 0, len(files))
+	for _, fileInfo := range files {
+		if !fileInfo.IsDir() {
+			segments = append(segments, fileInfo.Name())
+		}
+	}
+
+	return segments, nil
+}
+
+// ReadSegment вычитывает записи сегмента в порядке их записи на диск.
+func (r *Reader) ReadSegment(segment string) ([]*LogRecord, error) {
+	records, err := r.readSegment(r.directory + "/" + segment)
+	if err != nil {
+		return nil, fmt.Errorf("read segment: %w", err)
+	}
+
+	return records, nil
+}
+
 func (r *Reader) readSegment(filename string) ([]*LogRecord, error) {
 	file, err := r.fs.Open(filename)
 	if err != nil {
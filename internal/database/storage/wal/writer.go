@@ -83,6 +83,22 @@ func (w *Writer) WriteRecords(records []*LogRecord) error {
 	return nil
 }
 
+// Close сбрасывает на диск и закрывает текущий файл сегмента.
+func (w *Writer) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	if err := w.file.Sync(); err != nil {
+		return fmt.Errorf("sync WAL file: %w", err)
+	}
+	if err := w.file.Close(); err != nil {
+		return fmt.Errorf("close WAL file: %w", err)
+	}
+	w.file = nil
+
+	return nil
+}
+
 func (w *Writer) init() error {
 	if err := w.fs.MkdirAll(w.directory, os.ModePerm); err != nil {
 		return fmt.Errorf("create WAL directory: %w", err)
@@ -1,17 +1,60 @@
 package di
 
 import (
+	"crypto/tls"
 	"fmt"
 
 	"github.com/strider2038/key-value-database/internal/config"
 	"github.com/strider2038/key-value-database/internal/database/network"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
 )
 
 func NewClient(options config.ClientOptions) (*network.TCPClient, error) {
-	client, err := network.NewTCPClient(options.Address, options.MaxMessageSize, options.IdleTimeout)
+	tlsConfig, err := newClientTLSConfig(options.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("init TLS: %w", err)
+	}
+
+	client, err := newNodeDialer(
+		tlsConfig,
+		options.MaxMessageSize,
+		options.IdleTimeout,
+		options.User,
+		options.Password,
+	)(options.Address)
 	if err != nil {
 		return nil, fmt.Errorf("create TCP client: %w", err)
 	}
 
 	return client, nil
 }
+
+func NewClusterClient(options config.ClientOptions) (*sharding.Client, error) {
+	tlsConfig, err := newClientTLSConfig(options.TLS)
+	if err != nil {
+		return nil, fmt.Errorf("init TLS: %w", err)
+	}
+
+	dialNode := newNodeDialer(tlsConfig, options.MaxMessageSize, options.IdleTimeout, options.User, options.Password)
+	client, err := sharding.NewClient(options.Address, func(address string) (sharding.NodeClient, error) {
+		return dialNode(address)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create cluster client: %w", err)
+	}
+
+	return client, nil
+}
+
+func newClientTLSConfig(options config.ClientTLS) (*tls.Config, error) {
+	if !options.Enabled {
+		return nil, nil
+	}
+
+	minVersion, err := network.ParseTLSVersion(options.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	return network.NewClientTLSConfig(options.CertFile, options.KeyFile, options.CAFile, minVersion)
+}
@@ -1,20 +1,31 @@
 package di
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/strider2038/key-value-database/internal/config"
 	"github.com/strider2038/key-value-database/internal/database"
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/database/antientropy"
 	"github.com/strider2038/key-value-database/internal/database/computation/basic"
 	"github.com/strider2038/key-value-database/internal/database/computation/basic/analyzing"
 	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
 	"github.com/strider2038/key-value-database/internal/database/engine"
+	"github.com/strider2038/key-value-database/internal/database/hlc"
+	"github.com/strider2038/key-value-database/internal/database/multileader"
 	"github.com/strider2038/key-value-database/internal/database/network"
+	"github.com/strider2038/key-value-database/internal/database/raft"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
 	"github.com/strider2038/key-value-database/internal/database/storage"
 	"github.com/strider2038/key-value-database/internal/database/storage/inmemory"
 	"github.com/strider2038/key-value-database/internal/database/storage/wal"
@@ -31,24 +42,71 @@ func NewServer(options *config.ServerOptions) (*database.Server, error) {
 		return nil, fmt.Errorf("create logger: %w", err)
 	}
 
-	tcpServer, err := network.NewTCPServer(
-		options.Network.Address,
-		options.Network.MaxConnections,
-		options.Network.MaxMessageSize,
-		options.Network.IdleTimeout,
-		options.Network.OnServerStart,
-		logger,
-	)
+	serverTLS, nodeTLS, err := newTLSConfigs(options.Network.TLS, logger)
+	if err != nil {
+		return nil, fmt.Errorf("init TLS: %w", err)
+	}
+
+	listeners, err := newListeners(options.Network)
+	if err != nil {
+		return nil, fmt.Errorf("init listeners: %w", err)
+	}
+	// соединения всех протоколов учитываются в общем реестре для команд CLIENT
+	clients := network.NewClientRegistry()
+	tcpServer, err := newNetwork(options.Network, listeners, serverTLS, clients, logger)
 	if err != nil {
 		return nil, fmt.Errorf("create TCP server: %w", err)
 	}
 
+	if options.MultiLeader.Enabled && (options.Raft.Enabled || !options.WAL.Enabled) {
+		return nil, fmt.Errorf("multi-leader mode requires WAL to be enabled and raft to be disabled")
+	}
+
+	accessControl, err := newAccessControl(options.ACL)
+	if err != nil {
+		return nil, fmt.Errorf("init ACL: %w", err)
+	}
+	nodeUser, nodePassword := "", ""
+	if options.ACL.Enabled {
+		nodeUser, nodePassword = options.ACL.NodeUser, options.ACL.NodePassword
+	}
+	dialNode := newNodeDialer(
+		nodeTLS,
+		options.Network.MaxMessageSize,
+		options.Network.IdleTimeout,
+		nodeUser,
+		nodePassword,
+	)
+
 	server := database.NewServer()
+	idGenerator := &engine.IDGenerator{}
+	// storageServices - сервисы хранилища, которые останавливаются
+	// после завершения запросов сетевых сервисов.
+	var storageServices []database.Service
+
+	treeStorage := antientropy.NewStorage(inmemory.NewMapStorage())
+	baseStorageController := storage.NewController(treeStorage)
 
 	var storageController engine.StorageController
-	storageController = storage.NewController(inmemory.NewMapStorage())
+	storageController = baseStorageController
+	var changeFeed engine.ChangeFeed
+
+	if options.Raft.Enabled {
+		node, err := newRaftNode(options.Raft, baseStorageController, fs, logger)
+		if err != nil {
+			return nil, fmt.Errorf("init raft node: %w", err)
+		}
+
+		storageController = node
+		server.AddService(node)
+		storageServices = append(storageServices, node)
+	} else if options.WAL.Enabled {
+		var register *multileader.Register
+		if options.MultiLeader.Enabled {
+			register = multileader.NewRegister(storageController)
+			storageController = register
+		}
 
-	if options.WAL.Enabled {
 		walController, err := wal.NewController(
 			storageController,
 			fs,
@@ -63,12 +121,57 @@ func NewServer(options *config.ServerOptions) (*database.Server, error) {
 		}
 
 		storageController = walController
+		changeFeed = walController
 		server.AddService(walController)
+		storageServices = append(storageServices, walController)
+
+		if options.MultiLeader.Enabled {
+			multiLeaderController := multileader.NewController(
+				walController,
+				register,
+				hlc.NewClock(nil),
+				options.MultiLeader.NodeID,
+				logger,
+			)
+			storageController = multiLeaderController
+			server.AddService(newReplicator(options, multiLeaderController, idGenerator, dialNode, logger), walController)
+		}
+	}
+
+	if options.Sharding.Enabled {
+		shardingController, err := newShardingController(
+			options.Sharding,
+			dialNode,
+			storageController,
+			baseStorageController,
+			idGenerator,
+			fs,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("init sharding controller: %w", err)
+		}
+
+		storageController = shardingController
 	}
 
+	storageController = antientropy.NewController(
+		storageController,
+		treeStorage,
+		idGenerator,
+		func(address string) (antientropy.NodeClient, error) {
+			return dialNode(address)
+		},
+		logger,
+	)
+
 	controller := engine.NewController(
 		basic.NewComputer(parsing.NewParser(), analyzing.NewAnalyzer(), logger),
 		storageController,
+		changeFeed,
+		accessControl,
+		clients,
+		idGenerator,
 		logger,
 	)
 	networkService := database.NewNetworkService(
@@ -76,11 +179,354 @@ func NewServer(options *config.ServerOptions) (*database.Server, error) {
 		tcpServer,
 		logger,
 	)
-	server.AddService(networkService)
+	server.AddService(networkService, storageServices...)
+
+	if options.RESP.Enabled {
+		respServer, err := network.NewTCPServer(
+			[]network.Listener{newListener(options.RESP.Address, options.Network)},
+			nil,
+			options.Network.MaxMessageSize,
+			0,
+			options.Network.IdleTimeout,
+			options.Network.DrainTimeout,
+			clients,
+			newOutputLimits(options.Network),
+			options.RESP.OnServerStart,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create RESP server: %w", err)
+		}
+		server.AddService(database.NewRESPService(
+			controller,
+			respServer,
+			options.Network.MaxMessageSize,
+			options.Network.IdleTimeout,
+			logger,
+		), storageServices...)
+	}
+
+	if options.HTTP.Enabled {
+		httpServer, err := network.NewHTTPServer(
+			options.HTTP.Address,
+			options.Network.MaxConnections,
+			options.Network.MaxMessageSize,
+			options.Network.IdleTimeout,
+			options.Network.DrainTimeout,
+			options.HTTP.OnServerStart,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create HTTP server: %w", err)
+		}
+		server.AddService(database.NewHTTPService(controller, httpServer, logger), storageServices...)
+	}
+
+	if options.Memcached.Enabled {
+		memcachedServer, err := network.NewTCPServer(
+			[]network.Listener{newListener(options.Memcached.Address, options.Network)},
+			nil,
+			options.Network.MaxMessageSize,
+			0,
+			options.Network.IdleTimeout,
+			options.Network.DrainTimeout,
+			clients,
+			newOutputLimits(options.Network),
+			options.Memcached.OnServerStart,
+			logger,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("create memcached server: %w", err)
+		}
+		server.AddService(database.NewMemcachedService(
+			controller,
+			memcachedServer,
+			options.Network.MaxMessageSize,
+			options.Network.IdleTimeout,
+			logger,
+		), storageServices...)
+	}
 
 	return server, nil
 }
 
+// newNetwork создает сервер основного протокола в соответствии с network.io_model.
+func newNetwork(
+	options config.Network,
+	listeners []network.Listener,
+	tlsConfig *tls.Config,
+	clients *network.ClientRegistry,
+	logger *slog.Logger,
+) (database.Network, error) {
+	if options.IOModel != "epoll" {
+		return network.NewTCPServer(
+			listeners,
+			tlsConfig,
+			options.MaxMessageSize,
+			options.MaxValueSize,
+			options.IdleTimeout,
+			options.DrainTimeout,
+			clients,
+			newOutputLimits(options),
+			options.OnServerStart,
+			logger,
+		)
+	}
+
+	if tlsConfig != nil {
+		return nil, fmt.Errorf("TLS is not supported by epoll IO model")
+	}
+	workers := options.Workers
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+
+	return network.NewEpollServer(
+		listeners,
+		options.MaxMessageSize,
+		workers,
+		options.IdleTimeout,
+		options.DrainTimeout,
+		clients,
+		newOutputLimits(options),
+		options.OnServerStart,
+		logger,
+	)
+}
+
+func newRaftNode(
+	options config.Raft,
+	stateMachine raft.StateMachine,
+	fs afero.Fs,
+	logger *slog.Logger,
+) (*raft.Node, error) {
+	peers := make([]raft.Peer, 0, len(options.Peers))
+	for _, peer := range options.Peers {
+		peers = append(peers, raft.Peer{
+			ID:            peer.ID,
+			Address:       peer.Address,
+			ClientAddress: peer.ClientAddress,
+		})
+	}
+
+	return raft.NewNode(
+		options.NodeID,
+		options.Address,
+		peers,
+		stateMachine,
+		fs,
+		logger,
+		options.ElectionTimeout,
+		options.HeartbeatInterval,
+		options.CommitTimeout,
+		options.SnapshotThreshold,
+		options.SnapshotOnShutdown,
+		options.DataDirectory,
+	)
+}
+
+func newReplicator(
+	options *config.ServerOptions,
+	controller *multileader.Controller,
+	idGenerator multileader.IDGenerator,
+	dialNode nodeDialer,
+	logger *slog.Logger,
+) *multileader.Replicator {
+	peers := make([]multileader.Peer, 0, len(options.MultiLeader.Peers))
+	for _, peer := range options.MultiLeader.Peers {
+		peers = append(peers, multileader.Peer{ID: peer.ID, Address: peer.Address})
+	}
+
+	return multileader.NewReplicator(
+		controller,
+		peers,
+		idGenerator,
+		func(address string) (multileader.StreamClient, error) {
+			return dialNode(address)
+		},
+		options.MultiLeader.RetryInterval,
+		logger,
+	)
+}
+
+func newShardingController(
+	options config.Sharding,
+	dialNode nodeDialer,
+	storageController sharding.StorageController,
+	values sharding.ValueSource,
+	idGenerator sharding.IDGenerator,
+	fs afero.Fs,
+	logger *slog.Logger,
+) (*sharding.Controller, error) {
+	var ranges []sharding.SlotRange
+	for _, node := range options.Nodes {
+		nodeRanges, err := sharding.ParseSlots(node.Slots, node.Address)
+		if err != nil {
+			return nil, fmt.Errorf("parse slots of node %s: %w", node.Address, err)
+		}
+		ranges = append(ranges, nodeRanges...)
+	}
+
+	return sharding.NewController(
+		storageController,
+		values,
+		idGenerator,
+		func(address string) (sharding.NodeClient, error) {
+			return dialNode(address)
+		},
+		options.Address,
+		ranges,
+		fs,
+		logger,
+		options.DataDirectory,
+	)
+}
+
+// newListeners возвращает адреса основного протокола. Ограничение количества
+// соединений по умолчанию берется из network.max_connections.
+func newListeners(options config.Network) ([]network.Listener, error) {
+	listenerOptions := options.Listeners
+	if len(listenerOptions) == 0 {
+		listenerOptions = []config.Listener{{Address: options.Address}}
+	}
+
+	listeners := make([]network.Listener, 0, len(listenerOptions))
+	for _, listenerOptions := range listenerOptions {
+		listener := newListener(listenerOptions.Address, options)
+		if listenerOptions.MaxConnections != 0 {
+			listener.MaxConnections = listenerOptions.MaxConnections
+		}
+		if listenerOptions.SocketPermissions != "" {
+			permissions, err := strconv.ParseUint(listenerOptions.SocketPermissions, 8, 32)
+			if err != nil {
+				return nil, fmt.Errorf("parse socket permissions of %s: %w", listener.Address, err)
+			}
+			listener.SocketPermissions = fs.FileMode(permissions)
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, nil
+}
+
+// newListener создает адрес с общими для всех адресов ограничениями соединений.
+func newListener(address string, options config.Network) network.Listener {
+	return network.Listener{
+		Address:             address,
+		MaxConnections:      options.MaxConnections,
+		Overload:            network.OverloadPolicy(options.Overload),
+		QueueSize:           options.QueueSize,
+		QueueTimeout:        options.QueueTimeout,
+		ReservedConnections: options.ReservedConnections,
+	}
+}
+
+// newOutputLimits возвращает ограничения буфера ответов, общие для соединений
+// всех протоколов.
+func newOutputLimits(options config.Network) network.OutputLimits {
+	return network.OutputLimits{
+		Normal: network.OutputLimit(options.OutputLimits.Normal),
+		Stream: network.OutputLimit(options.OutputLimits.Stream),
+	}
+}
+
+// nodeDialer подключается к узлу по основному протоколу.
+type nodeDialer func(address string) (*network.TCPClient, error)
+
+// newNodeDialer создает функцию подключения к узлам. Если задан пользователь,
+// после подключения выполняется аутентификация командой AUTH.
+func newNodeDialer(
+	tlsConfig *tls.Config,
+	maxMessageSize int,
+	idleTimeout time.Duration,
+	user string,
+	password string,
+) nodeDialer {
+	return func(address string) (*network.TCPClient, error) {
+		client, err := network.NewTCPClient(address, tlsConfig, maxMessageSize, idleTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if user == "" {
+			return client, nil
+		}
+
+		response, err := client.Send([]byte("AUTH " + user + " " + password))
+		if err == nil && string(response) != "OK" {
+			err = fmt.Errorf("unexpected response: %s", response)
+		}
+		if err != nil {
+			_ = client.Close()
+
+			return nil, fmt.Errorf("authenticate on %s: %w", address, err)
+		}
+
+		return client, nil
+	}
+}
+
+// newAccessControl создает список пользователей. Если контроль доступа выключен,
+// возвращает nil.
+func newAccessControl(options config.ACL) (engine.AccessControl, error) {
+	if !options.Enabled {
+		return nil, nil
+	}
+
+	users := make([]*acl.User, 0, len(options.Users))
+	for _, userOptions := range options.Users {
+		rules := make([]acl.Rule, 0, len(userOptions.Rules))
+		for _, ruleOptions := range userOptions.Rules {
+			rule := acl.Rule{Keys: ruleOptions.Keys}
+			for _, name := range ruleOptions.Categories {
+				category, err := acl.ParseCategory(name)
+				if err != nil {
+					return nil, fmt.Errorf("user %q: %w", userOptions.Name, err)
+				}
+				rule.Categories = append(rule.Categories, category)
+			}
+			rules = append(rules, rule)
+		}
+
+		user, err := acl.NewUser(userOptions.Name, userOptions.Password, rules...)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return acl.NewACL(users...)
+}
+
+// newTLSConfigs создает настройки TLS сервера и настройки для подключения
+// к другим узлам кластера с сертификатом этого узла. Если TLS выключен, возвращает nil.
+func newTLSConfigs(options config.TLS, logger *slog.Logger) (*tls.Config, *tls.Config, error) {
+	if !options.Enabled {
+		return nil, nil, nil
+	}
+
+	minVersion, err := network.ParseTLSVersion(options.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverTLS, err := network.NewServerTLSConfig(
+		options.CertFile,
+		options.KeyFile,
+		options.CAFile,
+		minVersion,
+		options.VerifyClient,
+		logger,
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("server TLS: %w", err)
+	}
+	nodeTLS, err := network.NewClientTLSConfig(options.CertFile, options.KeyFile, options.CAFile, minVersion)
+	if err != nil {
+		return nil, nil, fmt.Errorf("node client TLS: %w", err)
+	}
+
+	return serverTLS, nodeTLS, nil
+}
+
 func newLogger(logging config.Logging) (*slog.Logger, error) {
 	var output io.Writer
 
@@ -0,0 +1,303 @@
+// Package client - клиент базы данных для Go приложений. Клиент использует
+// основной протокол сервера, держит пул соединений и безопасен для
+// конкурентного использования.
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+const (
+	DefaultPoolSize       = 10
+	DefaultMaxMessageSize = 10_000
+	DefaultTimeout        = 10 * time.Second
+	DefaultMaxIdleTime    = 30 * time.Second
+	DefaultRetryBackoff   = 50 * time.Millisecond
+)
+
+type Options struct {
+	// Address - адрес сервера "host:port" или "unix:///path/to/socket.sock".
+	Address string
+	// TLS - настройки TLS соединения, если nil - соединение без шифрования.
+	TLS *tls.Config
+	// User и Password - учетные данные, с которыми аутентифицируется каждое
+	// соединение пула. Если User пустой, аутентификация не выполняется.
+	User     string
+	Password string
+	// Name - имя, которое задается каждому соединению пула командой
+	// CLIENT SETNAME, чтобы соединения клиента можно было найти в CLIENT LIST.
+	Name string
+
+	// PoolSize - максимальное количество одновременно открытых соединений.
+	PoolSize int
+	// MaxMessageSize - максимальный размер ответа сервера.
+	MaxMessageSize int
+	// Timeout - время ожидания подключения и ответа на запрос, если срок
+	// контекста не наступает раньше.
+	Timeout time.Duration
+	// MaxIdleTime - время, после которого свободное соединение не используется
+	// повторно. Должно быть меньше таймаута простоя соединения на сервере.
+	MaxIdleTime time.Duration
+
+	// MaxRetries - количество повторов идемпотентных команд после сетевой ошибки.
+	MaxRetries int
+	// RetryBackoff - пауза перед первым повтором, удваивается с каждым повтором.
+	RetryBackoff time.Duration
+	// RetryWrites разрешает повторять SET и DEL: повтор устанавливает то же
+	// состояние ключа, но может перезаписать изменения других клиентов,
+	// сделанные между попытками.
+	RetryWrites bool
+}
+
+// Client - клиент базы данных с пулом соединений. Команды чтения, а также SET
+// и DEL при Options.RetryWrites, повторяются после сетевых ошибок. Ошибки
+// выполнения команд возвращаются как BadRequestError или ServerError.
+type Client struct {
+	options Options
+	pool    *pool
+}
+
+func New(options Options) (*Client, error) {
+	options = withDefaults(options)
+	if options.Address == "" {
+		return nil, fmt.Errorf("%w: address is required", ErrInvalidOptions)
+	}
+	if options.MaxRetries < 0 {
+		return nil, fmt.Errorf("%w: max retries must not be negative", ErrInvalidOptions)
+	}
+	if options.Name != "" {
+		if err := validateArguments([]string{options.Name}); err != nil {
+			return nil, fmt.Errorf("%w: name: %w", ErrInvalidOptions, err)
+		}
+	}
+	if options.User != "" {
+		if err := validateArguments([]string{options.User, options.Password}); err != nil {
+			return nil, fmt.Errorf("%w: credentials: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	c := &Client{options: options}
+	c.pool = newPool(options.PoolSize, options.MaxIdleTime, c.dial)
+
+	return c, nil
+}
+
+func withDefaults(options Options) Options {
+	if options.PoolSize <= 0 {
+		options.PoolSize = DefaultPoolSize
+	}
+	if options.MaxMessageSize <= 0 {
+		options.MaxMessageSize = DefaultMaxMessageSize
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultTimeout
+	}
+	if options.MaxIdleTime <= 0 {
+		options.MaxIdleTime = DefaultMaxIdleTime
+	}
+	if options.RetryBackoff <= 0 {
+		options.RetryBackoff = DefaultRetryBackoff
+	}
+
+	return options
+}
+
+// Do выполняет произвольную команду, например, Do(ctx, "CLUSTER", "NODES"),
+// и возвращает ее результат. Команда не повторяется после ошибок отправки.
+func (c *Client) Do(ctx context.Context, arguments ...string) (string, error) {
+	return c.execute(ctx, false, arguments...)
+}
+
+// Close закрывает свободные соединения. Соединения, занятые выполняемыми
+// командами, закрываются после их завершения.
+func (c *Client) Close() error {
+	return c.pool.close()
+}
+
+// execute отправляет команду, повторяя ее после сетевых ошибок не более
+// Options.MaxRetries раз, если команда идемпотентна или не была отправлена.
+func (c *Client) execute(ctx context.Context, idempotent bool, arguments ...string) (string, error) {
+	return c.executeWithValue(ctx, idempotent, nil, arguments...)
+}
+
+// executeWithValue отправляет команду так же, как execute. Если value не nil,
+// оно передается перед командой по частям и не проверяется validateArguments.
+func (c *Client) executeWithValue(ctx context.Context, idempotent bool, value []byte, arguments ...string) (string, error) {
+	if err := validateArguments(arguments); err != nil {
+		return "", err
+	}
+
+	backoff := c.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		response, err := c.send(ctx, arguments, value)
+		if err == nil {
+			return response, nil
+		}
+		if attempt >= c.options.MaxRetries || !isRetryable(err, idempotent) {
+			return "", err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+
+			return "", fmt.Errorf("%w: %w", ctx.Err(), err)
+		}
+		backoff *= 2
+	}
+}
+
+func (c *Client) send(ctx context.Context, arguments []string, value []byte) (string, error) {
+	connection, err := c.pool.get(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	request := []byte(formatRequest(ctx, arguments))
+	var response []byte
+	if value != nil {
+		response, err = connection.client.SendChunked(ctx, request, value)
+	} else {
+		response, err = connection.client.SendContext(ctx, request)
+	}
+	c.pool.put(connection, err != nil)
+	if err != nil {
+		return "", responseError(err)
+	}
+
+	return string(response), nil
+}
+
+// responseError преобразует ошибку, полученную от сервера в кадре ошибки,
+// в *BadRequestError или *ServerError. Кадром ошибки сервер отвечает как
+// на невыполненную команду, так и на нарушение протокола, например,
+// превышение размера значения.
+func responseError(err error) error {
+	var frameErr *network.ServerError
+	if errors.As(err, &frameErr) {
+		if parsed := parseError(frameErr.Message); parsed != nil {
+			return parsed
+		}
+
+		return &ServerError{Message: frameErr.Message}
+	}
+
+	return fmt.Errorf("send request: %w", err)
+}
+
+// dial открывает соединение, аутентифицирует его, если задан пользователь,
+// и задает ему имя, если оно указано. Ошибки выполнения команд сервер
+// передает в соединении кадрами ошибки, поэтому результат команды не может
+// быть принят за ошибку.
+func (c *Client) dial() (*network.TCPClient, error) {
+	client, err := network.NewTCPClientWithErrorFrames(c.options.Address, c.options.TLS, c.options.MaxMessageSize, c.options.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if c.options.User != "" {
+		if err := setUp(client, "AUTH "+c.options.User+" "+c.options.Password); err != nil {
+			return nil, fmt.Errorf("authenticate: %w", err)
+		}
+	}
+	if c.options.Name != "" {
+		if err := setUp(client, "CLIENT SETNAME "+c.options.Name); err != nil {
+			return nil, fmt.Errorf("set client name: %w", err)
+		}
+	}
+
+	return client, nil
+}
+
+// setUp выполняет команду настройки нового соединения и закрывает его,
+// если команда не выполнена.
+func setUp(client *network.TCPClient, request string) error {
+	response, err := client.Send([]byte(request))
+	if err != nil {
+		err = responseError(err)
+	} else {
+		err = expectOK(string(response), nil)
+	}
+	if err != nil {
+		_ = client.Close()
+
+		return err
+	}
+
+	return nil
+}
+
+// isRetryable проверяет, можно ли повторить запрос после ошибки. Ошибки
+// сервера, например, об отказе в аутентификации, и истечение срока контекста
+// не повторяются.
+func isRetryable(err error, idempotent bool) bool {
+	if errors.Is(err, ErrClosed) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var serverErr *ServerError
+	if errors.As(err, &serverErr) {
+		return false
+	}
+	var notSent *dialError
+	if errors.As(err, &notSent) {
+		return true
+	}
+
+	return idempotent
+}
+
+// formatRequest собирает текст запроса. Если у контекста есть срок, он
+// передается серверу префиксом TIMEOUT, чтобы сервер прекратил выполнение
+// команды, результат которой клиент уже не ждет.
+func formatRequest(ctx context.Context, arguments []string) string {
+	request := strings.Join(arguments, " ")
+	deadline, ok := ctx.Deadline()
+	if !ok || arguments[0] == "TIMEOUT" {
+		return request
+	}
+	timeout := time.Until(deadline).Milliseconds()
+	if timeout < 1 {
+		timeout = 1
+	}
+
+	return fmt.Sprintf("TIMEOUT %dms %s", timeout, request)
+}
+
+// validateArguments проверяет, что аргументы можно передать серверу: протокол
+// не поддерживает экранирование, поэтому аргумент не может быть пустым
+// и содержит только латинские буквы, цифры и символы "*_/.:-".
+func validateArguments(arguments []string) error {
+	if len(arguments) == 0 {
+		return fmt.Errorf("%w: empty command", ErrInvalidArgument)
+	}
+	for _, argument := range arguments {
+		if argument == "" {
+			return fmt.Errorf("%w: empty argument", ErrInvalidArgument)
+		}
+		if !parsing.IsPlainToken(argument) {
+			return fmt.Errorf("%w: unsupported symbols in %q", ErrInvalidArgument, argument)
+		}
+	}
+
+	return nil
+}
+
+func expectOK(response string, err error) error {
+	if err != nil {
+		return err
+	}
+	if response != "OK" {
+		return fmt.Errorf("%w: %s", ErrUnexpectedResponse, response)
+	}
+
+	return nil
+}
@@ -0,0 +1,258 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/strider2038/key-value-database/internal/config"
+	"github.com/strider2038/key-value-database/internal/database/acl"
+	"github.com/strider2038/key-value-database/internal/di"
+	"github.com/strider2038/key-value-database/pkg/client"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestClient_GetSetDel(t *testing.T) {
+	const address = "127.0.0.1:13001"
+	startServer(t, address, config.ACL{})
+	c, err := client.New(client.Options{Address: address, PoolSize: 2, Name: "test-client"})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	require.NoError(t, c.Set(ctx, "key", "value"))
+	clients, err := c.ClientList(ctx)
+	require.NoError(t, err)
+	require.Len(t, clients, 1)
+	assert.Contains(t, clients[0], "name=test-client")
+	value, found, err := c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "value", value)
+	require.NoError(t, c.Del(ctx, "key"))
+	_, found, err = c.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestClient_SetChunked_WhenValueLargerThanMessage_ExpectValueStored(t *testing.T) {
+	const address = "127.0.0.1:13006"
+	startServer(t, address, config.ACL{})
+	c, err := client.New(client.Options{Address: address, MaxMessageSize: 1000})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+	value := make([]byte, 5000)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	require.NoError(t, c.SetChunked(ctx, "blob", value))
+	stored, found, err := c.GetChunked(ctx, "blob")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, value, stored)
+
+	part, found, err := c.GetRange(ctx, "blob", 4990, 100)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, string(value[4990:]), part)
+
+	err = c.SetChunked(ctx, "blob", make([]byte, 10_001))
+	var serverErr *client.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Contains(t, serverErr.Message, "value too large")
+}
+
+func TestClient_Set_WhenValueIsNotPlainToken_ExpectValueStoredAsIs(t *testing.T) {
+	const address = "127.0.0.1:13007"
+	startServer(t, address, config.ACL{})
+	c, err := client.New(client.Options{Address: address})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	values := []string{"two words", "Bad request: x", "NOPERM stored text", "Internal server error", "line\nbreak"}
+	for _, value := range values {
+		require.NoError(t, c.Set(ctx, "key", value))
+		stored, found, err := c.Get(ctx, "key")
+		require.NoError(t, err, "stored value is not an error")
+		assert.True(t, found)
+		assert.Equal(t, value, stored)
+	}
+}
+
+func TestNew_WhenCredentialsAreNotPlainTokens_ExpectInvalidOptions(t *testing.T) {
+	_, err := client.New(client.Options{Address: "127.0.0.1:13005", User: "alice", Password: "two words"})
+	assert.ErrorIs(t, err, client.ErrInvalidOptions)
+
+	_, err = client.New(client.Options{Address: "127.0.0.1:13005", User: "alice bob", Password: "secret"})
+	assert.ErrorIs(t, err, client.ErrInvalidOptions)
+}
+
+func TestClient_Get_ConcurrentRequests(t *testing.T) {
+	const address = "127.0.0.1:13002"
+	startServer(t, address, config.ACL{})
+	c, err := client.New(client.Options{Address: address, PoolSize: 3})
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Set(context.Background(), "key", "value"))
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, found, err := c.Get(context.Background(), "key")
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, "value", value)
+		}()
+	}
+	wg.Wait()
+}
+
+func TestClient_Do_Errors(t *testing.T) {
+	const address = "127.0.0.1:13003"
+	passwordHash, err := acl.HashPassword("secret")
+	require.NoError(t, err)
+	startServer(t, address, config.ACL{
+		Enabled: true,
+		Users: []config.ACLUser{{
+			Name:     "alice",
+			Password: passwordHash,
+			Rules:    []config.ACLRule{{Categories: []string{"read", "write"}, Keys: "user:*"}},
+		}},
+	})
+	c, err := client.New(client.Options{Address: address, User: "alice", Password: "secret"})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx := context.Background()
+
+	user, err := c.WhoAmI(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", user)
+
+	_, err = c.Do(ctx, "UNKNOWN")
+	var badRequest *client.BadRequestError
+	require.ErrorAs(t, err, &badRequest)
+	assert.Contains(t, badRequest.Message, "unknown command")
+
+	err = c.Set(ctx, "other", "value")
+	var serverErr *client.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, client.CodeNoPerm, serverErr.Code)
+
+	err = c.Set(ctx, "user:1 x", "value")
+	assert.ErrorIs(t, err, client.ErrInvalidArgument)
+
+	wrongPassword, err := client.New(client.Options{Address: address, User: "alice", Password: "wrong", MaxRetries: 3})
+	require.NoError(t, err)
+	defer wrongPassword.Close()
+	_, _, err = wrongPassword.Get(ctx, "user:1")
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, client.CodeWrongPass, serverErr.Code)
+}
+
+func TestClient_Get_WhenServerRestarted_ExpectReconnected(t *testing.T) {
+	const address = "127.0.0.1:13004"
+	stop := startServer(t, address, config.ACL{})
+	c, err := client.New(client.Options{Address: address, PoolSize: 1, MaxRetries: 3, RetryBackoff: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Set(context.Background(), "key", "value"))
+
+	stop()
+	startServer(t, address, config.ACL{})
+	_, found, err := c.Get(context.Background(), "key")
+
+	require.NoError(t, err, "stale connection replaced")
+	assert.False(t, found, "new in-memory server")
+}
+
+func TestClient_Get_WhenServerUnavailable_ExpectRetriesStoppedByContext(t *testing.T) {
+	c, err := client.New(client.Options{Address: "127.0.0.1:13005", MaxRetries: 100, RetryBackoff: 10 * time.Millisecond})
+	require.NoError(t, err)
+	defer c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err = c.Get(ctx, "key")
+
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_Close_ExpectClosedErrorOnRequest(t *testing.T) {
+	c, err := client.New(client.Options{Address: "127.0.0.1:13005"})
+	require.NoError(t, err)
+
+	require.NoError(t, c.Close())
+	_, _, err = c.Get(context.Background(), "key")
+
+	assert.True(t, errors.Is(err, client.ErrClosed))
+}
+
+// startServer запускает сервер без сохранения данных и возвращает функцию его
+// остановки, которая также вызывается по завершении теста.
+func startServer(tb testing.TB, address string, aclOptions config.ACL) func() {
+	tb.Helper()
+
+	waitServer := make(chan struct{})
+	done := make(chan struct{})
+	server, err := di.NewServer(&config.ServerOptions{
+		FS: afero.NewMemMapFs(),
+		Network: config.Network{
+			Address:        address,
+			MaxConnections: 10,
+			MaxMessageSize: 1000,
+			MaxValueSize:   10_000,
+			IdleTimeout:    time.Second,
+			OnServerStart:  func() { close(waitServer) },
+		},
+		ACL: aclOptions,
+	})
+	require.NoError(tb, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		defer close(done)
+		assert.NoError(tb, server.Serve(ctx))
+	}()
+	stop := sync.OnceFunc(func() {
+		cancel()
+		<-done
+	})
+	tb.Cleanup(stop)
+
+	select {
+	case <-waitServer:
+	case <-time.After(time.Second):
+		require.FailNow(tb, "waiting for server start")
+	}
+
+	return stop
+}
+
+func TestParseResponse(t *testing.T) {
+	result, err := client.ParseResponse("value")
+	require.NoError(t, err)
+	assert.Equal(t, "value", result)
+
+	_, err = client.ParseResponse("Bad request: unknown command")
+	var badRequest *client.BadRequestError
+	require.ErrorAs(t, err, &badRequest)
+	assert.Equal(t, "unknown command", badRequest.Message)
+
+	_, err = client.ParseResponse("TIMEOUT request deadline exceeded")
+	var serverErr *client.ServerError
+	require.ErrorAs(t, err, &serverErr)
+	assert.Equal(t, client.CodeTimeout, serverErr.Code)
+}
@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/strider2038/key-value-database/internal/database/computation/basic/parsing"
+	"github.com/strider2038/key-value-database/internal/database/querylang"
+	"github.com/strider2038/key-value-database/internal/database/sharding"
+)
+
+// Node - узел raft кластера из ответа CLUSTER NODES.
+type Node struct {
+	ID            string
+	Address       string
+	ClientAddress string
+	Leader        bool
+}
+
+// SlotRange - диапазон хеш-слотов шардированного кластера и адрес владельца.
+type SlotRange struct {
+	From    int
+	To      int
+	Address string
+}
+
+// Get возвращает значение ключа. Если ключ не найден, второе значение - false.
+func (c *Client) Get(ctx context.Context, key string) (string, bool, error) {
+	value, err := c.execute(ctx, true, "GET", key)
+	if err != nil {
+		return "", false, err
+	}
+	if value == querylang.Nil {
+		return "", false, nil
+	}
+
+	return value, true, nil
+}
+
+// Set сохраняет значение ключа. Значение, которое нельзя передать аргументом
+// команды (например, с пробелами), отправляется так же, как в SetChunked.
+func (c *Client) Set(ctx context.Context, key, value string) error {
+	if !parsing.IsPlainToken(value) {
+		return c.SetChunked(ctx, key, []byte(value))
+	}
+
+	return expectOK(c.execute(ctx, c.options.RetryWrites, "SET", key, value))
+}
+
+// SetChunked сохраняет значение, которое может быть больше Options.MaxMessageSize
+// (но не больше max_value_size сервера) и содержать любые байты: значение
+// передается отдельно от команды по частям.
+func (c *Client) SetChunked(ctx context.Context, key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+
+	return expectOK(c.executeWithValue(ctx, c.options.RetryWrites, value, "SETCHUNKED", key))
+}
+
+// GetRange возвращает часть значения ключа длиной не более length байт,
+// начиная с offset. Если ключ не найден, второе значение - false.
+func (c *Client) GetRange(ctx context.Context, key string, offset, length int) (string, bool, error) {
+	value, err := c.execute(ctx, true, "GETRANGE", key, strconv.Itoa(offset), strconv.Itoa(length))
+	if err != nil {
+		return "", false, err
+	}
+	if value == querylang.Nil {
+		return "", false, nil
+	}
+
+	return value, true, nil
+}
+
+// GetChunked читает значение, которое может быть больше Options.MaxMessageSize,
+// частями по Options.MaxMessageSize байт. Части читаются отдельными командами,
+// поэтому при одновременной записи ключа результат может состоять из частей
+// разных значений.
+func (c *Client) GetChunked(ctx context.Context, key string) ([]byte, bool, error) {
+	value := []byte{}
+	for {
+		chunk, found, err := c.GetRange(ctx, key, len(value), c.options.MaxMessageSize)
+		if err != nil || !found {
+			return nil, found, err
+		}
+		value = append(value, chunk...)
+		if len(chunk) < c.options.MaxMessageSize {
+			return value, true, nil
+		}
+	}
+}
+
+func (c *Client) Del(ctx context.Context, key string) error {
+	return expectOK(c.execute(ctx, c.options.RetryWrites, "DEL", key))
+}
+
+// WhoAmI возвращает имя пользователя, от имени которого выполняются команды.
+func (c *Client) WhoAmI(ctx context.Context) (string, error) {
+	return c.execute(ctx, true, "ACL", "WHOAMI")
+}
+
+// ACLList возвращает описание пользователей и их прав доступа.
+func (c *Client) ACLList(ctx context.Context) ([]string, error) {
+	response, err := c.execute(ctx, true, "ACL", "LIST")
+	if err != nil {
+		return nil, err
+	}
+
+	return splitLines(response), nil
+}
+
+// ClientList возвращает описания открытых соединений сервера
+// в формате "id=1 addr=... name=... age=... idle=... cmd=... in=... out=...".
+func (c *Client) ClientList(ctx context.Context) ([]string, error) {
+	response, err := c.execute(ctx, true, "CLIENT", "LIST")
+	if err != nil {
+		return nil, err
+	}
+
+	return splitLines(response), nil
+}
+
+// ClientKill закрывает соединение сервера по идентификатору
+// или по адресу клиента "host:port".
+func (c *Client) ClientKill(ctx context.Context, target string) error {
+	return expectOK(c.execute(ctx, false, "CLIENT", "KILL", target))
+}
+
+// ClusterNodes возвращает узлы raft кластера.
+func (c *Client) ClusterNodes(ctx context.Context) ([]Node, error) {
+	response, err := c.execute(ctx, true, "CLUSTER", "NODES")
+	if err != nil {
+		return nil, err
+	}
+
+	lines := splitLines(response)
+	nodes := make([]Node, 0, len(lines))
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			return nil, fmt.Errorf("%w: node %q", ErrUnexpectedResponse, line)
+		}
+		nodes = append(nodes, Node{
+			ID:            fields[0],
+			Address:       fields[1],
+			ClientAddress: fields[2],
+			Leader:        fields[3] == "leader",
+		})
+	}
+
+	return nodes, nil
+}
+
+// ClusterJoin добавляет узел в raft кластер.
+func (c *Client) ClusterJoin(ctx context.Context, id, address, clientAddress string) error {
+	return expectOK(c.execute(ctx, false, "CLUSTER", "JOIN", id, address, clientAddress))
+}
+
+// ClusterLeave удаляет узел из raft кластера.
+func (c *Client) ClusterLeave(ctx context.Context, id string) error {
+	return expectOK(c.execute(ctx, false, "CLUSTER", "LEAVE", id))
+}
+
+// ClusterSlots возвращает карту хеш-слотов шардированного кластера.
+func (c *Client) ClusterSlots(ctx context.Context) ([]SlotRange, error) {
+	response, err := c.execute(ctx, true, "CLUSTER", "SLOTS")
+	if err != nil {
+		return nil, err
+	}
+	ranges, err := sharding.ParseSlotRanges(response)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnexpectedResponse, err)
+	}
+
+	slots := make([]SlotRange, 0, len(ranges))
+	for _, slotRange := range ranges {
+		slots = append(slots, SlotRange{From: slotRange.From, To: slotRange.To, Address: slotRange.Address})
+	}
+
+	return slots, nil
+}
+
+// ClusterSetSlot назначает владельца хеш-слота.
+func (c *Client) ClusterSetSlot(ctx context.Context, slot int, address string) error {
+	return expectOK(c.execute(ctx, false, "CLUSTER", "SETSLOT", strconv.Itoa(slot), address))
+}
+
+// ClusterMigrate переносит ключи хеш-слота на узел address.
+func (c *Client) ClusterMigrate(ctx context.Context, slot int, address string) error {
+	return expectOK(c.execute(ctx, false, "CLUSTER", "MIGRATE", strconv.Itoa(slot), address))
+}
+
+// ClusterImport записывает ключ переносимого хеш-слота на принимающем узле.
+// Значение передается отдельно от команды и может содержать любые байты.
+func (c *Client) ClusterImport(ctx context.Context, slot int, key, value string) error {
+	return expectOK(c.executeWithValue(ctx, false, []byte(value), "CLUSTER", "IMPORTCHUNKED", strconv.Itoa(slot), key))
+}
+
+// Digest возвращает хеш содержимого ключей с префиксом prefix или всех ключей,
+// если префикс пустой.
+func (c *Client) Digest(ctx context.Context, prefix string) (uint64, error) {
+	arguments := []string{"DIGEST"}
+	if prefix != "" {
+		arguments = append(arguments, prefix)
+	}
+	response, err := c.execute(ctx, true, arguments...)
+	if err != nil {
+		return 0, err
+	}
+	digest, err := strconv.ParseUint(response, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: digest %q", ErrUnexpectedResponse, response)
+	}
+
+	return digest, nil
+}
+
+// Merkle возвращает хеши узлов дерева Меркла в формате ответа сервера.
+func (c *Client) Merkle(ctx context.Context, level, index int) (string, error) {
+	return c.execute(ctx, true, "MERKLE", strconv.Itoa(level), strconv.Itoa(index))
+}
+
+// Repair сверяет данные с узлом address и возвращает количество исправленных ключей.
+func (c *Client) Repair(ctx context.Context, address string) (int, error) {
+	response, err := c.execute(ctx, false, "REPAIR", address)
+	if err != nil {
+		return 0, err
+	}
+	repaired, err := strconv.Atoi(strings.TrimPrefix(response, "REPAIRED "))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrUnexpectedResponse, response)
+	}
+
+	return repaired, nil
+}
+
+// SubscribeChanges подписывается на изменения ключей с префиксом prefix, начиная
+// с позиции from в журнале (пустые строки - без ограничений), и передает их
+// в receive до отмены контекста или закрытия соединения сервером. Подписка
+// использует отдельное соединение вне пула.
+func (c *Client) SubscribeChanges(ctx context.Context, from, prefix string, receive func(change string)) error {
+	arguments := []string{"SUBSCRIBE-CHANGES"}
+	if from != "" {
+		arguments = append(arguments, "FROM", from)
+	}
+	if prefix != "" {
+		arguments = append(arguments, "PREFIX", prefix)
+	}
+	if err := validateArguments(arguments); err != nil {
+		return err
+	}
+
+	client, err := c.dial()
+	if err != nil {
+		return &dialError{err: err}
+	}
+	defer client.Close()
+	stop := context.AfterFunc(ctx, func() { _ = client.Close() })
+	defer stop()
+
+	err = client.Stream([]byte(strings.Join(arguments, " ")), func(message []byte) {
+		receive(strings.TrimSuffix(string(message), "\n"))
+	})
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+func splitLines(response string) []string {
+	if response == "" {
+		return nil
+	}
+
+	return strings.Split(response, "\n")
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrClosed             = errors.New("client is closed")
+	ErrInvalidArgument    = errors.New("invalid argument")
+	ErrInvalidOptions     = errors.New("invalid client options")
+	ErrUnexpectedResponse = errors.New("unexpected server response")
+)
+
+// Коды ошибок сервера, передаваемые первым словом ответа.
+const (
+	CodeMoved       = "MOVED"
+	CodeRedirect    = "REDIRECT"
+	CodeClusterDown = "CLUSTERDOWN"
+	CodeNoAuth      = "NOAUTH"
+	CodeWrongPass   = "WRONGPASS"
+	CodeNoPerm      = "NOPERM"
+	CodeTimeout     = "TIMEOUT"
+)
+
+var errorCodes = []string{CodeMoved, CodeRedirect, CodeClusterDown, CodeNoAuth, CodeWrongPass, CodeNoPerm, CodeTimeout}
+
+const (
+	badRequestPrefix    = "Bad request: "
+	internalServerError = "Internal server error"
+	notLeaderPrefix     = "Not leader: "
+	tooManyConnections  = "too many connections: "
+)
+
+// BadRequestError - сервер отклонил запрос как некорректный: неизвестная команда,
+// неверные аргументы и т.п. Повтор такого запроса не имеет смысла.
+type BadRequestError struct {
+	Message string
+}
+
+func (e *BadRequestError) Error() string {
+	return "bad request: " + e.Message
+}
+
+// ServerError - сервер не смог выполнить корректный запрос. Code содержит код
+// ошибки (CodeMoved, CodeNoPerm и т.д.) или пустую строку для ошибок без кода,
+// например, внутренней ошибки сервера.
+type ServerError struct {
+	Code    string
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	if e.Code == "" {
+		return "server error: " + e.Message
+	}
+
+	return fmt.Sprintf("server error: %s %s", e.Code, e.Message)
+}
+
+// dialError - ошибка подключения к серверу. Запрос в этом случае не отправлен,
+// поэтому его можно повторить независимо от идемпотентности команды.
+type dialError struct {
+	err error
+}
+
+func (e *dialError) Error() string {
+	return "connect: " + e.err.Error()
+}
+
+func (e *dialError) Unwrap() error {
+	return e.err
+}
+
+// ParseResponse отделяет ответы об ошибках от результата команды и возвращает
+// ошибку *BadRequestError или *ServerError. Используется для ответов на запросы,
+// отправленные в обход Client по основной версии протокола. Она передает ошибки
+// выполнения команд тем же текстом, что и результаты, поэтому значение,
+// начинающееся с текста ошибки сервера, будет прочитано как ошибка.
+func ParseResponse(response string) (string, error) {
+	if err := parseError(response); err != nil {
+		return "", err
+	}
+
+	return response, nil
+}
+
+// parseError определяет тип ошибки по тексту сообщения сервера. Возвращает nil,
+// если текст не похож на ошибку.
+func parseError(message string) error {
+	if text, ok := strings.CutPrefix(message, badRequestPrefix); ok {
+		return &BadRequestError{Message: text}
+	}
+	if message == internalServerError ||
+		strings.HasPrefix(message, notLeaderPrefix) ||
+		strings.HasPrefix(message, tooManyConnections) {
+		return &ServerError{Message: message}
+	}
+	for _, code := range errorCodes {
+		if text, ok := strings.CutPrefix(message, code+" "); ok {
+			return &ServerError{Code: code, Message: text}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/strider2038/key-value-database/internal/database/network"
+)
+
+// connection - соединение пула и время его последнего использования.
+type connection struct {
+	client *network.TCPClient
+	usedAt time.Time
+}
+
+// pool ограничивает количество одновременно открытых соединений и хранит
+// свободные соединения для повторного использования. Соединения открываются
+// по требованию, поэтому после разрыва соединение восстанавливается при
+// следующем запросе.
+type pool struct {
+	dial        func() (*network.TCPClient, error)
+	maxIdleTime time.Duration
+
+	// slots - занятые места в пуле, емкость равна размеру пула.
+	slots chan struct{}
+	// idle - свободные соединения, последнее возвращенное - в конце.
+	idle   []*connection
+	mu     sync.Mutex
+	closed bool
+}
+
+func newPool(size int, maxIdleTime time.Duration, dial func() (*network.TCPClient, error)) *pool {
+	return &pool{
+		dial:        dial,
+		maxIdleTime: maxIdleTime,
+		slots:       make(chan struct{}, size),
+	}
+}
+
+// get занимает место в пуле, ожидая его освобождения не дольше срока
+// контекста, и возвращает свободное или новое соединение.
+func (p *pool) get(ctx context.Context) (*connection, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	idle, err := p.takeIdle()
+	if err != nil {
+		<-p.slots
+
+		return nil, err
+	}
+	if idle != nil {
+		return idle, nil
+	}
+
+	client, err := p.dial()
+	if err != nil {
+		<-p.slots
+
+		return nil, &dialError{err: err}
+	}
+
+	return &connection{client: client}, nil
+}
+
+// put возвращает соединение в пул. Соединение, операция на котором завершилась
+// ошибкой, закрывается, т.к. его состояние неизвестно.
+func (p *pool) put(connection *connection, broken bool) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if broken || p.closed {
+		_ = connection.client.Close()
+
+		return
+	}
+	connection.usedAt = time.Now()
+	p.idle = append(p.idle, connection)
+}
+
+func (p *pool) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+	errs := make([]error, 0, len(p.idle))
+	for _, connection := range p.idle {
+		errs = append(errs, connection.client.Close())
+	}
+	p.idle = nil
+
+	return errors.Join(errs...)
+}
+
+// takeIdle возвращает последнее использованное свободное соединение. Соединения,
+// простоявшие дольше maxIdleTime, закрываются: сервер мог закрыть их по таймауту.
+func (p *pool) takeIdle() (*connection, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+
+	for len(p.idle) > 0 {
+		connection := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if time.Since(connection.usedAt) < p.maxIdleTime {
+			return connection, nil
+		}
+		_ = connection.client.Close()
+	}
+
+	return nil, nil
+}